@@ -0,0 +1,413 @@
+package crontinuous
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Struct
+// --------------------------------------------------------------------------------------------
+
+// JobStatus describes a loaded job as exposed by the admin API
+type JobStatus struct {
+	ID          string            `json:"id"`
+	Command     string            `json:"command"`
+	Args        string            `json:"args"`
+	Schedule    string            `json:"schedule"`
+	File        string            `json:"file"`
+	Concurrency ConcurrencyPolicy `json:"concurrency"`
+	Timezone    string            `json:"timezone,omitempty"`
+	IsRunning   bool              `json:"isRunning"`
+	Paused      bool              `json:"paused"`
+	Maintenance string            `json:"maintenance,omitempty"`
+	Disabled    bool              `json:"disabled"`
+	Tags        []string          `json:"tags,omitempty"`
+	Next        time.Time         `json:"next"`
+	Prev        time.Time         `json:"prev"`
+	LastStatus  Event             `json:"lastStatus,omitempty"`
+	LastExit    int               `json:"lastExitCode"`
+	Failures    int               `json:"consecutiveFailures,omitempty"`
+	Suspended   bool              `json:"suspended,omitempty"`
+}
+
+// JobOutput is the latest output of a job as exposed by the admin API
+type JobOutput struct {
+	RunID string   `json:"runId"`
+	Lines []string `json:"lines"`
+}
+
+// --------------------------------------------------------------------------------------------
+// ~ Private methods
+// --------------------------------------------------------------------------------------------
+
+func startAPI(addr string) {
+	log.WithField("listen", addr).Info("starting admin api")
+	if *apiToken == "" {
+		log.WithField("listen", addr).Warn("admin api without -api-token, only requests from loopback addresses may change anything")
+	}
+	go func() {
+		if err := http.ListenAndServe(addr, tcpAPIHandler(apiHandler())); err != nil {
+			log.WithField("listen", addr).Fatal(err)
+		}
+	}()
+}
+
+// apiHandler serves the admin api, both over tcp and the control socket
+func apiHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/jobs", handleJobs)
+	mux.HandleFunc("/jobs/", handleJob)
+	mux.HandleFunc("/tags/", handleTag)
+	mux.HandleFunc("/stats", handleStats)
+	mux.HandleFunc("/maintenance", handleMaintenance)
+	mux.HandleFunc("/maintenance/", handleMaintenanceWindow)
+	mux.HandleFunc("/", handleDashboard)
+	mux.HandleFunc("/reload", handleReload)
+	mux.HandleFunc("/drain", handleDrain)
+	mux.HandleFunc("/undrain", handleUndrain)
+	mux.HandleFunc("/healthz", handleHealthz)
+	mux.HandleFunc("/readyz", handleReadyz)
+	return csrfHandler(mux)
+}
+
+// csrfHandler rejects changes without an X-Requested-With header, which other sites cannot make browsers send
+// without asking, so a page opened in the browser of an operator cannot run jobs through the dashboard's session.
+// Triggers are left out, their bearer token cannot be sent by other sites either.
+func csrfHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" && r.Method != "HEAD" && r.Header.Get("X-Requested-With") == "" && !isTriggerPath(r.URL.Path) {
+			http.Error(w, "changes need an X-Requested-With header", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// tcpAPIHandler guards the admin api on tcp, which unlike the control socket anyone reaching the port may call:
+// with -api-token all requests but health checks and the dashboard page need it as bearer token, without it
+// only requests from loopback addresses may change anything. Triggers are authenticated by the token of their job.
+func tcpAPIHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/healthz" || r.URL.Path == "/readyz" || r.URL.Path == "/" && r.Method == "GET":
+		case isTriggerPath(r.URL.Path):
+		case *apiToken != "":
+			if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+*apiToken)) != 1 {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="crontinuous"`)
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+		case r.Method != "GET" && r.Method != "HEAD" && !isLoopback(r.RemoteAddr):
+			http.Error(w, "changes need -api-token on tcp, or the control socket", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func isLoopback(remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func handleJobs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	statuses := jobStatuses()
+	if tag := r.URL.Query().Get("tag"); tag != "" {
+		tagged := []JobStatus{}
+		for _, status := range statuses {
+			if containsString(status.Tags, tag) {
+				tagged = append(tagged, status)
+			}
+		}
+		statuses = tagged
+	}
+	jsonReply(w, statuses)
+}
+
+func handleJob(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/jobs/")
+	if strings.HasSuffix(id, "/trigger") {
+		handleJobTrigger(w, r, strings.TrimSuffix(id, "/trigger"))
+		return
+	}
+	if strings.HasSuffix(id, "/run") {
+		handleJobRun(w, r, strings.TrimSuffix(id, "/run"))
+		return
+	}
+	if strings.HasSuffix(id, "/pause") {
+		handleJobPause(w, r, strings.TrimSuffix(id, "/pause"), true)
+		return
+	}
+	if strings.HasSuffix(id, "/resume") {
+		handleJobPause(w, r, strings.TrimSuffix(id, "/resume"), false)
+		return
+	}
+	if r.Method != "GET" {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	if parts := strings.Split(id, "/"); len(parts) == 4 && parts[1] == "runs" && parts[3] == "log" {
+		handleRunLog(w, r, parts[0], parts[2])
+		return
+	}
+	if strings.HasSuffix(id, "/runs") {
+		handleJobRuns(w, r, strings.TrimSuffix(id, "/runs"))
+		return
+	}
+	if strings.HasSuffix(id, "/output") {
+		handleJobOutput(w, r, strings.TrimSuffix(id, "/output"))
+		return
+	}
+	if strings.HasSuffix(id, "/stats") {
+		handleJobStats(w, r, strings.TrimSuffix(id, "/stats"))
+		return
+	}
+	for _, status := range jobStatuses() {
+		if status.ID == id {
+			jsonReply(w, status)
+			return
+		}
+	}
+	http.NotFound(w, r)
+}
+
+// handleJobRun runs a job right away, out of its schedule
+func handleJobRun(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != "POST" {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	runnable := findJob(id)
+	if runnable == nil {
+		http.NotFound(w, r)
+		return
+	}
+	if isDraining() {
+		http.Error(w, "draining, launching no new runs", http.StatusServiceUnavailable)
+		return
+	}
+	runnable.contextLogger.WithField("remote", r.RemoteAddr).Info("running job on request")
+	go runnable.run()
+	jsonStatusReply(w, http.StatusAccepted, map[string]string{"id": runnable.ID, "status": "started"})
+}
+
+// handleTag runs, pauses or resumes all jobs with a tag
+func handleTag(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/tags/"), "/")
+	if len(parts) != 2 {
+		http.NotFound(w, r)
+		return
+	}
+	tag, action := parts[0], parts[1]
+	if action != "run" && action != "pause" && action != "resume" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != "POST" {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	jobs := taggedJobs(tag)
+	if len(jobs) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+	if action == "run" && isDraining() {
+		http.Error(w, "draining, launching no new runs", http.StatusServiceUnavailable)
+		return
+	}
+	ids := []string{}
+	status := map[string]string{"run": "started", "pause": "paused", "resume": "resumed"}[action]
+	for _, runnable := range jobs {
+		switch action {
+		case "run":
+			go runnable.run()
+		default:
+			setPaused(runnable.ID, action == "pause")
+		}
+		runnable.contextLogger.WithFields(log.Fields{"remote": r.RemoteAddr, "tag": tag}).Info("job " + status + " on request")
+		ids = append(ids, runnable.ID)
+	}
+	code := http.StatusOK
+	if action == "run" {
+		code = http.StatusAccepted
+	}
+	jsonStatusReply(w, code, map[string]interface{}{"tag": tag, "status": status, "jobs": ids})
+}
+
+// handleReload reloads the crontabs like SIGHUP does
+func handleReload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	log.WithField("remote", r.RemoteAddr).Info("reloading crontab on request")
+	initCron("api request from " + r.RemoteAddr)
+	cronLock.RLock()
+	defer cronLock.RUnlock()
+	jsonReply(w, map[string]interface{}{"status": "reloaded", "jobs": len(loadedJobs), "errors": lastLoad.errors})
+}
+
+// handleJobPause pauses or resumes the scheduled runs of a job
+func handleJobPause(w http.ResponseWriter, r *http.Request, id string, paused bool) {
+	if r.Method != "POST" {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	runnable := findJob(id)
+	if runnable == nil {
+		http.NotFound(w, r)
+		return
+	}
+	setPaused(runnable.ID, paused)
+	status := "resumed"
+	if paused {
+		status = "paused"
+	}
+	runnable.contextLogger.WithField("remote", r.RemoteAddr).Info("job " + status + " on request")
+	jsonReply(w, map[string]string{"id": runnable.ID, "status": status})
+}
+
+// handleJobOutput serves the latest output lines of a job, of the running or the last run
+func handleJobOutput(w http.ResponseWriter, r *http.Request, id string) {
+	runnable := findJob(id)
+	if runnable == nil {
+		http.NotFound(w, r)
+		return
+	}
+	runID, lines := runnable.output()
+	jsonReply(w, JobOutput{RunID: runID, Lines: lines})
+}
+
+// handleJobRuns serves the history of a job, filtered by the query parameters from, to, status and limit
+func handleJobRuns(w http.ResponseWriter, r *http.Request, id string) {
+	if runHistory == nil {
+		http.Error(w, "history is disabled, start crontinuous with -state-dir", http.StatusNotFound)
+		return
+	}
+	query := r.URL.Query()
+	filter := historyFilter{
+		JobID:  id,
+		Status: Event(query.Get("status")),
+		Limit:  100,
+	}
+	var err error
+	if filter.From, err = parseHistoryTime(query.Get("from")); err != nil {
+		http.Error(w, "invalid from: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if filter.To, err = parseHistoryTime(query.Get("to")); err != nil {
+		http.Error(w, "invalid to: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if limit := query.Get("limit"); limit != "" {
+		if filter.Limit, err = strconv.Atoi(limit); err != nil {
+			http.Error(w, "invalid limit: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	records, err := runHistory.query(filter)
+	if err != nil {
+		log.Error("failed to query history", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	jsonReply(w, records)
+}
+
+// handleRunLog serves the complete output of a run kept with -run-logs
+func handleRunLog(w http.ResponseWriter, r *http.Request, id string, runID string) {
+	runnable := findJob(id)
+	if runnable == nil || !runIDRegexp.MatchString(runID) {
+		http.NotFound(w, r)
+		return
+	}
+	filename := runLogFilename(runnable.ID, runID)
+	if filename == "" {
+		http.Error(w, "run logs are disabled, start crontinuous with -run-logs and -state-dir", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	http.ServeFile(w, r, filename)
+}
+
+func jobStatuses() []JobStatus {
+	statuses := []JobStatus{}
+	scheduler := currentScheduler()
+	if scheduler == nil {
+		return statuses
+	}
+	for _, entry := range scheduler.Entries() {
+		runnable, ok := entry.Job.(*Runnable)
+		if !ok {
+			continue
+		}
+		status := runnable.status()
+		status.Next = entry.Next
+		status.Prev = entry.Prev
+		statuses = append(statuses, status)
+	}
+	// disabled, triggered and @onfile jobs are not scheduled but listed anyway
+	for _, runnable := range unscheduledJobs() {
+		statuses = append(statuses, runnable.status())
+	}
+	return statuses
+}
+
+// status describes the job, without its next and previous run which only the scheduler knows
+func (r *Runnable) status() JobStatus {
+	status := JobStatus{
+		ID:          r.ID,
+		Command:     r.Command,
+		Args:        r.Args,
+		Schedule:    r.Schedule,
+		File:        r.File,
+		Concurrency: r.Concurrency,
+		IsRunning:   r.activeRuns() > 0,
+		Paused:      isPaused(r.ID),
+		Disabled:    r.Disabled,
+		Tags:        r.Tags,
+		Failures:    consecutiveFailures(r.ID),
+		Suspended:   r.suspended(time.Now()),
+	}
+	if w := r.maintenance(time.Now()); w != nil {
+		status.Maintenance = w.name
+	}
+	if last := r.lastRun(); last != nil {
+		status.LastStatus = last.Event
+		status.LastExit = last.ExitCode
+	}
+	if r.Location != nil {
+		status.Timezone = r.Location.String()
+	}
+	return status
+}
+
+func jsonReply(w http.ResponseWriter, v interface{}) {
+	jsonStatusReply(w, http.StatusOK, v)
+}
+
+// jsonStatusReply replies with the given status, the content type is set before the headers are written
+func jsonStatusReply(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Error("failed writing api response", err)
+	}
+}
@@ -0,0 +1,142 @@
+package crontinuous
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTCPAPIHandler(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	tests := []struct {
+		name   string
+		token  string
+		method string
+		path   string
+		remote string
+		auth   string
+		want   int
+	}{
+		{"read without token", "", "GET", "/jobs", "192.0.2.1:1234", "", http.StatusOK},
+		{"change from loopback", "", "POST", "/reload", "127.0.0.1:1234", "", http.StatusOK},
+		{"change from ipv6 loopback", "", "POST", "/reload", "[::1]:1234", "", http.StatusOK},
+		{"change from remote", "", "POST", "/jobs/a/run", "192.0.2.1:1234", "", http.StatusForbidden},
+		{"missing token", "secret", "GET", "/jobs", "127.0.0.1:1234", "", http.StatusUnauthorized},
+		{"wrong token", "secret", "POST", "/reload", "192.0.2.1:1234", "Bearer other", http.StatusUnauthorized},
+		{"token", "secret", "POST", "/reload", "192.0.2.1:1234", "Bearer secret", http.StatusOK},
+		{"health check", "secret", "GET", "/healthz", "192.0.2.1:1234", "", http.StatusOK},
+		{"dashboard page", "secret", "GET", "/", "192.0.2.1:1234", "", http.StatusOK},
+		{"trigger from remote", "secret", "POST", "/jobs/a/trigger", "192.0.2.1:1234", "Bearer job-token", http.StatusOK},
+	}
+	defer func(token string) { *apiToken = token }(*apiToken)
+	for _, test := range tests {
+		*apiToken = test.token
+		req := httptest.NewRequest(test.method, test.path, nil)
+		req.RemoteAddr = test.remote
+		if test.auth != "" {
+			req.Header.Set("Authorization", test.auth)
+		}
+		rec := httptest.NewRecorder()
+		tcpAPIHandler(ok).ServeHTTP(rec, req)
+		if rec.Code != test.want {
+			t.Errorf("%s: got status %d, want %d", test.name, rec.Code, test.want)
+		}
+	}
+}
+
+func TestCSRFHandler(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	tests := []struct {
+		method string
+		header string
+		want   int
+	}{
+		{"GET", "", http.StatusOK},
+		{"POST", "", http.StatusForbidden},
+		{"POST", "XMLHttpRequest", http.StatusOK},
+		{"DELETE", "", http.StatusForbidden},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest(test.method, "/reload", nil)
+		if test.header != "" {
+			req.Header.Set("X-Requested-With", test.header)
+		}
+		rec := httptest.NewRecorder()
+		csrfHandler(ok).ServeHTTP(rec, req)
+		if rec.Code != test.want {
+			t.Errorf("%s with %q: got status %d, want %d", test.method, test.header, rec.Code, test.want)
+		}
+	}
+}
+
+func TestJobTrigger(t *testing.T) {
+	jobs, errs := parseCrontabReader("trigger", strings.NewReader("CI_TOKEN=s3cret\n"+
+		"# name: deploy\n# trigger: ${CI_TOKEN}\n@trigger true\n"+
+		"# name: nightly\n0 3 * * * true\n"+
+		"# name: orphan\n@trigger true\n"), nil)
+	if len(errs) != 1 || len(jobs) != 2 {
+		t.Fatalf("expected the @trigger job without token to fail, got %d jobs and %v", len(jobs), errs)
+	}
+	cronLock.Lock()
+	defer func(jobs []*Runnable) {
+		cronLock.Lock()
+		loadedJobs = jobs
+		cronLock.Unlock()
+	}(loadedJobs)
+	loadedJobs = jobs
+	cronLock.Unlock()
+
+	tests := []struct {
+		name string
+		path string
+		auth string
+		want int
+	}{
+		{"token", "/jobs/deploy/trigger", "Bearer s3cret", http.StatusAccepted},
+		{"wrong token", "/jobs/deploy/trigger", "Bearer other", http.StatusUnauthorized},
+		{"missing token", "/jobs/deploy/trigger", "", http.StatusUnauthorized},
+		{"job without trigger", "/jobs/nightly/trigger", "Bearer s3cret", http.StatusNotFound},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest("POST", test.path, nil)
+		if test.auth != "" {
+			req.Header.Set("Authorization", test.auth)
+		}
+		rec := httptest.NewRecorder()
+		apiHandler().ServeHTTP(rec, req)
+		if rec.Code != test.want {
+			t.Errorf("%s: got status %d, want %d", test.name, rec.Code, test.want)
+		}
+		if contentType := rec.Result().Header.Get("Content-Type"); test.want == http.StatusAccepted && contentType != "application/json" {
+			t.Errorf("%s: expected a json reply, got %q", test.name, contentType)
+		}
+	}
+}
+
+func TestJobRunReply(t *testing.T) {
+	jobs, errs := parseCrontabReader("run", strings.NewReader("# name: manual\n# tags: ops\n0 3 * * * true\n"), nil)
+	if len(errs) != 0 || len(jobs) != 1 {
+		t.Fatalf("expected a job, got %v", errs)
+	}
+	cronLock.Lock()
+	defer func(jobs []*Runnable) {
+		cronLock.Lock()
+		loadedJobs = jobs
+		cronLock.Unlock()
+	}(loadedJobs)
+	loadedJobs = jobs
+	cronLock.Unlock()
+
+	for _, path := range []string{"/jobs/manual/run", "/tags/ops/run"} {
+		req := httptest.NewRequest("POST", path, nil)
+		req.Header.Set("X-Requested-With", "XMLHttpRequest")
+		rec := httptest.NewRecorder()
+		apiHandler().ServeHTTP(rec, req)
+		// the recorded result has the headers as they were written with the status
+		resp := rec.Result()
+		if resp.StatusCode != http.StatusAccepted || resp.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("%s: expected a json reply with status 202, got %d with %q", path, resp.StatusCode, resp.Header.Get("Content-Type"))
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package crontinuous
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Constants
+// --------------------------------------------------------------------------------------------
+
+const auditFilename = "audit.jsonl"
+
+// --------------------------------------------------------------------------------------------
+// ~ Struct
+// --------------------------------------------------------------------------------------------
+
+// auditLog persists every load of the crontabs as a line of json appended to a file in the state directory
+type auditLog struct {
+	file *os.File
+	lock sync.Mutex
+}
+
+// ReloadRecord is a load of the crontabs with the jobs it added, removed and changed
+type ReloadRecord struct {
+	Time    time.Time   `json:"time"`
+	Trigger string      `json:"trigger"`
+	Added   []string    `json:"added"`
+	Removed []string    `json:"removed"`
+	Changed []JobChange `json:"changed"`
+	Errors  []string    `json:"errors,omitempty"`
+}
+
+// JobChange is a job whose definition changed with a reload
+type JobChange struct {
+	ID     string        `json:"id"`
+	Fields []FieldChange `json:"fields"`
+}
+
+// FieldChange is a setting of a job that changed with a reload
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// --------------------------------------------------------------------------------------------
+// ~ Variables
+// --------------------------------------------------------------------------------------------
+
+var audit *auditLog
+
+// --------------------------------------------------------------------------------------------
+// ~ Private methods
+// --------------------------------------------------------------------------------------------
+
+func openAuditLog(stateDir string) (*auditLog, error) {
+	if err := os.MkdirAll(stateDir, 0755); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(filepath.Join(stateDir, auditFilename), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &auditLog{file: file}, nil
+}
+
+// record stores a reload, a nil audit log does not record anything
+func (a *auditLog) record(record *ReloadRecord) error {
+	if a == nil {
+		return nil
+	}
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	_, err = a.file.Write(append(line, '\n'))
+	return err
+}
+
+// diffJobs compares the jobs before and after a reload, jobs are told apart by their ids
+func diffJobs(trigger string, before []*Runnable, after []*Runnable) *ReloadRecord {
+	record := &ReloadRecord{
+		Time:    time.Now(),
+		Trigger: trigger,
+		Added:   []string{},
+		Removed: []string{},
+		Changed: []JobChange{},
+	}
+	previous := jobsByID(before)
+	current := jobsByID(after)
+	for _, id := range sortedJobIDs(current) {
+		old, ok := previous[id]
+		if !ok {
+			record.Added = append(record.Added, id)
+			continue
+		}
+		if fields := diffJobSettings(jobSettings(old), jobSettings(current[id])); len(fields) > 0 {
+			record.Changed = append(record.Changed, JobChange{ID: id, Fields: fields})
+		}
+	}
+	for _, id := range sortedJobIDs(previous) {
+		if _, ok := current[id]; !ok {
+			record.Removed = append(record.Removed, id)
+		}
+	}
+	return record
+}
+
+// jobsByID maps jobs to their ids, the first of jobs sharing an id wins
+func jobsByID(jobs []*Runnable) map[string]*Runnable {
+	byID := map[string]*Runnable{}
+	for _, r := range jobs {
+		if _, ok := byID[r.ID]; !ok {
+			byID[r.ID] = r
+		}
+	}
+	return byID
+}
+
+func sortedJobIDs(jobs map[string]*Runnable) []string {
+	ids := make([]string, 0, len(jobs))
+	for id := range jobs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// jobSettings describes the exported settings of a job field by field. Only the names of
+// the variables of a job are given, their values may be credentials.
+func jobSettings(r *Runnable) map[string]string {
+	definition := map[string]string{}
+	value := reflect.ValueOf(r).Elem()
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		definition[field.Name] = formatJobField(value.Field(i))
+	}
+	names := []string{}
+	for _, env := range r.Env {
+		names = append(names, strings.SplitN(env, "=", 2)[0])
+	}
+	definition["Env"] = strings.Join(names, " ")
+	return definition
+}
+
+func formatJobField(value reflect.Value) string {
+	if value.Kind() == reflect.Ptr && value.IsNil() {
+		return ""
+	}
+	if stringer, ok := value.Interface().(fmt.Stringer); ok {
+		return stringer.String()
+	}
+	if value.Kind() == reflect.Ptr {
+		return formatJobField(value.Elem())
+	}
+	return fmt.Sprint(value.Interface())
+}
+
+// diffJobSettings returns the fields that differ between the settings of two jobs
+func diffJobSettings(before map[string]string, after map[string]string) []FieldChange {
+	changes := []FieldChange{}
+	for _, field := range sortedKeys(after) {
+		if before[field] != after[field] {
+			changes = append(changes, FieldChange{Field: field, Old: before[field], New: after[field]})
+		}
+	}
+	return changes
+}
+
+// logReload logs the jobs a reload added, removed and changed and records it in the audit log
+func logReload(record *ReloadRecord) {
+	logger := log.WithField("trigger", record.Trigger)
+	for _, id := range record.Added {
+		logger.WithField("id", id).Info("job added")
+	}
+	for _, id := range record.Removed {
+		logger.WithField("id", id).Info("job removed")
+	}
+	for _, change := range record.Changed {
+		fields := []string{}
+		for _, field := range change.Fields {
+			fields = append(fields, fmt.Sprintf("%s: %q -> %q", field.Field, field.Old, field.New))
+		}
+		logger.WithFields(log.Fields{
+			"id":      change.ID,
+			"changes": strings.Join(fields, ", "),
+		}).Info("job changed")
+	}
+	logger.WithFields(log.Fields{
+		"added":   len(record.Added),
+		"removed": len(record.Removed),
+		"changed": len(record.Changed),
+	}).Info("crontab reloaded")
+	if err := audit.record(record); err != nil {
+		log.Error("failed to record reload", err)
+	}
+}
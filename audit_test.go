@@ -0,0 +1,37 @@
+package crontinuous
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffJobs(t *testing.T) {
+	kept := createRunnable("/bin/true", "", "0 0 * * * *")
+	kept.ID = "kept"
+	removed := createRunnable("/bin/true", "", "0 0 * * * *")
+	removed.ID = "removed"
+	changed := createRunnable("/bin/backup", "--all", "0 0 3 * * *")
+	changed.ID = "changed"
+	changed.Env = []string{"TOKEN=old"}
+
+	keptAgain := createRunnable("/bin/true", "", "0 0 * * * *")
+	keptAgain.ID = "kept"
+	changedAgain := createRunnable("/bin/backup", "--all", "0 0 4 * * *")
+	changedAgain.ID = "changed"
+	changedAgain.Env = []string{"TOKEN=new"}
+	added := createRunnable("/bin/false", "", "0 0 * * * *")
+	added.ID = "added"
+
+	record := diffJobs("test", []*Runnable{kept, removed, changed}, []*Runnable{keptAgain, changedAgain, added})
+	if !reflect.DeepEqual(record.Added, []string{"added"}) {
+		t.Errorf("added = %v, want [added]", record.Added)
+	}
+	if !reflect.DeepEqual(record.Removed, []string{"removed"}) {
+		t.Errorf("removed = %v, want [removed]", record.Removed)
+	}
+	// variables are compared by name only, their values may be credentials
+	want := []JobChange{{ID: "changed", Fields: []FieldChange{{Field: "Schedule", Old: "0 0 3 * * *", New: "0 0 4 * * *"}}}}
+	if !reflect.DeepEqual(record.Changed, want) {
+		t.Errorf("changed = %+v, want %+v", record.Changed, want)
+	}
+}
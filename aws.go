@@ -0,0 +1,342 @@
+package crontinuous
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Constants
+// --------------------------------------------------------------------------------------------
+
+const (
+	awsMetadataAddr      = "http://169.254.169.254"
+	awsContainerAddr     = "http://169.254.170.2"
+	awsCredentialsMargin = 5 * time.Minute
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Struct
+// --------------------------------------------------------------------------------------------
+
+// awsClient calls AWS json apis signed with the credentials of the environment or of the IAM role
+// of the web identity, container or instance crontinuous runs on
+type awsClient struct {
+	region      string
+	client      *http.Client
+	credentials *awsCredentials
+	lock        sync.Mutex
+}
+
+// awsCredentials are temporary unless Expiration is zero
+type awsCredentials struct {
+	AccessKeyID     string `json:"AccessKeyId"`
+	SecretAccessKey string
+	Token           string
+	Expiration      time.Time
+}
+
+// awsError is the error document of AWS json apis
+type awsError struct {
+	Type    string `json:"__type"`
+	Message string `json:"message"`
+}
+
+// --------------------------------------------------------------------------------------------
+// ~ Private methods
+// --------------------------------------------------------------------------------------------
+
+func newAWSClient(region string) *awsClient {
+	return &awsClient{
+		region: region,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// call posts a request to the target of a json api, in the region of the client if region is empty
+func (a *awsClient) call(service string, region string, target string, request interface{}, response interface{}) error {
+	if region == "" {
+		var err error
+		if region, err = a.defaultRegion(); err != nil {
+			return err
+		}
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", awsEndpoint(service, region)+"/", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", target)
+	resp, err := a.do(req, body, service, region)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		e := awsError{}
+		json.NewDecoder(resp.Body).Decode(&e)
+		return fmt.Errorf("%s failed with %s: %s %s", target, resp.Status, e.Type[strings.LastIndex(e.Type, "#")+1:], e.Message)
+	}
+	return json.NewDecoder(resp.Body).Decode(response)
+}
+
+// do signs and sends a request
+func (a *awsClient) do(req *http.Request, body []byte, service string, region string) (*http.Response, error) {
+	credentials, err := a.getCredentials(region)
+	if err != nil {
+		return nil, fmt.Errorf("no aws credentials: %s", err)
+	}
+	signAWSRequest(req, body, credentials, service, region, time.Now())
+	return a.client.Do(req)
+}
+
+// awsEndpoint is the regional endpoint of a service, or $AWS_ENDPOINT_URL for all services
+func awsEndpoint(service string, region string) string {
+	if custom := os.Getenv("AWS_ENDPOINT_URL"); custom != "" {
+		return strings.TrimRight(custom, "/")
+	}
+	return "https://" + service + "." + region + ".amazonaws.com"
+}
+
+// defaultRegion is -aws-region, or the region of the instance crontinuous runs on
+func (a *awsClient) defaultRegion() (string, error) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if a.region != "" {
+		return a.region, nil
+	}
+	token, err := a.metadataToken()
+	if err != nil {
+		return "", fmt.Errorf("no aws region, set -aws-region or AWS_REGION")
+	}
+	region, err := a.metadata(token, "/latest/meta-data/placement/region")
+	if err != nil {
+		return "", fmt.Errorf("no aws region, set -aws-region or AWS_REGION")
+	}
+	a.region = region
+	return region, nil
+}
+
+// getCredentials returns cached credentials until shortly before they expire
+func (a *awsClient) getCredentials(region string) (*awsCredentials, error) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if a.credentials != nil && (a.credentials.Expiration.IsZero() || time.Now().Add(awsCredentialsMargin).Before(a.credentials.Expiration)) {
+		return a.credentials, nil
+	}
+	credentials, err := a.loadCredentials(region)
+	if err != nil {
+		return nil, err
+	}
+	a.credentials = credentials
+	return credentials, nil
+}
+
+// loadCredentials looks for credentials like the AWS SDKs do: in the environment,
+// for a web identity like on EKS, from the container like on ECS and from the instance profile on EC2
+func (a *awsClient) loadCredentials(region string) (*awsCredentials, error) {
+	if id := os.Getenv("AWS_ACCESS_KEY_ID"); id != "" {
+		return &awsCredentials{
+			AccessKeyID:     id,
+			SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			Token:           os.Getenv("AWS_SESSION_TOKEN"),
+		}, nil
+	}
+	if tokenFile := os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE"); tokenFile != "" {
+		return a.assumeRoleWithWebIdentity(region, tokenFile, os.Getenv("AWS_ROLE_ARN"))
+	}
+	if uri := os.Getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI"); uri != "" {
+		return a.fetchCredentials(awsContainerAddr+uri, nil)
+	}
+	if uri := os.Getenv("AWS_CONTAINER_CREDENTIALS_FULL_URI"); uri != "" {
+		return a.fetchCredentials(uri, map[string]string{"Authorization": os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN")})
+	}
+	token, err := a.metadataToken()
+	if err != nil {
+		return nil, err
+	}
+	role, err := a.metadata(token, "/latest/meta-data/iam/security-credentials/")
+	if err != nil {
+		return nil, err
+	}
+	return a.fetchCredentials(awsMetadataAddr+"/latest/meta-data/iam/security-credentials/"+strings.TrimSpace(role), map[string]string{"X-aws-ec2-metadata-token": token})
+}
+
+func (a *awsClient) fetchCredentials(url string, headers map[string]string) (*awsCredentials, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s from %s", resp.Status, url)
+	}
+	credentials := &awsCredentials{}
+	return credentials, json.NewDecoder(resp.Body).Decode(credentials)
+}
+
+func (a *awsClient) assumeRoleWithWebIdentity(region string, tokenFile string, roleARN string) (*awsCredentials, error) {
+	token, err := ioutil.ReadFile(tokenFile)
+	if err != nil {
+		return nil, err
+	}
+	query := url.Values{
+		"Action":           {"AssumeRoleWithWebIdentity"},
+		"Version":          {"2011-06-15"},
+		"RoleArn":          {roleARN},
+		"RoleSessionName":  {"crontinuous"},
+		"WebIdentityToken": {strings.TrimSpace(string(token))},
+	}
+	resp, err := a.client.Get("https://sts." + region + ".amazonaws.com/?" + query.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s assuming role %s", resp.Status, roleARN)
+	}
+	result := struct {
+		Credentials struct {
+			AccessKeyID     string `xml:"AccessKeyId"`
+			SecretAccessKey string
+			SessionToken    string
+			Expiration      time.Time
+		} `xml:"AssumeRoleWithWebIdentityResult>Credentials"`
+	}{}
+	if err := xml.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	return &awsCredentials{
+		AccessKeyID:     result.Credentials.AccessKeyID,
+		SecretAccessKey: result.Credentials.SecretAccessKey,
+		Token:           result.Credentials.SessionToken,
+		Expiration:      result.Credentials.Expiration,
+	}, nil
+}
+
+// metadataToken starts an IMDSv2 session of the instance metadata service
+func (a *awsClient) metadataToken() (string, error) {
+	req, err := http.NewRequest("PUT", awsMetadataAddr+"/latest/api/token", nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-aws-ec2-metadata-token-ttl-seconds", "300")
+	client := &http.Client{Timeout: 2 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	token, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status %s from the instance metadata service", resp.Status)
+	}
+	return string(token), nil
+}
+
+func (a *awsClient) metadata(token string, path string) (string, error) {
+	req, err := http.NewRequest("GET", awsMetadataAddr+path, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-aws-ec2-metadata-token", token)
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status %s from the instance metadata service", resp.Status)
+	}
+	return string(data), nil
+}
+
+// signAWSRequest signs a request with signature version 4
+func signAWSRequest(req *http.Request, body []byte, credentials *awsCredentials, service string, region string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	payloadHash := sha256.Sum256(body)
+	req.Header.Set("X-Amz-Date", amzDate)
+	if service == "s3" {
+		req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(payloadHash[:]))
+	}
+	if credentials.Token != "" {
+		req.Header.Set("X-Amz-Security-Token", credentials.Token)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for key, values := range req.Header {
+		headers[strings.ToLower(key)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	canonicalHeaders := ""
+	for _, name := range names {
+		canonicalHeaders += name + ":" + headers[name] + "\n"
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		req.URL.RawQuery,
+		canonicalHeaders,
+		signedHeaders,
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := []byte("AWS4" + credentials.SecretAccessKey)
+	for _, part := range []string{date, region, service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+credentials.AccessKeyID+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
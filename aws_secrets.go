@@ -0,0 +1,93 @@
+package crontinuous
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Struct
+// --------------------------------------------------------------------------------------------
+
+// awsSecretsManager reads secrets from AWS Secrets Manager
+type awsSecretsManager struct {
+	aws *awsClient
+}
+
+// awsParameterStore reads parameters from the AWS Systems Manager Parameter Store
+type awsParameterStore struct {
+	aws *awsClient
+}
+
+// --------------------------------------------------------------------------------------------
+// ~ Private methods
+// --------------------------------------------------------------------------------------------
+
+func awsRegionFromEnv() string {
+	if region := os.Getenv("AWS_REGION"); region != "" {
+		return region
+	}
+	return os.Getenv("AWS_DEFAULT_REGION")
+}
+
+// arnRegion is the region of an arn, empty for names
+func arnRegion(ref string) string {
+	parts := strings.SplitN(ref, ":", 5)
+	if len(parts) == 5 && parts[0] == "arn" {
+		return parts[3]
+	}
+	return ""
+}
+
+// resolve reads the current version of a secret referenced by name or arn,
+// with #key the value of a key of a secret stored as json
+func (s *awsSecretsManager) resolve(ref string) (string, error) {
+	parts := strings.SplitN(ref, "#", 2)
+	if parts[0] == "" {
+		return "", fmt.Errorf("invalid secrets manager reference %q, expected secret or secret#key", ref)
+	}
+	secret := struct {
+		SecretString *string
+	}{}
+	if err := s.aws.call("secretsmanager", arnRegion(parts[0]), "secretsmanager.GetSecretValue", map[string]string{"SecretId": parts[0]}, &secret); err != nil {
+		return "", err
+	}
+	if secret.SecretString == nil {
+		return "", fmt.Errorf("secret %s is binary", parts[0])
+	}
+	if len(parts) == 1 {
+		return *secret.SecretString, nil
+	}
+	data := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(*secret.SecretString), &data); err != nil {
+		return "", fmt.Errorf("secret %s is not json: %s", parts[0], err)
+	}
+	value, ok := data[parts[1]]
+	if !ok {
+		return "", fmt.Errorf("secret %s has no key %s", parts[0], parts[1])
+	}
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+	encoded, err := json.Marshal(value)
+	return string(encoded), err
+}
+
+// resolve reads a parameter referenced by name or arn, secure strings are decrypted
+func (p *awsParameterStore) resolve(ref string) (string, error) {
+	if ref == "" {
+		return "", fmt.Errorf("invalid parameter store reference, expected the name of a parameter")
+	}
+	parameter := struct {
+		Parameter struct {
+			Value string
+		}
+	}{}
+	request := map[string]interface{}{"Name": ref, "WithDecryption": true}
+	if err := p.aws.call("ssm", arnRegion(ref), "AmazonSSM.GetParameter", request, &parameter); err != nil {
+		return "", err
+	}
+	return parameter.Parameter.Value, nil
+}
@@ -0,0 +1,100 @@
+package crontinuous
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Struct
+// --------------------------------------------------------------------------------------------
+
+// outputBuffer collects the output lines of a run to log them in batches. Lines are added by the
+// reader of the output while the buffer is flushed periodically, so all access is locked.
+type outputBuffer struct {
+	lock    sync.Mutex
+	data    []byte
+	max     int
+	log     func(output string)
+	stopped chan struct{}
+	done    chan struct{}
+}
+
+// --------------------------------------------------------------------------------------------
+// ~ Private methods
+// --------------------------------------------------------------------------------------------
+
+// newOutputBuffer returns a buffer of at most max bytes, which hands its output to log when flushed
+func newOutputBuffer(max int, log func(output string)) *outputBuffer {
+	return &outputBuffer{max: max, log: log}
+}
+
+// add appends a line, the buffer is flushed first if the line does not fit anymore.
+// The buffer grows with the output and is released when flushed, idle jobs do not hold one.
+func (b *outputBuffer) add(line string) {
+	if b == nil {
+		return
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if len(b.data)+len(line)+1 > b.max {
+		b.flushLocked()
+	}
+	b.data = append(b.data, line...)
+	b.data = append(b.data, '\n')
+}
+
+// flush logs the buffered output
+func (b *outputBuffer) flush() {
+	if b == nil {
+		return
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.flushLocked()
+}
+
+// flushLocked logs the buffered output while holding the lock, so batches are never logged out of order
+func (b *outputBuffer) flushLocked() {
+	if len(b.data) == 0 {
+		return
+	}
+	output := strings.TrimSpace(string(b.data))
+	b.data = nil
+	b.log(output)
+}
+
+// start flushes the buffer every interval until it is stopped
+func (b *outputBuffer) start(interval time.Duration) {
+	if b == nil {
+		return
+	}
+	b.stopped = make(chan struct{})
+	b.done = make(chan struct{})
+	go func() {
+		defer close(b.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				b.flush()
+			case <-b.stopped:
+				return
+			}
+		}
+	}()
+}
+
+// stop ends the periodic flushing and flushes the rest of the output
+func (b *outputBuffer) stop() {
+	if b == nil {
+		return
+	}
+	if b.stopped != nil {
+		close(b.stopped)
+		<-b.done
+	}
+	b.flush()
+}
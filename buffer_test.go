@@ -0,0 +1,47 @@
+package crontinuous
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOutputBufferConcurrentFlush(t *testing.T) {
+	var logged []string
+	b := newOutputBuffer(64, func(output string) {
+		logged = append(logged, strings.Split(output, "\n")...)
+	})
+	b.start(time.Microsecond)
+	for i := 0; i < 10000; i++ {
+		b.add(strconv.Itoa(i))
+	}
+	b.stop()
+	if len(logged) != 10000 {
+		t.Fatalf("logged %d lines, want 10000", len(logged))
+	}
+	for i, line := range logged {
+		if line != strconv.Itoa(i) {
+			t.Fatalf("line %d is %q, want %q", i, line, strconv.Itoa(i))
+		}
+	}
+}
+
+func TestOutputBufferNil(t *testing.T) {
+	var b *outputBuffer
+	b.start(time.Millisecond)
+	b.add("line")
+	b.stop()
+}
+
+func BenchmarkOutputBuffer(b *testing.B) {
+	line := strings.Repeat("x", 80)
+	buffer := newOutputBuffer(512*1024, func(output string) {})
+	buffer.start(time.Millisecond)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buffer.add(line)
+	}
+	buffer.stop()
+}
@@ -0,0 +1,54 @@
+package crontinuous
+
+import (
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Constants
+// --------------------------------------------------------------------------------------------
+
+// maxCatchUpSlots bounds the search for missed slots of very frequent schedules
+const maxCatchUpSlots = 100000
+
+// --------------------------------------------------------------------------------------------
+// ~ Private methods
+// --------------------------------------------------------------------------------------------
+
+// missedRun returns the latest slot the job was scheduled for but not run since its last run,
+// zero if there is none within its catch-up window
+func (r *Runnable) missedRun(now time.Time) time.Time {
+	if r.CatchUp <= 0 || r.schedule == nil {
+		return time.Time{}
+	}
+	last := jobsState.lastRun(r.ID)
+	if last.IsZero() {
+		return time.Time{}
+	}
+
+	missed := time.Time{}
+	slot := r.schedule.Next(last.In(schedulerLocation))
+	for i := 0; i < maxCatchUpSlots && !slot.IsZero() && !slot.After(now); i++ {
+		missed = slot
+		slot = r.schedule.Next(slot)
+	}
+	if missed.IsZero() || now.Sub(missed) > r.CatchUp {
+		return time.Time{}
+	}
+	return missed
+}
+
+// catchUp runs the job right away if it missed a slot while crontinuous was down
+func (r *Runnable) catchUp() {
+	missed := r.missedRun(time.Now())
+	if missed.IsZero() {
+		return
+	}
+	r.contextLogger.WithFields(log.Fields{
+		"missed":  missed,
+		"lastRun": jobsState.lastRun(r.ID),
+	}).Info("catching up missed run")
+	go r.Run()
+}
@@ -0,0 +1,112 @@
+//go:build linux
+// +build linux
+
+package crontinuous
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Constants
+// --------------------------------------------------------------------------------------------
+
+// cpuPeriod is the cgroup cpu.max period in microseconds
+const cpuPeriod = 100000
+
+// --------------------------------------------------------------------------------------------
+// ~ Struct
+// --------------------------------------------------------------------------------------------
+
+// cgroup is the cgroup v2 a single run is limited by
+type cgroup struct {
+	path string
+}
+
+// --------------------------------------------------------------------------------------------
+// ~ Private methods
+// --------------------------------------------------------------------------------------------
+
+// newCgroup creates a cgroup with the job's limits for a run, nil if the job has no limits
+func (r *Runnable) newCgroup(runID string) (*cgroup, error) {
+	if r.Limits == nil {
+		return nil, nil
+	}
+	if err := os.MkdirAll(*cgroupRoot, 0755); err != nil {
+		return nil, err
+	}
+	// the controllers have to be enabled for the children of every level
+	for _, dir := range []string{filepath.Dir(*cgroupRoot), *cgroupRoot} {
+		if err := ioutil.WriteFile(filepath.Join(dir, "cgroup.subtree_control"), []byte("+cpu +memory"), 0644); err != nil {
+			return nil, fmt.Errorf("failed to enable cgroup controllers in %s: %s", dir, err)
+		}
+	}
+
+	c := &cgroup{path: filepath.Join(*cgroupRoot, r.containerName(runID))}
+	if err := os.Mkdir(c.path, 0755); err != nil {
+		return nil, err
+	}
+	if r.Limits.CPU > 0 {
+		if err := c.write("cpu.max", fmt.Sprintf("%d %d", int64(r.Limits.CPU*cpuPeriod), cpuPeriod)); err != nil {
+			c.remove()
+			return nil, err
+		}
+	}
+	if r.Limits.Memory > 0 {
+		if err := c.write("memory.max", strconv.FormatInt(r.Limits.Memory, 10)); err != nil {
+			c.remove()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+func (c *cgroup) write(file string, value string) error {
+	return ioutil.WriteFile(filepath.Join(c.path, file), []byte(value), 0644)
+}
+
+// add moves a process into the cgroup, its children inherit it
+func (c *cgroup) add(pid int) error {
+	if c == nil {
+		return nil
+	}
+	return c.write("cgroup.procs", strconv.Itoa(pid))
+}
+
+// oomKilled reports whether the kernel killed processes of the run for exceeding the memory limit
+func (c *cgroup) oomKilled() bool {
+	if c == nil {
+		return false
+	}
+	data, err := ioutil.ReadFile(filepath.Join(c.path, "memory.events"))
+	if err != nil {
+		return false
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == "oom_kill" && fields[1] != "0" {
+			return true
+		}
+	}
+	return false
+}
+
+// remove kills what is left of the run and removes the cgroup
+func (c *cgroup) remove() {
+	if c == nil {
+		return
+	}
+	c.write("cgroup.kill", "1")
+	for i := 0; i < 10; i++ {
+		if err := os.Remove(c.path); err == nil || os.IsNotExist(err) {
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
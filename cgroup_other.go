@@ -0,0 +1,34 @@
+//go:build !linux
+// +build !linux
+
+package crontinuous
+
+import "errors"
+
+// --------------------------------------------------------------------------------------------
+// ~ Struct
+// --------------------------------------------------------------------------------------------
+
+// cgroup is not available outside of linux
+type cgroup struct{}
+
+// --------------------------------------------------------------------------------------------
+// ~ Private methods
+// --------------------------------------------------------------------------------------------
+
+func (r *Runnable) newCgroup(runID string) (*cgroup, error) {
+	if r.Limits == nil {
+		return nil, nil
+	}
+	return nil, errors.New("resource limits are only supported on linux")
+}
+
+func (c *cgroup) add(pid int) error {
+	return nil
+}
+
+func (c *cgroup) oomKilled() bool {
+	return false
+}
+
+func (c *cgroup) remove() {}
@@ -0,0 +1,76 @@
+package crontinuous
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Variables
+// --------------------------------------------------------------------------------------------
+
+var (
+	// suspendedJobs hold by id when the circuit breaker of a job suspended it, kept like pausedJobs
+	suspendedJobs     = map[string]time.Time{}
+	suspendedJobsLock sync.Mutex
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Private methods
+// --------------------------------------------------------------------------------------------
+
+// tripBreaker suspends the scheduled runs of the job once as many runs as its breaker allows failed in a row,
+// and notifies about it. After the cool-down a single failed run suspends the job again.
+func (r *Runnable) tripBreaker(n *Notification, failures int) {
+	now := time.Now()
+	if r.Breaker <= 0 || failures < r.Breaker || r.suspended(now) {
+		return
+	}
+	suspendedJobsLock.Lock()
+	suspendedJobs[r.ID] = now
+	suspendedJobsLock.Unlock()
+	if err := jobsState.setSuspended(r.ID, now); err != nil {
+		r.contextLogger.Error("failed to save state", err)
+	}
+
+	until := "until resumed"
+	if r.Cooldown > 0 {
+		until = "until " + now.Add(r.Cooldown).Format(time.RFC3339)
+	}
+	err := fmt.Errorf("%d runs failed in a row, suspending the scheduled runs %s", failures, until)
+	r.contextLogger.WithFields(log.Fields{"failures": failures, "runId": n.RunID}).Error(err)
+	r.notify(&Notification{
+		Event:    EventSuspended,
+		RunID:    n.RunID,
+		Start:    now,
+		ExitCode: n.ExitCode,
+		Err:      err,
+		Stderr:   n.Stderr,
+	})
+}
+
+// suspended reports whether the circuit breaker of the job suspends its scheduled runs
+func (r *Runnable) suspended(now time.Time) bool {
+	suspendedJobsLock.Lock()
+	defer suspendedJobsLock.Unlock()
+	since, ok := suspendedJobs[r.ID]
+	return ok && (r.Cooldown <= 0 || now.Before(since.Add(r.Cooldown)))
+}
+
+// closeBreaker ends the suspension of a job, after a successful run or when it is resumed
+func closeBreaker(id string) bool {
+	suspendedJobsLock.Lock()
+	_, ok := suspendedJobs[id]
+	delete(suspendedJobs, id)
+	suspendedJobsLock.Unlock()
+	if !ok {
+		return false
+	}
+	if err := jobsState.setSuspended(id, time.Time{}); err != nil {
+		log.WithField("id", id).Error("failed to save state", err)
+	}
+	return true
+}
@@ -0,0 +1,59 @@
+package crontinuous
+
+import (
+	"testing"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	job := &Runnable{ID: "breaker", Breaker: 2, Cooldown: time.Hour, contextLogger: log.WithField("id", "breaker")}
+	defer func() {
+		closeBreaker(job.ID)
+		failureCountsLock.Lock()
+		delete(failureCounts, job.ID)
+		failureCountsLock.Unlock()
+	}()
+	fail := func() {
+		job.finishRun(&Notification{Job: job, Event: EventFailure, ExitCode: 1})
+	}
+
+	fail()
+	if job.suspended(time.Now()) {
+		t.Fatal("expected a single failure not to suspend the job")
+	}
+	fail()
+	if !job.suspended(time.Now()) {
+		t.Fatal("expected the job to be suspended after 2 failures")
+	}
+	// after the cool-down one run is tried
+	if job.suspended(time.Now().Add(2 * time.Hour)) {
+		t.Error("expected the job to be tried after the cool-down")
+	}
+	suspendedJobsLock.Lock()
+	suspendedJobs[job.ID] = time.Now().Add(-2 * time.Hour)
+	suspendedJobsLock.Unlock()
+	fail()
+	if !job.suspended(time.Now()) {
+		t.Error("expected a failure after the cool-down to suspend the job again")
+	}
+
+	// a success closes the breaker
+	job.finishRun(&Notification{Job: job, Event: EventSuccess})
+	if job.suspended(time.Now()) {
+		t.Error("expected a success to close the breaker")
+	}
+
+	// resuming closes it too, a job without cool-down stays suspended until then
+	job.Cooldown = 0
+	fail()
+	fail()
+	if !job.suspended(time.Now().Add(24 * time.Hour)) {
+		t.Error("expected the job to stay suspended without cool-down")
+	}
+	setPaused(job.ID, false)
+	if job.suspended(time.Now()) {
+		t.Error("expected resuming to close the breaker")
+	}
+}
@@ -0,0 +1,10 @@
+// crontinuous runs the jobs of a crontab in the foreground, e.g. as the entrypoint of a container
+package main
+
+import (
+	"github.com/foomo/crontinuous"
+)
+
+func main() {
+	crontinuous.Main()
+}
@@ -0,0 +1,350 @@
+// crontinuousctl controls a crontinuous daemon on the same host through its control socket,
+// without exposing a tcp port
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Struct
+// --------------------------------------------------------------------------------------------
+
+// job is the part of a job of the admin api shown by crontinuousctl
+type job struct {
+	ID         string    `json:"id"`
+	Command    string    `json:"command"`
+	Args       string    `json:"args"`
+	Schedule   string    `json:"schedule"`
+	File       string    `json:"file"`
+	IsRunning  bool      `json:"isRunning"`
+	Paused     bool      `json:"paused"`
+	Suspended  bool      `json:"suspended"`
+	Disabled   bool      `json:"disabled"`
+	Tags       []string  `json:"tags"`
+	Next       time.Time `json:"next"`
+	Prev       time.Time `json:"prev"`
+	LastStatus string    `json:"lastStatus"`
+	LastExit   int       `json:"lastExitCode"`
+}
+
+// drainStatus tells whether the daemon is draining and which runs it waits for
+type drainStatus struct {
+	Draining bool     `json:"draining"`
+	Drained  bool     `json:"drained"`
+	Running  int      `json:"running"`
+	Jobs     []string `json:"jobs"`
+}
+
+// jobStats are the statistics of the recent runs of a job of the admin api
+type jobStats struct {
+	ID       string      `json:"id"`
+	Runs     int         `json:"runs"`
+	Duration *valueStats `json:"duration"`
+	CPU      *valueStats `json:"cpu"`
+	MaxRSS   *valueStats `json:"maxRss"`
+}
+
+type valueStats struct {
+	Min    float64 `json:"min"`
+	Max    float64 `json:"max"`
+	Mean   float64 `json:"mean"`
+	P95    float64 `json:"p95"`
+	Last   float64 `json:"last"`
+	Recent float64 `json:"recent"`
+}
+
+// --------------------------------------------------------------------------------------------
+// ~ Constants
+// --------------------------------------------------------------------------------------------
+
+const usage = `usage: crontinuousctl [-socket <path>] <command>
+
+commands:
+  status [job-id]   show the jobs or a single job
+  trigger <job-id>  run a job right away
+  pause <job-id>    pause the scheduled runs of a job
+  resume <job-id>   resume the scheduled runs of a job
+  stats [job-id]    show the duration, cpu time and memory of the recent runs of the jobs or a single job
+  reload            reload the crontabs
+  drain             stop launching new runs and wait for the running ones to finish
+  undrain           launch runs again after drain
+
+status, trigger, pause and resume take -tag <tag> instead of a job id for all jobs with a tag.
+`
+
+// --------------------------------------------------------------------------------------------
+// ~ Variables
+// --------------------------------------------------------------------------------------------
+
+var socket = flag.String("socket", defaultSocket, "control socket of the crontinuous daemon")
+
+// --------------------------------------------------------------------------------------------
+// ~ Private methods
+// --------------------------------------------------------------------------------------------
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	var err error
+	switch args := flag.Args(); {
+	case len(args) == 3 && args[1] == "-tag" && args[0] == "status":
+		err = statusTag(args[2])
+	case len(args) == 3 && args[1] == "-tag" && args[0] == "trigger":
+		err = tagAction(args[2], "run", "started")
+	case len(args) == 3 && args[1] == "-tag" && (args[0] == "pause" || args[0] == "resume"):
+		err = tagAction(args[2], args[0], args[0]+"d")
+	case len(args) >= 1 && args[0] == "status" && len(args) <= 2:
+		err = status(args[1:])
+	case len(args) == 2 && args[0] == "trigger":
+		err = jobAction(args[1], "run", "started")
+	case len(args) == 2 && (args[0] == "pause" || args[0] == "resume"):
+		err = jobAction(args[1], args[0], args[0]+"d")
+	case len(args) >= 1 && args[0] == "stats" && len(args) <= 2:
+		err = stats(args[1:])
+	case len(args) == 1 && args[0] == "reload":
+		err = reload()
+	case len(args) == 1 && args[0] == "drain":
+		err = drain()
+	case len(args) == 1 && args[0] == "undrain":
+		err = undrain()
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// status prints the jobs as table or the details of a single job
+func status(args []string) error {
+	if len(args) == 1 {
+		j := job{}
+		if err := request("GET", "/jobs/"+url.PathEscape(args[0]), &j); err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		fmt.Fprintf(w, "id:\t%s\n", j.ID)
+		fmt.Fprintf(w, "command:\t%s %s\n", j.Command, j.Args)
+		fmt.Fprintf(w, "schedule:\t%s\n", j.Schedule)
+		fmt.Fprintf(w, "file:\t%s\n", j.File)
+		fmt.Fprintf(w, "tags:\t%s\n", strings.Join(j.Tags, ","))
+		fmt.Fprintf(w, "status:\t%s\n", jobStatus(j))
+		fmt.Fprintf(w, "last exit code:\t%s\n", lastExit(j))
+		fmt.Fprintf(w, "previous run:\t%s\n", formatTime(j.Prev))
+		fmt.Fprintf(w, "next run:\t%s\n", formatTime(j.Next))
+		return w.Flush()
+	}
+
+	return statusTable("/jobs")
+}
+
+// statusTag prints the jobs with a tag as table
+func statusTag(tag string) error {
+	return statusTable("/jobs?tag=" + url.QueryEscape(tag))
+}
+
+func statusTable(path string) error {
+	jobs := []job{}
+	if err := request("GET", path, &jobs); err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSCHEDULE\tSTATUS\tEXIT\tPREV\tNEXT\tCOMMAND")
+	for _, j := range jobs {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s %s\n", j.ID, j.Schedule, jobStatus(j), lastExit(j), formatTime(j.Prev), formatTime(j.Next), j.Command, j.Args)
+	}
+	return w.Flush()
+}
+
+// jobAction posts an action like run or pause of a job
+func jobAction(id string, action string, done string) error {
+	if err := request("POST", "/jobs/"+url.PathEscape(id)+"/"+action, nil); err != nil {
+		return err
+	}
+	fmt.Printf("%s job %s\n", done, id)
+	return nil
+}
+
+// tagAction posts an action like run or pause to all jobs with a tag
+func tagAction(tag string, action string, done string) error {
+	result := struct {
+		Jobs []string `json:"jobs"`
+	}{}
+	if err := request("POST", "/tags/"+url.PathEscape(tag)+"/"+action, &result); err != nil {
+		return err
+	}
+	for _, id := range result.Jobs {
+		fmt.Printf("%s job %s\n", done, id)
+	}
+	return nil
+}
+
+// stats prints the statistics of the recent runs of the jobs, durations and cpu times in seconds
+func stats(args []string) error {
+	list := []jobStats{}
+	if len(args) == 1 {
+		s := jobStats{}
+		if err := request("GET", "/jobs/"+url.PathEscape(args[0])+"/stats", &s); err != nil {
+			return err
+		}
+		list = append(list, s)
+	} else if err := request("GET", "/stats", &list); err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tRUNS\tMIN\tMEAN\tP95\tMAX\tRECENT\tCPU P95\tRSS MAX")
+	for _, s := range list {
+		if s.Duration == nil {
+			fmt.Fprintf(w, "%s\t0\t-\t-\t-\t-\t-\t-\t-\n", s.ID)
+			continue
+		}
+		d := s.Duration
+		cpu, rss := "-", "-"
+		if s.CPU != nil {
+			cpu = fmt.Sprintf("%.2f", s.CPU.P95)
+		}
+		if s.MaxRSS != nil {
+			rss = formatBytes(s.MaxRSS.Max)
+		}
+		fmt.Fprintf(w, "%s\t%d\t%.2f\t%.2f\t%.2f\t%.2f\t%.2f\t%s\t%s\n", s.ID, s.Runs, d.Min, d.Mean, d.P95, d.Max, d.Recent, cpu, rss)
+	}
+	return w.Flush()
+}
+
+func reload() error {
+	result := struct {
+		Jobs   int      `json:"jobs"`
+		Errors []string `json:"errors"`
+	}{}
+	if err := request("POST", "/reload", &result); err != nil {
+		return err
+	}
+	for _, problem := range result.Errors {
+		fmt.Fprintln(os.Stderr, problem)
+	}
+	fmt.Printf("reloaded %d jobs\n", result.Jobs)
+	if len(result.Errors) > 0 {
+		return fmt.Errorf("%d problem(s) found", len(result.Errors))
+	}
+	return nil
+}
+
+// drain stops the daemon from launching new runs and waits until the running ones finished,
+// interrupting the wait leaves the daemon draining
+func drain() error {
+	status := drainStatus{}
+	if err := request("POST", "/drain", &status); err != nil {
+		return err
+	}
+	running := -1
+	for !status.Drained {
+		if status.Running != running {
+			running = status.Running
+			fmt.Printf("waiting for %d run(s) to finish: %s\n", status.Running, strings.Join(status.Jobs, ", "))
+		}
+		time.Sleep(time.Second)
+		if err := request("GET", "/drain", &status); err != nil {
+			return err
+		}
+		if !status.Draining {
+			return fmt.Errorf("undrained while waiting for the runs to finish")
+		}
+	}
+	fmt.Println("drained, no runs left")
+	return nil
+}
+
+func undrain() error {
+	if err := request("POST", "/undrain", nil); err != nil {
+		return err
+	}
+	fmt.Println("undrained, launching runs again")
+	return nil
+}
+
+func jobStatus(j job) string {
+	switch {
+	case j.IsRunning:
+		return "running"
+	case j.Disabled:
+		return "disabled"
+	case j.Paused:
+		return "paused"
+	case j.Suspended:
+		return "suspended"
+	case j.LastStatus != "":
+		return j.LastStatus
+	}
+	return "-"
+}
+
+func lastExit(j job) string {
+	if j.LastStatus == "" {
+		return "-"
+	}
+	return fmt.Sprint(j.LastExit)
+}
+
+func formatBytes(bytes float64) string {
+	for _, unit := range []string{"B", "K", "M", "G"} {
+		if bytes < 1024 {
+			return fmt.Sprintf("%.0f%s", bytes, unit)
+		}
+		bytes /= 1024
+	}
+	return fmt.Sprintf("%.1fT", bytes)
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Format(time.RFC3339)
+}
+
+// request sends a request to the daemon and decodes the JSON response into v if not nil
+func request(method string, path string, v interface{}) error {
+	client := &http.Client{
+		Timeout: 30 * time.Second,
+		Transport: &http.Transport{
+			Dial: func(network, addr string) (net.Conn, error) {
+				return net.Dial("unix", *socket)
+			},
+		},
+	}
+	req, err := http.NewRequest(method, "http://crontinuous"+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Requested-With", "crontinuousctl")
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach crontinuous on %s: %s", *socket, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	if v == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
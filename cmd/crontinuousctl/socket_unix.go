@@ -0,0 +1,6 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+const defaultSocket = "/var/run/crontinuous.sock"
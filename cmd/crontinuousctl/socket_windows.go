@@ -0,0 +1,11 @@
+//go:build windows
+// +build windows
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+)
+
+var defaultSocket = filepath.Join(os.Getenv("ProgramData"), "crontinuous", "crontinuous.sock")
@@ -0,0 +1,139 @@
+package crontinuous
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Constants
+// --------------------------------------------------------------------------------------------
+
+// ConcurrencyPolicy decides what happens when a job is due while its previous run is still active
+type ConcurrencyPolicy string
+
+const (
+	// ConcurrencyAllow starts the new run next to the active one
+	ConcurrencyAllow ConcurrencyPolicy = "allow"
+	// ConcurrencyForbid skips the new run
+	ConcurrencyForbid ConcurrencyPolicy = "forbid"
+	// ConcurrencyReplace terminates the active run, kills it if it does not exit within its grace, and starts the new one
+	ConcurrencyReplace ConcurrencyPolicy = "replace"
+	// ConcurrencyQueue starts the new run once the active one and the runs queued before finished
+	ConcurrencyQueue ConcurrencyPolicy = "queue"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Private methods
+// --------------------------------------------------------------------------------------------
+
+func parseConcurrencyPolicy(value string) (ConcurrencyPolicy, error) {
+	switch policy := ConcurrencyPolicy(strings.ToLower(strings.TrimSpace(value))); policy {
+	case ConcurrencyAllow, ConcurrencyForbid, ConcurrencyReplace, ConcurrencyQueue:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unknown concurrency policy %q, expected allow, forbid, replace or queue", value)
+	}
+}
+
+// acquire registers a new run according to the job's concurrency policy and
+// reports whether the run may start; the returned execution has to be passed to release
+func (r *Runnable) acquire() (*execution, bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	switch r.Concurrency {
+	case ConcurrencyForbid:
+		if r.runs > 0 {
+			r.contextLogger.Warn("previous run still active, skipping run")
+			return nil, false
+		}
+	case ConcurrencyReplace:
+		for r.runs > 0 {
+			r.contextLogger.WithField("runId", r.current.id).Warn("previous run still active, replacing it")
+			r.stop(r.current)
+		}
+	case ConcurrencyQueue:
+		if r.runs > 0 || len(r.queue) > 0 {
+			if *queueSize > 0 && len(r.queue) >= *queueSize {
+				r.contextLogger.WithField("queued", len(r.queue)).Warn("previous run still active and queue full, skipping run")
+				return nil, false
+			}
+			r.contextLogger.WithField("queued", len(r.queue)).Info("previous run still active, queueing run")
+			turn := make(chan struct{})
+			r.queue = append(r.queue, turn)
+			r.lock.Unlock()
+			<-turn
+			r.lock.Lock()
+			// the finished run handed its place over, runs stays the same
+			r.current = newExecution(r.contextLogger, r.revision)
+			r.current.queued = true
+			return r.current, true
+		}
+	}
+
+	r.runs++
+	r.current = newExecution(r.contextLogger, r.revision)
+	return r.current, true
+}
+
+// stop terminates the process of an execution and waits for it to finish, killing the process if it does not
+// exit within the grace of the job. The lock has to be held, it is released while waiting.
+func (r *Runnable) stop(e *execution) {
+	e.abort()
+	terminated := e.process != nil && r.KillGrace > 0
+	if terminated {
+		if err := terminateProcessGroup(e.process); err != nil {
+			r.contextLogger.Error("failed to terminate previous run", err)
+		}
+	} else if e.process != nil {
+		if err := killProcessGroup(e.process); err != nil {
+			r.contextLogger.Error("failed to kill previous run", err)
+		}
+	}
+	r.lock.Unlock()
+	defer r.lock.Lock()
+	if terminated {
+		select {
+		case <-e.finished:
+			return
+		case <-time.After(r.KillGrace):
+		}
+		r.lock.Lock()
+		// the process is reset when the run finished meanwhile
+		if e.process != nil {
+			r.contextLogger.WithFields(log.Fields{"runId": e.id, "grace": r.KillGrace}).Warn("previous run did not exit within its grace, killing it")
+			if err := killProcessGroup(e.process); err != nil {
+				r.contextLogger.Error("failed to kill previous run", err)
+			}
+		}
+		r.lock.Unlock()
+	}
+	<-e.finished
+}
+
+// setProcess remembers the process of an execution, so it can be replaced
+func (r *Runnable) setProcess(e *execution, process *os.Process) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	e.process = process
+}
+
+// release marks a run as finished, handing its place over to the first queued run if there is one
+func (r *Runnable) release(e *execution) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	e.process = nil
+	close(e.finished)
+	if len(r.queue) > 0 {
+		close(r.queue[0])
+		r.queue = r.queue[1:]
+		return
+	}
+	r.runs--
+}
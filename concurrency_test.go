@@ -0,0 +1,110 @@
+package crontinuous
+
+import (
+	"bufio"
+	"os/exec"
+	"runtime"
+	"testing"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+func TestConcurrencyQueue(t *testing.T) {
+	previous := *queueSize
+	defer func() { *queueSize = previous }()
+	*queueSize = 2
+	job := &Runnable{ID: "queue", Concurrency: ConcurrencyQueue, contextLogger: log.WithField("id", "queue")}
+
+	first, ok := job.acquire()
+	if !ok {
+		t.Fatal("expected the first run to start")
+	}
+	started := make(chan int, 2)
+	for i := 1; i <= 2; i++ {
+		go func(i int) {
+			e, ok := job.acquire()
+			if !ok || !e.queued {
+				t.Errorf("expected run %d to be queued", i)
+				return
+			}
+			started <- i
+			job.release(e)
+		}(i)
+		// let the run take its place in the queue
+		for waiting := 0; waiting < i; {
+			time.Sleep(time.Millisecond)
+			job.lock.Lock()
+			waiting = len(job.queue)
+			job.lock.Unlock()
+		}
+	}
+	if _, ok := job.acquire(); ok {
+		t.Error("expected a run beyond the queue size to be skipped")
+	}
+
+	job.release(first)
+	for i := 1; i <= 2; i++ {
+		select {
+		case n := <-started:
+			if n != i {
+				t.Errorf("expected run %d to start, got %d", i, n)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("expected the queued runs to start")
+		}
+	}
+	time.Sleep(10 * time.Millisecond)
+	job.lock.Lock()
+	defer job.lock.Unlock()
+	if job.runs != 0 || len(job.queue) != 0 {
+		t.Errorf("expected no runs left, got %d active and %d queued", job.runs, len(job.queue))
+	}
+}
+
+func TestConcurrencyReplace(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a shell and signals")
+	}
+	job := &Runnable{ID: "replace", Concurrency: ConcurrencyReplace, KillGrace: 200 * time.Millisecond, contextLogger: log.WithField("id", "replace")}
+	replace := func(script string) time.Duration {
+		e, ok := job.acquire()
+		if !ok {
+			t.Fatal("expected the run to start")
+		}
+		cmd := exec.Command("sh", "-c", script+"; echo started; sleep 5")
+		prepareProcess(cmd, nil)
+		stdout, err := cmd.StdoutPipe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := cmd.Start(); err != nil {
+			t.Fatal(err)
+		}
+		// signals sent before the shell set its traps would not be ignored
+		if _, err := bufio.NewReader(stdout).ReadString('\n'); err != nil {
+			t.Fatal(err)
+		}
+		job.setProcess(e, cmd.Process)
+		go func() {
+			cmd.Wait()
+			job.release(e)
+		}()
+		start := time.Now()
+		next, ok := job.acquire()
+		if !ok {
+			t.Fatal("expected the replacing run to start")
+		}
+		job.release(next)
+		return time.Since(start)
+	}
+
+	// a run exiting on SIGTERM is replaced right away
+	if took := replace("true"); took >= job.KillGrace {
+		t.Errorf("expected the run to exit on SIGTERM, took %s", took)
+	}
+	// a run ignoring it is killed after the grace
+	if took := replace("trap '' TERM"); took < job.KillGrace || took > 2*time.Second {
+		t.Errorf("expected the run to be killed after its grace, took %s", took)
+	}
+}
@@ -0,0 +1,279 @@
+package crontinuous
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v2"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Struct
+// --------------------------------------------------------------------------------------------
+
+// jobConfig is the structured definition of jobs, as alternative to a crontab
+type jobConfig struct {
+	Jobs []jobDefinition `yaml:"jobs" toml:"jobs"`
+}
+
+// jobDefinition declares a job with what would be annotations in a crontab
+type jobDefinition struct {
+	Name          string            `yaml:"name" toml:"name"`
+	Schedule      string            `yaml:"schedule" toml:"schedule"`
+	Command       string            `yaml:"command" toml:"command"`
+	Args          []string          `yaml:"args" toml:"args"`
+	Env           map[string]string `yaml:"env" toml:"env"`
+	Shell         string            `yaml:"shell" toml:"shell"`
+	User          string            `yaml:"user" toml:"user"`
+	Group         string            `yaml:"group" toml:"group"`
+	Timezone      string            `yaml:"timezone" toml:"timezone"`
+	Concurrency   string            `yaml:"concurrency" toml:"concurrency"`
+	DST           string            `yaml:"dst" toml:"dst"`
+	ExitCodes     string            `yaml:"exitCodes" toml:"exitCodes"`
+	Template      *bool             `yaml:"template" toml:"template"`
+	Limits        string            `yaml:"limits" toml:"limits"`
+	Nice          *int              `yaml:"nice" toml:"nice"`
+	IONice        string            `yaml:"ionice" toml:"ionice"`
+	Timeout       string            `yaml:"timeout" toml:"timeout"`
+	KillGrace     string            `yaml:"killGrace" toml:"killGrace"`
+	SLA           string            `yaml:"sla" toml:"sla"`
+	Retries       *int              `yaml:"retries" toml:"retries"`
+	RetryDelay    string            `yaml:"retryDelay" toml:"retryDelay"`
+	RetryBackoff  string            `yaml:"retryBackoff" toml:"retryBackoff"`
+	CatchUp       string            `yaml:"catchUp" toml:"catchUp"`
+	Deadman       string            `yaml:"deadman" toml:"deadman"`
+	Lock          *bool             `yaml:"lock" toml:"lock"`
+	Breaker       *int              `yaml:"circuitBreaker" toml:"circuitBreaker"`
+	Cooldown      string            `yaml:"circuitCooldown" toml:"circuitCooldown"`
+	SkipHolidays  *bool             `yaml:"skipHolidays" toml:"skipHolidays"`
+	BusinessDays  *bool             `yaml:"businessDaysOnly" toml:"businessDaysOnly"`
+	Disabled      bool              `yaml:"disabled" toml:"disabled"`
+	Pre           string            `yaml:"pre" toml:"pre"`
+	Post          string            `yaml:"post" toml:"post"`
+	Tags          []string          `yaml:"tags" toml:"tags"`
+	Secrets       map[string]string `yaml:"secrets" toml:"secrets"`
+	After         []string          `yaml:"after" toml:"after"`
+	AfterWindow   string            `yaml:"afterWindow" toml:"afterWindow"`
+	Image         string            `yaml:"image" toml:"image"`
+	Pull          string            `yaml:"pull" toml:"pull"`
+	Kubernetes    string            `yaml:"kubernetes" toml:"kubernetes"`
+	Executor      string            `yaml:"executor" toml:"executor"`
+	HTTP          string            `yaml:"http" toml:"http"`
+	HTTPHeaders   map[string]string `yaml:"httpHeaders" toml:"httpHeaders"`
+	HTTPBody      string            `yaml:"httpBody" toml:"httpBody"`
+	HTTPStatus    string            `yaml:"httpStatus" toml:"httpStatus"`
+	Publish       string            `yaml:"publish" toml:"publish"`
+	Payload       string            `yaml:"payload" toml:"payload"`
+	Trigger       string            `yaml:"trigger" toml:"trigger"`
+	Debounce      string            `yaml:"debounce" toml:"debounce"`
+	Notifications struct {
+		Slack   string `yaml:"slack" toml:"slack"`
+		Webhook string `yaml:"webhook" toml:"webhook"`
+		Mailto  string `yaml:"mailto" toml:"mailto"`
+		Ping    string `yaml:"ping" toml:"ping"`
+	} `yaml:"notifications" toml:"notifications"`
+}
+
+// --------------------------------------------------------------------------------------------
+// ~ Private methods
+// --------------------------------------------------------------------------------------------
+
+// isJobConfig reports whether a file holds structured job definitions rather than a crontab
+func isJobConfig(filename string) bool {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".yaml", ".yml", ".toml":
+		return true
+	}
+	return false
+}
+
+// parseJobConfig parses the jobs of a yaml or toml file, collecting every problem on the way
+func parseJobConfig(filename string) ([]*Runnable, []error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, []error{err}
+	}
+	config := jobConfig{}
+	if strings.ToLower(filepath.Ext(filename)) == ".toml" {
+		err = toml.Unmarshal(data, &config)
+	} else {
+		err = yaml.UnmarshalStrict(data, &config)
+	}
+	if err != nil {
+		return nil, []error{fmt.Errorf("%s: %s", filename, err)}
+	}
+
+	jobs := []*Runnable{}
+	errs := []error{}
+	for i, definition := range config.Jobs {
+		r, err := definition.runnable()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: job %d: %s", filename, i+1, err))
+			continue
+		}
+		r.setFile(filename)
+		jobs = append(jobs, r)
+	}
+	return jobs, errs
+}
+
+// runnable creates the job, its settings are applied like the annotations of a crontab job
+func (d *jobDefinition) runnable() (*Runnable, error) {
+	// http and publish jobs send the request or message instead of running a command
+	command, args := d.Command, shellQuote(d.Args)
+	if d.HTTP != "" || d.Publish != "" {
+		if d.Command != "" || len(d.Args) > 0 {
+			return nil, fmt.Errorf("http and publish jobs take no command")
+		}
+		requests := map[string]string{}
+		if d.HTTP != "" {
+			requests["http"] = d.HTTP
+		} else {
+			requests["publish"] = d.Publish
+		}
+		var err error
+		if command, args, err = annotatedCommand(requests); err != nil {
+			return nil, err
+		}
+	} else if d.Command == "" {
+		return nil, fmt.Errorf("missing command")
+	}
+	if d.Schedule == "" {
+		return nil, fmt.Errorf("missing schedule")
+	}
+	// robfig/cron expects a leading seconds field
+	schedule := strings.Join(strings.Fields(d.Schedule), " ")
+	if !strings.HasPrefix(schedule, "@") {
+		schedule = withSecondsField(strings.Fields(schedule))
+	}
+
+	r := createRunnable(command, args, schedule)
+	r.argv = d.Args
+	r.User = *runUser
+	r.Group = *runGroup
+	r.Mailto = d.Notifications.Mailto
+	r.PingURL = d.Notifications.Ping
+	for _, key := range sortedKeys(d.Env) {
+		r.Env = append(r.Env, key+"="+d.Env[key])
+	}
+
+	annotations := map[string]string{
+		"name":          d.Name,
+		"shell":         d.Shell,
+		"user":          d.User,
+		"group":         d.Group,
+		"timezone":      d.Timezone,
+		"concurrency":   d.Concurrency,
+		"dst":           d.DST,
+		"exit-codes":    d.ExitCodes,
+		"limits":        d.Limits,
+		"ionice":        d.IONice,
+		"timeout":       d.Timeout,
+		"kill-grace":    d.KillGrace,
+		"sla":           d.SLA,
+		"retry-delay":   d.RetryDelay,
+		"retry-backoff": d.RetryBackoff,
+		"catch-up":      d.CatchUp,
+		"deadman":       d.Deadman,
+		"pre":           d.Pre,
+		"post":          d.Post,
+		"tags":          strings.Join(d.Tags, ","),
+		"after":         strings.Join(d.After, ","),
+		"after-window":  d.AfterWindow,
+		"image":         d.Image,
+		"pull":          d.Pull,
+		"kubernetes":    d.Kubernetes,
+		"executor":      d.Executor,
+		"http":          d.HTTP,
+		"http-body":     d.HTTPBody,
+		"http-status":   d.HTTPStatus,
+		"publish":       d.Publish,
+		"payload":       d.Payload,
+		"trigger":       d.Trigger,
+		"debounce":      d.Debounce,
+		"slack":         d.Notifications.Slack,
+		"webhook":       d.Notifications.Webhook,
+		"ping":          d.Notifications.Ping,
+	}
+	if d.Retries != nil {
+		annotations["retries"] = strconv.Itoa(*d.Retries)
+	}
+	if d.Breaker != nil {
+		annotations["circuit-breaker"] = strconv.Itoa(*d.Breaker)
+	}
+	if d.Cooldown != "" {
+		annotations["circuit-cooldown"] = d.Cooldown
+	}
+	if d.Nice != nil {
+		annotations["nice"] = strconv.Itoa(*d.Nice)
+	}
+	secrets := []string{}
+	for _, name := range sortedKeys(d.Secrets) {
+		secrets = append(secrets, name+"="+d.Secrets[name])
+	}
+	if len(secrets) > 0 {
+		annotations["secret"] = strings.Join(secrets, " ")
+	}
+	if d.Template != nil {
+		annotations["template"] = strconv.FormatBool(*d.Template)
+	}
+	if d.Lock != nil {
+		annotations["lock"] = strconv.FormatBool(*d.Lock)
+	}
+	if d.SkipHolidays != nil {
+		annotations["skip-holidays"] = strconv.FormatBool(*d.SkipHolidays)
+	}
+	if d.BusinessDays != nil {
+		annotations["business-days-only"] = strconv.FormatBool(*d.BusinessDays)
+	}
+	if d.Disabled {
+		annotations["disabled"] = "true"
+	}
+	// unset settings keep their defaults
+	for key, value := range annotations {
+		if value == "" {
+			delete(annotations, key)
+		}
+	}
+	if err := r.applyAnnotations(annotations); err != nil {
+		return nil, fmt.Errorf("invalid setting: %s", err)
+	}
+	if len(d.HTTPHeaders) > 0 && d.HTTP == "" {
+		return nil, fmt.Errorf("invalid setting: httpHeaders need http")
+	}
+	for _, name := range sortedKeys(d.HTTPHeaders) {
+		r.httpRequest().Headers = append(r.httpRequest().Headers, name+": "+d.HTTPHeaders[name])
+	}
+	if err := r.checkTemplate(); err != nil {
+		return nil, fmt.Errorf("invalid command template: %s", err)
+	}
+	if err := r.resolveRunAs(); err != nil {
+		return nil, fmt.Errorf("unknown user or group: %s", err)
+	}
+
+	if schedule == "@reboot" || schedule == triggerSchedule {
+		return r, nil
+	}
+	if onFile, err := r.setOnFile(schedule); err != nil || onFile {
+		return r, err
+	}
+	parsedSchedule, err := r.parseSchedule(schedule)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse schedule %q: %s", schedule, err)
+	}
+	r.schedule = parsedSchedule
+	return r, nil
+}
+
+// shellQuote joins arguments to be passed through a shell unchanged
+func shellQuote(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = "'" + strings.Replace(arg, "'", `'\''`, -1) + "'"
+	}
+	return strings.Join(quoted, " ")
+}
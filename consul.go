@@ -0,0 +1,215 @@
+package crontinuous
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Variables
+// --------------------------------------------------------------------------------------------
+
+var sharding *consulSharding
+
+// --------------------------------------------------------------------------------------------
+// ~ Struct
+// --------------------------------------------------------------------------------------------
+
+// consulSharding splits the jobs between the crontinuous instances registered in consul
+type consulSharding struct {
+	addr    string
+	service string
+	id      string
+	ttl     time.Duration
+	client  *http.Client
+	members []string
+	lock    sync.RWMutex
+}
+
+// consulHealthEntry is the part of a /v1/health/service entry we are interested in
+type consulHealthEntry struct {
+	Service struct {
+		ID string
+	}
+}
+
+// --------------------------------------------------------------------------------------------
+// ~ Private methods
+// --------------------------------------------------------------------------------------------
+
+func newConsulSharding(addr string, service string, id string, ttl time.Duration) *consulSharding {
+	return &consulSharding{
+		addr:    strings.TrimRight(addr, "/"),
+		service: service,
+		id:      id,
+		ttl:     ttl,
+		// blocking queries wait up to five minutes
+		client:  &http.Client{Timeout: 6 * time.Minute},
+		members: []string{id},
+	}
+}
+
+// start registers this instance and keeps the members up to date
+func (s *consulSharding) start() error {
+	if err := s.register(); err != nil {
+		return err
+	}
+	go s.heartbeat()
+	go s.watchMembers()
+	return nil
+}
+
+// owns reports whether this instance runs the job, every instance owns all jobs without sharding
+func (s *consulSharding) owns(id string) bool {
+	if s == nil {
+		return true
+	}
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return shardOwner(id, s.members) == s.id
+}
+
+// shardOwner picks the member with the highest hash of member and job id (rendezvous hashing),
+// so only the jobs of a member joining or leaving move
+func shardOwner(id string, members []string) string {
+	var owner string
+	var highest uint64
+	for _, member := range members {
+		sum := sha1.Sum([]byte(member + "/" + id))
+		if weight := binary.BigEndian.Uint64(sum[:8]); owner == "" || weight > highest {
+			owner = member
+			highest = weight
+		}
+	}
+	return owner
+}
+
+// deregister removes this instance, so the others take over its jobs right away
+func (s *consulSharding) deregister() {
+	if s == nil {
+		return
+	}
+	if err := s.put("/v1/agent/service/deregister/"+s.id, nil); err != nil {
+		log.Warn("failed to deregister from consul", err)
+	}
+}
+
+func (s *consulSharding) register() error {
+	return s.put("/v1/agent/service/register", map[string]interface{}{
+		"ID":   s.id,
+		"Name": s.service,
+		"Check": map[string]interface{}{
+			"TTL":                            s.ttl.String(),
+			"DeregisterCriticalServiceAfter": "1m",
+		},
+	})
+}
+
+// heartbeat passes the ttl check of this instance
+func (s *consulSharding) heartbeat() {
+	for {
+		if err := s.put("/v1/agent/check/pass/service:"+s.id, nil); err != nil {
+			log.Warn("failed to pass consul check, registering again", err)
+			if err := s.register(); err != nil {
+				log.Warn("failed to register in consul", err)
+			}
+		}
+		time.Sleep(s.ttl / 3)
+	}
+}
+
+// watchMembers follows the healthy instances with blocking queries
+func (s *consulSharding) watchMembers() {
+	index := "0"
+	for {
+		members, nextIndex, err := s.healthyMembers(index)
+		if err != nil {
+			log.Warn("failed to query consul members", err)
+			time.Sleep(s.ttl / 3)
+			continue
+		}
+		index = nextIndex
+		s.setMembers(members)
+	}
+}
+
+func (s *consulSharding) setMembers(healthy []string) {
+	// keep our own jobs while we are not registered yet, e.g. after a consul restart, and count every
+	// instance once, even if consul lists its service on several nodes
+	seen := map[string]bool{}
+	members := []string{}
+	for _, member := range append(healthy, s.id) {
+		if !seen[member] {
+			seen[member] = true
+			members = append(members, member)
+		}
+	}
+	sort.Strings(members)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if strings.Join(members, ",") != strings.Join(s.members, ",") {
+		log.WithField("members", members).Info("consul members changed, rebalancing jobs")
+	}
+	s.members = members
+}
+
+func (s *consulSharding) healthyMembers(index string) ([]string, string, error) {
+	resp, err := s.client.Get(s.addr + "/v1/health/service/" + s.service + "?passing&index=" + index)
+	if err != nil {
+		return nil, index, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, index, fmt.Errorf("unexpected status %s from consul", resp.Status)
+	}
+	entries := []consulHealthEntry{}
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return nil, index, err
+	}
+	members := []string{}
+	for _, entry := range entries {
+		members = append(members, entry.Service.ID)
+	}
+	// a reset index must not make us busy loop
+	nextIndex := resp.Header.Get("X-Consul-Index")
+	if n, err := strconv.ParseUint(nextIndex, 10, 64); err != nil || n == 0 {
+		nextIndex = "0"
+		time.Sleep(s.ttl / 3)
+	}
+	return members, nextIndex, nil
+}
+
+func (s *consulSharding) put(path string, v interface{}) error {
+	payload := []byte{}
+	if v != nil {
+		var err error
+		if payload, err = json.Marshal(v); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequest("PUT", s.addr+path, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	resp, err := notifyClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s from consul", resp.Status)
+	}
+	return nil
+}
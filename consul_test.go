@@ -0,0 +1,25 @@
+package crontinuous
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestConsulShardingSetMembers(t *testing.T) {
+	tests := []struct {
+		healthy []string
+		want    []string
+	}{
+		{[]string{}, []string{"b"}},
+		{[]string{"c", "a"}, []string{"a", "b", "c"}},
+		{[]string{"b", "a", "b"}, []string{"a", "b"}},
+		{[]string{"a", "a", "c", "c"}, []string{"a", "b", "c"}},
+	}
+	for _, test := range tests {
+		s := newConsulSharding("http://127.0.0.1:8500", "crontinuous", "b", 0)
+		s.setMembers(test.healthy)
+		if !reflect.DeepEqual(s.members, test.want) {
+			t.Errorf("setMembers(%v) = %v, want %v", test.healthy, s.members, test.want)
+		}
+	}
+}
@@ -0,0 +1,716 @@
+package crontinuous
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/robfig/cron"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Constants
+// --------------------------------------------------------------------------------------------
+
+// maxIncludeDepth bounds nested includes, which would otherwise recurse forever on cycles
+const maxIncludeDepth = 10
+
+// --------------------------------------------------------------------------------------------
+// ~ Struct
+// --------------------------------------------------------------------------------------------
+
+// crontabParser parses a crontab line by line, collecting the annotations for the next job
+type crontabParser struct {
+	annotations map[string]string
+	variables   map[string]string
+	system      bool
+}
+
+// crontabPaths are the crontab files and directories to load
+type crontabPaths struct {
+	paths []string
+	// prefixes of the job ids of the crontabs, by index of their path
+	prefixes map[int]string
+	set      bool
+}
+
+// crontabError is a problem in a crontab line
+type crontabError struct {
+	File string
+	Line int
+	Err  error
+}
+
+// --------------------------------------------------------------------------------------------
+// ~ Public methods
+// --------------------------------------------------------------------------------------------
+
+func (e *crontabError) Error() string {
+	return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Err)
+}
+
+// --------------------------------------------------------------------------------------------
+// ~ Private methods
+// --------------------------------------------------------------------------------------------
+
+// pathsFlag defines a flag taking paths, which may be repeated or comma separated
+func pathsFlag(name string, value string, usage string) *crontabPaths {
+	p := &crontabPaths{paths: []string{value}}
+	CommandLine.Var(p, name, usage)
+	return p
+}
+
+// String implements flag.Value
+func (p *crontabPaths) String() string {
+	if p == nil {
+		return ""
+	}
+	return strings.Join(p.paths, ",")
+}
+
+// Set implements flag.Value, the first value replaces the default. A crontab may be given as
+// <prefix>=<crontab> to prefix the ids of its jobs.
+func (p *crontabPaths) Set(value string) error {
+	if !p.set {
+		p.paths = nil
+		p.prefixes = map[int]string{}
+		p.set = true
+	}
+	for _, path := range strings.Split(value, ",") {
+		if path = strings.TrimSpace(path); path == "" {
+			continue
+		}
+		if matches := sourcePrefixRegexp.FindStringSubmatch(path); matches != nil {
+			p.prefixes[len(p.paths)] = matches[1]
+			path = matches[2]
+		}
+		p.paths = append(p.paths, path)
+	}
+	return nil
+}
+
+// crontabFiles returns the crontabs themselves or, for directories, the crontabs in them
+func crontabFiles(paths []string) ([]string, error) {
+	filenames := []string{}
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			filenames = append(filenames, path)
+			continue
+		}
+
+		infos, err := ioutil.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, info := range infos {
+			// skip hidden files and editor backups like cron does
+			if info.IsDir() || strings.HasPrefix(info.Name(), ".") || strings.HasSuffix(info.Name(), "~") {
+				continue
+			}
+			filenames = append(filenames, filepath.Join(path, info.Name()))
+		}
+	}
+	return filenames, nil
+}
+
+// crontabFingerprint hashes the names and contents of the crontab files and their includes, following symlinks
+func crontabFingerprint() string {
+	return fingerprint(crontabs.paths)
+}
+
+// fingerprint hashes the files of the given crontabs and their includes
+func fingerprint(paths []string) string {
+	h := sha1.New()
+	for _, filename := range sourceFiles(paths) {
+		data, err := ioutil.ReadFile(filename)
+		if err != nil {
+			continue
+		}
+		fmt.Fprintf(h, "%s\x00%d\x00", filename, len(data))
+		h.Write(data)
+	}
+	// a new revision is applied even if the crontab did not change, so runs record it
+	for _, remote := range remoteCrontabs {
+		if containsString(paths, remote.local()) {
+			fmt.Fprintf(h, "%s\x00", remote.revision())
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// crontabSources returns the crontab files together with all files they include
+func crontabSources() []string {
+	return sourceFiles(crontabs.paths)
+}
+
+// sourceFiles returns the files of the given crontabs together with all files they include, missing
+// crontabs are skipped
+func sourceFiles(paths []string) []string {
+	sources := []string{}
+	for _, path := range paths {
+		filenames, err := crontabFiles([]string{path})
+		if err != nil {
+			continue
+		}
+		for _, filename := range filenames {
+			sources = append(sources, filename)
+			sources = append(sources, includedFiles(filename, 0)...)
+		}
+	}
+	return sources
+}
+
+// crontabWatchDirs returns the directories to watch for changes of the crontab and its includes,
+// including those where files matching an include pattern may appear
+func crontabWatchDirs() []string {
+	dirs := []string{}
+	seen := map[string]bool{}
+	add := func(dir string) {
+		if !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+	for _, path := range crontabs.paths {
+		// the parent of a crontab directory tells when the directory is replaced
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			add(path)
+		}
+		add(filepath.Dir(path))
+		// crontabs below a symlink are replaced by swapping it, like the checkouts of git crontabs
+		for dir := filepath.Dir(path); dir != filepath.Dir(dir); dir = filepath.Dir(dir) {
+			if info, err := os.Lstat(dir); err == nil && info.Mode()&os.ModeSymlink != 0 {
+				add(filepath.Dir(dir))
+			}
+		}
+	}
+	for _, filename := range crontabSources() {
+		add(filepath.Dir(filename))
+		for _, pattern := range includePatterns(filename) {
+			add(filepath.Dir(pattern))
+		}
+	}
+	return dirs
+}
+
+// includePatterns returns the paths and globs a crontab includes, relative ones resolved against its directory
+func includePatterns(filename string) []string {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+	patterns := []string{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if matches := includeRegexp.FindStringSubmatch(strings.TrimSpace(scanner.Text())); matches != nil {
+			patterns = append(patterns, includePattern(filename, matches[1]))
+		}
+	}
+	return patterns
+}
+
+func includePattern(filename string, pattern string) string {
+	pattern = strings.TrimSpace(pattern)
+	if !filepath.IsAbs(pattern) {
+		pattern = filepath.Join(filepath.Dir(filename), pattern)
+	}
+	return pattern
+}
+
+// includedFiles returns the files a crontab includes, recursively
+func includedFiles(filename string, depth int) []string {
+	if depth >= maxIncludeDepth {
+		return nil
+	}
+	files := []string{}
+	for _, pattern := range includePatterns(filename) {
+		matches, _ := expandInclude(pattern)
+		for _, match := range matches {
+			files = append(files, match)
+			files = append(files, includedFiles(match, depth+1)...)
+		}
+	}
+	return files
+}
+
+// expandInclude returns the files matching an include, a plain path has to exist
+func expandInclude(pattern string) ([]string, error) {
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+	if len(matches) == 0 && !strings.ContainsAny(pattern, "*?[") {
+		return nil, fmt.Errorf("included file %s does not exist", pattern)
+	}
+	// like in crontab directories, editor backups are skipped
+	files := []string{}
+	for _, match := range matches {
+		if info, err := os.Stat(match); err == nil && !info.IsDir() && !strings.HasSuffix(match, "~") {
+			files = append(files, match)
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// parseCrontab parses all jobs of a crontab or job config, collecting every problem on the way
+func parseCrontab(filename string) ([]*Runnable, []error) {
+	jobs, errs := parseCrontabFile(filename, nil)
+	setRemoteOrigin(jobs)
+	return jobs, errs
+}
+
+// parseCrontabFile parses a crontab included through the given chain of crontabs
+func parseCrontabFile(filename string, including []string) ([]*Runnable, []error) {
+	if isJobConfig(filename) {
+		return parseJobConfig(filename)
+	}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, []error{err}
+	}
+	defer file.Close()
+	return parseCrontabReader(filename, file, including)
+}
+
+// parseCrontabReader parses a crontab named filename, includes are resolved against its directory
+func parseCrontabReader(filename string, reader io.Reader, including []string) ([]*Runnable, []error) {
+	jobs := []*Runnable{}
+	errs := []error{}
+	parser := newCrontabParser(*systemCrontab)
+	scanner := bufio.NewScanner(reader)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		// #include parses other crontabs in place, each with its own variables
+		if matches := includeRegexp.FindStringSubmatch(strings.TrimSpace(scanner.Text())); matches != nil {
+			included, err := expandInclude(includePattern(filename, matches[1]))
+			if err != nil {
+				errs = append(errs, &crontabError{File: filename, Line: lineNumber, Err: err})
+				continue
+			}
+			chain := append(append([]string{}, including...), filepath.Clean(filename))
+			for _, includedFile := range included {
+				if containsString(chain, filepath.Clean(includedFile)) {
+					errs = append(errs, &crontabError{File: filename, Line: lineNumber, Err: fmt.Errorf("%s includes itself", includedFile)})
+					continue
+				}
+				includedJobs, includedErrs := parseCrontabFile(includedFile, chain)
+				jobs = append(jobs, includedJobs...)
+				errs = append(errs, includedErrs...)
+			}
+			continue
+		}
+
+		r, err := parser.parseLine(scanner.Text())
+		if err != nil {
+			errs = append(errs, &crontabError{File: filename, Line: lineNumber, Err: err})
+			continue
+		}
+		if r != nil {
+			r.setFile(filename)
+			jobs = append(jobs, r)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		errs = append(errs, err)
+	}
+	return jobs, errs
+}
+
+// loadCrontab returns the jobs of a crontab selected by their tags
+func loadCrontab(filename string) ([]*Runnable, []error) {
+	parsed, errs := parseCrontab(filename)
+	jobs := []*Runnable{}
+	for _, r := range parsed {
+		if !r.selectedByTags() {
+			r.contextLogger.Debug("job is not selected by its tags, skipping it")
+			continue
+		}
+		jobs = append(jobs, r)
+	}
+	for _, err := range errs {
+		log.WithField("file", filename).Error(err)
+	}
+	return jobs, errs
+}
+
+// scheduleJob schedules a job with the scheduler,
+// @reboot jobs are run and missed runs caught up if crontinuous just started
+func scheduleJob(scheduler *cron.Cron, r *Runnable, reboot bool) {
+	// disabled jobs are kept to be listed, but neither scheduled nor run on start
+	if r.Disabled {
+		return
+	}
+	if r.schedule == nil {
+		if reboot && r.Schedule == "@reboot" {
+			go r.Run()
+		}
+		return
+	}
+	scheduler.Schedule(r.schedule, r)
+	if reboot {
+		r.catchUp()
+	}
+}
+
+func newCrontabParser(system bool) *crontabParser {
+	return &crontabParser{
+		annotations: map[string]string{},
+		variables:   map[string]string{},
+		system:      system,
+	}
+}
+
+// parseLine parses the next line of a crontab, returning a runnable if the line defines a job
+func (p *crontabParser) parseLine(line string) (*Runnable, error) {
+	line = strings.TrimSpace(line)
+
+	// an empty line ends a block of annotations
+	if len(line) <= 0 {
+		p.annotations = map[string]string{}
+		return nil, nil
+	}
+
+	// # key: value annotates the next job, a bare # disabled, # skip-holidays or # business-days-only switches it on
+	if strings.HasPrefix(line, "#") {
+		if matches := annotationRegexp.FindStringSubmatch(line); matches != nil {
+			p.annotations[strings.ToLower(matches[1])] = strings.TrimSpace(matches[2])
+		} else if word := strings.ToLower(strings.TrimSpace(line[1:])); word == "disabled" || word == "skip-holidays" || word == "business-days-only" {
+			p.annotations[word] = "true"
+		}
+		return nil, nil
+	}
+
+	// KEY=value sets a variable for all following jobs
+	if matches := variableRegexp.FindStringSubmatch(line); matches != nil {
+		p.variables[matches[1]] = strings.Trim(strings.TrimSpace(matches[2]), `"'`)
+		return nil, nil
+	}
+
+	annotations := p.annotations
+	p.annotations = map[string]string{}
+
+	// # ┌───────────── min (0 - 59)
+	// # │ ┌────────────── hour (0 - 23)
+	// # │ │ ┌─────────────── day of month (1 - 31)
+	// # │ │ │ ┌──────────────── month (1 - 12)
+	// # │ │ │ │ ┌───────────────── day of week (0 - 6) (0 to 6 are Sunday to Saturday, or use names; 7 is Sunday, the same as 0)
+	// # │ │ │ │ │
+	// # │ │ │ │ │
+	// # * * * * *  command to execute
+
+	// shortcuts like @daily take one field, @every takes its duration as second field
+	var scheduleFields = 5
+	if fields := strings.Fields(line); fields[0] == "@every" || fields[0] == onFileSchedule {
+		scheduleFields = 2
+	} else if strings.HasPrefix(line, "@") {
+		scheduleFields = 1
+	} else if p.hasSecondsField(line) {
+		scheduleFields = 6
+	}
+	// a year may follow the day of week field
+	if scheduleFields >= 5 && p.hasYearField(line, scheduleFields) {
+		scheduleFields++
+	}
+
+	// system crontabs name the user to run the job as between schedule and command
+	var commandField = scheduleFields
+	if p.system {
+		commandField++
+	}
+
+	// the arguments are kept as they are, including quotes and repeated spaces, for the shell to split them
+	substrings, rest := splitFields(line, commandField)
+	var command, args string
+	var err error
+	if command, args, err = annotatedCommand(annotations); err != nil {
+		return nil, fmt.Errorf("invalid annotation: %s", err)
+	} else if command != "" {
+		// http and publish jobs send the request or message of their annotation instead of running a command
+		if len(substrings) < commandField || rest != "" {
+			return nil, errors.New("http and publish jobs take a schedule only")
+		}
+	} else {
+		if len(substrings) < commandField || rest == "" {
+			if p.system {
+				return nil, errors.New("missing command, system crontabs take the user before the command")
+			}
+			return nil, errors.New("missing command")
+		}
+		if command, args, err = splitCommand(rest); err != nil {
+			return nil, err
+		}
+	}
+
+	// robfig/cron expects a leading seconds field
+	var schedule = strings.Join(substrings[:scheduleFields], " ")
+	if scheduleFields >= 5 {
+		schedule = withSecondsField(substrings[:scheduleFields])
+	}
+
+	r := createRunnable(command, args, schedule)
+	r.User = *runUser
+	r.Group = *runGroup
+	if p.system {
+		r.User = substrings[scheduleFields]
+	}
+	for _, key := range sortedKeys(p.variables) {
+		r.Env = append(r.Env, key+"="+p.variables[key])
+	}
+	r.Mailto = p.variables["MAILTO"]
+	r.PingURL = p.variables["PING_URL"]
+	if timezone := p.variables["CRON_TZ"]; timezone != "" {
+		if err := r.setLocation(timezone); err != nil {
+			return nil, fmt.Errorf("invalid CRON_TZ: %s", err)
+		}
+	}
+	if err := r.applyAnnotations(annotations); err != nil {
+		return nil, fmt.Errorf("invalid annotation: %s", err)
+	}
+	if err := r.checkTemplate(); err != nil {
+		return nil, fmt.Errorf("invalid command template: %s", err)
+	}
+	if err := r.resolveRunAs(); err != nil {
+		return nil, fmt.Errorf("unknown user or group: %s", err)
+	}
+
+	// @reboot jobs only run once when crontinuous starts and are not scheduled, @trigger jobs only on requests
+	if schedule == "@reboot" || schedule == triggerSchedule {
+		return r, nil
+	}
+	if onFile, err := r.setOnFile(schedule); err != nil || onFile {
+		return r, err
+	}
+
+	parsedSchedule, err := r.parseSchedule(schedule)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse schedule %q: %s", schedule, err)
+	}
+	r.schedule = parsedSchedule
+	return r, nil
+}
+
+// annotatedCommand returns the command and arguments given by an http or publish annotation, none
+// for jobs running the command of their line
+func annotatedCommand(annotations map[string]string) (string, string, error) {
+	if request, ok := annotations["http"]; ok {
+		return splitHTTPRequest(request)
+	}
+	if target, ok := annotations["publish"]; ok {
+		broker, err := splitPublishURL(target)
+		return broker, target, err
+	}
+	return "", "", nil
+}
+
+// parseEnv parses space separated KEY=value pairs, values may be quoted to contain spaces
+func parseEnv(value string) ([]string, error) {
+	fields, err := splitQuoted(value)
+	if err != nil {
+		return nil, err
+	}
+	env := []string{}
+	for _, field := range fields {
+		parts := strings.SplitN(field, "=", 2)
+		if len(parts) != 2 || !variableRegexp.MatchString(field) {
+			return nil, fmt.Errorf("invalid variable %q, expected KEY=value", field)
+		}
+		env = append(env, field)
+	}
+	return env, nil
+}
+
+// splitQuoted splits words like a shell does: at spaces outside of single or double quotes,
+// removing the quotes and backslashes escaping the next character, which inside double quotes
+// only escape ", \, $ and `. Backslashes are kept as they are on windows.
+func splitQuoted(value string) ([]string, error) {
+	fields := []string{}
+	field := []rune{}
+	inField := false
+	escaped := false
+	var quote rune
+	for _, c := range value {
+		switch {
+		case escaped:
+			if quote == '"' && !strings.ContainsRune("\"\\$`", c) {
+				field = append(field, '\\')
+			}
+			field = append(field, c)
+			escaped = false
+		case c == '\\' && quote != '\'' && backslashEscapes:
+			escaped = true
+			inField = true
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != 0:
+			field = append(field, c)
+		case c == '\'' || c == '"':
+			quote = c
+			inField = true
+		case c == ' ' || c == '\t':
+			if inField {
+				fields = append(fields, string(field))
+				field = field[:0]
+				inField = false
+			}
+		default:
+			field = append(field, c)
+			inField = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote in %q", value)
+	}
+	if escaped {
+		field = append(field, '\\')
+	}
+	if inField {
+		fields = append(fields, string(field))
+	}
+	return fields, nil
+}
+
+// backslashEscapes is false on windows, where backslashes separate the directories of paths
+const backslashEscapes = runtime.GOOS != "windows"
+
+// splitFields splits the first n fields separated by spaces or tabs off a line and returns them with the rest of the line
+func splitFields(line string, n int) ([]string, string) {
+	fields := []string{}
+	rest := strings.TrimLeft(line, " \t")
+	for len(fields) < n && rest != "" {
+		end := strings.IndexAny(rest, " \t")
+		if end < 0 {
+			end = len(rest)
+		}
+		fields = append(fields, rest[:end])
+		rest = strings.TrimLeft(rest[end:], " \t")
+	}
+	return fields, rest
+}
+
+// splitCommand splits the command, which may be quoted, off its arguments. The arguments are returned as they are,
+// an unterminated quote in them fails like in the command. Unlike cron, % is not special.
+func splitCommand(line string) (string, string, error) {
+	end := len(line)
+	escaped := false
+	var quote rune
+	for i, c := range line {
+		switch {
+		case escaped:
+			escaped = false
+		case c == '\\' && quote != '\'' && backslashEscapes:
+			escaped = true
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == ' ' || c == '\t':
+			end = i
+		}
+		if end < len(line) {
+			break
+		}
+	}
+	words, err := splitQuoted(line[:end])
+	if err != nil {
+		return "", "", err
+	}
+	if len(words) != 1 || words[0] == "" {
+		return "", "", errors.New("missing command")
+	}
+	args := strings.TrimLeft(line[end:], " \t")
+	if _, err := splitQuoted(args); err != nil {
+		return "", "", err
+	}
+	return words[0], args, nil
+}
+
+// commandLine joins a command and its arguments for a shell, quoting the command if it contains spaces or quotes
+func commandLine(command string, args string) string {
+	if strings.ContainsAny(command, " \t'\"\\$`") {
+		if strings.ContainsAny(command, "\"$`") || (backslashEscapes && strings.Contains(command, "\\")) {
+			command = "'" + strings.Replace(command, "'", `'\''`, -1) + "'"
+		} else {
+			// double quotes are understood by cmd.exe as well
+			command = `"` + command + `"`
+		}
+	}
+	if args == "" {
+		return command
+	}
+	return command + " " + args
+}
+
+// duplicateIDs reports jobs sharing an id, which would share their state, history and logs as well
+func duplicateIDs(jobs []*Runnable) []error {
+	errs := []error{}
+	files := map[string]string{}
+	for _, r := range jobs {
+		if file, ok := files[r.ID]; ok {
+			errs = append(errs, fmt.Errorf("%s: job %s is defined in %s already, give it another name", r.File, r.ID, file))
+			continue
+		}
+		files[r.ID] = r.File
+	}
+	return errs
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// hasSecondsField reports whether a line starts with six schedule fields, seconds first.
+// With -seconds this is always the case, otherwise it is detected by a sixth field that
+// looks like a schedule field rather than a command.
+func (p *crontabParser) hasSecondsField(line string) bool {
+	if *secondsField {
+		return true
+	}
+	// in system crontabs a numeric user id would look like a schedule field
+	if p.system {
+		return false
+	}
+	fields := strings.Fields(line)
+	return len(fields) > 6 && scheduleFieldRegexp.MatchString(fields[5]) && !yearFieldRegexp.MatchString(fields[5])
+}
+
+// hasYearField reports whether a year field follows the first n schedule fields of a line, which
+// is not detected in system crontabs where it would look like a numeric user id
+func (p *crontabParser) hasYearField(line string, n int) bool {
+	if p.system {
+		return false
+	}
+	fields := strings.Fields(line)
+	return len(fields) > n+1 && yearFieldRegexp.MatchString(fields[n])
+}
@@ -0,0 +1,202 @@
+package crontinuous
+
+import (
+	"os/user"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestSplitQuoted(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    []string
+		wantErr bool
+	}{
+		{"a  b\tc", []string{"a", "b", "c"}, false},
+		{`'a b' "c  d"`, []string{"a b", "c  d"}, false},
+		{`"it's" 'say "hi"'`, []string{"it's", `say "hi"`}, false},
+		{`"a 'b c' d"`, []string{"a 'b c' d"}, false},
+		{`pre'a b'"c d"post`, []string{"prea bc dpost"}, false},
+		{`'' ""`, []string{"", ""}, false},
+		{`"a \"b\" c"`, []string{`a "b" c`}, false},
+		{`\"a b\"`, []string{`"a`, `b"`}, false},
+		{`'a\' b`, []string{`a\`, "b"}, false},
+		{`"a\n\$b"`, []string{`a\n$b`}, false},
+		{`a\ b c\`, []string{"a b", `c\`}, false},
+		{"date +%Y-%m-%d", []string{"date", "+%Y-%m-%d"}, false},
+		{`printf 50\%`, []string{"printf", "50%"}, false},
+		{`'unterminated`, nil, true},
+		{`a "b c`, nil, true},
+		{`"escaped quote\"`, nil, true},
+	}
+	for _, test := range tests {
+		if !backslashEscapes && strings.Contains(test.value, `\`) {
+			continue
+		}
+		got, err := splitQuoted(test.value)
+		if (err != nil) != test.wantErr || !test.wantErr && !reflect.DeepEqual(got, test.want) {
+			t.Errorf("splitQuoted(%s) = %q, %v, want %q", test.value, got, err, test.want)
+		}
+	}
+}
+
+func TestSplitCommand(t *testing.T) {
+	tests := []struct {
+		line        string
+		wantCommand string
+		wantArgs    string
+		wantErr     bool
+	}{
+		{"/bin/echo hello  world", "/bin/echo", "hello  world", false},
+		{"/bin/true", "/bin/true", "", false},
+		{`"/opt/my tools/run" --all`, "/opt/my tools/run", "--all", false},
+		{`'/opt/it'"'"'s/run' -v`, "/opt/it's/run", "-v", false},
+		{`/opt/my\ tools/run 'a  b'   "c"`, "/opt/my tools/run", `'a  b'   "c"`, false},
+		{`/bin/echo "say 'hi'" \"quoted\"`, "/bin/echo", `"say 'hi'" \"quoted\"`, false},
+		{"date +%Y-%m-%d", "date", "+%Y-%m-%d", false},
+		{`printf 50\%`, "printf", `50\%`, false},
+		{`"/bin/echo hello`, "", "", true},
+		{`/bin/echo 'unterminated`, "", "", true},
+		{`/bin/echo "a \"b"`, "/bin/echo", `"a \"b"`, false},
+		{`/bin/echo "a \"b`, "", "", true},
+		{`""`, "", "", true},
+	}
+	for _, test := range tests {
+		if !backslashEscapes && strings.Contains(test.line, `\`) {
+			continue
+		}
+		command, args, err := splitCommand(test.line)
+		if (err != nil) != test.wantErr || command != test.wantCommand || args != test.wantArgs {
+			t.Errorf("splitCommand(%s) = %q, %q, %v, want %q, %q", test.line, command, args, err, test.wantCommand, test.wantArgs)
+		}
+	}
+}
+
+func TestScheduleFields(t *testing.T) {
+	tests := []struct {
+		line         string
+		wantSchedule string
+		seconds      bool
+		year         bool
+		wantCommand  string
+		wantArgs     string
+	}{
+		// five fields
+		{"*/5 * * * * /bin/poll", "0 */5 * * * *", false, false, "/bin/poll", ""},
+		{"0 12 * * mon-fri /bin/report --daily", "0 0 12 * * mon-fri", false, false, "/bin/report", "--daily"},
+		// six fields, seconds first
+		{"*/10 * * * * * /bin/poll", "*/10 * * * * *", true, false, "/bin/poll", ""},
+		{"0 0 12 * * mon-fri /bin/report", "0 0 12 * * mon-fri", true, false, "/bin/report", ""},
+		// six fields, a year last
+		{"0 0 * * * 2025 /bin/report", "0 0 0 * * * 2025", false, true, "/bin/report", ""},
+		{"0 0 1 1 * 2025-2027,2030 /bin/report", "0 0 0 1 1 * 2025-2027,2030", false, true, "/bin/report", ""},
+		// seven fields
+		{"0 0 0 1 1 * 2025/2 /bin/report", "0 0 0 1 1 * 2025/2", true, true, "/bin/report", ""},
+		// commands looking like a field are commands when nothing follows them
+		{"0 12 * * * 5", "0 0 12 * * *", false, false, "5", ""},
+		{"0 12 * * * 2025", "0 0 12 * * *", false, false, "2025", ""},
+		{"0 12 * * * 7z a backup.7z", "0 0 12 * * *", false, false, "7z", "a backup.7z"},
+		// but the sixth field of longer lines is read as seconds or year
+		{"0 12 * * * 5 minutes", "0 12 * * * 5", true, false, "minutes", ""},
+		{"0 12 * * * 2025 report", "0 0 12 * * * 2025", false, true, "report", ""},
+	}
+	for _, test := range tests {
+		p := newCrontabParser(false)
+		seconds := p.hasSecondsField(test.line)
+		n := 5
+		if seconds {
+			n = 6
+		}
+		if year := p.hasYearField(test.line, n); seconds != test.seconds || year != test.year {
+			t.Errorf("%q: expected seconds %t and year %t, got %t and %t", test.line, test.seconds, test.year, seconds, year)
+		}
+		jobs, errs := parseCrontabReader("fields", strings.NewReader(test.line+"\n"), nil)
+		if len(errs) != 0 || len(jobs) != 1 {
+			t.Errorf("%q: expected a job, got %v", test.line, errs)
+			continue
+		}
+		if r := jobs[0]; r.Schedule != test.wantSchedule || r.Command != test.wantCommand || r.Args != test.wantArgs {
+			t.Errorf("%q: expected schedule %q, command %q and args %q, got %q, %q and %q", test.line, test.wantSchedule, test.wantCommand, test.wantArgs, r.Schedule, r.Command, r.Args)
+		}
+	}
+}
+
+func TestSecondsFlag(t *testing.T) {
+	previous := *secondsField
+	defer func() { *secondsField = previous }()
+	*secondsField = true
+	jobs, errs := parseCrontabReader("seconds", strings.NewReader("0 0 12 * * * 2025 /bin/report\n*/30 * * * * * 5\n"), nil)
+	if len(errs) != 0 || len(jobs) != 2 {
+		t.Fatalf("expected 2 jobs, got %v", errs)
+	}
+	if jobs[0].Schedule != "0 0 12 * * * 2025" || jobs[0].Command != "/bin/report" {
+		t.Errorf("expected a schedule with seconds and year, got %q running %q", jobs[0].Schedule, jobs[0].Command)
+	}
+	if jobs[1].Schedule != "*/30 * * * * *" || jobs[1].Command != "5" {
+		t.Errorf("expected a schedule with seconds, got %q running %q", jobs[1].Schedule, jobs[1].Command)
+	}
+}
+
+func TestSystemCrontab(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs users")
+	}
+	current, err := user.Current()
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := current.Username
+	previous := *systemCrontab
+	defer func() { *systemCrontab = previous }()
+
+	tests := []struct {
+		line         string
+		system       bool
+		wantSchedule string
+		wantUser     string
+		wantCommand  string
+		wantArgs     string
+		wantErr      string
+	}{
+		{"17 * * * * " + name + " cd / && run-parts --report /etc/cron.hourly", true, "0 17 * * * *", name, "cd", "/ && run-parts --report /etc/cron.hourly", ""},
+		{"@daily " + name + " /bin/backup --all", true, "@daily", name, "/bin/backup", "--all", ""},
+		{"@every 5m " + current.Uid + " /bin/poll", true, "@every 5m", current.Uid, "/bin/poll", "", ""},
+		// numeric users are not taken for seconds or years in system crontabs
+		{"0 12 * * * " + current.Uid + " /bin/report", true, "0 0 12 * * *", current.Uid, "/bin/report", "", ""},
+		{"0 12 * * * /bin/report", true, "", "", "", "", "system crontabs take the user before the command"},
+		{"0 12 * * * no-such-user-" + name + " /bin/report", true, "", "", "", "", "unknown user"},
+		// without -system-crontab the user is the command
+		{"0 12 * * * " + name + " /bin/report", false, "0 0 12 * * *", "", name, "/bin/report", ""},
+		{"0 12 * * * /bin/report", false, "0 0 12 * * *", "", "/bin/report", "", ""},
+	}
+	for _, test := range tests {
+		*systemCrontab = test.system
+		jobs, errs := parseCrontabReader("crontab", strings.NewReader(test.line+"\n"), nil)
+		if test.wantErr != "" {
+			if len(errs) != 1 || !strings.Contains(errs[0].Error(), test.wantErr) {
+				t.Errorf("%q: expected error %q, got %v", test.line, test.wantErr, errs)
+			}
+			continue
+		}
+		if len(errs) != 0 || len(jobs) != 1 {
+			t.Errorf("%q: expected a job, got %v", test.line, errs)
+			continue
+		}
+		r := jobs[0]
+		if r.Schedule != test.wantSchedule || r.User != test.wantUser || r.Command != test.wantCommand || r.Args != test.wantArgs {
+			t.Errorf("%q: expected %q, %q, %q and %q, got %q, %q, %q and %q", test.line, test.wantSchedule, test.wantUser, test.wantCommand, test.wantArgs, r.Schedule, r.User, r.Command, r.Args)
+		}
+	}
+
+	// with -seconds system crontabs take a seconds field as well
+	previousSeconds := *secondsField
+	defer func() { *secondsField = previousSeconds }()
+	*secondsField = true
+	*systemCrontab = true
+	jobs, errs := parseCrontabReader("crontab", strings.NewReader("*/30 * * * * * "+name+" /bin/poll\n"), nil)
+	if len(errs) != 0 || len(jobs) != 1 || jobs[0].Schedule != "*/30 * * * * *" || jobs[0].User != name {
+		t.Errorf("expected a job with seconds run as %s, got %v", name, errs)
+	}
+}
@@ -1,4 +1,4 @@
-package main
+package crontinuous
 
 import (
 	"bufio"
@@ -6,11 +6,15 @@ import (
 	"encoding/hex"
 	"flag"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"os/signal"
+	"regexp"
+	"strconv"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	log "github.com/Sirupsen/logrus"
@@ -22,19 +26,143 @@ import (
 // ~ Constants
 // --------------------------------------------------------------------------------------------
 
-const logDelay = 1               // in seconds
-const logBufferSize = 1024 * 512 // in byte => 512Kb
+// minLogBuffer keeps -log-buffer from splitting ordinary output lines
+const minLogBuffer = 1024
+
+// outputTailLines is how many output lines of the last run are kept for the dashboard
+const outputTailLines = 200
+const reloadDelay = 500 * time.Millisecond
+
+// fallbackPollInterval is how often crontabs are checked for changes if they cannot be watched
+const fallbackPollInterval = 10 * time.Second
 
 // --------------------------------------------------------------------------------------------
 // ~ Variables
 // --------------------------------------------------------------------------------------------
 
 var (
-	version         = "0.1.0"
-	showVersionFlag = flag.Bool("version", false, "version info")
-	executer        = flag.String("exec", os.Getenv("SHELL"), "shell / script to be called by the scheduler to execute the job")
-	crontab         = flag.String("crontab", "/etc/crontab", "where to describe the jobs")
-	cronScheduler   *cron.Cron
+	// CommandLine holds the options of crontinuous, embedders may set them before creating a Scheduler
+	CommandLine = flag.NewFlagSet("crontinuous", flag.ExitOnError)
+
+	version          = "0.1.0"
+	showVersionFlag  = CommandLine.Bool("version", false, "version info")
+	healthcheck      = CommandLine.Bool("healthcheck", false, "check the health of the running daemon through -socket and exit non-zero if it is failing")
+	executer         = CommandLine.String("exec", os.Getenv("SHELL"), "shell / script to be called by the scheduler to execute the job, none to execute commands directly")
+	noShell          = CommandLine.Bool("no-shell", false, "execute commands directly, with their arguments split at spaces like a shell does but without one")
+	crontabs         = pathsFlag("crontab", defaultCrontab, "where to describe the jobs, either a file, a directory of files or an http(s) url, may be repeated or comma separated")
+	crontabInterval  = CommandLine.Duration("crontab-interval", time.Minute, "interval in which crontabs from urls are fetched again")
+	pollInterval     = CommandLine.Duration("poll-interval", 0, "interval in which the crontabs are checked for changes next to watching them, for file systems without change notifications like NFS (disabled if 0)")
+	configFile       = CommandLine.String("config", "", "yaml or toml file with job definitions, used instead of the default crontab or in addition to given ones")
+	listen           = CommandLine.String("listen", "", "address of the admin api, e.g. :8080 (disabled if empty)")
+	apiToken         = CommandLine.String("api-token", os.Getenv("CRONTINUOUS_API_TOKEN"), "bearer token the admin api on -listen requires, except for health checks and the dashboard page (only requests from loopback addresses may change anything if empty)")
+	socket           = CommandLine.String("socket", defaultSocket, "unix socket to serve the admin api on for the cli (disabled if empty)")
+	socketGroup      = CommandLine.String("socket-group", "", "group allowed to use the control socket next to root and the user of crontinuous (only those if empty)")
+	grpcListen       = CommandLine.String("grpc", "", "address of the grpc control api, e.g. :9090 (disabled if empty)")
+	grpcTLSCert      = CommandLine.String("grpc-tls-cert", "", "certificate file the grpc control api serves TLS with, together with -grpc-tls-key (plain text if empty)")
+	grpcTLSKey       = CommandLine.String("grpc-tls-key", "", "private key file of -grpc-tls-cert")
+	concurrency      = CommandLine.String("concurrency", string(ConcurrencyAllow), "default concurrency policy of jobs: allow, forbid, replace or queue")
+	maintenanceFile  = CommandLine.String("maintenance", "", "yaml file of maintenance windows suppressing the scheduled runs of jobs by tag, read again on reload")
+	dst              = CommandLine.String("dst", string(DSTOnce), "default policy of jobs for runs at times daylight saving time skips or repeats: once, skip or shift")
+	holidayFiles     = CommandLine.String("holidays", "", "comma separated ics or date list files of the holidays jobs with skip-holidays or business-days-only do not run on, read again on reload")
+	maxConcurrent    = CommandLine.Int("max-concurrent", 0, "max number of jobs running at the same time (unlimited if 0)")
+	overflow         = CommandLine.String("overflow", string(OverflowQueue), "what to do with due jobs when max-concurrent is reached: queue or skip")
+	queueSize        = CommandLine.Int("queue-size", 100, "max number of runs queued by a job with concurrency queue or a full worker pool, further runs are skipped (unlimited if 0)")
+	tagConcurrency   = CommandLine.String("tag-concurrency", "", "comma separated tag=limit pairs limiting how many jobs with a tag run at the same time, e.g. db=2")
+	onlyTags         = CommandLine.String("only-tags", "", "comma separated tags, only jobs with one of them are loaded (all jobs if empty)")
+	skipTags         = CommandLine.String("skip-tags", "", "comma separated tags, jobs with one of them are not loaded")
+	systemCrontab    = CommandLine.Bool("system-crontab", false, "read the crontab in system format with a user field before the command")
+	runUser          = CommandLine.String("user", "", "default user to run the jobs as")
+	runGroup         = CommandLine.String("group", "", "default group to run the jobs as")
+	timeout          = CommandLine.Duration("timeout", 0, "default time after which a job run is killed, e.g. 1h (no timeout if 0)")
+	killGrace        = CommandLine.Duration("kill-grace", 10*time.Second, "default time a run replaced by concurrency replace gets to exit after SIGTERM before it is killed")
+	sla              = CommandLine.Duration("sla", 0, "default expected maximum duration of runs, longer runs are notified about as slow but not killed (disabled if 0)")
+	retries          = CommandLine.Int("retries", 0, "default number of retries of failed job runs")
+	retryDelay       = CommandLine.Duration("retry-delay", 10*time.Second, "default delay before retrying a failed job run")
+	retryBackoff     = CommandLine.String("retry-backoff", string(BackoffFixed), "default backoff between retries: fixed or exponential")
+	stateDir         = CommandLine.String("state-dir", "", "directory to keep the run history and state in (disabled if empty)")
+	historyMaxAge    = CommandLine.Duration("history-max-age", 30*24*time.Hour, "time after which runs are dropped from the history (kept forever if 0)")
+	historyMax       = CommandLine.Int("history-max-records", 100000, "max number of runs kept in the history, older ones are dropped (unlimited if 0)")
+	pidFile          = CommandLine.String("pidfile", "", "file to write the pid to, locked like the state directory so a second crontinuous with the same file refuses to start (disabled if empty)")
+	catchUp          = CommandLine.Duration("catch-up", 0, "default window in which runs missed while crontinuous was down are caught up on start, requires -state-dir (disabled if 0)")
+	deadman          = CommandLine.Duration("deadman", 0, "default tolerance after which a job that did not run when expected is notified about as missed (disabled if 0)")
+	circuitBreaker   = CommandLine.Int("circuit-breaker", 0, "default number of runs failing in a row after which the scheduled runs of a job are suspended (disabled if 0)")
+	circuitCooldown  = CommandLine.Duration("circuit-cooldown", 0, "default time after which a job suspended by its circuit breaker is run again (until resumed if 0)")
+	templates        = CommandLine.Bool("template", false, "expand templates like {{.Date}} in the commands of all jobs on every run")
+	vaultAddr        = CommandLine.String("vault-addr", os.Getenv("VAULT_ADDR"), "address of HashiCorp Vault to resolve secrets of jobs from, the token is read from VAULT_TOKEN (disabled if empty)")
+	vaultNamespace   = CommandLine.String("vault-namespace", os.Getenv("VAULT_NAMESPACE"), "vault namespace to read secrets from")
+	awsRegion        = CommandLine.String("aws-region", awsRegionFromEnv(), "aws region of secrets manager and parameter store secrets, the region of the instance if empty")
+	awsSecretsTTL    = CommandLine.Duration("aws-secrets-ttl", 5*time.Minute, "how long secrets from aws are cached before they are looked up again, 0 to look them up on every run")
+	vaultTokenFile   = CommandLine.String("vault-token-file", "", "file to read the vault token from on every lookup, e.g. the sink of a vault agent, instead of VAULT_TOKEN")
+	logBuffer        = CommandLine.Int("log-buffer", 512*1024, "size in bytes of the output buffer of a job, longer output lines are split")
+	logFlushInterval = CommandLine.Duration("log-flush-interval", time.Second, "interval in which the buffered output of running jobs is logged")
+	logStream        = CommandLine.Bool("log-stream", false, "log every output line of jobs right away with run id and line number instead of buffering the output")
+	logDir           = CommandLine.String("log-dir", "", "directory to write a log file per job to (disabled if empty)")
+	logMaxSize       = CommandLine.Int64("log-max-size", 10*1024*1024, "size in bytes after which a job log file is rotated (never if 0)")
+	logMaxAge        = CommandLine.Duration("log-max-age", 24*time.Hour, "age after which a job log file is rotated (never if 0)")
+	logMaxFiles      = CommandLine.Int("log-max-files", 7, "number of rotated log files to keep per job (all if 0)")
+	logRetention     = CommandLine.Duration("log-retention", 0, "age after which rotated log files are deleted (never if 0)")
+	runLogs          = CommandLine.Bool("run-logs", false, "keep the complete output of every run in <state-dir>/<job-id>/<run-id>.log")
+	runLogKeep       = CommandLine.Int("run-log-keep", 20, "number of run logs to keep per job (all if 0)")
+	runLogRetention  = CommandLine.Duration("run-log-retention", 0, "age after which run logs are deleted (never if 0)")
+	logSyslog        = CommandLine.String("log-syslog", "", "also log to syslog: local, udp://host:port or tcp://host:port (disabled if empty)")
+	logSyslogTag     = CommandLine.String("log-syslog-tag", "crontinuous", "syslog tag, job entries are tagged with <tag>-<job id>")
+	shipLoki         = CommandLine.String("ship-loki", "", "grafana loki url to push a record per run to, e.g. http://loki:3100 (disabled if empty)")
+	shipFluentd      = CommandLine.String("ship-fluentd", "", "fluentd forward input host:port to send a record per run to (disabled if empty)")
+	shipFluentdTag   = CommandLine.String("ship-fluentd-tag", "crontinuous.run", "fluentd tag of the run records")
+	otlpEndpoint     = CommandLine.String("otlp-endpoint", os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"), "OTLP/HTTP endpoint to export a span per job run to, e.g. http://otel-collector:4318 (disabled if empty)")
+	otlpService      = CommandLine.String("otlp-service", "crontinuous", "service name of the exported spans")
+	statsdAddr       = CommandLine.String("statsd-addr", "", "statsd host:port to send metrics of job runs to (disabled if empty)")
+	statsdPrefix     = CommandLine.String("statsd-prefix", "crontinuous.", "prefix of the statsd metric names")
+	statsdTags       = CommandLine.Bool("statsd-tags", true, "tag metrics with job and status in the DogStatsD format, false puts the job id into the metric names")
+	shipLabels       = CommandLine.String("ship-labels", "", "comma separated key=value labels attached to shipped run records")
+	uploadTarget     = CommandLine.String("upload", "", "bucket to upload the output and metadata of every run to, s3://bucket/prefix or gs://bucket/prefix (disabled if empty)")
+	uploadKey        = CommandLine.String("upload-key", defaultUploadKey, "go template of the keys of uploads, .log and .json are appended")
+	slackWebhook     = CommandLine.String("slack-webhook", "", "slack incoming webhook url to notify about failed jobs")
+	webhook          = CommandLine.String("webhook", "", "url to post job run events to as JSON")
+	webhookEvents    = CommandLine.String("webhook-events", "", "comma separated events to post to webhooks: start, success, warning, failure, timeout (all if empty)")
+	smtpAddr         = CommandLine.String("smtp-addr", "", "smtp server host:port to send MAILTO mails through")
+	smtpUser         = CommandLine.String("smtp-user", "", "smtp user name")
+	smtpPassword     = CommandLine.String("smtp-password", "", "smtp password")
+	smtpFrom         = CommandLine.String("smtp-from", "crontinuous@localhost", "sender address of mails")
+	smtpTLS          = CommandLine.Bool("smtp-tls", false, "connect to the smtp server with TLS instead of STARTTLS")
+	secondsField     = CommandLine.Bool("seconds", false, "schedules have a leading seconds field (six fields instead of five)")
+	hashSeed         = CommandLine.String("hash-seed", "", "mixed into the hash spreading H fields of schedules, e.g. the host name to spread identical crontabs of many hosts apart")
+	timezone         = CommandLine.String("timezone", "", "time zone to evaluate the schedules in, e.g. Europe/Berlin (defaults to local time)")
+	haEtcd           = CommandLine.String("ha-etcd", "", "comma separated etcd endpoints to elect a leader through, only the leader schedules jobs (disabled if empty)")
+	haKey            = CommandLine.String("ha-key", "/crontinuous/leader", "etcd key of the leader lock, shared by all instances of a cluster")
+	haTTL            = CommandLine.Duration("ha-ttl", 15*time.Second, "time after which another instance takes over when the leader is gone")
+	consulAddr       = CommandLine.String("consul", "", "consul agent url, e.g. http://127.0.0.1:8500, to split the jobs between the registered instances (disabled if empty)")
+	consulService    = CommandLine.String("consul-service", "crontinuous", "consul service name of the instances sharing the jobs")
+	consulTTL        = CommandLine.Duration("consul-ttl", 15*time.Second, "ttl of the consul health check, after which the jobs of a dead instance move")
+	cgroupRoot       = CommandLine.String("cgroup-root", "/sys/fs/cgroup/crontinuous", "cgroup v2 directory to create the cgroups of runs with limits in")
+	dockerBinary     = CommandLine.String("docker", "docker", "docker client to run the jobs of annotated images with")
+	dockerPull       = CommandLine.String("docker-pull", string(PullMissing), "default pull policy of images: missing, always or never")
+	kubeAPI          = CommandLine.String("kube-api", "", "kubernetes api server url to launch the jobs of annotated templates through, e.g. http://127.0.0.1:8001 (in cluster if empty)")
+	kubeNamespace    = CommandLine.String("kube-namespace", "", "kubernetes namespace to launch jobs in (the pod's namespace if empty)")
+	redisAddrs       = CommandLine.String("redis", "", "comma separated redis servers host:port to lock jobs annotated with lock through")
+	redisPassword    = CommandLine.String("redis-password", "", "redis password")
+	redisPrefix      = CommandLine.String("redis-prefix", "crontinuous:lock:", "prefix of the job lock keys")
+	redisLockTTL     = CommandLine.Duration("redis-lock-ttl", 30*time.Second, "ttl of job locks, refreshed while the job runs")
+	cronScheduler    *cron.Cron
+	schedulerStarted bool
+	cronLock         sync.RWMutex
+	booted           bool
+	loadedJobs       []*Runnable
+
+	defaultConcurrency  = ConcurrencyAllow
+	defaultDSTPolicy    = DSTOnce
+	pool                *workerPool
+	defaultRetryBackoff = BackoffFixed
+	defaultPullPolicy   = PullMissing
+	runHistory          *history
+	jobsState           *schedulerState
+	shippers            []Notifier
+	schedulerLocation   = time.Local
+	annotationRegexp    = regexp.MustCompile(`^#\s*([A-Za-z][A-Za-z0-9_-]*):\s*(.*)$`)
+	scheduleFieldRegexp = regexp.MustCompile(`^(?i)([0-9*?/-]+|[0-7](l|#[1-5])|(sun|mon|tue|wed|thu|fri|sat)(l|#[1-5]|-(sun|mon|tue|wed|thu|fri|sat))?|h(\([0-9]+-[0-9]+\))?(/[0-9]+)?)(,([0-9*?/-]+|[0-7](l|#[1-5])|(sun|mon|tue|wed|thu|fri|sat)(l|#[1-5]|-(sun|mon|tue|wed|thu|fri|sat))?|h(\([0-9]+-[0-9]+\))?(/[0-9]+)?))*$`)
+	variableRegexp      = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_]*)\s*=(.*)$`)
+	jobNameRegexp       = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+	includeRegexp       = regexp.MustCompile(`^#include\s+(.+)$`)
 )
 
 // --------------------------------------------------------------------------------------------
@@ -43,30 +171,107 @@ var (
 
 // Runnable implements cron.Job to Run() a command
 type Runnable struct {
-	ID            string
-	Command       string
-	Args          string
-	Schedule      string
-	buffer        []byte
-	bufferPos     int
-	isRunning     bool
+	ID           string
+	Command      string
+	Args         string
+	Shell        string
+	Schedule     string
+	File         string
+	Concurrency  ConcurrencyPolicy
+	DST          DSTPolicy
+	Location     *time.Location
+	User         string
+	Group        string
+	Template     bool
+	Timeout      time.Duration
+	KillGrace    time.Duration
+	SLA          time.Duration
+	ExitCodes    exitCodePolicy
+	Retries      int
+	RetryDelay   time.Duration
+	RetryBackoff BackoffPolicy
+	CatchUp      time.Duration
+	Deadman      time.Duration
+	Lock         bool
+	Disabled     bool
+	After        []string
+	AfterWindow  time.Duration
+	Tags         []string
+	Secrets      []secretRef
+	Pre          string
+	Post         string
+	Limits       *resourceLimits
+	Priority     *processPriority
+	Image        string
+	Kubernetes   string
+	HTTP         *HTTPRequest
+	// Payload is the template of the message of publish jobs
+	Payload string
+	// Trigger is the token to run the job with a request, a ${NAME} of a variable or secret
+	Trigger string
+	// OnFile is the pattern of the files @onfile jobs run for
+	OnFile   string
+	Debounce time.Duration
+	// SkipHolidays leaves out runs on the holidays of -holidays, BusinessDaysOnly on weekends too
+	SkipHolidays     bool
+	BusinessDaysOnly bool
+	// Breaker suspends the scheduled runs after as many failed runs in a row, for Cooldown or until resumed if 0
+	Breaker  int
+	Cooldown time.Duration
+	// Executor runs the job, picked from what the job looks like if empty
+	Executor     string
+	Pull         PullPolicy
+	Env          []string
+	SlackWebhook string
+	Mailto       string
+	Webhook      string
+	PingURL      string
+	argv         []string
+	runAs        *runAs
+	revision     string
+	// scheduler is set for jobs of an embedded Scheduler
+	scheduler     *Scheduler
+	outputTail    *lineTail
+	outputRunID   string
 	contextLogger *log.Entry
+	schedule      cron.Schedule
+	lock          sync.Mutex
+	runs          int
+	current       *execution
+	last          *Notification
+	// queue holds the runs waiting for the active one with concurrency queue, first in first out
+	queue []chan struct{}
 }
 
 func createRunnable(command string, args string, schedule string) *Runnable {
+	// jobs without name are identified by their whole line, so the same command with other args is another job
 	h := sha1.New()
-	h.Write([]byte(command))
+	h.Write([]byte(schedule + " " + command + " " + args))
 	hash := h.Sum(nil)
 	id := hex.EncodeToString(hash)
 
 	return &Runnable{
-		ID:        id,
-		Command:   command,
-		Args:      args,
-		Schedule:  schedule,
-		buffer:    make([]byte, logBufferSize),
-		bufferPos: 0,
-		isRunning: false,
+		ID:           id,
+		Command:      command,
+		Args:         args,
+		Shell:        defaultShell(),
+		Schedule:     schedule,
+		Concurrency:  defaultConcurrency,
+		DST:          defaultDSTPolicy,
+		Template:     *templates,
+		Timeout:      *timeout,
+		KillGrace:    *killGrace,
+		SLA:          *sla,
+		Retries:      *retries,
+		RetryDelay:   *retryDelay,
+		RetryBackoff: defaultRetryBackoff,
+		CatchUp:      *catchUp,
+		Deadman:      *deadman,
+		Breaker:      *circuitBreaker,
+		Cooldown:     *circuitCooldown,
+		Pull:         defaultPullPolicy,
+		SlackWebhook: *slackWebhook,
+		Webhook:      *webhook,
 		contextLogger: log.WithFields(log.Fields{
 			"id":       id,
 			"schedule": schedule,
@@ -75,23 +280,429 @@ func createRunnable(command string, args string, schedule string) *Runnable {
 	}
 }
 
-func (r *Runnable) flushBufferPeriodically() {
-	for r.isRunning {
-		time.Sleep(logDelay * time.Second)
-		go r.flush()
+// defaultShell returns the shell of -exec, the one of the system if none is set
+func defaultShell() string {
+	switch {
+	case *noShell:
+		return "none"
+	case *executer == "":
+		return systemShell()
 	}
+	return *executer
 }
 
-func (r *Runnable) flush() {
-	if r.bufferPos == 0 {
+// checkShell falls back to the shell of the system if the one of -exec or $SHELL does not exist,
+// like bash in an alpine container inheriting $SHELL
+func checkShell() {
+	if *executer == "" || *executer == "none" || *executer == "go" {
 		return
 	}
-	trimmedLines := strings.TrimSpace(string(r.buffer[0:r.bufferPos]))
-	r.bufferPos = 0
-	r.contextLogger.WithField("output", trimmedLines).Info("command std output")
+	if _, err := exec.LookPath(*executer); err != nil {
+		log.WithField("shell", *executer).Warn("shell not found, falling back to ", systemShell())
+		*executer = ""
+	}
+}
+
+// newOutputScanner scans the output of a run line by line, lines not fitting into the
+// output buffer are split instead of stopping the scan
+func newOutputScanner(reader io.Reader) *bufio.Scanner {
+	// room for the newline added in the buffer
+	maxLine := *logBuffer - 1
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 4096), *logBuffer)
+	scanner.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		advance, token, err := bufio.ScanLines(data, atEOF)
+		if len(token) > maxLine || (advance == 0 && err == nil && len(data) >= maxLine) {
+			return maxLine, data[:maxLine], nil
+		}
+		return advance, token, err
+	})
+	return scanner
+}
+
+// outputLine is a line a run wrote to stdout or stderr
+type outputLine struct {
+	stream string
+	text   string
+	// size is the number of bytes read for the line, including its newline
+	size int
+}
+
+// captureOutput reads stdout and stderr of a run at the same time and sends their lines in the
+// order they were read, until both are closed. Reading them one after the other blocks jobs
+// filling the pipe of the other and holds back stderr lines until stdout is closed.
+func captureOutput(logger *log.Entry, stdout io.Reader, stderr io.Reader) <-chan outputLine {
+	lines := make(chan outputLine, 64)
+	wg := sync.WaitGroup{}
+	read := func(stream string, reader io.Reader) {
+		defer wg.Done()
+		scanner := newOutputScanner(reader)
+		for scanner.Scan() {
+			lines <- outputLine{stream: stream, text: scanner.Text(), size: len(scanner.Bytes()) + 1}
+		}
+		if err := scanner.Err(); err != nil {
+			logger.WithField("stream", stream).Error(err)
+		}
+	}
+	wg.Add(2)
+	go read("stdout", stdout)
+	go read("stderr", stderr)
+	go func() {
+		wg.Wait()
+		close(lines)
+	}()
+	return lines
+}
+
+// applyAnnotations configures the runnable from the "# key: value" comments preceding its crontab line
+func (r *Runnable) applyAnnotations(annotations map[string]string) error {
+	for key, value := range annotations {
+		switch key {
+		case "name":
+			if !jobNameRegexp.MatchString(value) {
+				return fmt.Errorf("invalid name %q, use letters, digits, dots, dashes and underscores", value)
+			}
+			r.ID = value
+			r.contextLogger = r.contextLogger.WithField("id", value)
+		case "env":
+			env, err := parseEnv(value)
+			if err != nil {
+				return err
+			}
+			r.Env = append(r.Env, env...)
+		case "shell":
+			r.Shell = value
+		case "concurrency":
+			policy, err := parseConcurrencyPolicy(value)
+			if err != nil {
+				return err
+			}
+			r.Concurrency = policy
+		case "dst":
+			policy, err := parseDSTPolicy(value)
+			if err != nil {
+				return err
+			}
+			r.DST = policy
+		case "timezone":
+			if err := r.setLocation(value); err != nil {
+				return err
+			}
+		case "user":
+			r.User = value
+		case "group":
+			r.Group = value
+		case "timeout":
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return err
+			}
+			r.Timeout = d
+		case "kill-grace":
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return err
+			}
+			r.KillGrace = d
+		case "template":
+			enabled, err := strconv.ParseBool(value)
+			if err != nil {
+				return err
+			}
+			r.Template = enabled
+		case "sla":
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return err
+			}
+			r.SLA = d
+		case "retries":
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return err
+			}
+			if n < 0 {
+				return fmt.Errorf("retries must not be negative, got %d", n)
+			}
+			r.Retries = n
+		case "retry-delay":
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return err
+			}
+			r.RetryDelay = d
+		case "retry-backoff":
+			backoff, err := parseBackoffPolicy(value)
+			if err != nil {
+				return err
+			}
+			r.RetryBackoff = backoff
+		case "catch-up":
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return err
+			}
+			r.CatchUp = d
+		case "deadman":
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return err
+			}
+			r.Deadman = d
+		case "circuit-breaker":
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return err
+			}
+			if n < 0 {
+				return fmt.Errorf("circuit-breaker must not be negative, got %d", n)
+			}
+			r.Breaker = n
+		case "circuit-cooldown":
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return err
+			}
+			r.Cooldown = d
+		case "lock":
+			lock, err := strconv.ParseBool(value)
+			if err != nil {
+				return err
+			}
+			r.Lock = lock
+		case "pre":
+			r.Pre = value
+		case "post":
+			r.Post = value
+		case "secret":
+			secrets, err := parseSecrets(value)
+			if err != nil {
+				return err
+			}
+			r.Secrets = secrets
+		case "tags":
+			tags, err := parseTags(value)
+			if err != nil {
+				return err
+			}
+			r.Tags = tags
+			r.contextLogger = r.contextLogger.WithField("tags", strings.Join(tags, ","))
+		case "after":
+			r.After = parseAfter(value)
+		case "after-window":
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return err
+			}
+			r.AfterWindow = d
+		case "skip-holidays":
+			skip, err := strconv.ParseBool(value)
+			if err != nil {
+				return err
+			}
+			r.SkipHolidays = skip
+		case "business-days-only":
+			businessDays, err := strconv.ParseBool(value)
+			if err != nil {
+				return err
+			}
+			r.BusinessDaysOnly = businessDays
+		case "disabled":
+			disabled, err := strconv.ParseBool(value)
+			if err != nil {
+				return err
+			}
+			r.Disabled = disabled
+		case "limits":
+			limits, err := parseLimits(value)
+			if err != nil {
+				return err
+			}
+			r.Limits = limits
+		case "exit-codes":
+			policy, err := parseExitCodes(value)
+			if err != nil {
+				return err
+			}
+			r.ExitCodes = policy
+		case "nice":
+			nice, err := parseNice(value)
+			if err != nil {
+				return err
+			}
+			r.priority().Nice = &nice
+		case "ionice":
+			class, level, err := parseIONice(value)
+			if err != nil {
+				return err
+			}
+			r.priority().IOClass = class
+			r.priority().IOLevel = level
+		case "image":
+			r.Image = value
+		case "kubernetes":
+			r.Kubernetes = value
+		case "http":
+			method, url, err := splitHTTPRequest(value)
+			if err != nil {
+				return err
+			}
+			r.Command, r.Args, r.argv = method, url, nil
+			r.httpRequest()
+		case "http-headers":
+			headers, err := parseHTTPHeaders(value)
+			if err != nil {
+				return err
+			}
+			r.httpRequest().Headers = headers
+		case "http-body":
+			r.httpRequest().Body = value
+		case "http-status":
+			status, err := parseHTTPStatus(value)
+			if err != nil {
+				return err
+			}
+			r.httpRequest().Status = status
+		case "publish":
+			broker, err := splitPublishURL(value)
+			if err != nil {
+				return err
+			}
+			r.Command, r.Args, r.argv = broker, value, nil
+			r.Executor = "publish"
+		case "payload":
+			if _, err := r.parseCommandTemplate(value); err != nil {
+				return fmt.Errorf("invalid payload template: %s", err)
+			}
+			r.Payload = value
+		case "debounce":
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return err
+			}
+			r.Debounce = d
+		case "trigger":
+			if value == "" {
+				return fmt.Errorf("trigger needs a token")
+			}
+			r.Trigger = value
+		case "executor":
+			if _, ok := lookupExecutor(value); !ok {
+				return fmt.Errorf("unknown executor %q, use one of %s", value, strings.Join(executorNames(), ", "))
+			}
+			r.Executor = value
+		case "pull":
+			policy, err := parsePullPolicy(value)
+			if err != nil {
+				return err
+			}
+			r.Pull = policy
+		case "slack":
+			r.SlackWebhook = value
+		case "webhook":
+			r.Webhook = value
+		case "ping":
+			r.PingURL = value
+		}
+	}
+	// requests and messages of the annotations are sent by their executors, whatever other annotations say
+	if r.HTTP != nil {
+		if _, ok := annotations["http"]; !ok {
+			return fmt.Errorf("http-headers, http-body and http-status need an http annotation")
+		}
+		r.Executor = "http"
+	}
+	if r.Schedule == triggerSchedule && r.Trigger == "" {
+		return fmt.Errorf("%s jobs need a trigger annotation", triggerSchedule)
+	}
+	if _, ok := annotations["publish"]; ok {
+		r.Executor = "publish"
+	} else if r.Payload != "" {
+		return fmt.Errorf("payload needs a publish annotation")
+	}
+	return nil
+}
+
+// setFile attributes the runnable to the crontab it was defined in
+func (r *Runnable) setFile(filename string) {
+	r.File = filename
+	r.contextLogger = r.contextLogger.WithField("file", filename)
+}
+
+// setLocation makes the runnable's schedule being evaluated in the named time zone
+func (r *Runnable) setLocation(name string) error {
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return err
+	}
+	r.Location = location
+	r.contextLogger = r.contextLogger.WithField("timezone", location.String())
+	return nil
+}
+
+// resolveRunAs looks up the runnable's user and group to drop privileges to when executing
+func (r *Runnable) resolveRunAs() error {
+	if r.User == "" && r.Group == "" {
+		return nil
+	}
+	a, err := lookupRunAs(r.User, r.Group)
+	if err != nil {
+		return err
+	}
+	r.runAs = a
+	r.contextLogger = r.contextLogger.WithFields(log.Fields{
+		"user":  a.user.Username,
+		"group": r.Group,
+	})
+	return nil
+}
+
+// activeRuns returns the number of currently running executions
+func (r *Runnable) activeRuns() int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.runs
+}
+
+// lastRun returns the last finished run of the job, nil if it has not run yet
+func (r *Runnable) lastRun() *Notification {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.last
+}
+
+func (r *Runnable) setLastRun(n *Notification) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.last = n
+}
+
+// addOutput keeps the latest output lines of the job for the dashboard, a new run starts over
+func (r *Runnable) addOutput(runID string, line string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.outputTail == nil || r.outputRunID != runID {
+		r.outputTail = newLineTail(outputTailLines)
+		r.outputRunID = runID
+	}
+	r.outputTail.add(line)
+}
+
+// output returns the run id and the latest output lines of the job's last run with output
+func (r *Runnable) output() (string, []string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.outputTail == nil {
+		return "", []string{}
+	}
+	return r.outputRunID, append([]string{}, r.outputTail.lines...)
 }
 
 func (r *Runnable) logCreation() {
+	if r.Disabled {
+		r.contextLogger.Info("job created, it is disabled and will not be scheduled")
+		return
+	}
 	r.contextLogger.Info("job created")
 }
 
@@ -99,38 +710,185 @@ func (r *Runnable) logCreation() {
 // ~ Main method
 // --------------------------------------------------------------------------------------------
 
-func init() {
+// Main runs the crontinuous command line with the arguments of the process
+func Main() {
 	log.SetFormatter(&log.TextFormatter{FullTimestamp: true})
 	log.SetLevel(log.DebugLevel)
-}
-
-func main() {
-	flag.Parse()
+	CommandLine.Parse(os.Args[1:])
 	if *showVersionFlag {
 		fmt.Printf("%v\n", version)
 		return
 	}
+	initService()
+	checkShell()
+
+	if *logSyslog != "" {
+		hook, err := newSyslogHook(*logSyslog, *logSyslogTag)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.AddHook(hook)
+	}
+
+	labels, err := parseLabels(*shipLabels)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *shipLoki != "" {
+		shippers = append(shippers, &lokiShipper{url: *shipLoki, labels: labels})
+	}
+	if *shipFluentd != "" {
+		shippers = append(shippers, &fluentdShipper{addr: *shipFluentd, tag: *shipFluentdTag, labels: labels})
+	}
+	if *uploadTarget != "" {
+		up, err := newUploader(*uploadTarget, *uploadKey, labels)
+		if err != nil {
+			log.Fatal(err)
+		}
+		shippers = append(shippers, up)
+	}
+
+	if *otlpEndpoint != "" {
+		hostname, _ := os.Hostname()
+		tracer = newOTLPTracer(*otlpEndpoint, *otlpService, hostname)
+	}
+
+	if *statsdAddr != "" {
+		metrics, err = newStatsdSink(*statsdAddr, *statsdPrefix, *statsdTags)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if *configFile != "" {
+		crontabs.Set(*configFile)
+	}
+
+	// the options shared with the subcommands and embedded schedulers
+	if err := applyOptions(); err != nil {
+		log.Fatal(err)
+	}
+
+	if *healthcheck {
+		os.Exit(healthcheckCommand())
+	}
+
+	switch CommandLine.Arg(0) {
+	case "validate":
+		os.Exit(validateCommand(CommandLine.Args()[1:]))
+	case "next":
+		os.Exit(nextCommand(CommandLine.Args()[1:]))
+	case "history":
+		os.Exit(historyCommand(CommandLine.Args()[1:]))
+	case "simulate":
+		os.Exit(simulateCommand(CommandLine.Args()[1:]))
+	case "run":
+		os.Exit(runCommand(CommandLine.Args()[1:]))
+	case "list":
+		os.Exit(listCommand(CommandLine.Args()[1:]))
+	case "pause":
+		os.Exit(pauseCommand(CommandLine.Args()[1:], true))
+	case "resume":
+		os.Exit(pauseCommand(CommandLine.Args()[1:], false))
+	}
+
+	if err := openStateDir(); err != nil {
+		log.Fatal(err)
+	}
+
+	// remote crontabs are copied to local files, which are watched like any other crontab
+	paths, remotes, err := mirrorCrontabs(crontabs.paths)
+	if err != nil {
+		log.Fatal(err)
+	}
+	crontabs.paths = paths
+	if CommandLine.Arg(0) == "once" {
+		// runs the jobs with the setup of the daemon, without scheduling or serving anything
+		os.Exit(onceCommand(CommandLine.Args()[1:]))
+	}
+	// a second daemon with the same state directory or pid file would run every job twice
+	if instance, err = lockInstance(*stateDir, *pidFile); err != nil {
+		log.Fatal(err)
+	}
+	for _, remote := range remotes {
+		go remote.run(*crontabInterval)
+	}
 
 	wg := sync.WaitGroup{}
 	wg.Add(1)
 	go watchCrontab()
 
+	if *listen != "" {
+		startAPI(*listen)
+	}
+	if *socket != "" {
+		startSocketAPI(*socket)
+	}
+	if *grpcListen != "" {
+		startGRPC(*grpcListen, *grpcTLSCert, *grpcTLSKey)
+	}
+
 	signalChan := make(chan os.Signal, 1)
-	signal.Notify(signalChan, os.Interrupt)
+	signal.Notify(signalChan, os.Interrupt, syscall.SIGTERM)
+	startService(signalChan)
 	go func() {
 		for _ = range signalChan {
-			if cronScheduler != nil {
+			sdNotify("STOPPING=1")
+			if scheduler := currentScheduler(); scheduler != nil {
 				// Stop the scheduler (does not stop any jobs already running).
-				cronScheduler.Stop()
+				scheduler.Stop()
 			}
+			// running jobs would outlive us otherwise, they are not in our process group
+			killRunningProcesses()
+			elector.resign()
+			sharding.deregister()
 			fmt.Println("\nReceived an interrupt, stopping cron scheduler.")
 			wg.Done()
+			stopService()
+			instance.release()
 			os.Exit(0)
 		}
 	}()
 
-	cronScheduler = cron.New()
-	initCron()
+	reloadChan := make(chan os.Signal, 1)
+	signal.Notify(reloadChan, syscall.SIGHUP)
+	go func() {
+		for _ = range reloadChan {
+			log.Info("received SIGHUP, reloading crontab")
+			initCron("SIGHUP")
+		}
+	}()
+
+	if *consulAddr != "" {
+		sharding = newConsulSharding(*consulAddr, *consulService, haInstanceID(), *consulTTL)
+		if err := sharding.start(); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if *redisAddrs != "" {
+		setupJobLocks(*redisAddrs, *redisPassword)
+	}
+
+	if *haEtcd != "" {
+		lock, err := newEtcdLock(*haEtcd, *haKey, haInstanceID(), *haTTL)
+		if err != nil {
+			log.Fatal(err)
+		}
+		elector = newLeaderElection(lock, *haTTL)
+	}
+
+	cronLock.Lock()
+	cronScheduler = cron.NewWithLocation(schedulerLocation)
+	cronLock.Unlock()
+	initCron("start")
+	go sdWatchdog()
+	startDeadmanWatchdog()
+	if elector != nil {
+		go elector.run(func(leader bool) {
+			initCron("leader election")
+		})
+	}
 	wg.Wait()
 }
 
@@ -140,189 +898,460 @@ func main() {
 
 // Run a command as a cron.Job
 func (r *Runnable) Run() {
+	r.runUnlessPaused()
+}
 
-	// test cmd
-	_, err := exec.LookPath(r.Command)
-	if err != nil {
-		r.contextLogger.Error(err)
+// runUnlessPaused runs the job unless it is paused, suspended or waits for its dependencies, env is added to the
+// environment of the run
+func (r *Runnable) runUnlessPaused(env ...string) {
+	if isPaused(r.ID) {
+		r.contextLogger.Info("job is paused, skipping run")
 		return
 	}
-
-	// prepare execute cmd statement
-	var cmd *exec.Cmd
-	if *executer == "go" {
-		cmdArgs := strings.Split(r.Args, " ")
-		cmd = exec.Command(r.Command, cmdArgs...)
-	} else {
-		cmdString := r.Command + " " + r.Args
-		cmd = exec.Command(os.Getenv("SHELL"), "-c", cmdString)
+	if r.suspended(time.Now()) {
+		r.contextLogger.Info("job is suspended by its circuit breaker, skipping run")
+		return
 	}
-
-	/*
-		instead we use logrus for improved logging
-
-		// set commands stderr and stdout to default stderr and stdout
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-	*/
-
-	// prepare cmd logging
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		log.Fatal(err)
+	if w := r.maintenance(time.Now()); w != nil {
+		r.contextLogger.WithField("maintenance", w.name).Info("job is in a maintenance window, suppressing run")
+		return
+	}
+	if !r.dependenciesMet() {
+		return
 	}
+	r.run(env...)
+}
 
-	stderr, err := cmd.StderrPipe()
-	if err != nil {
-		log.Fatal(err)
+// run runs the job regardless of whether it is paused, e.g. when triggered by hand
+func (r *Runnable) run(env ...string) {
+	if !sharding.owns(r.ID) {
+		r.contextLogger.Debug("job belongs to another instance, skipping run")
+		return
 	}
+	if !startRun(r.ID) {
+		r.contextLogger.Info("draining, skipping run")
+		return
+	}
+	defer finishedRun(r.ID)
 
-	// run cmd
-	r.isRunning = true
-	go r.flushBufferPeriodically()
-	err = cmd.Start()
-	if err != nil {
-		r.contextLogger.Error(err)
+	due := time.Now()
+	e, ok := r.acquire()
+	if !ok {
+		return
 	}
+	defer r.release(e)
+	e.env = env
 
-	// cmd logging piped stdout
-	scanner := bufio.NewScanner(stdout)
-	for scanner.Scan() {
-		message := []byte(scanner.Text() + "\n")
-		length := len(message)
-		if length > logBufferSize {
-			r.contextLogger.Println("message received was too large")
-			continue
-		}
-		if (length + r.bufferPos) > logBufferSize {
-			r.flush()
-		}
-		copy(r.buffer[r.bufferPos:], message)
-		r.bufferPos += length
+	releaseSlots, ok := r.acquireSlots(e)
+	if !ok {
+		return
+	}
+	// the slots are given back while waiting for a retry, the deferred func releases what is held
+	defer func() { releaseSlots() }()
+	if e.queued && isDraining() {
+		e.logger.Info("draining, skipping queued run")
+		return
 	}
-	if err := scanner.Err(); err != nil {
-		//fmt.Fprintln(os.Stderr, "reading standard input:", err)
-		r.contextLogger.Error(err)
+	if e.queued {
+		e.waited = time.Since(due)
+		e.logger.WithField("waited", e.waited.Seconds()).Info("run waited for a free slot")
 	}
 
-	// cmd logging piped stderr
-	stderrScanner := bufio.NewScanner(stderr)
-	for stderrScanner.Scan() {
-		r.contextLogger.WithField("output", stderrScanner.Text()).Warn("command std error")
-	}
-	if err := stderrScanner.Err(); err != nil {
-		//fmt.Fprintln(os.Stderr, "reading standard input:", err)
-		r.contextLogger.Error(err)
+	lock, ok := r.lockRun()
+	if !ok {
+		e.logger.Info("job is locked by another instance, skipping run")
+		return
 	}
+	defer lock.release()
 
-	err = cmd.Wait()
-	if err != nil {
-		r.contextLogger.Error(err)
+	recordStart(r.ID, time.Now())
+	if err := jobsState.setLastRun(r.ID, time.Now()); err != nil {
+		e.logger.Error("failed to save state", err)
 	}
 
-	r.isRunning = false
+	for attempt := 1; ; attempt++ {
+		stopSLA := r.watchSLA(e, attempt)
+		n := r.executeWithHooks(e, attempt)
+		stopSLA()
+		if attempt == 1 {
+			n.Waited = e.waited
+		}
+		r.logResult(n)
+		tracer.export(n)
+		metrics.record(n)
+		recordStats(n)
+		r.setLastRun(n)
+		recordResult(n)
+		if err := runHistory.record(n); err != nil {
+			e.logger.Error("failed to record run", err)
+		}
+		if n.Event != EventSuccess && n.Event != EventWarning {
+			// the secrets may have been rotated, look them up again for the retry
+			r.invalidateSecrets()
+		}
+		// warnings are expected outcomes, retrying would not change them
+		if n.Event == EventSuccess || n.Event == EventWarning || attempt > r.Retries {
+			r.finishRun(n)
+			return
+		}
+
+		// retry unless the next scheduled run is due before
+		delay := r.retryDelay(attempt)
+		if next := r.nextRun(); !next.IsZero() && time.Now().Add(delay).After(next) {
+			e.logger.Warn("next run is due before the retry, not retrying")
+			r.finishRun(n)
+			return
+		}
+		e.logger.WithFields(log.Fields{
+			"attempt": attempt,
+			"delay":   delay,
+		}).Warn("run failed, retrying")
+		// other jobs may use the slots while this one waits for its retry
+		releaseSlots()
+		releaseSlots = func() {}
+		select {
+		case <-time.After(delay):
+		case <-e.aborted:
+			r.finishRun(n)
+			return
+		}
+		if releaseSlots, ok = r.acquireSlots(e); !ok {
+			releaseSlots = func() {}
+			e.logger.Warn("no free slot for the retry, not retrying")
+			r.finishRun(n)
+			return
+		}
+	}
 }
 
 // --------------------------------------------------------------------------------------------
 // ~ Private methods
 // --------------------------------------------------------------------------------------------
 
-func initCron() {
-	// Stop the scheduler (does not stop any jobs already running).
-	cronScheduler.Stop()
+// acquireSlots takes a slot of the worker pool and of the pools of the tags of the job and reports
+// whether the run may start, the returned func releases them
+func (r *Runnable) acquireSlots(e *execution) (func(), bool) {
+	if !pool.acquire(e) {
+		return nil, false
+	}
+	releaseTags, ok := r.acquireTags(e)
+	if !ok {
+		pool.release()
+		return nil, false
+	}
+	return func() {
+		releaseTags()
+		pool.release()
+	}, true
+}
+
+// finishRun counts the outcome of the last attempt of a run and notifies about it, tripping or closing
+// the circuit breaker of the job
+func (r *Runnable) finishRun(n *Notification) {
+	failures := countFailures(n)
+	r.notify(n)
+	if failures == 0 && closeBreaker(r.ID) {
+		r.contextLogger.Info("run succeeded, circuit breaker closed")
+	}
+	r.tripBreaker(n, failures)
+}
 
-	// initialize a new cron
-	cronScheduler = cron.New()
+// execute runs the command once and returns the notification describing the outcome
+func (r *Runnable) execute(e *execution, attempt int) *Notification {
+	start := time.Now()
+	result := &Notification{
+		Event:    EventFailure,
+		Job:      r,
+		RunID:    e.id,
+		SpanID:   newSpanID(),
+		Attempt:  attempt,
+		Start:    start,
+		ExitCode: -1,
+		Revision: e.revision,
+	}
 
-	// read crontab
-	file, err := os.Open(*crontab)
+	// templated commands are filled in for every run
+	command, args, argv, err := r.expandCommand(result)
 	if err != nil {
-		log.Fatal(err)
-		os.Exit(1)
+		e.logger.Error("failed to expand the command template: ", err)
+		result.Err = err
+		return result
 	}
-	defer file.Close()
 
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		parseCrontabLine(scanner.Text())
+	// secrets are looked up for every run and handed to the job only
+	secrets, err := r.resolveSecrets()
+	if err != nil {
+		e.logger.Error("failed to resolve secrets: ", err)
+		result.Err = err
+		return result
 	}
 
-	if err := scanner.Err(); err != nil {
-		log.Error("failed reading crontab", err)
-		return
+	executor, ok := lookupExecutor(r.executorName())
+	if !ok {
+		result.Err = fmt.Errorf("unknown executor %q", r.executorName())
+		e.logger.Error(result.Err)
+		return result
+	}
+	a := &Attempt{
+		Job:       r,
+		Command:   command,
+		Args:      args,
+		Argv:      argv,
+		Env:       append(r.runEnv(e, result), secrets.env...),
+		Result:    result,
+		Logger:    e.logger,
+		execution: e,
+		secrets:   secrets,
 	}
+	executor.Execute(a)
+	a.finishOutput()
+	return result
+}
 
-	// start cron scheduler
-	// Funcs are invoked in their own goroutine, asynchronously.
-	cronScheduler.Start()
+// classify sets the outcome of a run that exited from its exit code
+func (r *Runnable) classify(result *Notification) {
+	result.Event = r.ExitCodes.event(result.ExitCode)
+	switch result.Event {
+	case EventSuccess:
+		result.Err = nil
+	case EventFailure:
+		if result.Err == nil {
+			result.Err = fmt.Errorf("exit status %d is mapped to failure", result.ExitCode)
+		}
+	}
 }
 
-func parseCrontabLine(line string) {
-	line = strings.TrimSpace(line)
+func currentScheduler() *cron.Cron {
+	cronLock.RLock()
+	defer cronLock.RUnlock()
+	return cronScheduler
+}
+
+// initCron (re)loads the crontabs, the trigger tells what caused the load in the audit log
+func initCron(trigger string) {
+	cronLock.Lock()
+	defer cronLock.Unlock()
+
+	if booted {
+		sdNotify("RELOADING=1")
+	}
 
-	if len(line) <= 0 || strings.HasPrefix(line, "#") {
+	// read crontabs that changed, a directory is read file by file like /etc/cron.d
+	if loadedSources == nil {
+		loadedSources = newCrontabSources(crontabs)
+	}
+	if err := loadSources(loadedSources, loadCrontab); err != nil {
+		// only the first load is fatal, a broken crontab must not stop a running daemon
+		if loadedJobs == nil {
+			log.Fatal(err)
+		}
+		log.WithField("trigger", trigger).Error("failed to reload, keeping the jobs loaded before: ", err)
+		if !containsString(lastLoad.errors, err.Error()) {
+			lastLoad.errors = append(lastLoad.errors, err.Error())
+		}
+		sdNotify("READY=1")
 		return
 	}
+	// a broken maintenance or holidays file keeps the windows or holidays read before
+	var maintenanceErr, holidaysErr error
+	if *maintenanceFile != "" {
+		if maintenanceErr = loadMaintenance(*maintenanceFile); maintenanceErr != nil {
+			log.Error("failed to read maintenance windows: ", maintenanceErr)
+		}
+	}
+	if *holidayFiles != "" {
+		if holidaysErr = loadHolidays(strings.Split(*holidayFiles, ",")); holidaysErr != nil {
+			log.Error("failed to read holidays: ", holidaysErr)
+		}
+	}
 
-	replacer := strings.NewReplacer("  ", " ", "	", " ")
-	line = replacer.Replace(line)
+	// in a cluster only the leader schedules, it runs the @reboot jobs once it leads for the first time
+	leader := elector.isLeader()
+	reboot := !booted && leader
+	booted = booted || leader
+	previousJobs := loadedJobs
+	lastLoad = loadResult{loaded: true}
+	var errs, duplicates []error
+	loadedJobs, errs, duplicates = mergeSources(loadedSources)
+	for _, err := range errs {
+		lastLoad.errors = append(lastLoad.errors, err.Error())
+	}
+	for _, err := range append(duplicates, dependencyErrors(loadedJobs)...) {
+		log.Error(err)
+		lastLoad.errors = append(lastLoad.errors, err.Error())
+	}
+	if maintenanceErr != nil {
+		lastLoad.errors = append(lastLoad.errors, maintenanceErr.Error())
+	}
+	if holidaysErr != nil {
+		lastLoad.errors = append(lastLoad.errors, holidaysErr.Error())
+	}
 
-	// # ┌───────────── min (0 - 59)
-	// # │ ┌────────────── hour (0 - 23)
-	// # │ │ ┌─────────────── day of month (1 - 31)
-	// # │ │ │ ┌──────────────── month (1 - 12)
-	// # │ │ │ │ ┌───────────────── day of week (0 - 6) (0 to 6 are Sunday to Saturday, or use names; 7 is Sunday, the same as 0)
-	// # │ │ │ │ │
-	// # │ │ │ │ │
-	// # * * * * *  command to execute
+	// the first load lists all jobs, reloads only what changed
+	record := diffJobs(trigger, previousJobs, loadedJobs)
+	record.Errors = lastLoad.errors
+	if previousJobs == nil {
+		for _, r := range loadedJobs {
+			r.logCreation()
+		}
+		if err := audit.record(record); err != nil {
+			log.Error("failed to record load", err)
+		}
+	} else {
+		logReload(record)
+	}
 
-	var args string
-	var substrings = strings.SplitN(line, " ", 7)
-	if len(substrings) < 5 {
-		return
-	} else if len(substrings) >= 6 {
-		args = strings.Join(substrings[6:7], " ")
+	// unchanged jobs are kept with their runs, so a reload does not interrupt them
+	var kept map[*Runnable]bool
+	loadedJobs, kept = reuseJobs(previousJobs, loadedJobs)
+
+	// jobs cannot be removed from a running scheduler, it is only kept if jobs were added alone
+	if leader && schedulerStarted && len(kept) == len(previousJobs) {
+		for _, r := range loadedJobs {
+			if !kept[r] {
+				scheduleJob(cronScheduler, r, false)
+			}
+		}
+	} else {
+		// Stop the scheduler (does not stop any jobs already running).
+		if cronScheduler != nil {
+			cronScheduler.Stop()
+		}
+		cronScheduler = cron.NewWithLocation(schedulerLocation)
+		for _, r := range loadedJobs {
+			scheduleJob(cronScheduler, r, reboot)
+		}
+
+		// start cron scheduler
+		// Funcs are invoked in their own goroutine, asynchronously.
+		schedulerStarted = leader
+		if leader {
+			cronScheduler.Start()
+		}
 	}
+	// like scheduled runs, runs for files are started by the leader only
+	if leader {
+		daemonFileTriggers.update(loadedJobs)
+	} else {
+		daemonFileTriggers.update(nil)
+	}
+	sdNotify("READY=1", fmt.Sprintf("STATUS=%d jobs loaded from %s", len(loadedJobs), crontabs))
+}
 
-	var schedule = "0 " + strings.Join(substrings[:5], " ")
-	var command = substrings[5]
+// findJob returns the loaded job with the given id, nil if there is none
+func findJob(id string) *Runnable {
+	cronLock.RLock()
+	defer cronLock.RUnlock()
+	for _, job := range loadedJobs {
+		if job.ID == id {
+			return job
+		}
+	}
+	return nil
+}
 
-	r := createRunnable(command, args, schedule)
-	var err = cronScheduler.AddJob(schedule, r)
-	if err != nil {
-		r.contextLogger.Error("unable to parse schedule", err)
-		//fmt.Printf("unable to parse schedule \"%s\" for command \"%s\" and args \"%s\" with error: \"%s\"", schedule, command, args, err)
-		return
+// loadedJobsCopy returns the loaded jobs safe to iterate while the crontab is reloaded
+func loadedJobsCopy() []*Runnable {
+	cronLock.RLock()
+	defer cronLock.RUnlock()
+	return append([]*Runnable{}, loadedJobs...)
+}
+
+// unscheduledJobs returns the loaded jobs that are not scheduled because they are disabled, triggered or run for files
+func unscheduledJobs() []*Runnable {
+	cronLock.RLock()
+	defer cronLock.RUnlock()
+	jobs := []*Runnable{}
+	for _, job := range loadedJobs {
+		if job.Disabled || job.Schedule == triggerSchedule || job.OnFile != "" {
+			jobs = append(jobs, job)
+		}
 	}
-	r.logCreation()
+	return jobs
 }
 
 func watchCrontab() {
+	for _, path := range crontabs.paths {
+		if _, err := os.Stat(path); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// files are watched through their directories, as editors and kubernetes config maps replace files
+	// instead of writing to them: editors like vim rename the file and write a new one, config maps
+	// swap the ..data symlink their files point through. Watches are added again after every change,
+	// as directories replaced the same way lose theirs.
+	var events <-chan fsnotify.Event
+	var errors <-chan error
 	watcher, err := fsnotify.NewWatcher()
-	if err != nil {
-		log.Fatal(err)
-		os.Exit(1)
+	if err == nil {
+		defer watcher.Close()
+		events, errors = watcher.Events, watcher.Errors
 	}
-	defer watcher.Close()
+
+	// polling catches changes where no events arrive, it takes over if the crontabs cannot be watched,
+	// e.g. when the inotify watches are used up
+	var poll <-chan time.Time
+	startPolling := func(interval time.Duration) {
+		ticker := time.NewTicker(interval)
+		poll = ticker.C
+	}
+	if *pollInterval > 0 {
+		startPolling(*pollInterval)
+	}
+	fallBack := func(err error) {
+		if poll == nil {
+			log.WithField("interval", fallbackPollInterval).Warn("failed to watch crontab, polling it instead: ", err)
+			startPolling(fallbackPollInterval)
+		}
+	}
+	if watcher == nil {
+		fallBack(err)
+	}
+
+	watch := func() {
+		if watcher == nil {
+			return
+		}
+		for _, dir := range crontabWatchDirs() {
+			if err := watcher.Add(dir); err != nil && os.IsNotExist(err) {
+				log.WithField("dir", dir).Warn("failed to watch crontab", err)
+			} else if err != nil {
+				fallBack(err)
+			}
+		}
+	}
+	watch()
+	fingerprint := crontabFingerprint()
+	var reload <-chan time.Time
 
 	done := make(chan bool)
 	go func() {
 		for {
 			select {
-			case event := <-watcher.Events:
-				if event.Op&fsnotify.Write == fsnotify.Write {
-					log.WithField("file", event.Name).Info("crontab updated")
-					initCron()
+			case event := <-events:
+				// permissions do not change the jobs
+				if event.Op&^fsnotify.Chmod == 0 {
+					continue
+				}
+				// coalesce the events of a single update
+				reload = time.After(reloadDelay)
+			case <-poll:
+				reload = time.After(0)
+			case <-reload:
+				// includes may have changed
+				watch()
+				// reload only if the jobs changed, only the crontabs that changed are parsed again
+				if current := crontabFingerprint(); current != fingerprint {
+					fingerprint = current
+					initCron("crontab changed")
 				}
-			case err := <-watcher.Errors:
+			case err := <-errors:
 				log.Println("error:", err)
+			case reply := <-watcherPing:
+				close(reply)
 			}
 		}
 	}()
-
-	err = watcher.Add(*crontab)
-	if err != nil {
-		log.Fatal(err)
-	}
 	<-done
 }
@@ -0,0 +1,187 @@
+package crontinuous
+
+import (
+	"net/http"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Constants
+// --------------------------------------------------------------------------------------------
+
+// dashboardHTML is a single page on top of the admin api, it has no dependencies so it
+// works without internet access
+const dashboardHTML = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>crontinuous</title>
+<style>
+body { font-family: sans-serif; font-size: 14px; margin: 20px; color: #222; }
+h1 { font-size: 20px; }
+table { border-collapse: collapse; width: 100%; }
+th, td { text-align: left; padding: 4px 8px; border-bottom: 1px solid #ddd; white-space: nowrap; }
+td.command { white-space: normal; font-family: monospace; }
+tr.selected { background: #eef; }
+.status { padding: 1px 6px; border-radius: 3px; color: #fff; background: #999; }
+.success { background: #2a2; }
+.warning { background: #d90; }
+.failure, .timeout { background: #c22; }
+.running { background: #27c; }
+.paused, .disabled { background: #777; }
+.tag { padding: 0 4px; border: 1px solid #ccc; border-radius: 3px; color: #555; font-size: 11px; }
+button { font-size: 12px; }
+pre { background: #111; color: #ddd; padding: 8px; height: 300px; overflow: auto; }
+#details { display: none; margin-top: 20px; }
+</style>
+</head>
+<body>
+<h1>crontinuous</h1>
+<table>
+<thead><tr><th>ID</th><th>Schedule</th><th>Status</th><th>Exit</th><th>Prev</th><th>Next</th><th>Command</th><th></th></tr></thead>
+<tbody id="jobs"></tbody>
+</table>
+<div id="details">
+<h2 id="title"></h2>
+<h3>Output</h3>
+<pre id="output"></pre>
+<h3>Last runs <small>(recorded with -state-dir)</small></h3>
+<table>
+<thead><tr><th>Start</th><th>Status</th><th>Exit</th><th>Duration</th><th>Attempt</th></tr></thead>
+<tbody id="runs"></tbody>
+</table>
+</div>
+<script>
+var selected = null;
+
+function text(value) {
+	var span = document.createElement("span");
+	span.textContent = value;
+	return span.innerHTML;
+}
+
+function time(value) {
+	if (!value || value.indexOf("0001-") === 0) {
+		return "-";
+	}
+	return new Date(value).toLocaleString();
+}
+
+function badge(status) {
+	return status ? '<span class="status ' + text(status) + '">' + text(status) + "</span>" : "-";
+}
+
+// the token of the admin api is passed as #token=... once and kept for the session
+var token = sessionStorage.getItem("token");
+if (location.hash.indexOf("#token=") === 0) {
+	token = decodeURIComponent(location.hash.substring(7));
+	sessionStorage.setItem("token", token);
+	history.replaceState(null, "", location.pathname + location.search);
+}
+
+function request(method, path, callback) {
+	var xhr = new XMLHttpRequest();
+	xhr.open(method, path);
+	xhr.setRequestHeader("X-Requested-With", "XMLHttpRequest");
+	if (token) {
+		xhr.setRequestHeader("Authorization", "Bearer " + token);
+	}
+	xhr.onload = function() {
+		if (callback && xhr.status >= 200 && xhr.status < 300) {
+			callback(JSON.parse(xhr.responseText));
+		}
+	};
+	xhr.send();
+}
+
+function jobPath(id, action) {
+	return "jobs/" + encodeURIComponent(id) + "/" + action;
+}
+
+function loadJobs() {
+	request("GET", "jobs", function(jobs) {
+		var rows = "";
+		jobs.forEach(function(job) {
+			var status = job.isRunning ? "running" : job.disabled ? "disabled" : job.paused ? "paused" : job.lastStatus;
+			rows += '<tr data-id="' + text(job.id) + '"' + (job.id === selected ? ' class="selected"' : "") + ">" +
+				"<td>" + text(job.id) + (job.tags || []).map(function(tag) { return ' <span class="tag">' + text(tag) + "</span>"; }).join("") + "</td>" +
+				"<td>" + text(job.schedule) + "</td>" +
+				"<td>" + badge(status) + "</td>" +
+				"<td>" + (job.lastStatus ? job.lastExitCode : "-") + "</td>" +
+				"<td>" + time(job.prev) + "</td>" +
+				"<td>" + time(job.next) + "</td>" +
+				'<td class="command">' + text(job.command + " " + job.args) + "</td>" +
+				'<td><button data-action="run">run</button> ' +
+				(job.disabled ? "" : '<button data-action="' + (job.paused ? "resume" : "pause") + '">' + (job.paused ? "resume" : "pause") + "</button> ") +
+				'<button data-action="show">output</button></td>' +
+				"</tr>";
+		});
+		document.getElementById("jobs").innerHTML = rows;
+	});
+}
+
+function loadDetails() {
+	if (selected === null) {
+		return;
+	}
+	request("GET", jobPath(selected, "output"), function(output) {
+		var pre = document.getElementById("output");
+		var following = pre.scrollTop + pre.clientHeight >= pre.scrollHeight - 5;
+		pre.textContent = output.lines.join("\n");
+		if (following) {
+			pre.scrollTop = pre.scrollHeight;
+		}
+	});
+	request("GET", jobPath(selected, "runs") + "?limit=10", function(runs) {
+		var rows = "";
+		runs.forEach(function(run) {
+			var duration = (new Date(run.finishedAt) - new Date(run.startedAt)) / 1000;
+			rows += "<tr><td>" + time(run.startedAt) + "</td><td>" + badge(run.status) + "</td><td>" + run.exitCode +
+				"</td><td>" + duration.toFixed(1) + "s</td><td>" + run.attempt + "</td></tr>";
+		});
+		document.getElementById("runs").innerHTML = rows;
+	});
+}
+
+document.getElementById("jobs").addEventListener("click", function(event) {
+	var action = event.target.getAttribute("data-action");
+	if (!action) {
+		return;
+	}
+	var id = event.target.closest("tr").getAttribute("data-id");
+	if (action === "show") {
+		selected = id;
+		document.getElementById("title").textContent = id;
+		document.getElementById("details").style.display = "block";
+		document.getElementById("runs").innerHTML = "";
+		loadDetails();
+		loadJobs();
+		return;
+	}
+	request("POST", jobPath(id, action), loadJobs);
+});
+
+loadJobs();
+setInterval(loadJobs, 2000);
+setInterval(loadDetails, 1000);
+</script>
+</body>
+</html>
+`
+
+// --------------------------------------------------------------------------------------------
+// ~ Private methods
+// --------------------------------------------------------------------------------------------
+
+// handleDashboard serves the web ui on the root of the admin api
+func handleDashboard(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != "GET" {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(dashboardHTML))
+}
@@ -0,0 +1,112 @@
+package crontinuous
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Constants
+// --------------------------------------------------------------------------------------------
+
+// deadmanInterval is how often the watchdog checks whether jobs ran when expected
+const deadmanInterval = 30 * time.Second
+
+// --------------------------------------------------------------------------------------------
+// ~ Variables
+// --------------------------------------------------------------------------------------------
+
+var (
+	// expectedSince holds by id from when the next run of a job is expected,
+	// so it survives reloads of the crontab like missedSlots
+	expectedSince = map[string]time.Time{}
+	missedSlots   = map[string]time.Time{}
+	deadmanLock   sync.Mutex
+	deadmanStart  sync.Once
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Private methods
+// --------------------------------------------------------------------------------------------
+
+// recordStart remembers when a job was started for the watchdog
+func recordStart(id string, t time.Time) {
+	deadmanLock.Lock()
+	defer deadmanLock.Unlock()
+	expectedSince[id] = t
+}
+
+// startDeadmanWatchdog checks periodically that jobs with a dead man tolerance ran when expected
+func startDeadmanWatchdog() {
+	deadmanStart.Do(func() {
+		go func() {
+			for range time.Tick(deadmanInterval) {
+				checkDeadman(time.Now())
+			}
+		}()
+	})
+}
+
+// checkDeadman notifies about every job whose expected run is overdue by more than its tolerance,
+// once per missed slot
+func checkDeadman(now time.Time) {
+	// only the leader schedules, the others would miss every run
+	leader := elector.isLeader()
+	draining := isDraining()
+	for _, r := range loadedJobsCopy() {
+		if r.Deadman <= 0 || r.schedule == nil {
+			continue
+		}
+		// locked jobs may run on any instance and jobs of other shards on another one,
+		// paused, suspended and disabled jobs and jobs in maintenance are expected to run once they are resumed, enabled or out of it,
+		// all jobs once draining ended
+		if !leader || draining || r.Disabled || r.Lock || isPaused(r.ID) || r.suspended(now) || r.maintenance(now) != nil || !sharding.owns(r.ID) {
+			recordStart(r.ID, now)
+			continue
+		}
+		expected, missed := r.overdueRun(now)
+		if !missed {
+			continue
+		}
+		deadmanLock.Lock()
+		alerted := missedSlots[r.ID].Equal(expected)
+		missedSlots[r.ID] = expected
+		deadmanLock.Unlock()
+		if alerted {
+			continue
+		}
+		if err := jobsState.setMissedSlot(r.ID, expected); err != nil {
+			r.contextLogger.Error("failed to save state", err)
+		}
+		err := fmt.Errorf("job did not run, it was expected at %s", expected.Format(time.RFC3339))
+		r.contextLogger.WithField("tolerance", r.Deadman).Error(err)
+		r.notify(&Notification{
+			Event:    EventMissed,
+			RunID:    newRunID(),
+			Start:    expected,
+			ExitCode: -1,
+			Err:      err,
+		})
+	}
+}
+
+// overdueRun returns the run the job was expected at after its last start and whether it is overdue
+func (r *Runnable) overdueRun(now time.Time) (time.Time, bool) {
+	deadmanLock.Lock()
+	since, ok := expectedSince[r.ID]
+	if !ok {
+		// after a restart runs are expected since the last recorded run, else since the job was loaded
+		if since = jobsState.lastRun(r.ID); since.IsZero() {
+			since = now
+		}
+		expectedSince[r.ID] = since
+	}
+	deadmanLock.Unlock()
+
+	expected := r.schedule.Next(since)
+	if expected.IsZero() {
+		return expected, false
+	}
+	return expected, now.After(expected.Add(r.Deadman))
+}
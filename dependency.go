@@ -0,0 +1,178 @@
+package crontinuous
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Constants
+// --------------------------------------------------------------------------------------------
+
+// defaultAfterWindow is how old the run a job depends on may be if no after-window is set
+const defaultAfterWindow = 24 * time.Hour
+
+// dependencyPollInterval is how often a job checks whether the jobs it waits for are done
+const dependencyPollInterval = time.Second
+
+// --------------------------------------------------------------------------------------------
+// ~ Struct
+// --------------------------------------------------------------------------------------------
+
+// runResult is the outcome of the most recent run of a job
+type runResult struct {
+	Event    Event
+	Finished time.Time
+}
+
+// --------------------------------------------------------------------------------------------
+// ~ Variables
+// --------------------------------------------------------------------------------------------
+
+var (
+	// lastResults are kept by id, so dependencies hold when the crontab is reloaded
+	lastResults     = map[string]runResult{}
+	lastResultsLock sync.Mutex
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Private methods
+// --------------------------------------------------------------------------------------------
+
+// parseAfter parses a comma separated list of job ids
+func parseAfter(value string) []string {
+	ids := []string{}
+	for _, id := range strings.Split(value, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// recordResult remembers the outcome of a run for the jobs depending on it
+func recordResult(n *Notification) {
+	result := runResult{Event: n.Event, Finished: n.Start.Add(n.Duration)}
+	lastResultsLock.Lock()
+	lastResults[n.Job.ID] = result
+	lastResultsLock.Unlock()
+	if err := jobsState.setLastResult(n.Job.ID, result); err != nil {
+		n.Job.contextLogger.Error("failed to save state", err)
+	}
+}
+
+// lastResult returns the outcome of the most recent run of a job, falling back to the persisted state after a restart
+func lastResult(id string) (runResult, bool) {
+	lastResultsLock.Lock()
+	result, ok := lastResults[id]
+	lastResultsLock.Unlock()
+	if ok {
+		return result, true
+	}
+	return jobsState.lastResult(id)
+}
+
+// dependenciesMet waits for the jobs the runnable runs after to finish
+// and reports whether their most recent runs succeeded within the window
+func (r *Runnable) dependenciesMet() bool {
+	if len(r.After) == 0 {
+		return true
+	}
+	window := r.AfterWindow
+	if window <= 0 {
+		window = defaultAfterWindow
+	}
+	deadline := time.Now().Add(window)
+	for _, id := range r.After {
+		logger := r.contextLogger.WithField("after", id)
+		for {
+			job := findJob(id)
+			if job == nil || job.activeRuns() == 0 {
+				break
+			}
+			if time.Now().After(deadline) {
+				logger.Warn("job is still running, skipping run")
+				return false
+			}
+			time.Sleep(dependencyPollInterval)
+		}
+		result, ok := lastResult(id)
+		switch {
+		case !ok:
+			logger.Info("job did not run yet, skipping run")
+			return false
+		case result.Event != EventSuccess:
+			logger.WithField("status", result.Event).Info("last run of job did not succeed, skipping run")
+			return false
+		case time.Since(result.Finished) > window:
+			logger.WithFields(log.Fields{
+				"finished": result.Finished,
+				"window":   window,
+			}).Info("last successful run of job is too old, skipping run")
+			return false
+		}
+	}
+	return true
+}
+
+// dependencyErrors reports jobs running after unknown jobs and cycles of jobs running after each other
+func dependencyErrors(jobs []*Runnable) []error {
+	errs := []error{}
+	byID := map[string]*Runnable{}
+	for _, r := range jobs {
+		byID[r.ID] = r
+	}
+	for _, r := range jobs {
+		for _, id := range r.After {
+			if _, ok := byID[id]; !ok {
+				errs = append(errs, fmt.Errorf("%s: job %s runs after unknown job %s", r.File, r.ID, id))
+			}
+		}
+	}
+
+	// depth first search, a job seen again on the current path closes a cycle
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := map[string]int{}
+	var visit func(r *Runnable, path []string)
+	visit = func(r *Runnable, path []string) {
+		state[r.ID] = visiting
+		path = append(path, r.ID)
+		for _, id := range r.After {
+			next, ok := byID[id]
+			if !ok {
+				continue
+			}
+			switch state[id] {
+			case visiting:
+				cycle := append(path[indexOf(path, id):], id)
+				errs = append(errs, fmt.Errorf("%s: jobs run after each other in a cycle: %s", r.File, strings.Join(cycle, " -> ")))
+			case unvisited:
+				visit(next, path)
+			}
+		}
+		state[r.ID] = visited
+	}
+	for _, r := range jobs {
+		if state[r.ID] == unvisited {
+			visit(r, nil)
+		}
+	}
+	return errs
+}
+
+func indexOf(values []string, value string) int {
+	for i, v := range values {
+		if v == value {
+			return i
+		}
+	}
+	return -1
+}
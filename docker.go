@@ -0,0 +1,77 @@
+package crontinuous
+
+import (
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Constants
+// --------------------------------------------------------------------------------------------
+
+// PullPolicy decides when the image of a container job is pulled
+type PullPolicy string
+
+const (
+	// PullMissing pulls images not present on the host yet
+	PullMissing PullPolicy = "missing"
+	// PullAlways pulls the image before every run
+	PullAlways PullPolicy = "always"
+	// PullNever only uses images present on the host
+	PullNever PullPolicy = "never"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Variables
+// --------------------------------------------------------------------------------------------
+
+var containerNameRegexp = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// --------------------------------------------------------------------------------------------
+// ~ Private methods
+// --------------------------------------------------------------------------------------------
+
+func parsePullPolicy(value string) (PullPolicy, error) {
+	switch policy := PullPolicy(value); policy {
+	case PullMissing, PullAlways, PullNever:
+		return policy, nil
+	}
+	return "", fmt.Errorf("unknown pull policy %q, expected missing, always or never", value)
+}
+
+// containerName names the container of a run, so it can be removed if the docker client is killed.
+// The name is valid for kubernetes jobs as well.
+func (r *Runnable) containerName(runID string) string {
+	id := strings.Trim(containerNameRegexp.ReplaceAllString(strings.ToLower(r.ID), "-"), "-")
+	if len(id) > 30 {
+		id = id[:30]
+	}
+	return "crontinuous-" + id + "-" + runID[:12]
+}
+
+// dockerCommand returns the command line running the job in a container of its image with the
+// given environment, its output is streamed through the attached docker client. The values of
+// secrets are passed on from the environment of the docker client, so they do not show in its arguments.
+func (r *Runnable) dockerCommand(runID string, env []string, secrets []string, commandLine string) *exec.Cmd {
+	args := []string{"run", "--rm", "--pull", string(r.Pull), "--name", r.containerName(runID)}
+	if r.Limits != nil && r.Limits.CPU > 0 {
+		args = append(args, "--cpus", strconv.FormatFloat(r.Limits.CPU, 'f', -1, 64))
+	}
+	if r.Limits != nil && r.Limits.Memory > 0 {
+		args = append(args, "--memory", strconv.FormatInt(r.Limits.Memory, 10))
+	}
+	for _, variable := range append(env, secrets...) {
+		args = append(args, "--env", variable)
+	}
+	args = append(args, r.Image, "/bin/sh", "-c", commandLine)
+	return exec.Command(*dockerBinary, args...)
+}
+
+// removeContainer removes the container of a run, in case the docker client was killed before it did
+func (r *Runnable) removeContainer(runID string) {
+	// the container is usually gone already, so the result does not matter
+	exec.Command(*dockerBinary, "rm", "--force", r.containerName(runID)).Run()
+}
@@ -0,0 +1,125 @@
+package crontinuous
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Struct
+// --------------------------------------------------------------------------------------------
+
+// DrainStatus tells whether crontinuous is draining and which runs it waits for
+type DrainStatus struct {
+	Draining bool      `json:"draining"`
+	Since    time.Time `json:"since,omitempty"`
+	// Drained is true once no run is left while draining
+	Drained bool     `json:"drained"`
+	Running int      `json:"running"`
+	Jobs    []string `json:"jobs"`
+}
+
+// --------------------------------------------------------------------------------------------
+// ~ Variables
+// --------------------------------------------------------------------------------------------
+
+var (
+	// drainingSince is set while no new runs are launched, e.g. before rebooting the host
+	drainingSince time.Time
+	// runningJobs counts the runs of every job id, of jobs reloaded meanwhile too
+	runningJobs = map[string]int{}
+	drainLock   sync.Mutex
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Private methods
+// --------------------------------------------------------------------------------------------
+
+// drain stops launching new runs, running ones finish
+func drain() DrainStatus {
+	drainLock.Lock()
+	if drainingSince.IsZero() {
+		drainingSince = time.Now()
+	}
+	drainLock.Unlock()
+	return drainStatus()
+}
+
+// undrain launches runs again, runs due while draining are not caught up
+func undrain() DrainStatus {
+	drainLock.Lock()
+	drainingSince = time.Time{}
+	drainLock.Unlock()
+	return drainStatus()
+}
+
+func isDraining() bool {
+	drainLock.Lock()
+	defer drainLock.Unlock()
+	return !drainingSince.IsZero()
+}
+
+func drainStatus() DrainStatus {
+	drainLock.Lock()
+	defer drainLock.Unlock()
+	status := DrainStatus{Draining: !drainingSince.IsZero(), Since: drainingSince, Jobs: []string{}}
+	for id, runs := range runningJobs {
+		status.Running += runs
+		status.Jobs = append(status.Jobs, id)
+	}
+	sort.Strings(status.Jobs)
+	status.Drained = status.Draining && status.Running == 0
+	return status
+}
+
+// startRun counts a run of a job unless crontinuous is draining
+func startRun(id string) bool {
+	drainLock.Lock()
+	defer drainLock.Unlock()
+	if !drainingSince.IsZero() {
+		return false
+	}
+	runningJobs[id]++
+	return true
+}
+
+// finishedRun counts a run of a job as finished, logging when it was the last one while draining
+func finishedRun(id string) {
+	drainLock.Lock()
+	defer drainLock.Unlock()
+	runningJobs[id]--
+	if runningJobs[id] <= 0 {
+		delete(runningJobs, id)
+	}
+	if !drainingSince.IsZero() && len(runningJobs) == 0 {
+		log.WithField("since", drainingSince).Info("drained, all runs finished")
+	}
+}
+
+// handleDrain tells whether crontinuous is draining on GET and starts draining on POST
+func handleDrain(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		jsonReply(w, drainStatus())
+	case "POST":
+		status := drain()
+		log.WithFields(log.Fields{"remote": r.RemoteAddr, "running": status.Running}).Info("draining on request, launching no new runs")
+		jsonReply(w, status)
+	default:
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+// handleUndrain launches runs again
+func handleUndrain(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	log.WithField("remote", r.RemoteAddr).Info("undraining on request, launching runs again")
+	jsonReply(w, undrain())
+}
@@ -0,0 +1,30 @@
+package crontinuous
+
+import (
+	"testing"
+)
+
+func TestDrain(t *testing.T) {
+	defer undrain()
+	if !startRun("drain") {
+		t.Fatal("expected runs to start before draining")
+	}
+	if status := drain(); !status.Draining || status.Drained || status.Running != 1 || len(status.Jobs) != 1 || status.Jobs[0] != "drain" {
+		t.Errorf("expected to wait for the running job, got %+v", status)
+	}
+	if startRun("drain") {
+		t.Error("expected no run to start while draining")
+	}
+	finishedRun("drain")
+	if status := drainStatus(); !status.Drained || status.Running != 0 {
+		t.Errorf("expected to be drained, got %+v", status)
+	}
+
+	if status := undrain(); status.Draining || status.Drained {
+		t.Errorf("expected not to be draining, got %+v", status)
+	}
+	if !startRun("drain") {
+		t.Error("expected runs to start again")
+	}
+	finishedRun("drain")
+}
@@ -0,0 +1,155 @@
+package crontinuous
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/robfig/cron"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Constants
+// --------------------------------------------------------------------------------------------
+
+// DSTPolicy decides about runs at wall clock times daylight saving time skips or repeats
+type DSTPolicy string
+
+const (
+	// DSTOnce runs at skipped times when the clock jumps past them and at repeated times the first time
+	DSTOnce DSTPolicy = "once"
+	// DSTSkip leaves out runs at skipped times and runs at repeated times the first time
+	DSTSkip DSTPolicy = "skip"
+	// DSTShift runs at skipped times shifted by the jump, e.g. 02:30 at 03:30, and at repeated times the second time
+	DSTShift DSTPolicy = "shift"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Struct
+// --------------------------------------------------------------------------------------------
+
+// dstSchedule evaluates a schedule on the wall clock and maps its runs to the time zone they are
+// asked for, deciding about the runs daylight saving time skips or repeats by a policy
+type dstSchedule struct {
+	// schedule is evaluated in utc, which has no daylight saving time
+	schedule cron.Schedule
+	policy   DSTPolicy
+	logger   *log.Entry
+	lock     sync.Mutex
+	// logged is the wall clock time of the last decision logged, Next is asked for the same run many times
+	logged time.Time
+}
+
+// --------------------------------------------------------------------------------------------
+// ~ Public methods
+// --------------------------------------------------------------------------------------------
+
+// Next implements cron.Schedule
+func (s *dstSchedule) Next(t time.Time) time.Time {
+	location := t.Location()
+	wall := wallClock(t)
+	for {
+		if wall = s.schedule.Next(wall); wall.IsZero() {
+			return wall
+		}
+		next, decision := s.resolve(wall, location)
+		// runs at repeated times already passed are not run again
+		if next.IsZero() || next.After(t) {
+			if decision != "" {
+				s.log(wall, next, decision)
+			}
+			if !next.IsZero() {
+				return next
+			}
+		}
+	}
+}
+
+// --------------------------------------------------------------------------------------------
+// ~ Private methods
+// --------------------------------------------------------------------------------------------
+
+func parseDSTPolicy(value string) (DSTPolicy, error) {
+	switch policy := DSTPolicy(strings.ToLower(strings.TrimSpace(value))); policy {
+	case DSTOnce, DSTSkip, DSTShift:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unknown dst policy %q, expected once, skip or shift", value)
+	}
+}
+
+// hasFixedHours reports whether a schedule with a leading seconds field runs at fixed hours, which
+// daylight saving time may skip or repeat. Jobs running every hour keep running by the hour, like in cron.
+func hasFixedHours(fields []string) bool {
+	return len(fields) >= 6 && fields[2] != "*"
+}
+
+// resolve returns when a run at a wall clock time happens in a location, the zero time if it
+// is skipped, and the decision made if daylight saving time skips or repeats the time
+func (s *dstSchedule) resolve(wall time.Time, location *time.Location) (time.Time, string) {
+	// no location changes its offset twice within two days
+	_, before := wall.Add(-24 * time.Hour).In(location).Zone()
+	_, after := wall.Add(24 * time.Hour).In(location).Zone()
+	earlier := wall.Add(-time.Duration(before) * time.Second).In(location)
+	later := wall.Add(-time.Duration(after) * time.Second).In(location)
+	if before == after {
+		return earlier, ""
+	}
+	if earlier.After(later) {
+		earlier, later = later, earlier
+	}
+	earlierValid, laterValid := wallClock(earlier).Equal(wall), wallClock(later).Equal(wall)
+	switch {
+	case earlierValid && laterValid:
+		if s.policy == DSTShift {
+			return later, "time is repeated, running the second time"
+		}
+		return earlier, "time is repeated, running the first time only"
+	case earlierValid:
+		return earlier, ""
+	case laterValid:
+		return later, ""
+	}
+
+	// the clock jumps past the time, between the candidates
+	switch s.policy {
+	case DSTSkip:
+		return time.Time{}, "time is skipped, skipping the run"
+	case DSTShift:
+		return later, "time is skipped, running shifted by the jump"
+	}
+	jump := earlier
+	for step := later.Sub(earlier) / 2; step > 0; step /= 2 {
+		if _, offset := jump.Add(step).Zone(); offset == before {
+			jump = jump.Add(step)
+		}
+	}
+	// clocks jump at whole seconds
+	return jump.Truncate(time.Second).Add(time.Second), "time is skipped, running when the clock jumps past it"
+}
+
+// log logs a decision once for every run it is made for
+func (s *dstSchedule) log(wall time.Time, next time.Time, decision string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.logged.Equal(wall) {
+		return
+	}
+	s.logged = wall
+	logger := s.logger
+	if logger == nil {
+		logger = log.NewEntry(log.StandardLogger())
+	}
+	fields := log.Fields{"wallClock": wall.Format("2006-01-02 15:04:05"), "dst": s.policy}
+	if !next.IsZero() {
+		fields["at"] = next.Format(time.RFC3339)
+	}
+	logger.WithFields(fields).Info("daylight saving time: ", decision)
+}
+
+// wallClock returns the wall clock time of t in utc
+func wallClock(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+}
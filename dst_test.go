@@ -0,0 +1,63 @@
+package crontinuous
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDSTPolicy(t *testing.T) {
+	location, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("no time zone data: ", err)
+	}
+	crontab := "CRON_TZ=Europe/Berlin\n" +
+		"# name: once\n30 2 * * * once\n" +
+		"# name: skip\n# dst: skip\n30 2 * * * skip\n" +
+		"# name: shift\n# dst: shift\n30 2 * * * shift\n" +
+		"# name: every\n*/20 2 * * * every\n" +
+		"# name: hourly\n@hourly hourly\n" +
+		"# dst: sometimes\n30 2 * * * broken\n"
+	jobs, errs := parseCrontabReader("dst", strings.NewReader(crontab), nil)
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "unknown dst policy") {
+		t.Errorf("expected an error for an unknown policy, got %v", errs)
+	}
+	runs := func(id string, from time.Time, n int) string {
+		for _, r := range jobs {
+			if r.ID != id {
+				continue
+			}
+			times := []string{}
+			for next := from; len(times) < n; {
+				next = r.schedule.Next(next)
+				times = append(times, next.Format("01-02 15:04 MST"))
+			}
+			return strings.Join(times, ", ")
+		}
+		return ""
+	}
+
+	// on the 27th of March 2016 the clocks jumped from 02:00 to 03:00, on the 30th of October from 03:00 back to 02:00
+	spring := time.Date(2016, 3, 26, 12, 0, 0, 0, location)
+	fall := time.Date(2016, 10, 29, 12, 0, 0, 0, location)
+	for _, test := range []struct {
+		id   string
+		from time.Time
+		n    int
+		want string
+	}{
+		{"once", spring, 2, "03-27 03:00 CEST, 03-28 02:30 CEST"},
+		{"once", fall, 2, "10-30 02:30 CEST, 10-31 02:30 CET"},
+		{"skip", spring, 1, "03-28 02:30 CEST"},
+		{"skip", fall, 2, "10-30 02:30 CEST, 10-31 02:30 CET"},
+		{"shift", spring, 1, "03-27 03:30 CEST"},
+		{"shift", fall, 2, "10-30 02:30 CET, 10-31 02:30 CET"},
+		{"every", spring, 2, "03-27 03:00 CEST, 03-28 02:00 CEST"},
+		{"every", fall, 4, "10-30 02:00 CEST, 10-30 02:20 CEST, 10-30 02:40 CEST, 10-31 02:00 CET"},
+		{"hourly", time.Date(2016, 10, 30, 1, 30, 0, 0, location), 3, "10-30 02:00 CEST, 10-30 02:00 CET, 10-30 03:00 CET"},
+	} {
+		if got := runs(test.id, test.from, test.n); got != test.want {
+			t.Errorf("%s from %s: got %s, want %s", test.id, test.from.Format("01-02"), got, test.want)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package crontinuous
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Struct
+// --------------------------------------------------------------------------------------------
+
+// etcdLock is a leaderLock on a key attached to a lease, using the json gateway of the etcd v3 api
+type etcdLock struct {
+	endpoints []string
+	key       string
+	value     string
+	ttl       time.Duration
+	lease     string
+	client    *http.Client
+}
+
+// etcdLeaseResponse is the reply to granting and keeping alive a lease, int64s are strings in json
+type etcdLeaseResponse struct {
+	ID  string `json:"ID"`
+	TTL string `json:"TTL"`
+}
+
+// etcdKeyValue is a key of a range response, keys and values are base64 in json
+type etcdKeyValue struct {
+	Value string `json:"value"`
+	Lease string `json:"lease"`
+}
+
+// --------------------------------------------------------------------------------------------
+// ~ Private methods
+// --------------------------------------------------------------------------------------------
+
+// newEtcdLock creates a lock for comma separated endpoints like http://etcd-1:2379,http://etcd-2:2379
+func newEtcdLock(endpoints string, key string, value string, ttl time.Duration) (*etcdLock, error) {
+	l := &etcdLock{
+		key:    "/" + strings.Trim(key, "/"),
+		value:  value,
+		ttl:    ttl,
+		client: &http.Client{Timeout: 5 * time.Second},
+	}
+	for _, endpoint := range strings.Split(endpoints, ",") {
+		if endpoint = strings.TrimRight(strings.TrimSpace(endpoint), "/"); endpoint != "" {
+			l.endpoints = append(l.endpoints, endpoint)
+		}
+	}
+	if len(l.endpoints) == 0 {
+		return nil, fmt.Errorf("no etcd endpoints in %q", endpoints)
+	}
+	if ttl < time.Second {
+		return nil, fmt.Errorf("ttl %s is too short, etcd ttls are in seconds", ttl)
+	}
+	return l, nil
+}
+
+// acquire grants a lease and puts the key with it in a transaction, only if the key does not exist
+func (l *etcdLock) acquire() (bool, error) {
+	lease := etcdLeaseResponse{}
+	if err := l.request("/v3/lease/grant", map[string]interface{}{"TTL": l.ttlSeconds()}, &lease); err != nil {
+		return false, err
+	}
+	txn := struct {
+		Succeeded bool `json:"succeeded"`
+	}{}
+	key := l.encode(l.key)
+	if err := l.request("/v3/kv/txn", map[string]interface{}{
+		"compare": []map[string]interface{}{{"key": key, "target": "CREATE", "result": "EQUAL", "create_revision": "0"}},
+		"success": []map[string]interface{}{{"request_put": map[string]interface{}{"key": key, "value": l.encode(l.value), "lease": lease.ID}}},
+	}, &txn); err != nil {
+		l.revoke(lease.ID)
+		return false, err
+	}
+	if !txn.Succeeded {
+		l.revoke(lease.ID)
+		return false, nil
+	}
+	l.lease = lease.ID
+	return true, nil
+}
+
+// refresh keeps the lease alive and checks that the key is still ours, it is not if the lease expired meanwhile
+func (l *etcdLock) refresh() (bool, error) {
+	if l.lease == "" {
+		return false, nil
+	}
+	keepAlive := struct {
+		Result etcdLeaseResponse `json:"result"`
+	}{}
+	if err := l.request("/v3/lease/keepalive", map[string]interface{}{"ID": l.lease}, &keepAlive); err != nil {
+		return false, err
+	}
+	if ttl, _ := strconv.Atoi(keepAlive.Result.TTL); ttl <= 0 {
+		l.lease = ""
+		return false, nil
+	}
+	current := struct {
+		Kvs []etcdKeyValue `json:"kvs"`
+	}{}
+	if err := l.request("/v3/kv/range", map[string]interface{}{"key": l.encode(l.key)}, &current); err != nil {
+		return false, err
+	}
+	if len(current.Kvs) == 0 || current.Kvs[0].Lease != l.lease || current.Kvs[0].Value != l.encode(l.value) {
+		l.revoke(l.lease)
+		l.lease = ""
+		return false, nil
+	}
+	return true, nil
+}
+
+// release revokes the lease, which deletes the key with it
+func (l *etcdLock) release() error {
+	if l.lease == "" {
+		return nil
+	}
+	err := l.revoke(l.lease)
+	l.lease = ""
+	return err
+}
+
+func (l *etcdLock) revoke(lease string) error {
+	return l.request("/v3/lease/revoke", map[string]interface{}{"ID": lease}, nil)
+}
+
+func (l *etcdLock) ttlSeconds() string {
+	return strconv.Itoa(int(l.ttl / time.Second))
+}
+
+func (l *etcdLock) encode(s string) string {
+	return base64.StdEncoding.EncodeToString([]byte(s))
+}
+
+// request posts to the endpoints in turn until one replies and decodes the reply into v if not nil
+func (l *etcdLock) request(path string, body interface{}, v interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	var lastErr error
+	for _, endpoint := range l.endpoints {
+		resp, err := l.client.Post(endpoint+path, "application/json", bytes.NewReader(payload))
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		data, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if resp.StatusCode != http.StatusOK {
+			lastErr = fmt.Errorf("unexpected status %s from %s: %s", resp.Status, endpoint, strings.TrimSpace(string(data)))
+			continue
+		}
+		if v == nil {
+			return nil
+		}
+		// streamed replies like those of keepalive are json objects one after another, the first is ours
+		return json.NewDecoder(bytes.NewReader(data)).Decode(v)
+	}
+	return lastErr
+}
@@ -0,0 +1,59 @@
+package crontinuous
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Struct
+// --------------------------------------------------------------------------------------------
+
+// execution tracks a single run of a job including all of its retries
+type execution struct {
+	id        string
+	start     time.Time
+	process   *os.Process
+	finished  chan struct{}
+	aborted   chan struct{}
+	abortOnce sync.Once
+	// logger tags every entry of the run with its id, so overlapping runs of a job can be told apart
+	logger *log.Entry
+	// revision is the version of the crontab the job was loaded from when the run started
+	revision string
+	// env is added to the environment of this run only, e.g. the file of @onfile jobs
+	env []string
+	// queued tells that the run waited for a free slot, waited for how long
+	queued bool
+	waited time.Duration
+}
+
+// --------------------------------------------------------------------------------------------
+// ~ Private methods
+// --------------------------------------------------------------------------------------------
+
+func newExecution(logger *log.Entry, revision string) *execution {
+	id := newRunID()
+	logger = logger.WithField("runId", id)
+	if revision != "" {
+		logger = logger.WithField("revision", revision)
+	}
+	return &execution{
+		id:       id,
+		start:    time.Now(),
+		finished: make(chan struct{}),
+		aborted:  make(chan struct{}),
+		logger:   logger,
+		revision: revision,
+	}
+}
+
+// abort tells the execution to give up, e.g. not to retry anymore
+func (e *execution) abort() {
+	e.abortOnce.Do(func() {
+		close(e.aborted)
+	})
+}
@@ -0,0 +1,397 @@
+package crontinuous
+
+import (
+	"fmt"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Struct
+// --------------------------------------------------------------------------------------------
+
+// Executor runs the attempts of jobs, it is picked per job with the executor annotation
+type Executor interface {
+	// Execute runs an attempt and reports its output and outcome through the attempt
+	Execute(a *Attempt)
+}
+
+// Attempt is a single attempt of a run handed to an executor. Command and arguments are expanded,
+// Env holds the crontab variables, the trace context and the secrets of the job. Executors call
+// Started once the job runs, report its output with Output and its outcome with Finish, an attempt
+// that is not finished fails.
+type Attempt struct {
+	Job     *Job
+	Command string
+	Args    string
+	// Argv are the arguments if they were given one by one instead of a command line
+	Argv   []string
+	Env    []string
+	Result *Notification
+	Logger *log.Entry
+
+	execution *execution
+	secrets   *resolvedSecrets
+	output    *runOutput
+}
+
+// shellExecutor runs the command line with the shell of the job
+type shellExecutor struct{}
+
+// directExecutor runs the command without a shell, its arguments split like a shell does
+type directExecutor struct{}
+
+// dockerExecutor runs the command in a container of the image of the job
+type dockerExecutor struct{}
+
+// kubernetesExecutor runs the command as a kubernetes job created from the template of the job
+type kubernetesExecutor struct{}
+
+// runOutput takes the output of an attempt to the logs, the run log and the notification
+type runOutput struct {
+	logger  *log.Entry
+	runID   string
+	job     *Runnable
+	secrets *resolvedSecrets
+	logFile *rotatingFile
+	runLog  *runLog
+	output  *cappedBuffer
+	// stderr keeps the last lines of stderr for notifications
+	stderr *lineTail
+	lines  map[string]int
+	buffer *outputBuffer
+	bytes  int64
+}
+
+// --------------------------------------------------------------------------------------------
+// ~ Variables
+// --------------------------------------------------------------------------------------------
+
+var (
+	executors = map[string]Executor{
+		"shell":      shellExecutor{},
+		"exec":       directExecutor{},
+		"docker":     dockerExecutor{},
+		"kubernetes": kubernetesExecutor{},
+		"http":       httpExecutor{},
+		"publish":    publishExecutor{},
+	}
+	executorsLock sync.RWMutex
+	// jobVariableRegexp matches ${NAME} in requests and messages, replaced with variables and secrets of the job
+	jobVariableRegexp = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Public methods
+// --------------------------------------------------------------------------------------------
+
+// RegisterExecutor makes an executor available to the executor annotation under the given name,
+// jobs using it have to be loaded after it was registered
+func RegisterExecutor(name string, executor Executor) {
+	executorsLock.Lock()
+	defer executorsLock.Unlock()
+	executors[name] = executor
+}
+
+// Started tells that the job runs, sending the start notification
+func (a *Attempt) Started() {
+	a.Job.notify(&Notification{Event: EventStart, RunID: a.Result.RunID, Attempt: a.Result.Attempt, Start: a.Result.Start, Revision: a.Result.Revision})
+}
+
+// Output reports a line of output of the job on stdout or stderr, secrets are masked
+func (a *Attempt) Output(stream string, line string) {
+	a.outputSink().add(stream, line, len(line)+1)
+}
+
+// Finish reports the outcome of the attempt. An exit code of 0 or above is mapped to an event by
+// the exit codes of the job, a negative one fails with the error.
+func (a *Attempt) Finish(exitCode int, err error) {
+	a.Result.ExitCode = exitCode
+	a.Result.Err = err
+	if exitCode >= 0 {
+		a.Job.classify(a.Result)
+	} else if err == nil {
+		a.Result.Err = fmt.Errorf("exit code %d", exitCode)
+	}
+}
+
+// Aborted is closed when the run is to be given up, e.g. when it is replaced by the next one
+func (a *Attempt) Aborted() <-chan struct{} {
+	return a.execution.aborted
+}
+
+// Execute implements Executor, the command is not looked up as it may be a builtin of the shell
+// like cd or a variable assignment, the shell reports a missing command
+func (shellExecutor) Execute(a *Attempt) {
+	a.runProcess(shellCommand(a.Job.Shell, commandLine(a.Command, a.Args)), true)
+}
+
+// Execute implements Executor
+func (directExecutor) Execute(a *Attempt) {
+	if _, err := exec.LookPath(a.Command); err != nil {
+		a.fail(err)
+		return
+	}
+	// arguments given one by one are passed as they are
+	argv := a.Argv
+	if len(argv) == 0 {
+		var err error
+		if argv, err = splitQuoted(a.Args); err != nil {
+			a.fail(err)
+			return
+		}
+	}
+	a.runProcess(exec.Command(a.Command, argv...), true)
+}
+
+// Execute implements Executor, the command is looked up in the container
+func (dockerExecutor) Execute(a *Attempt) {
+	if _, err := exec.LookPath(*dockerBinary); err != nil {
+		a.fail(err)
+		return
+	}
+	r := a.Job
+	cmd := r.dockerCommand(a.execution.id, r.runEnv(a.execution, a.Result), a.secrets.names, commandLine(a.Command, a.Args))
+	defer r.removeContainer(a.execution.id)
+	// containers are limited by docker
+	a.runProcess(cmd, false)
+}
+
+// --------------------------------------------------------------------------------------------
+// ~ Private methods
+// --------------------------------------------------------------------------------------------
+
+// executorName returns the executor of the job, the annotation wins over what the job looks like
+func (r *Runnable) executorName() string {
+	switch {
+	case r.Executor != "":
+		return r.Executor
+	case r.Kubernetes != "":
+		return "kubernetes"
+	case r.Image != "":
+		return "docker"
+	case len(r.argv) > 0 || r.Shell == "none" || r.Shell == "go":
+		return "exec"
+	}
+	return "shell"
+}
+
+// lookupExecutor returns the executor registered under the name
+func lookupExecutor(name string) (Executor, bool) {
+	executorsLock.RLock()
+	defer executorsLock.RUnlock()
+	executor, ok := executors[name]
+	return executor, ok
+}
+
+// executorNames returns the names of the registered executors
+func executorNames() []string {
+	executorsLock.RLock()
+	defer executorsLock.RUnlock()
+	names := []string{}
+	for name := range executors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// expandVariables replaces ${NAME} with the variable or secret of the job, unknown ones with nothing
+func (a *Attempt) expandVariables(text string) string {
+	return jobVariableRegexp.ReplaceAllStringFunc(text, func(variable string) string {
+		name := variable[2 : len(variable)-1]
+		for i := len(a.Env) - 1; i >= 0; i-- {
+			if strings.HasPrefix(a.Env[i], name+"=") {
+				return strings.TrimPrefix(a.Env[i], name+"=")
+			}
+		}
+		return ""
+	})
+}
+
+// fail ends an attempt that could not run
+func (a *Attempt) fail(err error) {
+	a.Logger.Error(err)
+	a.Result.Err = err
+}
+
+func (a *Attempt) outputSink() *runOutput {
+	if a.output == nil {
+		a.output = newRunOutput(a.Job, a.execution, a.Result.Attempt, a.secrets)
+	}
+	return a.output
+}
+
+// finishOutput hands the output to the notification once the attempt is over
+func (a *Attempt) finishOutput() {
+	if a.output != nil {
+		a.output.close(a.Result)
+	}
+}
+
+// runProcess runs the command of an attempt as a local process, limited by the resource limits of
+// the job if limit is set
+func (a *Attempt) runProcess(cmd *exec.Cmd, limit bool) {
+	r, e, result := a.Job, a.execution, a.Result
+
+	// jobs get a process group of their own, so they are killed including their children,
+	// and drop privileges if they run as another user
+	prepareProcess(cmd, r.runAs)
+	if r.runAs != nil {
+		cmd.Env = r.runAs.environ()
+	}
+
+	// crontab variables are passed to jobs like cron does, next to the trace context
+	if cmd.Env == nil {
+		cmd.Env = jobEnviron()
+	}
+	cmd.Env = append(cmd.Env, a.Env...)
+
+	// prepare cmd logging
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		log.Fatal(err)
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var limits *cgroup
+	if limit {
+		limits, err = r.newCgroup(e.id)
+		if err != nil {
+			e.logger.Error("failed to limit resources", err)
+			result.Err = err
+			return
+		}
+		defer limits.remove()
+	}
+
+	// run cmd
+	if err := r.start(cmd); err != nil {
+		a.fail(err)
+		return
+	}
+	if err := limits.add(cmd.Process.Pid); err != nil {
+		e.logger.Error("failed to limit resources", err)
+	}
+	trackProcess(cmd.Process)
+	defer untrackProcess(cmd.Process)
+	r.setProcess(e, cmd.Process)
+	a.Started()
+
+	// kill the cmd once it exceeds its timeout
+	timedOut := make(chan struct{})
+	var timeoutTimer *time.Timer
+	if r.Timeout > 0 {
+		timeoutTimer = time.AfterFunc(r.Timeout, func() {
+			e.logger.WithField("timeout", r.Timeout).Warn("run timed out, killing it")
+			close(timedOut)
+			killProcessGroup(cmd.Process)
+		})
+	}
+
+	output := a.outputSink()
+	for l := range captureOutput(e.logger, stdout, stderr) {
+		output.add(l.stream, l.text, l.size)
+	}
+
+	err = cmd.Wait()
+	if timeoutTimer != nil {
+		timeoutTimer.Stop()
+	}
+
+	result.ExitCode = exitCode(cmd)
+	result.Signal = exitSignal(cmd)
+	// the usage of docker is not the one of the container
+	if limit {
+		result.UserCPU, result.SystemCPU, result.MaxRSS = processUsage(cmd.ProcessState)
+	}
+	result.Err = err
+	if limits.oomKilled() {
+		result.OOMKilled = true
+		result.Err = fmt.Errorf("killed for exceeding the memory limit (%s)", r.Limits)
+		e.logger.Warn(result.Err)
+	}
+	select {
+	case <-timedOut:
+		result.Event = EventTimeout
+	default:
+		if cmd.ProcessState != nil && cmd.ProcessState.Exited() && !result.OOMKilled {
+			r.classify(result)
+		}
+	}
+}
+
+// newRunOutput opens the logs of an attempt
+func newRunOutput(r *Runnable, e *execution, attempt int, secrets *resolvedSecrets) *runOutput {
+	o := &runOutput{
+		logger:  e.logger,
+		runID:   e.id,
+		job:     r,
+		secrets: secrets,
+		logFile: jobLogFile(r.ID),
+		output:  newCappedBuffer(maxNotificationOutput),
+		stderr:  newLineTail(stderrTailLines),
+		lines:   map[string]int{},
+	}
+	var err error
+	if o.runLog, err = openRunLog(r.ID, e.id, attempt); err != nil {
+		e.logger.Error("failed to open run log", err)
+	}
+	if !*logStream {
+		o.buffer = newOutputBuffer(*logBuffer, func(output string) {
+			e.logger.WithField("output", output).Info("command std output")
+		})
+		o.buffer.start(*logFlushInterval)
+	}
+	return o
+}
+
+// add takes a line of output, size is its size including the line break
+func (o *runOutput) add(stream string, text string, size int) {
+	o.lines[stream]++
+	o.bytes += int64(size)
+	text = o.secrets.mask(text)
+	o.output.add(text)
+	o.job.addOutput(o.runID, text)
+	if err := o.logFile.writeLine(o.runID, stream, text); err != nil {
+		o.logger.Error("failed to write log file", err)
+	}
+	if err := o.runLog.writeLine(stream, text); err != nil {
+		o.logger.Error("failed to write run log", err)
+	}
+	if stream == "stderr" {
+		stderrLogger := o.logger
+		if *logStream {
+			stderrLogger = stderrLogger.WithField("line", o.lines[stream])
+		}
+		stderrLogger.WithField("output", text).Warn("command std error")
+		o.stderr.add(text)
+		return
+	}
+	if *logStream {
+		o.logger.WithFields(log.Fields{
+			"line":   o.lines[stream],
+			"output": text,
+		}).Info("command std output")
+		return
+	}
+	o.buffer.add(text)
+}
+
+// close logs the buffered output and hands the output to the notification
+func (o *runOutput) close(result *Notification) {
+	o.buffer.stop()
+	o.runLog.close()
+	result.OutputBytes += o.bytes
+	result.Output = o.output.String()
+	result.Stderr = o.stderr.String()
+}
@@ -0,0 +1,82 @@
+package crontinuous
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+)
+
+type echoExecutor struct{}
+
+func (echoExecutor) Execute(a *Attempt) {
+	a.Started()
+	a.Output("stdout", a.Command+" "+a.Args)
+	a.Finish(0, nil)
+}
+
+func TestExecutorAnnotation(t *testing.T) {
+	RegisterExecutor("test-echo", echoExecutor{})
+	jobs, errs := parseCrontabReader("executors", strings.NewReader("# executor: test-echo\n* * * * * hello world\n# executor: unknown\n* * * * * hello\n"), nil)
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "unknown executor") {
+		t.Errorf("expected an unknown executor error, got %v", errs)
+	}
+	if len(jobs) != 1 {
+		t.Fatalf("expected 1 job, got %d", len(jobs))
+	}
+	r := jobs[0]
+	result := r.execute(newExecution(r.contextLogger, ""), 1)
+	if result.Event != EventSuccess || result.Err != nil {
+		t.Errorf("expected a successful run, got %s %v", result.Event, result.Err)
+	}
+	if result.Output != "hello world" {
+		t.Errorf("unexpected output %q", result.Output)
+	}
+}
+
+func TestExecutorName(t *testing.T) {
+	tests := []struct {
+		job  *Runnable
+		want string
+	}{
+		{&Runnable{}, "shell"},
+		{&Runnable{Shell: "none"}, "exec"},
+		{&Runnable{argv: []string{"-v"}}, "exec"},
+		{&Runnable{Image: "alpine"}, "docker"},
+		{&Runnable{Kubernetes: "job.yaml", Image: "alpine"}, "kubernetes"},
+		{&Runnable{Executor: "shell", Image: "alpine"}, "shell"},
+	}
+	for _, test := range tests {
+		if got := test.job.executorName(); got != test.want {
+			t.Errorf("executorName() of %+v = %q, want %q", test.job, got, test.want)
+		}
+	}
+}
+
+func TestShellExecutorBuiltins(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a posix shell")
+	}
+	tests := []struct {
+		line       string
+		wantEvent  Event
+		wantOutput string
+	}{
+		{"cd / && pwd", EventSuccess, "/"},
+		{"GREETING=hello sh -c 'echo $GREETING'", EventSuccess, "hello"},
+		{"test -d / && echo dir", EventSuccess, "dir"},
+		// the shell reports a missing command
+		{"no-such-command-of-crontinuous", EventFailure, "not found"},
+	}
+	for _, test := range tests {
+		jobs, errs := parseCrontabReader("builtins", strings.NewReader("# shell: /bin/sh\n0 3 * * * "+test.line+"\n"), nil)
+		if len(errs) != 0 || len(jobs) != 1 {
+			t.Errorf("%q: expected a job, got %v", test.line, errs)
+			continue
+		}
+		r := jobs[0]
+		result := r.execute(newExecution(r.contextLogger, ""), 1)
+		if result.Event != test.wantEvent || !strings.HasSuffix(result.Output, test.wantOutput) {
+			t.Errorf("%q: expected %s with output %q, got %s with %q (%v)", test.line, test.wantEvent, test.wantOutput, result.Event, result.Output, result.Err)
+		}
+	}
+}
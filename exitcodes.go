@@ -0,0 +1,71 @@
+package crontinuous
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Struct
+// --------------------------------------------------------------------------------------------
+
+// exitCodePolicy maps exit codes to the outcome of a run, unmapped codes
+// are a success if zero and a failure otherwise
+type exitCodePolicy map[int]Event
+
+// --------------------------------------------------------------------------------------------
+// ~ Private methods
+// --------------------------------------------------------------------------------------------
+
+// parseExitCodes parses mappings like "3=warning 1,10-19=success"
+func parseExitCodes(value string) (exitCodePolicy, error) {
+	policy := exitCodePolicy{}
+	for _, field := range strings.Fields(value) {
+		parts := strings.SplitN(field, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid exit code mapping %q, expected <codes>=<outcome>", field)
+		}
+		event := Event(parts[1])
+		switch event {
+		case EventSuccess, EventWarning, EventFailure:
+		default:
+			return nil, fmt.Errorf("unknown outcome %q, expected success, warning or failure", parts[1])
+		}
+		for _, codes := range strings.Split(parts[0], ",") {
+			from, to, err := parseExitCodeRange(codes)
+			if err != nil {
+				return nil, err
+			}
+			for code := from; code <= to; code++ {
+				policy[code] = event
+			}
+		}
+	}
+	return policy, nil
+}
+
+// parseExitCodeRange parses a single code like "3" or a range like "10-19"
+func parseExitCodeRange(value string) (int, int, error) {
+	bounds := strings.SplitN(value, "-", 2)
+	from, err := strconv.Atoi(bounds[0])
+	to := from
+	if err == nil && len(bounds) == 2 {
+		to, err = strconv.Atoi(bounds[1])
+	}
+	if err != nil || from < 0 || to > 255 || from > to {
+		return 0, 0, fmt.Errorf("invalid exit code %q, expected codes from 0 to 255", value)
+	}
+	return from, to, nil
+}
+
+// event returns the outcome of a run that exited with the code
+func (p exitCodePolicy) event(code int) Event {
+	if event, ok := p[code]; ok {
+		return event
+	}
+	if code == 0 {
+		return EventSuccess
+	}
+	return EventFailure
+}
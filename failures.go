@@ -0,0 +1,49 @@
+package crontinuous
+
+import (
+	"sync"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Variables
+// --------------------------------------------------------------------------------------------
+
+var (
+	// failureCounts are kept by id like lastResults, so they hold when the crontab is reloaded,
+	// and in the state directory if there is one, so they hold when crontinuous is restarted
+	failureCounts     = map[string]int{}
+	failureCountsLock sync.Mutex
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Private methods
+// --------------------------------------------------------------------------------------------
+
+// countFailures counts the consecutive failed runs of a job by the outcome of its last attempt, a
+// successful run starts over, and returns how many there are
+func countFailures(n *Notification) int {
+	failureCountsLock.Lock()
+	previous := failureCounts[n.Job.ID]
+	switch n.Event {
+	case EventFailure, EventTimeout:
+		failureCounts[n.Job.ID]++
+	default:
+		delete(failureCounts, n.Job.ID)
+	}
+	failures := failureCounts[n.Job.ID]
+	failureCountsLock.Unlock()
+	if failures == previous {
+		return failures
+	}
+	if err := jobsState.setFailures(n.Job.ID, failures); err != nil {
+		n.Job.contextLogger.Error("failed to save state", err)
+	}
+	return failures
+}
+
+// consecutiveFailures returns how many of the latest runs of a job failed in a row
+func consecutiveFailures(id string) int {
+	failureCountsLock.Lock()
+	defer failureCountsLock.Unlock()
+	return failureCounts[id]
+}
@@ -0,0 +1,191 @@
+package crontinuous
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Constants
+// --------------------------------------------------------------------------------------------
+
+const (
+	gcsScope        = "https://www.googleapis.com/auth/devstorage.read_write"
+	gcsMetadataHost = "metadata.google.internal"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Struct
+// --------------------------------------------------------------------------------------------
+
+// gcsStore puts objects into a Google Cloud Storage bucket, authenticated with the service account
+// of $GOOGLE_APPLICATION_CREDENTIALS or the one of the instance or pod
+type gcsStore struct {
+	bucket  string
+	client  *http.Client
+	token   string
+	expires time.Time
+	lock    sync.Mutex
+}
+
+// gcsServiceAccount is the part of a service account key file we are interested in
+type gcsServiceAccount struct {
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+}
+
+// gcsToken is an oauth2 access token
+type gcsToken struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+// --------------------------------------------------------------------------------------------
+// ~ Private methods
+// --------------------------------------------------------------------------------------------
+
+func (g *gcsStore) put(key string, contentType string, body []byte) error {
+	endpoint := "https://storage.googleapis.com"
+	if emulator := os.Getenv("STORAGE_EMULATOR_HOST"); emulator != "" {
+		endpoint = strings.TrimRight(emulator, "/")
+	}
+	req, err := http.NewRequest("POST", endpoint+"/upload/storage/v1/b/"+url.PathEscape(g.bucket)+"/o?uploadType=media&name="+url.QueryEscape(key), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if os.Getenv("STORAGE_EMULATOR_HOST") == "" {
+		token, err := g.accessToken()
+		if err != nil {
+			return fmt.Errorf("no gcs credentials: %s", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		message, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("unexpected status %s from gcs: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
+
+// accessToken returns a cached token until shortly before it expires
+func (g *gcsStore) accessToken() (string, error) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	if g.token != "" && time.Now().Add(time.Minute).Before(g.expires) {
+		return g.token, nil
+	}
+	var token *gcsToken
+	var err error
+	if filename := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS"); filename != "" {
+		token, err = g.serviceAccountToken(filename)
+	} else {
+		token, err = g.metadataToken()
+	}
+	if err != nil {
+		return "", err
+	}
+	g.token = token.AccessToken
+	g.expires = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	return g.token, nil
+}
+
+// serviceAccountToken exchanges a jwt signed with the key of a service account for a token
+func (g *gcsStore) serviceAccountToken(filename string) (*gcsToken, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	account := gcsServiceAccount{}
+	if err := json.Unmarshal(data, &account); err != nil {
+		return nil, err
+	}
+	if account.TokenURI == "" {
+		account.TokenURI = "https://oauth2.googleapis.com/token"
+	}
+	block, _ := pem.Decode([]byte(account.PrivateKey))
+	if block == nil {
+		return nil, errors.New("no private key in " + filename)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("the private key of " + filename + " is no rsa key")
+	}
+
+	now := time.Now()
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	claims, _ := json.Marshal(map[string]interface{}{
+		"iss":   account.ClientEmail,
+		"scope": gcsScope,
+		"aud":   account.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	hash := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+	if err != nil {
+		return nil, err
+	}
+	resp, err := g.client.PostForm(account.TokenURI, url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {unsigned + "." + base64.RawURLEncoding.EncodeToString(signature)},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return decodeGCSToken(resp)
+}
+
+// metadataToken gets a token of the service account of the instance or pod from the metadata server
+func (g *gcsStore) metadataToken() (*gcsToken, error) {
+	host := gcsMetadataHost
+	if custom := os.Getenv("GCE_METADATA_HOST"); custom != "" {
+		host = custom
+	}
+	req, err := http.NewRequest("GET", "http://"+host+"/computeMetadata/v1/instance/service-accounts/default/token", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	return decodeGCSToken(resp)
+}
+
+func decodeGCSToken(resp *http.Response) (*gcsToken, error) {
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s getting a token", resp.Status)
+	}
+	token := &gcsToken{}
+	return token, json.NewDecoder(resp.Body).Decode(token)
+}
@@ -0,0 +1,258 @@
+package crontinuous
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Constants
+// --------------------------------------------------------------------------------------------
+
+const (
+	// gitPrefix marks crontabs in git repositories, e.g. git+https://example.com/ops.git#main:cron.d
+	gitPrefix = "git+"
+	// gitDefaultPath is the crontab in the repository if the source does not name one
+	gitDefaultPath = "crontab"
+	// gitTimeout bounds a single git command
+	gitTimeout = 5 * time.Minute
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Struct
+// --------------------------------------------------------------------------------------------
+
+// gitCrontab keeps a checkout of a crontab in a git repository. Every commit is checked out into
+// its own directory, which is swapped in through the current symlink once its crontab has no
+// problems. The previous checkout is kept for reads still going through the old symlink.
+type gitCrontab struct {
+	// source is where the crontab comes from, without credentials
+	source string
+	url    string
+	// branch is fetched, the default branch of the repository if empty
+	branch string
+	// path of the crontab file or directory in the repository
+	path string
+	// dir holds the bare repository, the checkouts and the current symlink
+	dir  string
+	lock sync.Mutex
+	// commit is the checked out commit
+	commit string
+	// rejected is the last commit with problems, it is not checked again
+	rejected string
+}
+
+// --------------------------------------------------------------------------------------------
+// ~ Variables
+// --------------------------------------------------------------------------------------------
+
+var commitRegexp = regexp.MustCompile(`^[0-9a-f]{40}([0-9a-f]{24})?$`)
+
+// --------------------------------------------------------------------------------------------
+// ~ Private methods
+// --------------------------------------------------------------------------------------------
+
+// newGitCrontab parses a git source like git+<repository>#<branch>:<path>, branch and path being optional
+func newGitCrontab(source string) *gitCrontab {
+	g := &gitCrontab{url: strings.TrimPrefix(source, gitPrefix), path: gitDefaultPath}
+	if i := strings.LastIndex(g.url, "#"); i >= 0 {
+		ref := g.url[i+1:]
+		g.url = g.url[:i]
+		g.branch = ref
+		if j := strings.Index(ref, ":"); j >= 0 {
+			g.branch = ref[:j]
+			if path := strings.Trim(ref[j+1:], "/"); path != "" {
+				g.path = path
+			}
+		}
+	}
+	g.source = gitPrefix + redactURL(g.url) + "#" + g.branch + ":" + g.path
+
+	dir := filepath.Join(os.TempDir(), "crontinuous-git")
+	if *stateDir != "" {
+		dir = filepath.Join(*stateDir, "git")
+	}
+	h := sha1.Sum([]byte(g.url + "#" + g.branch))
+	g.dir = filepath.Join(dir, hex.EncodeToString(h[:8]))
+
+	// a checkout of an earlier start is used until the first update
+	if commit, err := os.Readlink(g.current()); err == nil {
+		g.commit = commit
+	}
+	return g
+}
+
+// current is the symlink to the applied checkout
+func (g *gitCrontab) current() string {
+	return filepath.Join(g.dir, "current")
+}
+
+// local implements remoteCrontab, the crontab is read through the current symlink
+func (g *gitCrontab) local() string {
+	return filepath.Join(g.current(), filepath.FromSlash(g.path))
+}
+
+// origin implements remoteCrontab
+func (g *gitCrontab) origin() string {
+	return g.source
+}
+
+// originOf implements remoteCrontab, files of the repository are named by their path in it
+func (g *gitCrontab) originOf(filename string) string {
+	rel, err := filepath.Rel(g.current(), filename)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return ""
+	}
+	return gitPrefix + redactURL(g.url) + "#" + g.branch + ":" + filepath.ToSlash(rel)
+}
+
+// revision implements remoteCrontab, the sha of the checked out commit
+func (g *gitCrontab) revision() string {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	return g.commit
+}
+
+// run implements remoteCrontab, the watcher reloads the crontab when the current symlink is swapped
+func (g *gitCrontab) run(interval time.Duration) {
+	for {
+		time.Sleep(interval)
+		if err := g.update(); err != nil {
+			log.WithField("crontab", g.source).Error("failed to update crontab: ", err)
+		}
+	}
+}
+
+// update fetches the branch and applies its latest commit if the crontab has no problems
+func (g *gitCrontab) update() error {
+	repo := filepath.Join(g.dir, "repo.git")
+	if _, err := os.Stat(repo); os.IsNotExist(err) {
+		if err := os.MkdirAll(g.dir, 0755); err != nil {
+			return err
+		}
+		if _, err := g.git("init", "--quiet", "--bare", repo); err != nil {
+			return err
+		}
+	}
+	ref := g.branch
+	if ref == "" {
+		ref = "HEAD"
+	}
+	if _, err := g.git("--git-dir", repo, "fetch", "--quiet", "--depth", "1", "--force", g.url, ref); err != nil {
+		return err
+	}
+	out, err := g.git("--git-dir", repo, "rev-parse", "FETCH_HEAD")
+	if err != nil {
+		return err
+	}
+	commit := strings.TrimSpace(out)
+	if !commitRegexp.MatchString(commit) {
+		return fmt.Errorf("unexpected commit %q", commit)
+	}
+	previous := g.revision()
+	if commit == previous || commit == g.rejected {
+		return nil
+	}
+
+	// the commit is checked out and checked next to the current one, which is kept if it has problems
+	checkout := filepath.Join(g.dir, commit)
+	if err := os.RemoveAll(checkout); err != nil {
+		return err
+	}
+	if err := os.Mkdir(checkout, 0755); err != nil {
+		return err
+	}
+	if _, err := g.git("--git-dir", repo, "--work-tree", checkout, "checkout", "--quiet", "--force", commit, "--", "."); err != nil {
+		os.RemoveAll(checkout)
+		return err
+	}
+	if err := g.check(filepath.Join(checkout, filepath.FromSlash(g.path))); err != nil {
+		os.RemoveAll(checkout)
+		g.rejected = commit
+		return fmt.Errorf("not applying commit %s, %s", commit, err)
+	}
+
+	// swap the symlink, renaming over it replaces it at once for the watcher and readers
+	tmp := filepath.Join(g.dir, ".current")
+	os.Remove(tmp)
+	if err := os.Symlink(commit, tmp); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, g.current()); err != nil {
+		return err
+	}
+	g.lock.Lock()
+	g.commit = commit
+	g.lock.Unlock()
+	log.WithFields(log.Fields{"crontab": g.source, "revision": commit}).Info("checked out crontab")
+	g.prune(commit, previous)
+	return nil
+}
+
+// check parses the crontab of a checkout and logs its problems
+func (g *gitCrontab) check(path string) error {
+	filenames, err := crontabFiles([]string{path})
+	if err != nil {
+		return err
+	}
+	problems := 0
+	for _, filename := range filenames {
+		_, errs := parseCrontab(filename)
+		for _, err := range errs {
+			log.WithField("crontab", g.source).Error(err)
+		}
+		problems += len(errs)
+	}
+	if problems > 0 {
+		return fmt.Errorf("its crontab has %d problem(s)", problems)
+	}
+	return nil
+}
+
+// prune removes the checkouts of commits other than the current and the previous one
+func (g *gitCrontab) prune(keep ...string) {
+	infos, err := ioutil.ReadDir(g.dir)
+	if err != nil {
+		return
+	}
+	kept := map[string]bool{}
+	for _, commit := range keep {
+		kept[commit] = true
+	}
+	for _, info := range infos {
+		if info.IsDir() && commitRegexp.MatchString(info.Name()) && !kept[info.Name()] {
+			if err := os.RemoveAll(filepath.Join(g.dir, info.Name())); err != nil {
+				log.WithField("crontab", g.source).Warn("failed to remove old checkout: ", err)
+			}
+		}
+	}
+}
+
+// git runs a git command without prompting for credentials, its errors do not reveal them
+func (g *gitCrontab) git(args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), gitTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	out, err := cmd.Output()
+	if err != nil {
+		message := err.Error()
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+			message = strings.TrimSpace(string(exitErr.Stderr))
+		}
+		return "", fmt.Errorf("git failed: %s", strings.Replace(message, g.url, redactURL(g.url), -1))
+	}
+	return string(out), nil
+}
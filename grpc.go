@@ -0,0 +1,247 @@
+package crontinuous
+
+import (
+	"context"
+	"crypto/subtle"
+	"net"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/foomo/crontinuous/control"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Constants
+// --------------------------------------------------------------------------------------------
+
+// runEventBuffer is how many events a slow StreamRuns client may lag behind before events are dropped
+const runEventBuffer = 100
+
+// --------------------------------------------------------------------------------------------
+// ~ Struct
+// --------------------------------------------------------------------------------------------
+
+// controlServer implements the gRPC control service on top of what the admin api uses
+type controlServer struct {
+	control.UnimplementedControlServer
+}
+
+// runEventHub fans the events of job runs out to the StreamRuns subscribers
+type runEventHub struct {
+	subscribers map[chan *control.RunEvent]struct{}
+	lock        sync.Mutex
+}
+
+// --------------------------------------------------------------------------------------------
+// ~ Variables
+// --------------------------------------------------------------------------------------------
+
+var (
+	runEvents = &runEventHub{subscribers: map[chan *control.RunEvent]struct{}{}}
+	// grpcChanges are the methods changing anything, which only loopback clients may call without -api-token
+	grpcChanges = map[string]bool{
+		control.Control_TriggerJob_FullMethodName: true,
+		control.Control_PauseJob_FullMethodName:   true,
+	}
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Public methods
+// --------------------------------------------------------------------------------------------
+
+// ListJobs implements control.ControlServer
+func (s *controlServer) ListJobs(ctx context.Context, req *control.ListJobsRequest) (*control.ListJobsResponse, error) {
+	resp := &control.ListJobsResponse{}
+	for _, job := range jobStatuses() {
+		resp.Jobs = append(resp.Jobs, &control.Job{
+			Id:           job.ID,
+			Command:      job.Command,
+			Args:         job.Args,
+			Schedule:     job.Schedule,
+			File:         job.File,
+			Concurrency:  string(job.Concurrency),
+			Timezone:     job.Timezone,
+			Running:      job.IsRunning,
+			Paused:       job.Paused,
+			Disabled:     job.Disabled,
+			Tags:         job.Tags,
+			Next:         protoTime(job.Next),
+			Prev:         protoTime(job.Prev),
+			LastStatus:   string(job.LastStatus),
+			LastExitCode: int32(job.LastExit),
+		})
+	}
+	return resp, nil
+}
+
+// TriggerJob implements control.ControlServer
+func (s *controlServer) TriggerJob(ctx context.Context, req *control.TriggerJobRequest) (*control.TriggerJobResponse, error) {
+	runnable := findJob(req.GetId())
+	if runnable == nil {
+		return nil, status.Errorf(codes.NotFound, "job %q not found", req.GetId())
+	}
+	if isDraining() {
+		return nil, status.Error(codes.Unavailable, "draining, launching no new runs")
+	}
+	runnable.contextLogger.Info("running job on grpc request")
+	go runnable.run()
+	return &control.TriggerJobResponse{Id: runnable.ID}, nil
+}
+
+// PauseJob implements control.ControlServer
+func (s *controlServer) PauseJob(ctx context.Context, req *control.PauseJobRequest) (*control.PauseJobResponse, error) {
+	runnable := findJob(req.GetId())
+	if runnable == nil {
+		return nil, status.Errorf(codes.NotFound, "job %q not found", req.GetId())
+	}
+	setPaused(runnable.ID, req.GetPaused())
+	runnable.contextLogger.WithField("paused", req.GetPaused()).Info("job paused or resumed on grpc request")
+	return &control.PauseJobResponse{Id: runnable.ID, Paused: req.GetPaused()}, nil
+}
+
+// StreamRuns implements control.ControlServer
+func (s *controlServer) StreamRuns(req *control.StreamRunsRequest, stream control.Control_StreamRunsServer) error {
+	events := runEvents.subscribe()
+	defer runEvents.unsubscribe(events)
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case event := <-events:
+			if req.GetJobId() != "" && event.JobId != req.GetJobId() {
+				continue
+			}
+			if err := stream.Send(event); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// --------------------------------------------------------------------------------------------
+// ~ Private methods
+// --------------------------------------------------------------------------------------------
+
+func startGRPC(addr string, certFile string, keyFile string) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.WithField("grpc", addr).Fatal(err)
+	}
+	options := []grpc.ServerOption{
+		grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			if err := authorizeGRPC(ctx, info.FullMethod); err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		}),
+		grpc.StreamInterceptor(func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			if err := authorizeGRPC(stream.Context(), info.FullMethod); err != nil {
+				return err
+			}
+			return handler(srv, stream)
+		}),
+	}
+	if certFile != "" || keyFile != "" {
+		creds, err := credentials.NewServerTLSFromFile(certFile, keyFile)
+		if err != nil {
+			log.WithField("grpc", addr).Fatal(err)
+		}
+		options = append(options, grpc.Creds(creds))
+	} else {
+		log.WithField("grpc", addr).Warn("grpc control api without -grpc-tls-cert, tokens and jobs are sent in plain text")
+	}
+	if *apiToken == "" {
+		log.WithField("grpc", addr).Warn("grpc control api without -api-token, only clients on loopback addresses may change anything")
+	}
+	server := grpc.NewServer(options...)
+	control.RegisterControlServer(server, &controlServer{})
+	log.WithField("grpc", addr).Info("starting grpc control api")
+	go func() {
+		if err := server.Serve(listener); err != nil {
+			log.WithField("grpc", addr).Fatal(err)
+		}
+	}()
+}
+
+// authorizeGRPC checks a call like tcpAPIHandler checks requests: with -api-token every call needs it
+// as bearer token in the authorization metadata, without it only loopback clients may change anything
+func authorizeGRPC(ctx context.Context, method string) error {
+	if *apiToken != "" {
+		md, _ := metadata.FromIncomingContext(ctx)
+		for _, value := range md.Get("authorization") {
+			if subtle.ConstantTimeCompare([]byte(value), []byte("Bearer "+*apiToken)) == 1 {
+				return nil
+			}
+		}
+		return status.Error(codes.Unauthenticated, "missing or wrong api token")
+	}
+	if !grpcChanges[method] {
+		return nil
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil && isLoopback(p.Addr.String()) {
+		return nil
+	}
+	return status.Error(codes.PermissionDenied, "changes need -api-token, or a client on a loopback address")
+}
+
+func protoTime(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
+
+func (h *runEventHub) subscribe() chan *control.RunEvent {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	events := make(chan *control.RunEvent, runEventBuffer)
+	h.subscribers[events] = struct{}{}
+	return events
+}
+
+func (h *runEventHub) unsubscribe(events chan *control.RunEvent) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	delete(h.subscribers, events)
+}
+
+// publish sends the event of a run to all subscribers, without blocking on slow ones
+func (h *runEventHub) publish(n *Notification) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if len(h.subscribers) == 0 {
+		return
+	}
+	event := &control.RunEvent{
+		JobId:    n.Job.ID,
+		RunId:    n.RunID,
+		Event:    string(n.Event),
+		Attempt:  int32(n.Attempt),
+		Start:    protoTime(n.Start),
+		ExitCode: int32(n.ExitCode),
+		Signal:   n.Signal,
+	}
+	if n.Event != EventStart {
+		event.Duration = durationpb.New(n.Duration)
+	}
+	if n.Err != nil {
+		event.Error = n.Err.Error()
+	}
+	for events := range h.subscribers {
+		select {
+		case events <- event:
+		default:
+			log.Warn("grpc run event subscriber is too slow, dropping event")
+		}
+	}
+}
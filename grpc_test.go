@@ -0,0 +1,45 @@
+package crontinuous
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/foomo/crontinuous/control"
+)
+
+func TestAuthorizeGRPC(t *testing.T) {
+	loopback := &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 4711}
+	remote := &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 4711}
+	tests := []struct {
+		token  string
+		method string
+		addr   net.Addr
+		auth   string
+		code   codes.Code
+	}{
+		{"", control.Control_ListJobs_FullMethodName, remote, "", codes.OK},
+		{"", control.Control_TriggerJob_FullMethodName, loopback, "", codes.OK},
+		{"", control.Control_TriggerJob_FullMethodName, remote, "", codes.PermissionDenied},
+		{"", control.Control_PauseJob_FullMethodName, remote, "", codes.PermissionDenied},
+		{"secret", control.Control_ListJobs_FullMethodName, loopback, "", codes.Unauthenticated},
+		{"secret", control.Control_StreamRuns_FullMethodName, remote, "Bearer wrong", codes.Unauthenticated},
+		{"secret", control.Control_TriggerJob_FullMethodName, remote, "Bearer secret", codes.OK},
+	}
+	defer func(token string) { *apiToken = token }(*apiToken)
+	for _, test := range tests {
+		*apiToken = test.token
+		ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: test.addr})
+		if test.auth != "" {
+			ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", test.auth))
+		}
+		if code := status.Code(authorizeGRPC(ctx, test.method)); code != test.code {
+			t.Errorf("%s from %s with token %q: expected %s, got %s", test.method, test.addr, test.auth, test.code, code)
+		}
+	}
+}
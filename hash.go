@@ -0,0 +1,76 @@
+package crontinuous
+
+import (
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Variables
+// --------------------------------------------------------------------------------------------
+
+var (
+	// hashRegexp matches H, H(2-4), H/15 and H(0-29)/10 items of a schedule field
+	hashRegexp = regexp.MustCompile(`^H(\(([0-9]+)-([0-9]+)\))?(/([0-9]+))?$`)
+	// hashRanges are the values of the fields second, minute, hour, day of month, month and day of week
+	// H picks from, days of month up to the 28th to run in every month
+	hashRanges = [][2]int{{0, 59}, {0, 59}, {0, 23}, {1, 28}, {1, 12}, {0, 6}}
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Private methods
+// --------------------------------------------------------------------------------------------
+
+// expandHashes replaces the H items of a schedule with a leading seconds field by values spread by a hash
+// of the job id, so jobs with the same schedule do not all start at the same time
+func expandHashes(spec string, id string) (string, error) {
+	if strings.HasPrefix(spec, "@") || !strings.Contains(spec, "H") {
+		return spec, nil
+	}
+	fields := strings.Fields(spec)
+	for i, field := range fields {
+		items := strings.Split(field, ",")
+		for j, item := range items {
+			matches := hashRegexp.FindStringSubmatch(item)
+			if matches == nil {
+				if strings.HasPrefix(item, "H") {
+					return "", fmt.Errorf("invalid hash %q, expected H, H(2-4), H/15 or H(0-29)/10", item)
+				}
+				continue
+			}
+			if i >= len(hashRanges) {
+				return "", fmt.Errorf("H is not supported in the year field")
+			}
+			from, to := hashRanges[i][0], hashRanges[i][1]
+			if matches[1] != "" {
+				from, _ = strconv.Atoi(matches[2])
+				to, _ = strconv.Atoi(matches[3])
+				if from < hashRanges[i][0] || to > hashRanges[i][1] || to < from {
+					return "", fmt.Errorf("invalid hash range %q, expected a range within %d-%d", item, hashRanges[i][0], hashRanges[i][1])
+				}
+			}
+			// every item is hashed on its own, so H H spreads the minute and the hour apart
+			h := fnv.New32a()
+			h.Write([]byte(*hashSeed + "\x00" + id + "\x00" + strconv.Itoa(i) + "\x00" + strconv.Itoa(j)))
+			hash := int(h.Sum32() & 0x7fffffff)
+			if matches[4] == "" {
+				items[j] = strconv.Itoa(from + hash%(to-from+1))
+				continue
+			}
+			step, _ := strconv.Atoi(matches[5])
+			if step < 1 {
+				return "", fmt.Errorf("invalid hash step %q", item)
+			}
+			offset := hash % step
+			if offset > to-from {
+				offset = hash % (to - from + 1)
+			}
+			items[j] = fmt.Sprintf("%d-%d/%d", from+offset, to, step)
+		}
+		fields[i] = strings.Join(items, ",")
+	}
+	return strings.Join(fields, " "), nil
+}
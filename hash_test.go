@@ -0,0 +1,49 @@
+package crontinuous
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestExpandHashes(t *testing.T) {
+	crontab := "# name: backup\nH H(2-4) * * * backup\n" +
+		"# name: report\nH H(2-4) * * * report\n" +
+		"# name: poll\nH/15 H(8-17)/2 * * MON-THU poll\n" +
+		"# name: broken\nH(5-70) * * * * broken\n"
+	jobs, errs := parseCrontabReader("hashes", strings.NewReader(crontab), nil)
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "range within 0-59") {
+		t.Errorf("expected an error for a range out of the field, got %v", errs)
+	}
+	if len(jobs) != 3 {
+		t.Fatalf("expected 3 jobs, got %d", len(jobs))
+	}
+
+	specs := map[string]string{}
+	for _, r := range jobs {
+		spec, err := expandHashes(r.Schedule, r.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the same job always gets the same schedule
+		if again, _ := expandHashes(r.Schedule, r.ID); again != spec {
+			t.Errorf("%s: got %q and %q", r.ID, spec, again)
+		}
+		specs[r.ID] = spec
+	}
+	if specs["backup"] == specs["report"] {
+		t.Errorf("expected jobs to be spread apart, both got %q", specs["backup"])
+	}
+	var minute, hour int
+	if _, err := fmt.Sscanf(specs["backup"], "0 %d %d * * *", &minute, &hour); err != nil || minute > 59 || hour < 2 || hour > 4 {
+		t.Errorf("unexpected schedule %q", specs["backup"])
+	}
+	fields := strings.Fields(specs["poll"])
+	if !strings.HasSuffix(fields[1], "-59/15") || !strings.HasSuffix(fields[2], "-17/2") || fields[5] != "MON-THU" {
+		t.Errorf("unexpected schedule %q", specs["poll"])
+	}
+
+	if _, err := expandHashes("0 0 0 1 1 * H", "job"); err == nil {
+		t.Error("expected an error for H in the year field")
+	}
+}
@@ -0,0 +1,107 @@
+package crontinuous
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Constants
+// --------------------------------------------------------------------------------------------
+
+// healthTimeout is how long the scheduler and the watcher get to answer a health check
+const healthTimeout = 2 * time.Second
+
+// --------------------------------------------------------------------------------------------
+// ~ Struct
+// --------------------------------------------------------------------------------------------
+
+// HealthStatus is the reply of /healthz and /readyz
+type HealthStatus struct {
+	Status string   `json:"status"`
+	Jobs   int      `json:"jobs,omitempty"`
+	Errors []string `json:"errors,omitempty"`
+}
+
+// loadResult describes the last load of the crontabs, guarded by cronLock
+type loadResult struct {
+	loaded bool
+	errors []string
+}
+
+// --------------------------------------------------------------------------------------------
+// ~ Variables
+// --------------------------------------------------------------------------------------------
+
+var (
+	lastLoad loadResult
+	// watcherPing is answered by the crontab watcher as long as it is running
+	watcherPing = make(chan chan struct{})
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Private methods
+// --------------------------------------------------------------------------------------------
+
+// handleHealthz reports whether the scheduler and the crontab watcher are alive
+func handleHealthz(w http.ResponseWriter, r *http.Request) {
+	status := HealthStatus{Status: "ok"}
+	if !schedulerAlive(healthTimeout) {
+		status.Errors = append(status.Errors, "scheduler is not responding")
+	}
+	if !watcherAlive(healthTimeout) {
+		status.Errors = append(status.Errors, "crontab watcher is not running")
+	}
+	healthReply(w, status)
+}
+
+// handleReadyz reports whether the crontabs were loaded without errors
+func handleReadyz(w http.ResponseWriter, r *http.Request) {
+	cronLock.RLock()
+	status := HealthStatus{Status: "ok", Jobs: len(loadedJobs), Errors: lastLoad.errors}
+	if !lastLoad.loaded {
+		status.Errors = append(status.Errors, "crontab not loaded yet")
+	}
+	cronLock.RUnlock()
+	healthReply(w, status)
+}
+
+func healthReply(w http.ResponseWriter, status HealthStatus) {
+	if len(status.Errors) > 0 {
+		status.Status = "failing"
+		jsonStatusReply(w, http.StatusServiceUnavailable, status)
+		return
+	}
+	jsonReply(w, status)
+}
+
+// watcherAlive reports whether the crontab watcher answers within the timeout
+func watcherAlive(timeout time.Duration) bool {
+	reply := make(chan struct{})
+	select {
+	case watcherPing <- reply:
+	case <-time.After(timeout):
+		return false
+	}
+	select {
+	case <-reply:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// healthcheckCommand implements -healthcheck, checking the running daemon for e.g. a docker HEALTHCHECK
+func healthcheckCommand() int {
+	for _, path := range []string{"/healthz", "/readyz"} {
+		status := HealthStatus{}
+		if err := socketRequest("GET", path, &status); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+	fmt.Println("ok")
+	return 0
+}
@@ -0,0 +1,25 @@
+package crontinuous
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHealthReply(t *testing.T) {
+	tests := []struct {
+		status HealthStatus
+		want   int
+	}{
+		{HealthStatus{Status: "ok"}, http.StatusOK},
+		{HealthStatus{Status: "ok", Errors: []string{"crontab:1: missing command"}}, http.StatusServiceUnavailable},
+	}
+	for _, test := range tests {
+		rec := httptest.NewRecorder()
+		healthReply(rec, test.status)
+		resp := rec.Result()
+		if resp.StatusCode != test.want || resp.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("%v: expected a json reply with status %d, got %d with %q", test.status.Errors, test.want, resp.StatusCode, resp.Header.Get("Content-Type"))
+		}
+	}
+}
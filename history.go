@@ -0,0 +1,304 @@
+package crontinuous
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Constants
+// --------------------------------------------------------------------------------------------
+
+const (
+	historyFilename = "history.jsonl"
+	// historyCompactEvery is after how many records the history drops the runs beyond its retention
+	historyCompactEvery = 1000
+	// historyChunk is how much of the history is read at once when reading it from the end
+	historyChunk = 64 << 10
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Struct
+// --------------------------------------------------------------------------------------------
+
+// history persists every job run as a line of json appended to a file in the state directory
+type history struct {
+	filename string
+	file     *os.File
+	lock     sync.Mutex
+	// maxAge and maxRecords bound the history of a daemon, zero does not, recorded counts towards the next compaction
+	maxAge     time.Duration
+	maxRecords int
+	recorded   int
+}
+
+// RunRecord is a run as stored in the history
+type RunRecord struct {
+	RunID      string    `json:"runId"`
+	Attempt    int       `json:"attempt"`
+	JobID      string    `json:"jobId"`
+	Command    string    `json:"command"`
+	Args       string    `json:"args"`
+	Schedule   string    `json:"schedule"`
+	StartedAt  time.Time `json:"startedAt"`
+	FinishedAt time.Time `json:"finishedAt"`
+	Status     Event     `json:"status"`
+	ExitCode   int       `json:"exitCode"`
+	Error      string    `json:"error,omitempty"`
+	Output     string    `json:"output,omitempty"`
+	Revision   string    `json:"revision,omitempty"`
+	// UserCPU and SystemCPU are in seconds, MaxRSS in bytes, all left out for runs without local process
+	UserCPU   float64 `json:"userCpu,omitempty"`
+	SystemCPU float64 `json:"systemCpu,omitempty"`
+	MaxRSS    int64   `json:"maxRss,omitempty"`
+	// Waited is how long the run was queued for a free slot in seconds
+	Waited float64 `json:"waited,omitempty"`
+}
+
+// historyFilter selects runs from the history, zero values do not filter
+type historyFilter struct {
+	JobID  string
+	From   time.Time
+	To     time.Time
+	Status Event
+	Limit  int
+}
+
+// --------------------------------------------------------------------------------------------
+// ~ Private methods
+// --------------------------------------------------------------------------------------------
+
+func openHistory(stateDir string) (*history, error) {
+	if err := os.MkdirAll(stateDir, 0755); err != nil {
+		return nil, err
+	}
+	filename := filepath.Join(stateDir, historyFilename)
+	file, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &history{filename: filename, file: file}, nil
+}
+
+// record stores a finished run, a nil history does not record anything
+func (h *history) record(n *Notification) error {
+	if h == nil {
+		return nil
+	}
+	record := RunRecord{
+		RunID:      n.RunID,
+		Attempt:    n.Attempt,
+		JobID:      n.Job.ID,
+		Command:    n.Job.Command,
+		Args:       n.Job.Args,
+		Schedule:   n.Job.Schedule,
+		StartedAt:  n.Start,
+		FinishedAt: time.Now(),
+		Status:     n.Event,
+		ExitCode:   n.ExitCode,
+		Output:     n.Output,
+		Revision:   n.Revision,
+		UserCPU:    n.UserCPU.Seconds(),
+		SystemCPU:  n.SystemCPU.Seconds(),
+		MaxRSS:     n.MaxRSS,
+		Waited:     n.Waited.Seconds(),
+	}
+	if n.Err != nil {
+		record.Error = n.Err.Error()
+	}
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	// a single write per record keeps lines whole for readers of the file
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if _, err := h.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	h.recorded++
+	if h.recorded >= historyCompactEvery {
+		_, err = h.compactLocked()
+	}
+	return err
+}
+
+// compact drops the runs older than maxAge and all but the latest maxRecords and returns how many,
+// only the daemon writing the history compacts it
+func (h *history) compact() (int, error) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	return h.compactLocked()
+}
+
+func (h *history) compactLocked() (int, error) {
+	h.recorded = 0
+	if h.maxAge <= 0 && h.maxRecords <= 0 {
+		return 0, nil
+	}
+	data, err := ioutil.ReadFile(h.filename)
+	if err != nil {
+		return 0, err
+	}
+	// the file is appended to under the lock only, its last line is whole
+	lines := bytes.SplitAfter(data, []byte("\n"))
+	if len(lines) > 0 && len(lines[len(lines)-1]) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+	first := 0
+	if h.maxRecords > 0 && len(lines) > h.maxRecords {
+		first = len(lines) - h.maxRecords
+	}
+	cutoff := time.Now().Add(-h.maxAge)
+	kept := &bytes.Buffer{}
+	for _, line := range lines[first:] {
+		var record RunRecord
+		if json.Unmarshal(line, &record) != nil || h.maxAge > 0 && record.FinishedAt.Before(cutoff) {
+			continue
+		}
+		kept.Write(line)
+	}
+	dropped := len(lines) - bytes.Count(kept.Bytes(), []byte("\n"))
+	if dropped == 0 {
+		return 0, nil
+	}
+
+	// readers keep reading the file they opened, the new one replaces it at once
+	temp := h.filename + ".tmp"
+	if err := ioutil.WriteFile(temp, kept.Bytes(), 0644); err != nil {
+		return 0, err
+	}
+	if err := os.Rename(temp, h.filename); err != nil {
+		os.Remove(temp)
+		return 0, err
+	}
+	file, err := os.OpenFile(h.filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return 0, err
+	}
+	h.file.Close()
+	h.file = file
+	return dropped, nil
+}
+
+// query returns the runs matching the filter, latest first. The file is read from its end, with a limit
+// only as far back as runs may still have started later than the ones found.
+func (h *history) query(filter historyFilter) ([]RunRecord, error) {
+	file, err := os.Open(h.filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	// the file is in the order runs finished, so it is read latest recorded first
+	records := []RunRecord{}
+	err = readLinesBackward(file, func(line []byte) bool {
+		var record RunRecord
+		if json.Unmarshal(line, &record) != nil {
+			return true
+		}
+		// runs finished before the latest start found or before From cannot have started after them
+		if !filter.From.IsZero() && record.FinishedAt.Before(filter.From) ||
+			filter.Limit > 0 && len(records) >= filter.Limit && record.FinishedAt.Before(limitStart(records, filter.Limit)) {
+			return false
+		}
+		if filter.JobID != "" && record.JobID != filter.JobID ||
+			!filter.From.IsZero() && record.StartedAt.Before(filter.From) ||
+			!filter.To.IsZero() && !record.StartedAt.Before(filter.To) ||
+			filter.Status != "" && record.Status != filter.Status {
+			return true
+		}
+		records = append(records, record)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// latest recorded first among runs started at the same time
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].StartedAt.After(records[j].StartedAt)
+	})
+	if filter.Limit > 0 && len(records) > filter.Limit {
+		records = records[:filter.Limit]
+	}
+	return records, nil
+}
+
+// limitStart returns the start of the run which is the limit-th latest started one of the records
+func limitStart(records []RunRecord, limit int) time.Time {
+	starts := make([]time.Time, len(records))
+	for i, record := range records {
+		starts[i] = record.StartedAt
+	}
+	sort.Slice(starts, func(i, j int) bool {
+		return starts[i].After(starts[j])
+	})
+	return starts[limit-1]
+}
+
+// readLinesBackward calls fn with the whole lines of a file from the last one to the first until it returns false,
+// a partial last line is a record still being written and left out
+func readLinesBackward(file *os.File, fn func(line []byte) bool) error {
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	offset := info.Size()
+	var rest []byte
+	partial := true
+	for offset > 0 {
+		size := int64(historyChunk)
+		if size > offset {
+			size = offset
+		}
+		offset -= size
+		chunk := make([]byte, size, size+int64(len(rest)))
+		if _, err := file.ReadAt(chunk, offset); err != nil && err != io.EOF {
+			return err
+		}
+		chunk = append(chunk, rest...)
+		for {
+			i := bytes.LastIndexByte(chunk, '\n')
+			if i < 0 {
+				break
+			}
+			line := chunk[i+1:]
+			chunk = chunk[:i]
+			if partial {
+				// whatever follows the last newline of the file is still being written
+				partial = false
+				continue
+			}
+			if len(line) > 0 && !fn(line) {
+				return nil
+			}
+		}
+		rest = chunk
+	}
+	if !partial && len(rest) > 0 {
+		fn(rest)
+	}
+	return nil
+}
+
+// parseHistoryTime accepts RFC3339 timestamps, dates and durations relative to now like 24h
+func parseHistoryTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("2006-01-02", value, time.Local)
+}
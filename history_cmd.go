@@ -0,0 +1,82 @@
+package crontinuous
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Private methods
+// --------------------------------------------------------------------------------------------
+
+// historyCommand implements `crontinuous history <job-id>`
+func historyCommand(args []string) int {
+	flags := flag.NewFlagSet("history", flag.ExitOnError)
+	from := flags.String("from", "", "only runs started after, e.g. 2016-11-01, 2016-11-01T03:00:00Z or 24h")
+	to := flags.String("to", "", "only runs started before, same formats as -from")
+	status := flags.String("status", "", "only runs with status success, failure or timeout")
+	limit := flags.Int("n", 20, "max number of runs to show (all if 0)")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: crontinuous -state-dir <dir> history [options] [job-id]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if *stateDir == "" {
+		fmt.Fprintln(os.Stderr, "history requires -state-dir")
+		return 2
+	}
+	filter := historyFilter{
+		JobID:  flags.Arg(0),
+		Status: Event(*status),
+		Limit:  *limit,
+	}
+	var err error
+	if filter.From, err = parseHistoryTime(*from); err != nil {
+		fmt.Fprintln(os.Stderr, "invalid -from:", err)
+		return 2
+	}
+	if filter.To, err = parseHistoryTime(*to); err != nil {
+		fmt.Fprintln(os.Stderr, "invalid -to:", err)
+		return 2
+	}
+
+	h, err := openHistory(*stateDir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	records, err := h.query(filter)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "STARTED\tDURATION\tCPU\tMAX RSS\tSTATUS\tEXIT\tATTEMPT\tRUN ID\tJOB ID\tCOMMAND")
+	for _, record := range records {
+		cpu, maxRSS := "-", "-"
+		if record.UserCPU > 0 || record.SystemCPU > 0 || record.MaxRSS > 0 {
+			cpu = fmt.Sprintf("%.3fs", record.UserCPU+record.SystemCPU)
+			maxRSS = fmt.Sprintf("%.1fM", float64(record.MaxRSS)/(1<<20))
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%d\t%d\t%s\t%s\t%s %s\n",
+			record.StartedAt.Format(time.RFC3339),
+			record.FinishedAt.Sub(record.StartedAt).Round(time.Millisecond),
+			cpu,
+			maxRSS,
+			record.Status,
+			record.ExitCode,
+			record.Attempt,
+			record.RunID,
+			record.JobID,
+			record.Command,
+			record.Args,
+		)
+	}
+	w.Flush()
+	return 0
+}
@@ -0,0 +1,151 @@
+package crontinuous
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHistoryResourceUsage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "crontinuous-history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	h, err := openHistory(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.file.Close()
+	job := &Runnable{ID: "usage", Command: "true"}
+	start := time.Now().Add(-time.Minute)
+	runs := []*Notification{
+		{Job: job, RunID: "1", Event: EventSuccess, Start: start, UserCPU: 1500 * time.Millisecond, SystemCPU: 250 * time.Millisecond, MaxRSS: 64 << 20},
+		{Job: job, RunID: "2", Event: EventSuccess, Start: start.Add(time.Second)},
+	}
+	for _, n := range runs {
+		if err := h.record(n); err != nil {
+			t.Fatal(err)
+		}
+	}
+	records, err := h.query(historyFilter{JobID: job.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(records))
+	}
+	// latest first, runs without local process have no usage
+	if r := records[1]; r.UserCPU != 1.5 || r.SystemCPU != 0.25 || r.MaxRSS != 64<<20 {
+		t.Errorf("unexpected usage %+v", r)
+	}
+	if r := records[0]; r.UserCPU != 0 || r.SystemCPU != 0 || r.MaxRSS != 0 {
+		t.Errorf("expected no usage, got %+v", r)
+	}
+
+	defer func() {
+		runSamplesLock.Lock()
+		delete(runSamples, job.ID)
+		runSamplesLock.Unlock()
+	}()
+	seedStats(records)
+	if stats := jobStats(job.ID); stats.Runs != 2 || stats.CPU == nil || stats.CPU.Max != 1.75 || stats.MaxRSS.Max != 64<<20 {
+		t.Errorf("expected the statistics to start off with the usage of the history, got %+v", stats)
+	}
+}
+
+func TestHistoryQuery(t *testing.T) {
+	dir, err := ioutil.TempDir("", "crontinuous-history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	h, err := openHistory(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.file.Close()
+	at := func(hour, minute int) time.Time {
+		return time.Date(2016, 11, 1, hour, minute, 0, 0, time.UTC)
+	}
+	// in the order the runs finished, the long run started before the short one
+	lines := []RunRecord{
+		{RunID: "old", JobID: "a", StartedAt: at(9, 0), FinishedAt: at(9, 30)},
+		{RunID: "short", JobID: "b", StartedAt: at(11, 0), FinishedAt: at(11, 5)},
+		{RunID: "long", JobID: "a", StartedAt: at(10, 0), FinishedAt: at(12, 0)},
+	}
+	for _, record := range lines {
+		line, _ := json.Marshal(record)
+		h.file.Write(append(line, '\n'))
+	}
+	// a record still being written is left out
+	h.file.Write([]byte(`{"runId":"partial"`))
+
+	for _, test := range []struct {
+		filter historyFilter
+		runs   string
+	}{
+		{historyFilter{}, "short,long,old"},
+		{historyFilter{Limit: 1}, "short"},
+		{historyFilter{Limit: 2}, "short,long"},
+		{historyFilter{JobID: "a", Limit: 1}, "long"},
+		{historyFilter{From: at(9, 45)}, "short,long"},
+	} {
+		records, err := h.query(test.filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		runs := []string{}
+		for _, record := range records {
+			runs = append(runs, record.RunID)
+		}
+		if strings.Join(runs, ",") != test.runs {
+			t.Errorf("%+v: expected %s, got %s", test.filter, test.runs, strings.Join(runs, ","))
+		}
+	}
+}
+
+func TestHistoryRetention(t *testing.T) {
+	dir, err := ioutil.TempDir("", "crontinuous-history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	h, err := openHistory(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { h.file.Close() }()
+	stale, _ := json.Marshal(RunRecord{RunID: "stale", JobID: "retention", FinishedAt: time.Now().Add(-48 * time.Hour)})
+	h.file.Write(append(stale, '\n'))
+
+	h.maxAge, h.maxRecords = 24*time.Hour, 300
+	job := &Runnable{ID: "retention", Command: "true"}
+	output := strings.Repeat("x", 100)
+	for i := 0; i < historyCompactEvery; i++ {
+		if err := h.record(&Notification{Job: job, RunID: fmt.Sprint(i), Event: EventSuccess, Start: time.Now(), Output: output}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	records, err := h.query(historyFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 300 || records[0].RunID != fmt.Sprint(historyCompactEvery-1) {
+		t.Fatalf("expected the latest 300 runs, got %d starting with %s", len(records), records[0].RunID)
+	}
+
+	// records keep being appended after compacting, older ones are dropped on start too
+	h.record(&Notification{Job: job, RunID: "next", Event: EventSuccess, Start: time.Now()})
+	h.maxRecords = 10
+	if dropped, err := h.compact(); err != nil || dropped != 291 {
+		t.Errorf("expected 291 runs to be dropped, got %d, %v", dropped, err)
+	}
+	if records, _ := h.query(historyFilter{Limit: 1}); len(records) != 1 || records[0].RunID != "next" {
+		t.Errorf("expected the latest run to be kept, got %+v", records)
+	}
+}
@@ -0,0 +1,299 @@
+package crontinuous
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/robfig/cron"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Constants
+// --------------------------------------------------------------------------------------------
+
+const (
+	// maxSkippedDays stops looking for a run of schedules skipping every day they run on
+	maxSkippedDays = 1000
+	// maxEventDays bounds the days of a single calendar event
+	maxEventDays = 366
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Struct
+// --------------------------------------------------------------------------------------------
+
+// holidayCalendar knows the holidays of the -holidays files, once by date or every year by day
+type holidayCalendar struct {
+	dates  map[string]string
+	yearly []yearlyHoliday
+}
+
+// yearlyHoliday is a holiday on the same day every year, from a first year up to a last one if not 0
+type yearlyHoliday struct {
+	name                string
+	month               time.Month
+	day                 int
+	firstYear, lastYear int
+}
+
+// calendarSchedule leaves out the days of a schedule that are holidays, and weekends for business days only
+type calendarSchedule struct {
+	schedule     cron.Schedule
+	businessDays bool
+	logger       *log.Entry
+	lock         sync.Mutex
+	// logged is the last day a skip was logged for, Next is asked for the same run many times
+	logged string
+}
+
+// --------------------------------------------------------------------------------------------
+// ~ Variables
+// --------------------------------------------------------------------------------------------
+
+var (
+	holidays     = &holidayCalendar{dates: map[string]string{}}
+	holidaysLock sync.RWMutex
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Public methods
+// --------------------------------------------------------------------------------------------
+
+// Next implements cron.Schedule, skipping the rest of a day that is left out
+func (s *calendarSchedule) Next(t time.Time) time.Time {
+	for i := 0; i < maxSkippedDays; i++ {
+		next := s.schedule.Next(t)
+		if next.IsZero() {
+			return next
+		}
+		reason := s.skip(next)
+		if reason == "" {
+			return next
+		}
+		s.log(next, reason)
+		t = time.Date(next.Year(), next.Month(), next.Day()+1, 0, 0, 0, 0, next.Location()).Add(-time.Nanosecond)
+	}
+	return time.Time{}
+}
+
+// --------------------------------------------------------------------------------------------
+// ~ Private methods
+// --------------------------------------------------------------------------------------------
+
+// loadHolidays reads the holidays of the -holidays files in place of the ones read before, either
+// ics calendars or lists of dates with an optional name, one per line
+func loadHolidays(filenames []string) error {
+	calendar := &holidayCalendar{dates: map[string]string{}}
+	for _, filename := range filenames {
+		file, err := os.Open(filename)
+		if err != nil {
+			return err
+		}
+		if strings.HasSuffix(strings.ToLower(filename), ".ics") {
+			err = calendar.readICS(file)
+		} else {
+			err = calendar.readList(file)
+		}
+		file.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %s", filename, err)
+		}
+	}
+	holidaysLock.Lock()
+	defer holidaysLock.Unlock()
+	holidays = calendar
+	return nil
+}
+
+// readList reads lines like 2016-12-25 Christmas Day, # starts a comment
+func (c *holidayCalendar) readList(reader io.Reader) error {
+	scanner := bufio.NewScanner(reader)
+	for number := 1; scanner.Scan(); number++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.SplitN(line, " ", 2)
+		date, err := time.Parse("2006-01-02", fields[0])
+		if err != nil {
+			return fmt.Errorf("line %d: expected a date like 2016-12-25, got %q", number, fields[0])
+		}
+		name := ""
+		if len(fields) == 2 {
+			name = strings.TrimSpace(fields[1])
+		}
+		c.dates[date.Format("2006-01-02")] = name
+	}
+	return scanner.Err()
+}
+
+// readICS reads the all day events of an ics calendar, which may recur every year
+func (c *holidayCalendar) readICS(reader io.Reader) error {
+	lines := []string{}
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		// long lines are folded onto lines starting with a space or tab
+		if len(lines) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	var event map[string]string
+	for _, line := range lines {
+		colon := strings.Index(line, ":")
+		if colon < 0 {
+			continue
+		}
+		// parameters like ;VALUE=DATE do not matter for dates
+		name, value := strings.ToUpper(strings.SplitN(line[:colon], ";", 2)[0]), line[colon+1:]
+		switch {
+		case name == "BEGIN" && value == "VEVENT":
+			event = map[string]string{}
+		case name == "END" && value == "VEVENT" && event != nil:
+			if err := c.addEvent(event); err != nil {
+				return err
+			}
+			event = nil
+		case event != nil:
+			event[name] = value
+		}
+	}
+	return nil
+}
+
+// addEvent adds the days of an event, DTEND is the day after an all day event
+func (c *holidayCalendar) addEvent(event map[string]string) error {
+	name := strings.NewReplacer(`\,`, ",", `\;`, ";", `\n`, " ").Replace(event["SUMMARY"])
+	start, err := parseICSDate(event["DTSTART"])
+	if err != nil {
+		return fmt.Errorf("event %q: invalid DTSTART: %s", name, err)
+	}
+	days := 1
+	if event["DTEND"] != "" {
+		end, err := parseICSDate(event["DTEND"])
+		if err != nil {
+			return fmt.Errorf("event %q: invalid DTEND: %s", name, err)
+		}
+		if days = int(end.Sub(start).Hours() / 24); days < 1 {
+			days = 1
+		} else if days > maxEventDays {
+			return fmt.Errorf("event %q: longer than %d days", name, maxEventDays)
+		}
+	}
+
+	if rule := event["RRULE"]; rule != "" {
+		holiday := yearlyHoliday{name: name, month: start.Month(), day: start.Day(), firstYear: start.Year()}
+		for _, part := range strings.Split(rule, ";") {
+			pair := strings.SplitN(part, "=", 2)
+			if len(pair) != 2 {
+				continue
+			}
+			switch strings.ToUpper(pair[0]) {
+			case "FREQ":
+				if strings.ToUpper(pair[1]) != "YEARLY" {
+					return fmt.Errorf("event %q: only yearly recurrences are supported, got %s", name, rule)
+				}
+			case "COUNT":
+				count, err := strconv.Atoi(pair[1])
+				if err != nil || count < 1 {
+					return fmt.Errorf("event %q: invalid COUNT in %s", name, rule)
+				}
+				holiday.lastYear = start.Year() + count - 1
+			case "UNTIL":
+				until, err := parseICSDate(pair[1])
+				if err != nil {
+					return fmt.Errorf("event %q: invalid UNTIL in %s", name, rule)
+				}
+				holiday.lastYear = until.Year()
+			case "INTERVAL":
+				if pair[1] != "1" {
+					return fmt.Errorf("event %q: only yearly recurrences are supported, got %s", name, rule)
+				}
+			default:
+				return fmt.Errorf("event %q: only yearly recurrences on the same day are supported, got %s", name, rule)
+			}
+		}
+		for i := 0; i < days; i++ {
+			day := holiday
+			date := start.AddDate(0, 0, i)
+			day.month, day.day = date.Month(), date.Day()
+			c.yearly = append(c.yearly, day)
+		}
+		return nil
+	}
+	for i := 0; i < days; i++ {
+		c.dates[start.AddDate(0, 0, i).Format("2006-01-02")] = name
+	}
+	return nil
+}
+
+// holiday returns the name of the holiday on the day of t and whether it is one
+func (c *holidayCalendar) holiday(t time.Time) (string, bool) {
+	if name, ok := c.dates[t.Format("2006-01-02")]; ok {
+		return name, true
+	}
+	for _, h := range c.yearly {
+		if h.month == t.Month() && h.day == t.Day() && t.Year() >= h.firstYear && (h.lastYear == 0 || t.Year() <= h.lastYear) {
+			return h.name, true
+		}
+	}
+	return "", false
+}
+
+// holiday returns the name of the holiday of the -holidays files on the day of t and whether it is one
+func holiday(t time.Time) (string, bool) {
+	holidaysLock.RLock()
+	defer holidaysLock.RUnlock()
+	return holidays.holiday(t)
+}
+
+// skip returns why the day of a run is left out, empty if it is not
+func (s *calendarSchedule) skip(t time.Time) string {
+	if s.businessDays && (t.Weekday() == time.Saturday || t.Weekday() == time.Sunday) {
+		return "weekend"
+	}
+	if name, ok := holiday(t); ok {
+		if name == "" {
+			return "holiday"
+		}
+		return "holiday " + name
+	}
+	return ""
+}
+
+// log logs a day left out once
+func (s *calendarSchedule) log(t time.Time, reason string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	day := t.Format("2006-01-02")
+	if s.logged == day {
+		return
+	}
+	s.logged = day
+	logger := s.logger
+	if logger == nil {
+		logger = log.NewEntry(log.StandardLogger())
+	}
+	logger.WithField("day", day).Info("skipping runs on ", reason)
+}
+
+// parseICSDate parses dates like 20161225 and date times like 20161225T000000Z, of which the date counts
+func parseICSDate(value string) (time.Time, error) {
+	if len(value) < 8 {
+		return time.Time{}, fmt.Errorf("invalid date %q", value)
+	}
+	return time.Parse("20060102", value[:8])
+}
@@ -0,0 +1,87 @@
+package crontinuous
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHolidays(t *testing.T) {
+	dir, err := ioutil.TempDir("", "crontinuous-holidays")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	list := filepath.Join(dir, "holidays.txt")
+	ics := filepath.Join(dir, "holidays.ics")
+	ioutil.WriteFile(list, []byte("# company holidays\n2016-12-23 Company Day\n"), 0644)
+	ioutil.WriteFile(ics, []byte("BEGIN:VCALENDAR\r\nVERSION:2.0\r\n"+
+		"BEGIN:VEVENT\r\nDTSTART;VALUE=DATE:20161225\r\nDTEND;VALUE=DATE:20161227\r\nSUMMARY:Christ\r\n mas\r\nRRULE:FREQ=YEARLY\r\nEND:VEVENT\r\n"+
+		"BEGIN:VEVENT\r\nDTSTART;VALUE=DATE:20161229\r\nSUMMARY:Closed\\, once\r\nEND:VEVENT\r\n"+
+		"END:VCALENDAR\r\n"), 0644)
+	if err := loadHolidays([]string{list, ics}); err != nil {
+		t.Fatal(err)
+	}
+	defer loadHolidays(nil)
+
+	location := schedulerLocation
+	schedulerLocation = time.UTC
+	defer func() { schedulerLocation = location }()
+	crontab := "# name: payroll\n# skip-holidays\n0 6 * * * payroll\n" +
+		"# name: report\n# business-days-only\n0 6 * * * report\n" +
+		"# name: plain\n0 6 * * * plain\n" +
+		"# name: annotated\n# skip-holidays: false\n0 6 * * * annotated\n"
+	jobs, errs := parseCrontabReader("holidays", strings.NewReader(crontab), nil)
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	runs := func(id string, n int) string {
+		for _, r := range jobs {
+			if r.ID != id {
+				continue
+			}
+			days := []string{}
+			for next := time.Date(2016, 12, 22, 12, 0, 0, 0, time.UTC); len(days) < n; {
+				next = r.schedule.Next(next)
+				days = append(days, next.Format("01-02"))
+			}
+			return strings.Join(days, " ")
+		}
+		return ""
+	}
+	for _, test := range []struct {
+		id   string
+		want string
+	}{
+		{"payroll", "12-24 12-27 12-28 12-30"},
+		{"report", "12-27 12-28 12-30 01-02"},
+		{"plain", "12-23 12-24 12-25 12-26"},
+		{"annotated", "12-23 12-24 12-25 12-26"},
+	} {
+		if got := runs(test.id, 4); got != test.want {
+			t.Errorf("%s: got %s, want %s", test.id, got, test.want)
+		}
+	}
+
+	// yearly events recur, events once do not
+	for day, want := range map[string]string{"2020-12-26": "Christmas", "2015-12-25": "", "2017-12-29": ""} {
+		date, _ := time.Parse("2006-01-02", day)
+		if name, _ := holiday(date); name != want {
+			t.Errorf("%s: got %q, want %q", day, name, want)
+		}
+	}
+	if name, ok := holiday(time.Date(2016, 12, 29, 0, 0, 0, 0, time.UTC)); !ok || name != "Closed, once" {
+		t.Errorf("expected the 29th to be a holiday, got %q", name)
+	}
+
+	ioutil.WriteFile(ics, []byte("BEGIN:VEVENT\nDTSTART:20161225\nRRULE:FREQ=MONTHLY\nEND:VEVENT\n"), 0644)
+	if err := loadHolidays([]string{ics}); err == nil || !strings.Contains(err.Error(), "only yearly") {
+		t.Errorf("expected an error for a monthly recurrence, got %v", err)
+	}
+	if name, _ := holiday(time.Date(2016, 12, 23, 0, 0, 0, 0, time.UTC)); name != "Company Day" {
+		t.Errorf("expected a broken file to keep the holidays read before, got %q", name)
+	}
+}
@@ -0,0 +1,99 @@
+package crontinuous
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Private methods
+// --------------------------------------------------------------------------------------------
+
+// executeWithHooks runs an attempt between the pre and post hooks of the job,
+// the command is not run if the pre hook fails and the post hook only runs if the pre hook succeeded
+func (r *Runnable) executeWithHooks(e *execution, attempt int) *Notification {
+	start := time.Now()
+	if err := r.runHook(e, "pre", r.Pre, r.hookEnv(e, attempt)); err != nil {
+		return &Notification{
+			Event:    EventFailure,
+			Job:      r,
+			RunID:    e.id,
+			SpanID:   newSpanID(),
+			Attempt:  attempt,
+			Start:    start,
+			Duration: time.Since(start),
+			ExitCode: -1,
+			Err:      fmt.Errorf("pre hook failed: %s", err),
+			Revision: e.revision,
+		}
+	}
+
+	n := r.execute(e, attempt)
+	n.Duration = time.Since(n.Start)
+
+	env := append(r.hookEnv(e, attempt),
+		"CRONTINUOUS_STATUS="+string(n.Event),
+		"CRONTINUOUS_EXIT_CODE="+strconv.Itoa(n.ExitCode),
+		"CRONTINUOUS_DURATION="+strconv.FormatFloat(n.Duration.Seconds(), 'f', 3, 64),
+		"CRONTINUOUS_SIGNAL="+n.Signal,
+	)
+	if n.Err != nil {
+		env = append(env, "CRONTINUOUS_ERROR="+n.Err.Error())
+	}
+	if err := r.runHook(e, "post", r.Post, env); err != nil {
+		e.logger.Error("post hook failed: ", err)
+	}
+	return n
+}
+
+// hookEnv returns the environment of the hooks of a run
+func (r *Runnable) hookEnv(e *execution, attempt int) []string {
+	return append(append(append([]string{}, r.Env...), e.env...),
+		"CRONTINUOUS_JOB_ID="+r.ID,
+		"CRONTINUOUS_RUN_ID="+e.id,
+		"CRONTINUOUS_ATTEMPT="+strconv.Itoa(attempt),
+	)
+}
+
+// runHook runs a hook command with the shell of the job as the user of the job, it is killed after the timeout of the job
+func (r *Runnable) runHook(e *execution, name string, command string, env []string) error {
+	if command == "" {
+		return nil
+	}
+	shell := r.Shell
+	if shell == "none" || shell == "go" {
+		shell = systemShell()
+	}
+	cmd := shellCommand(shell, command)
+	prepareProcess(cmd, r.runAs)
+	cmd.Env = jobEnviron()
+	if r.runAs != nil {
+		cmd.Env = r.runAs.environ()
+	}
+	cmd.Env = append(cmd.Env, env...)
+	output := &bytes.Buffer{}
+	cmd.Stdout = output
+	cmd.Stderr = output
+
+	logger := e.logger.WithField("hook", name)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	if r.Timeout > 0 {
+		timer := time.AfterFunc(r.Timeout, func() {
+			logger.WithField("timeout", r.Timeout).Warn("hook timed out, killing it")
+			killProcessGroup(cmd.Process)
+		})
+		defer timer.Stop()
+	}
+	err := cmd.Wait()
+
+	scanner := bufio.NewScanner(output)
+	for scanner.Scan() {
+		logger.WithField("output", scanner.Text()).Info("hook output")
+	}
+	return err
+}
@@ -0,0 +1,200 @@
+package crontinuous
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Constants
+// --------------------------------------------------------------------------------------------
+
+// maxHTTPResponse is the part of a response body that is read and reported as output of the job
+const maxHTTPResponse = 1024 * 1024 // in byte => 1Mb
+
+// --------------------------------------------------------------------------------------------
+// ~ Struct
+// --------------------------------------------------------------------------------------------
+
+// HTTPRequest is the request of a job annotated with http, its method and url are the command
+// and arguments of the job
+type HTTPRequest struct {
+	Headers []string
+	Body    string
+	// Status are the expected status codes, any 2xx if empty
+	Status []int
+}
+
+// httpExecutor runs jobs by sending their request, a response with an unexpected status fails
+type httpExecutor struct{}
+
+// --------------------------------------------------------------------------------------------
+// ~ Variables
+// --------------------------------------------------------------------------------------------
+
+var (
+	httpMethods = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
+	// httpJobClient sends the requests of jobs, they are bounded by the timeout of the job
+	httpJobClient = &http.Client{}
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Public methods
+// --------------------------------------------------------------------------------------------
+
+// Execute implements Executor
+func (httpExecutor) Execute(a *Attempt) {
+	r := a.Job
+	request := r.HTTP
+	if request == nil {
+		request = &HTTPRequest{}
+	}
+	expand := a.expandVariables
+	req, err := http.NewRequest(a.Command, expand(a.Args), strings.NewReader(expand(request.Body)))
+	if err != nil {
+		a.fail(errors.New(a.secrets.mask(err.Error())))
+		return
+	}
+	for _, header := range request.Headers {
+		parts := strings.SplitN(header, ":", 2)
+		req.Header.Add(strings.TrimSpace(parts[0]), strings.TrimSpace(expand(parts[1])))
+	}
+	req.Header.Set("X-Crontinuous-Run-Id", a.Result.RunID)
+
+	// the request is given up once the run times out or is aborted
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-a.Aborted():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	if r.Timeout > 0 {
+		var stop context.CancelFunc
+		ctx, stop = context.WithTimeout(ctx, r.Timeout)
+		defer stop()
+	}
+
+	a.Started()
+	start := time.Now()
+	resp, err := httpJobClient.Do(req.WithContext(ctx))
+	if err != nil {
+		a.fail(errors.New(a.secrets.mask(err.Error())))
+		if ctx.Err() == context.DeadlineExceeded {
+			a.Result.Event = EventTimeout
+		}
+		return
+	}
+	defer resp.Body.Close()
+	latency := time.Since(start)
+
+	scanner := bufio.NewScanner(io.LimitReader(resp.Body, maxHTTPResponse))
+	scanner.Buffer(make([]byte, 64*1024), maxHTTPResponse)
+	for scanner.Scan() {
+		a.Output("stdout", scanner.Text())
+	}
+	a.Logger.WithFields(log.Fields{
+		"status":  resp.StatusCode,
+		"latency": latency.Seconds(),
+	}).Info("http request finished")
+
+	if !request.expects(resp.StatusCode) {
+		a.Finish(-1, fmt.Errorf("unexpected status %s", resp.Status))
+		return
+	}
+	a.Finish(0, nil)
+}
+
+// --------------------------------------------------------------------------------------------
+// ~ Private methods
+// --------------------------------------------------------------------------------------------
+
+// splitHTTPRequest splits the value of an http annotation like "POST https://example.com/rollup"
+// into method and url, the method defaults to GET
+func splitHTTPRequest(value string) (string, string, error) {
+	fields := strings.Fields(value)
+	method, rawURL := "GET", ""
+	switch len(fields) {
+	case 1:
+		rawURL = fields[0]
+	case 2:
+		method, rawURL = strings.ToUpper(fields[0]), fields[1]
+	default:
+		return "", "", fmt.Errorf("invalid http request %q, expected [<method>] <url>", value)
+	}
+	if !containsString(httpMethods, method) {
+		return "", "", fmt.Errorf("unknown http method %q", method)
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", "", fmt.Errorf("invalid http url %q", rawURL)
+	}
+	return method, rawURL, nil
+}
+
+// parseHTTPHeaders parses headers like "Accept: application/json", quoted to contain spaces
+func parseHTTPHeaders(value string) ([]string, error) {
+	fields, err := splitQuoted(value)
+	if err != nil {
+		return nil, err
+	}
+	for _, field := range fields {
+		if parts := strings.SplitN(field, ":", 2); len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, fmt.Errorf("invalid http header %q, expected \"<name>: <value>\"", field)
+		}
+	}
+	return fields, nil
+}
+
+// parseHTTPStatus parses comma separated status codes and ranges like "200,202-204"
+func parseHTTPStatus(value string) ([]int, error) {
+	status := []int{}
+	for _, codes := range strings.Split(value, ",") {
+		bounds := strings.SplitN(strings.TrimSpace(codes), "-", 2)
+		from, err := strconv.Atoi(bounds[0])
+		to := from
+		if err == nil && len(bounds) == 2 {
+			to, err = strconv.Atoi(bounds[1])
+		}
+		if err != nil || from < 100 || to > 599 || from > to {
+			return nil, fmt.Errorf("invalid http status %q, expected codes from 100 to 599", codes)
+		}
+		for code := from; code <= to; code++ {
+			status = append(status, code)
+		}
+	}
+	return status, nil
+}
+
+// expects reports whether a response with the status code is a success
+func (h *HTTPRequest) expects(code int) bool {
+	if len(h.Status) == 0 {
+		return code >= 200 && code < 300
+	}
+	for _, status := range h.Status {
+		if status == code {
+			return true
+		}
+	}
+	return false
+}
+
+// httpRequest returns the request of the job, creating it for the first http annotation
+func (r *Runnable) httpRequest() *HTTPRequest {
+	if r.HTTP == nil {
+		r.HTTP = &HTTPRequest{}
+	}
+	return r.HTTP
+}
@@ -0,0 +1,68 @@
+package crontinuous
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHTTPJob(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		if req.Method != "POST" || req.Header.Get("Authorization") != "Bearer s3cret" || string(body) != `{"period":"hour"}` {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte("queued\n"))
+	}))
+	defer server.Close()
+
+	crontab := "TOKEN=s3cret\n" +
+		"# name: rollup\n# http: post " + server.URL + "/rollup\n" +
+		"# http-headers: \"Authorization: Bearer ${TOKEN}\"\n# http-body: {\"period\":\"hour\"}\n*/5 * * * *\n\n" +
+		"# name: strict\n# http: " + server.URL + "\n# http-status: 200\n*/5 * * * *\n\n" +
+		"# http: POST " + server.URL + "\n*/5 * * * * /bin/true\n"
+	jobs, errs := parseCrontabReader("http", strings.NewReader(crontab), nil)
+	if len(errs) != 1 {
+		t.Errorf("expected an error for the http job with a command, got %v", errs)
+	}
+	if len(jobs) != 2 {
+		t.Fatalf("expected 2 jobs, got %d", len(jobs))
+	}
+
+	rollup := jobs[0]
+	if rollup.Command != "POST" || rollup.executorName() != "http" {
+		t.Fatalf("unexpected job %+v", rollup)
+	}
+	result := rollup.execute(newExecution(rollup.contextLogger, ""), 1)
+	if result.Event != EventSuccess || result.Output != "queued" {
+		t.Errorf("expected a successful run, got %s %v %q", result.Event, result.Err, result.Output)
+	}
+
+	strict := jobs[1]
+	result = strict.execute(newExecution(strict.contextLogger, ""), 1)
+	if result.Event != EventFailure || result.Err == nil || !strings.Contains(result.Err.Error(), "400") {
+		t.Errorf("expected a failed run for an unexpected status, got %s %v", result.Event, result.Err)
+	}
+}
+
+func TestParseHTTPStatus(t *testing.T) {
+	status, err := parseHTTPStatus("200, 202-204")
+	if err != nil {
+		t.Fatal(err)
+	}
+	request := &HTTPRequest{Status: status}
+	for code, want := range map[int]bool{200: true, 201: false, 203: true, 204: true, 500: false} {
+		if got := request.expects(code); got != want {
+			t.Errorf("expects(%d) = %v, want %v", code, got, want)
+		}
+	}
+	for _, value := range []string{"", "99", "200-100", "600", "2xx"} {
+		if _, err := parseHTTPStatus(value); err == nil {
+			t.Errorf("expected an error for %q", value)
+		}
+	}
+}
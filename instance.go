@@ -0,0 +1,108 @@
+package crontinuous
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Constants
+// --------------------------------------------------------------------------------------------
+
+// instanceLockFilename is the file in the state directory the running daemon holds locked
+const instanceLockFilename = "crontinuous.lock"
+
+// --------------------------------------------------------------------------------------------
+// ~ Struct
+// --------------------------------------------------------------------------------------------
+
+// instanceLock keeps a second daemon from running with the same state directory or pid file, both
+// files are locked for as long as the daemon runs and hold its pid
+type instanceLock struct {
+	files []*os.File
+}
+
+// --------------------------------------------------------------------------------------------
+// ~ Variables
+// --------------------------------------------------------------------------------------------
+
+var (
+	// errLocked is returned when another process holds a lock file
+	errLocked = errors.New("locked")
+	instance  *instanceLock
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Private methods
+// --------------------------------------------------------------------------------------------
+
+// lockInstance locks the state directory and the pid file if set, failing if another daemon holds either.
+// Locks are released by the system when a daemon dies, stale files do not keep it from starting.
+func lockInstance(stateDir string, pidFile string) (*instanceLock, error) {
+	l := &instanceLock{}
+	filenames := []string{}
+	if stateDir != "" {
+		if err := os.MkdirAll(stateDir, 0755); err != nil {
+			return nil, err
+		}
+		filenames = append(filenames, filepath.Join(stateDir, instanceLockFilename))
+	}
+	if pidFile != "" {
+		filenames = append(filenames, pidFile)
+	}
+	for _, filename := range filenames {
+		file, err := openLockedFile(filename)
+		if err == errLocked {
+			l.release()
+			if pid := readPID(filename); pid > 0 {
+				return nil, fmt.Errorf("another crontinuous (pid %d) is running with %s, refusing to schedule the jobs twice", pid, filename)
+			}
+			return nil, fmt.Errorf("another crontinuous is running with %s, refusing to schedule the jobs twice", filename)
+		} else if err != nil {
+			l.release()
+			return nil, err
+		}
+		l.files = append(l.files, file)
+		if err := writePID(file); err != nil {
+			l.release()
+			return nil, fmt.Errorf("failed to write pid to %s: %s", filename, err)
+		}
+	}
+	return l, nil
+}
+
+// release removes and unlocks the files, a nil lock does not hold any
+func (l *instanceLock) release() {
+	if l == nil {
+		return
+	}
+	for _, file := range l.files {
+		unlockFile(file)
+	}
+	l.files = nil
+}
+
+func writePID(file *os.File) error {
+	if err := file.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := file.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0); err != nil {
+		return err
+	}
+	return file.Sync()
+}
+
+// readPID returns the pid in a lock file, 0 if it cannot be read
+func readPID(filename string) int {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return 0
+	}
+	pid, _ := strconv.Atoi(strings.TrimSpace(string(data)))
+	return pid
+}
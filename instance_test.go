@@ -0,0 +1,50 @@
+package crontinuous
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestLockInstance(t *testing.T) {
+	dir, err := ioutil.TempDir("", "crontinuous-instance")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	stateDir := filepath.Join(dir, "state")
+	pidFile := filepath.Join(dir, "crontinuous.pid")
+
+	first, err := lockInstance(stateDir, pidFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pid := readPID(pidFile); pid != os.Getpid() {
+		t.Errorf("expected pid %d in the pid file, got %d", os.Getpid(), pid)
+	}
+	if _, err := lockInstance(stateDir, ""); err == nil {
+		t.Error("expected the state directory to be locked")
+	}
+	_, err = lockInstance("", pidFile)
+	if err == nil {
+		t.Fatal("expected the pid file to be locked")
+	}
+	if os.PathSeparator == '/' && !strings.Contains(err.Error(), "pid "+strconv.Itoa(os.Getpid())) {
+		t.Errorf("expected the error to tell the pid, got %s", err)
+	}
+
+	first.release()
+	if _, err := os.Stat(pidFile); !os.IsNotExist(err) {
+		t.Errorf("expected the pid file to be removed, got %v", err)
+	}
+	// a stale pid file does not keep a daemon from starting
+	ioutil.WriteFile(pidFile, []byte("1\n"), 0644)
+	second, err := lockInstance(stateDir, pidFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second.release()
+}
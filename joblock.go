@@ -0,0 +1,147 @@
+package crontinuous
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Constants
+// --------------------------------------------------------------------------------------------
+
+// jobLockMinHold is how long a job lock is held at least, so hosts whose clocks are
+// a little behind do not run a short job a second time for the same slot
+const jobLockMinHold = 5 * time.Second
+
+// releaseScript deletes the lock if it is still ours, or lets it expire after the remaining hold time
+const releaseScript = `if redis.call("get", KEYS[1]) == ARGV[1] then
+	if tonumber(ARGV[2]) > 0 then
+		return redis.call("pexpire", KEYS[1], ARGV[2])
+	end
+	return redis.call("del", KEYS[1])
+end
+return 0`
+
+// extendScript extends the ttl of the lock if it is still ours
+const extendScript = `if redis.call("get", KEYS[1]) == ARGV[1] then
+	return redis.call("pexpire", KEYS[1], ARGV[2])
+end
+return 0`
+
+// --------------------------------------------------------------------------------------------
+// ~ Variables
+// --------------------------------------------------------------------------------------------
+
+var jobLockServers []*redisClient
+
+// --------------------------------------------------------------------------------------------
+// ~ Struct
+// --------------------------------------------------------------------------------------------
+
+// jobLock is a redlock style lock on a job across all redis servers, held while the job runs
+type jobLock struct {
+	key      string
+	token    string
+	acquired time.Time
+	minHold  time.Duration
+	done     chan struct{}
+	wg       sync.WaitGroup
+}
+
+// --------------------------------------------------------------------------------------------
+// ~ Private methods
+// --------------------------------------------------------------------------------------------
+
+// setupJobLocks connects to the comma separated redis servers to lock jobs with
+func setupJobLocks(addrs string, password string) {
+	for _, addr := range strings.Split(addrs, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			jobLockServers = append(jobLockServers, newRedisClient(addr, password))
+		}
+	}
+}
+
+// lockRun takes the job's lock if it asks for one, false if another instance holds it.
+// A nil lock is returned for jobs without lock, releasing it does nothing.
+func (r *Runnable) lockRun() (*jobLock, bool) {
+	if !r.Lock {
+		return nil, true
+	}
+	if len(jobLockServers) == 0 {
+		r.contextLogger.Error("job asks for a lock, but no -redis servers are configured")
+		return nil, false
+	}
+	l := &jobLock{
+		key:      *redisPrefix + r.ID,
+		token:    newRunID(),
+		acquired: time.Now(),
+		minHold:  jobLockMinHold,
+		done:     make(chan struct{}),
+	}
+	// never hold the lock into the next run of frequent jobs
+	if next := r.nextRun(); !next.IsZero() && next.Sub(l.acquired)/2 < l.minHold {
+		l.minHold = next.Sub(l.acquired) / 2
+	}
+	ttl := strconv.FormatInt(int64(*redisLockTTL/time.Millisecond), 10)
+	if !l.quorum(func(c *redisClient) (interface{}, error) {
+		return c.do("SET", l.key, l.token, "NX", "PX", ttl)
+	}) {
+		// give back what we got on a minority of the servers
+		l.eval(releaseScript, "0")
+		return nil, false
+	}
+
+	// keep the lock while the job is running
+	l.wg.Add(1)
+	go func() {
+		defer l.wg.Done()
+		for {
+			select {
+			case <-l.done:
+				return
+			case <-time.After(*redisLockTTL / 3):
+				if !l.eval(extendScript, ttl) {
+					r.contextLogger.Warn("failed to extend job lock")
+				}
+			}
+		}
+	}()
+	return l, true
+}
+
+// release gives up the lock, after its minimum hold time at the earliest
+func (l *jobLock) release() {
+	if l == nil {
+		return
+	}
+	close(l.done)
+	l.wg.Wait()
+	hold := l.minHold - time.Since(l.acquired)
+	if hold < 0 {
+		hold = 0
+	}
+	l.eval(releaseScript, strconv.FormatInt(int64(hold/time.Millisecond), 10))
+}
+
+func (l *jobLock) eval(script string, arg string) bool {
+	return l.quorum(func(c *redisClient) (interface{}, error) {
+		return c.do("EVAL", script, "1", l.key, l.token, arg)
+	})
+}
+
+// quorum runs a command on all servers and reports whether a majority succeeded
+func (l *jobLock) quorum(command func(c *redisClient) (interface{}, error)) bool {
+	succeeded := 0
+	for _, c := range jobLockServers {
+		reply, err := command(c)
+		if err != nil {
+			continue
+		}
+		if reply == "OK" || reply == int64(1) {
+			succeeded++
+		}
+	}
+	return succeeded >= len(jobLockServers)/2+1
+}
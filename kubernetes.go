@@ -0,0 +1,405 @@
+package crontinuous
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Constants
+// --------------------------------------------------------------------------------------------
+
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// kubernetesPollInterval is how often the status of a launched job is checked
+const kubernetesPollInterval = 2 * time.Second
+
+// --------------------------------------------------------------------------------------------
+// ~ Struct
+// --------------------------------------------------------------------------------------------
+
+// kubernetesJobData is what job templates are rendered with
+type kubernetesJobData struct {
+	Name      string
+	Namespace string
+	JobID     string
+	RunID     string
+	Command   string
+	Args      string
+	Env       map[string]string
+	// SecretName is the Secret holding the values of the job's secrets, empty without secrets
+	SecretName string
+	// Secrets are the names of the secrets, the keys of the Secret
+	Secrets []string
+}
+
+// kubernetesObject is the part of an object's metadata we are interested in
+type kubernetesObject struct {
+	Metadata struct {
+		UID string
+	}
+}
+
+// kubernetesJobStatus is the part of a batch/v1 Job we are interested in
+type kubernetesJobStatus struct {
+	Status struct {
+		Conditions []struct {
+			Type    string
+			Status  string
+			Message string
+		}
+	}
+}
+
+// kubernetesPodList is the part of a v1 PodList we are interested in
+type kubernetesPodList struct {
+	Items []struct {
+		Metadata struct {
+			Name string
+		}
+		Status struct {
+			ContainerStatuses []struct {
+				State struct {
+					Terminated *struct {
+						ExitCode int
+					}
+				}
+			}
+		}
+	}
+}
+
+// kubernetesClient talks to the api server, in cluster with the pod's service account
+type kubernetesClient struct {
+	api       string
+	token     string
+	namespace string
+	client    *http.Client
+}
+
+// --------------------------------------------------------------------------------------------
+// ~ Variables
+// --------------------------------------------------------------------------------------------
+
+var kubernetesTemplateFuncs = template.FuncMap{
+	// quote renders a string as a yaml/json string literal
+	"quote": func(s string) string {
+		data, _ := json.Marshal(s)
+		return string(data)
+	},
+}
+
+// --------------------------------------------------------------------------------------------
+// ~ Private methods
+// --------------------------------------------------------------------------------------------
+
+// newKubernetesClient uses -kube-api or, in a cluster, the api server and service account of the pod
+func newKubernetesClient() (*kubernetesClient, error) {
+	k := &kubernetesClient{
+		api:       strings.TrimRight(*kubeAPI, "/"),
+		namespace: *kubeNamespace,
+		client:    &http.Client{Timeout: 30 * time.Second},
+	}
+	if token, err := ioutil.ReadFile(serviceAccountDir + "/token"); err == nil {
+		k.token = strings.TrimSpace(string(token))
+	}
+	if k.namespace == "" {
+		k.namespace = "default"
+		if namespace, err := ioutil.ReadFile(serviceAccountDir + "/namespace"); err == nil {
+			k.namespace = strings.TrimSpace(string(namespace))
+		}
+	}
+	if k.api == "" {
+		host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+		if host == "" {
+			return nil, errors.New("not running in a kubernetes cluster, set -kube-api")
+		}
+		k.api = "https://" + host + ":" + port
+		ca, err := ioutil.ReadFile(serviceAccountDir + "/ca.crt")
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		pool.AppendCertsFromPEM(ca)
+		k.client.Transport = &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}
+	}
+	return k, nil
+}
+
+func (k *kubernetesClient) request(method string, path string, contentType string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequest(method, k.api+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if k.token != "" {
+		req.Header.Set("Authorization", "Bearer "+k.token)
+	}
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		message, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		return nil, fmt.Errorf("unexpected status %s from kubernetes: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+	return resp, nil
+}
+
+func (k *kubernetesClient) get(path string, v interface{}) error {
+	resp, err := k.request("GET", path, "", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func (k *kubernetesClient) jobsPath() string {
+	return "/apis/batch/v1/namespaces/" + k.namespace + "/jobs"
+}
+
+// createJob renders the template and creates the job from it, the api server accepts yaml and json
+func (k *kubernetesClient) createJob(templateFile string, data kubernetesJobData) error {
+	tmpl, err := template.New(filepath.Base(templateFile)).Funcs(kubernetesTemplateFuncs).ParseFiles(templateFile)
+	if err != nil {
+		return err
+	}
+	manifest := &bytes.Buffer{}
+	if err := tmpl.Execute(manifest, data); err != nil {
+		return err
+	}
+	resp, err := k.request("POST", k.jobsPath(), "application/yaml", manifest.Bytes())
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (k *kubernetesClient) secretsPath() string {
+	return "/api/v1/namespaces/" + k.namespace + "/secrets"
+}
+
+// createSecret creates a Secret for the secrets of a run, so their values do not show in the Job
+func (k *kubernetesClient) createSecret(name string, values map[string]string) error {
+	secret := map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"metadata":   map[string]string{"name": name},
+		"type":       "Opaque",
+		"stringData": values,
+	}
+	body, err := json.Marshal(secret)
+	if err != nil {
+		return err
+	}
+	resp, err := k.request("POST", k.secretsPath(), "application/json", body)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// ownSecret makes the job the owner of the Secret, so it is garbage collected with the job
+// even if crontinuous does not get to delete it
+func (k *kubernetesClient) ownSecret(name string, jobName string) error {
+	job := kubernetesObject{}
+	if err := k.get(k.jobsPath()+"/"+jobName, &job); err != nil {
+		return err
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"ownerReferences": []map[string]string{{
+				"apiVersion": "batch/v1",
+				"kind":       "Job",
+				"name":       jobName,
+				"uid":        job.Metadata.UID,
+			}},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	resp, err := k.request("PATCH", k.secretsPath()+"/"+name, "application/merge-patch+json", patch)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (k *kubernetesClient) deleteSecret(name string) error {
+	resp, err := k.request("DELETE", k.secretsPath()+"/"+name, "", nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// deleteJob deletes the job together with its pods
+func (k *kubernetesClient) deleteJob(name string) error {
+	resp, err := k.request("DELETE", k.jobsPath()+"/"+name, "application/json", []byte(`{"propagationPolicy":"Background"}`))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// jobDone reports whether the job finished and with which error
+func (k *kubernetesClient) jobDone(name string) (bool, error) {
+	job := kubernetesJobStatus{}
+	if err := k.get(k.jobsPath()+"/"+name, &job); err != nil {
+		return false, err
+	}
+	for _, condition := range job.Status.Conditions {
+		if condition.Status != "True" {
+			continue
+		}
+		switch condition.Type {
+		case "Complete":
+			return true, nil
+		case "Failed":
+			return true, fmt.Errorf("kubernetes job failed: %s", condition.Message)
+		}
+	}
+	return false, nil
+}
+
+// podLogs calls fn with every log line of the job's pods and returns the exit code of the last one
+func (k *kubernetesClient) podLogs(name string, fn func(line string)) (int, error) {
+	pods := kubernetesPodList{}
+	if err := k.get("/api/v1/namespaces/"+k.namespace+"/pods?labelSelector=job-name%3D"+name, &pods); err != nil {
+		return -1, err
+	}
+	exitCode := -1
+	for _, pod := range pods.Items {
+		resp, err := k.request("GET", "/api/v1/namespaces/"+k.namespace+"/pods/"+pod.Metadata.Name+"/log", "", nil)
+		if err != nil {
+			return exitCode, err
+		}
+		scanner := newOutputScanner(resp.Body)
+		for scanner.Scan() {
+			fn(scanner.Text())
+		}
+		resp.Body.Close()
+		for _, container := range pod.Status.ContainerStatuses {
+			if container.State.Terminated != nil {
+				exitCode = container.State.Terminated.ExitCode
+			}
+		}
+	}
+	return exitCode, nil
+}
+
+// Execute implements Executor, it launches a kubernetes job from the job's template with the command and
+// arguments of the attempt and waits for it to finish
+func (kubernetesExecutor) Execute(a *Attempt) {
+	r, e := a.Job, a.execution
+	k, err := newKubernetesClient()
+	if err != nil {
+		a.fail(err)
+		return
+	}
+	data := kubernetesJobData{
+		Name:      r.containerName(e.id),
+		Namespace: k.namespace,
+		JobID:     r.ID,
+		RunID:     e.id,
+		Command:   commandLine(a.Command, a.Args),
+		Args:      a.Args,
+		Env:       map[string]string{},
+	}
+	for _, env := range r.runEnv(e, a.Result) {
+		parts := strings.SplitN(env, "=", 2)
+		data.Env[parts[0]] = parts[1]
+	}
+
+	// secrets are passed in a Secret of their own, referenced by the template, never in the Job itself
+	if len(a.secrets.env) > 0 {
+		values := map[string]string{}
+		for _, env := range a.secrets.env {
+			parts := strings.SplitN(env, "=", 2)
+			values[parts[0]] = parts[1]
+		}
+		data.SecretName = data.Name
+		data.Secrets = a.secrets.names
+		if err := k.createSecret(data.SecretName, values); err != nil {
+			a.fail(fmt.Errorf("failed to create kubernetes secret: %s", err))
+			return
+		}
+		defer func() {
+			if err := k.deleteSecret(data.SecretName); err != nil {
+				a.Logger.Warn("failed to delete kubernetes secret", err)
+			}
+		}()
+	}
+	if err := k.createJob(r.Kubernetes, data); err != nil {
+		a.fail(fmt.Errorf("failed to create kubernetes job: %s", err))
+		return
+	}
+	if data.SecretName != "" {
+		if err := k.ownSecret(data.SecretName, data.Name); err != nil {
+			a.Logger.Warn("failed to hand the kubernetes secret to the job", err)
+		}
+	}
+	a.Started()
+	a.Logger.WithField("kubernetesJob", data.Name).Info("launched kubernetes job")
+	defer func() {
+		if err := k.deleteJob(data.Name); err != nil {
+			a.Logger.Warn("failed to delete kubernetes job", err)
+		}
+	}()
+
+	var timedOut <-chan time.Time
+	if r.Timeout > 0 {
+		timedOut = time.After(r.Timeout)
+	}
+	for done := false; !done; {
+		select {
+		case <-timedOut:
+			a.Logger.WithField("timeout", r.Timeout).Warn("run timed out, deleting kubernetes job")
+			a.Result.Event = EventTimeout
+			a.Result.Err = errors.New("timed out")
+			return
+		case <-a.Aborted():
+			a.Result.Err = errors.New("aborted")
+			return
+		case <-time.After(kubernetesPollInterval):
+			done, err = k.jobDone(data.Name)
+			if err != nil && !done {
+				a.Logger.Warn("failed to get kubernetes job status", err)
+			}
+		}
+	}
+
+	// pod logs do not tell stdout and stderr apart
+	exitCode, logErr := k.podLogs(data.Name, func(line string) {
+		a.Output("stdout", line)
+	})
+	if logErr != nil {
+		a.Logger.Warn("failed to get kubernetes pod logs", logErr)
+	}
+	if exitCode < 0 && err == nil {
+		// the job completed, its pods are gone before their exit code was read
+		exitCode = 0
+	}
+	a.Finish(exitCode, err)
+}
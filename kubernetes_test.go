@@ -0,0 +1,59 @@
+package crontinuous
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+func TestKubernetesExecutor(t *testing.T) {
+	dir, err := ioutil.TempDir("", "crontinuous-kubernetes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	templateFile := filepath.Join(dir, "job.yaml")
+	if err := ioutil.WriteFile(templateFile, []byte("name: {{.Name}}\ncommand: {{.Command}}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	created := ""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/apis/batch/v1/namespaces/test/jobs":
+			body, _ := ioutil.ReadAll(r.Body)
+			created = string(body)
+			w.WriteHeader(http.StatusCreated)
+		case r.Method == "GET" && strings.HasPrefix(r.URL.Path, "/apis/batch/v1/namespaces/test/jobs/"):
+			w.Write([]byte(`{"status":{"conditions":[{"type":"Complete","status":"True"}]}}`))
+		case r.Method == "GET" && r.URL.Path == "/api/v1/namespaces/test/pods":
+			w.Write([]byte(`{"items":[{"metadata":{"name":"pod"},"status":{"containerStatuses":[{"state":{"terminated":{"exitCode":3}}}]}}]}`))
+		case r.Method == "GET" && r.URL.Path == "/api/v1/namespaces/test/pods/pod/log":
+			w.Write([]byte("first\nsecond\n"))
+		case r.Method == "DELETE":
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	defer func(api string, namespace string) { *kubeAPI, *kubeNamespace = api, namespace }(*kubeAPI, *kubeNamespace)
+	*kubeAPI, *kubeNamespace = server.URL, "test"
+
+	job := &Runnable{ID: "kubernetes", Command: "echo", Args: "hello", Kubernetes: templateFile, contextLogger: log.WithField("id", "kubernetes")}
+	e := newExecution(job.contextLogger, "")
+	n := job.execute(e, 1)
+	if !strings.Contains(created, "command: echo hello") {
+		t.Errorf("expected the job to be created from the template, got %q", created)
+	}
+	// the output and outcome take the path of every other executor
+	if n.Event != EventFailure || n.ExitCode != 3 || n.Output != "first\nsecond" || n.OutputBytes != 13 {
+		t.Errorf("unexpected result %s %d %q %d", n.Event, n.ExitCode, n.Output, n.OutputBytes)
+	}
+}
@@ -0,0 +1,248 @@
+package crontinuous
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Constants
+// --------------------------------------------------------------------------------------------
+
+const (
+	// consulPrefix marks crontabs in a consul key, e.g. consul+http://127.0.0.1:8500/cron/batch
+	consulPrefix = "consul+"
+	// etcdPrefix marks crontabs in an etcd key, e.g. etcd+http://127.0.0.1:2379/cron/batch
+	etcdPrefix = "etcd+"
+	// kvWait bounds how long a watch on a key waits for a change before it is renewed
+	kvWait = 5 * time.Minute
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Struct
+// --------------------------------------------------------------------------------------------
+
+// consulCrontab fetches a crontab from the consul kv store, waiting for changes with blocking queries
+type consulCrontab struct {
+	addr  string
+	key   string
+	index uint64
+}
+
+// consulKeyValue is the part of a /v1/kv entry we are interested in, values are base64 in json
+type consulKeyValue struct {
+	Value       string
+	ModifyIndex uint64
+}
+
+// etcdCrontab fetches a crontab from an etcd key using the json gateway of the etcd v3 api,
+// waiting for changes with watches
+type etcdCrontab struct {
+	endpoint string
+	key      string
+	// revision of the store the crontab was fetched at, changes after it are watched
+	revision int64
+}
+
+// etcdWatchResponse is a message of a watch stream, int64s are strings in json
+type etcdWatchResponse struct {
+	Result struct {
+		Header struct {
+			Revision string `json:"revision"`
+		} `json:"header"`
+		Canceled        bool   `json:"canceled"`
+		CompactRevision string `json:"compact_revision"`
+		Events          []struct {
+			Type string `json:"type"`
+			Kv   struct {
+				Value       string `json:"value"`
+				ModRevision string `json:"mod_revision"`
+			} `json:"kv"`
+		} `json:"events"`
+	} `json:"result"`
+}
+
+// --------------------------------------------------------------------------------------------
+// ~ Private methods
+// --------------------------------------------------------------------------------------------
+
+// newKVFetcher returns the fetcher of a crontab in consul or etcd, false for other sources
+func newKVFetcher(source string) (crontabFetcher, bool) {
+	var raw string
+	switch {
+	case strings.HasPrefix(source, consulPrefix):
+		raw = strings.TrimPrefix(source, consulPrefix)
+	case strings.HasPrefix(source, etcdPrefix):
+		raw = strings.TrimPrefix(source, etcdPrefix)
+	default:
+		return nil, false
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return nil, false
+	}
+	key := strings.Trim(u.Path, "/")
+	u.Path = ""
+	addr := strings.TrimRight(u.String(), "/")
+	if strings.HasPrefix(source, consulPrefix) {
+		return &consulCrontab{addr: addr, key: key}, true
+	}
+	return &etcdCrontab{endpoint: addr, key: "/" + key}, true
+}
+
+// watches implements crontabFetcher, a blocking query returns once the key changes
+func (c *consulCrontab) watches() bool {
+	return true
+}
+
+// fetch implements crontabFetcher, the first fetch returns right away
+func (c *consulCrontab) fetch() ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), kvWait+time.Minute)
+	defer cancel()
+	query := url.Values{"index": {strconv.FormatUint(c.index, 10)}, "wait": {kvWait.String()}}
+	req, err := http.NewRequest("GET", c.addr+"/v1/kv/"+c.key+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// a reset index starts over, as consul asks clients to
+	index, _ := strconv.ParseUint(resp.Header.Get("X-Consul-Index"), 10, 64)
+	if index < c.index {
+		index = 0
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		c.index = index
+		return nil, fmt.Errorf("key %s not found", c.key)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s from consul", resp.Status)
+	}
+	entries := []consulKeyValue{}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 2*maxRemoteCrontab)).Decode(&entries); err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("key %s not found", c.key)
+	}
+	previous := c.index
+	c.index = index
+	if previous != 0 && entries[0].ModifyIndex <= previous {
+		return nil, nil
+	}
+	return base64.StdEncoding.DecodeString(entries[0].Value)
+}
+
+// watches implements crontabFetcher, a watch returns once the key changes
+func (e *etcdCrontab) watches() bool {
+	return true
+}
+
+// fetch implements crontabFetcher, the first fetch reads the key right away
+func (e *etcdCrontab) fetch() ([]byte, error) {
+	if e.revision == 0 {
+		return e.get()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), kvWait)
+	defer cancel()
+	payload, err := json.Marshal(map[string]interface{}{"create_request": map[string]interface{}{
+		"key":            e.encode(e.key),
+		"start_revision": strconv.FormatInt(e.revision+1, 10),
+	}})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest("POST", e.endpoint+"/v3/watch", bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s from etcd", resp.Status)
+	}
+
+	// the stream is json objects one after another, the first one confirms the watch
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		message := etcdWatchResponse{}
+		if err := decoder.Decode(&message); err != nil {
+			if ctx.Err() != nil {
+				return nil, nil
+			}
+			return nil, err
+		}
+		if message.Result.CompactRevision != "" && message.Result.CompactRevision != "0" {
+			// the changes since our revision are gone, read the key again
+			return e.get()
+		}
+		if message.Result.Canceled {
+			return nil, fmt.Errorf("etcd canceled the watch")
+		}
+		events := message.Result.Events
+		if len(events) == 0 {
+			continue
+		}
+		last := events[len(events)-1]
+		e.revision, _ = strconv.ParseInt(last.Kv.ModRevision, 10, 64)
+		if last.Type == "DELETE" {
+			return nil, fmt.Errorf("key %s was deleted", e.key)
+		}
+		return base64.StdEncoding.DecodeString(last.Kv.Value)
+	}
+}
+
+// get reads the key and remembers the revision of the store
+func (e *etcdCrontab) get() ([]byte, error) {
+	payload, err := json.Marshal(map[string]interface{}{"key": e.encode(e.key)})
+	if err != nil {
+		return nil, err
+	}
+	resp, err := remoteClient.Post(e.endpoint+"/v3/kv/range", "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s from etcd", resp.Status)
+	}
+	current := struct {
+		Header struct {
+			Revision string `json:"revision"`
+		} `json:"header"`
+		Kvs []etcdKeyValue `json:"kvs"`
+	}{}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 2*maxRemoteCrontab)).Decode(&current); err != nil {
+		return nil, err
+	}
+	e.revision, _ = strconv.ParseInt(current.Header.Revision, 10, 64)
+	if len(current.Kvs) == 0 {
+		return nil, fmt.Errorf("key %s not found", e.key)
+	}
+	return base64.StdEncoding.DecodeString(current.Kvs[0].Value)
+}
+
+func (e *etcdCrontab) encode(s string) string {
+	return base64.StdEncoding.EncodeToString([]byte(s))
+}
@@ -0,0 +1,120 @@
+package crontinuous
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Variables
+// --------------------------------------------------------------------------------------------
+
+var elector *leaderElection
+
+// --------------------------------------------------------------------------------------------
+// ~ Struct
+// --------------------------------------------------------------------------------------------
+
+// leaderLock is a lock with a ttl held by at most one instance of a cluster
+type leaderLock interface {
+	// acquire takes the lock if nobody holds it
+	acquire() (bool, error)
+	// refresh extends the ttl of the lock, false if it is not held anymore
+	refresh() (bool, error)
+	// release gives up the lock
+	release() error
+}
+
+// leaderElection makes sure only one of several crontinuous instances schedules jobs
+type leaderElection struct {
+	lock      leaderLock
+	ttl       time.Duration
+	leader    bool
+	refreshed time.Time
+	mutex     sync.Mutex
+}
+
+// --------------------------------------------------------------------------------------------
+// ~ Private methods
+// --------------------------------------------------------------------------------------------
+
+func newLeaderElection(lock leaderLock, ttl time.Duration) *leaderElection {
+	return &leaderElection{
+		lock: lock,
+		ttl:  ttl,
+	}
+}
+
+// haInstanceID identifies this instance in the cluster
+func haInstanceID() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	return fmt.Sprintf("%s-%d", hostname, os.Getpid())
+}
+
+// isLeader reports whether this instance may schedule jobs, always true without an election
+func (l *leaderElection) isLeader() bool {
+	if l == nil {
+		return true
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.leader
+}
+
+// run campaigns for the lock and keeps it, onChange is called whenever the leadership changes
+func (l *leaderElection) run(onChange func(leader bool)) {
+	for {
+		if leader := l.campaign(); leader != l.isLeader() {
+			l.mutex.Lock()
+			l.leader = leader
+			l.mutex.Unlock()
+			if leader {
+				log.Info("elected leader, scheduling jobs")
+			} else {
+				log.Warn("lost leadership, no longer scheduling jobs")
+			}
+			onChange(leader)
+		}
+		time.Sleep(l.ttl / 3)
+	}
+}
+
+// campaign acquires or refreshes the lock and returns whether this instance leads now
+func (l *leaderElection) campaign() bool {
+	var held bool
+	var err error
+	// the ttl of the lock runs from when it was asked for, not from the answer
+	attempted := time.Now()
+	if l.isLeader() {
+		held, err = l.lock.refresh()
+	} else {
+		held, err = l.lock.acquire()
+	}
+	if err != nil {
+		log.Warn("leader election failed", err)
+		// keep leading through a failed refresh, but step down well before the lock might have expired
+		// for the others, so clock drift and slow requests cannot let two instances lead at once
+		return l.isLeader() && time.Since(l.refreshed) < l.ttl/2
+	}
+	if held {
+		l.refreshed = attempted
+	}
+	return held
+}
+
+// resign gives up the leadership so another instance takes over right away
+func (l *leaderElection) resign() {
+	if l == nil || !l.isLeader() {
+		return
+	}
+	if err := l.lock.release(); err != nil {
+		log.Warn("failed to resign leadership", err)
+	}
+}
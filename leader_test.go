@@ -0,0 +1,41 @@
+package crontinuous
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// failingLock is a leader lock whose refreshes fail once it is taken
+type failingLock struct{}
+
+func (f *failingLock) acquire() (bool, error) {
+	return true, nil
+}
+
+func (f *failingLock) refresh() (bool, error) {
+	return false, errors.New("unavailable")
+}
+
+func (f *failingLock) release() error {
+	return nil
+}
+
+func TestLeaderStepsDownBeforeTTL(t *testing.T) {
+	l := newLeaderElection(&failingLock{}, time.Minute)
+	if !l.campaign() {
+		t.Fatal("expected to be elected")
+	}
+	l.leader = true
+
+	// a failed refresh within half the ttl keeps the leadership
+	l.refreshed = time.Now().Add(-20 * time.Second)
+	if !l.campaign() {
+		t.Error("expected to keep leading after a single failed refresh")
+	}
+	// past half the ttl it steps down, while the lock still holds for the others
+	l.refreshed = time.Now().Add(-31 * time.Second)
+	if l.campaign() {
+		t.Error("expected to step down before the lock may expire")
+	}
+}
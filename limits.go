@@ -0,0 +1,80 @@
+package crontinuous
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Struct
+// --------------------------------------------------------------------------------------------
+
+// resourceLimits restrict the resources a job run may use
+type resourceLimits struct {
+	// CPU is the number of cpus, e.g. 0.5 for half a cpu
+	CPU float64
+	// Memory is the max memory in bytes
+	Memory int64
+}
+
+// --------------------------------------------------------------------------------------------
+// ~ Private methods
+// --------------------------------------------------------------------------------------------
+
+// parseLimits parses limits like "cpu=0.5 mem=256M"
+func parseLimits(value string) (*resourceLimits, error) {
+	limits := &resourceLimits{}
+	for _, field := range strings.Fields(value) {
+		parts := strings.SplitN(field, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid limit %q, expected cpu=<cpus> or mem=<size>", field)
+		}
+		switch parts[0] {
+		case "cpu":
+			cpu, err := strconv.ParseFloat(parts[1], 64)
+			if err != nil || cpu <= 0 {
+				return nil, fmt.Errorf("invalid cpu limit %q", parts[1])
+			}
+			limits.CPU = cpu
+		case "mem", "memory":
+			memory, err := parseSize(parts[1])
+			if err != nil {
+				return nil, err
+			}
+			limits.Memory = memory
+		default:
+			return nil, fmt.Errorf("unknown limit %q, expected cpu or mem", parts[0])
+		}
+	}
+	return limits, nil
+}
+
+// parseSize parses sizes like 512K, 256M or 2G
+func parseSize(value string) (int64, error) {
+	multiplier := int64(1)
+	number := strings.ToUpper(value)
+	for i, unit := range []string{"K", "M", "G", "T"} {
+		if strings.HasSuffix(number, unit) {
+			multiplier = int64(1) << (10 * uint(i+1))
+			number = strings.TrimSuffix(number, unit)
+			break
+		}
+	}
+	size, err := strconv.ParseInt(number, 10, 64)
+	if err != nil || size <= 0 {
+		return 0, fmt.Errorf("invalid size %q, expected e.g. 256M", value)
+	}
+	return size * multiplier, nil
+}
+
+func (l *resourceLimits) String() string {
+	parts := []string{}
+	if l.CPU > 0 {
+		parts = append(parts, "cpu="+strconv.FormatFloat(l.CPU, 'f', -1, 64))
+	}
+	if l.Memory > 0 {
+		parts = append(parts, "mem="+strconv.FormatInt(l.Memory, 10))
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,127 @@
+package crontinuous
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Variables
+// --------------------------------------------------------------------------------------------
+
+var (
+	logFiles     = map[string]*rotatingFile{}
+	logFilesLock sync.Mutex
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Struct
+// --------------------------------------------------------------------------------------------
+
+// rotatingFile is a job's log file, rotated when it grows too large or too old
+type rotatingFile struct {
+	lock     sync.Mutex
+	filename string
+	file     *os.File
+	size     int64
+	opened   time.Time
+}
+
+// --------------------------------------------------------------------------------------------
+// ~ Private methods
+// --------------------------------------------------------------------------------------------
+
+// jobLogFile returns the log file of a job, nil if logging to files is disabled
+func jobLogFile(id string) *rotatingFile {
+	if *logDir == "" {
+		return nil
+	}
+	logFilesLock.Lock()
+	defer logFilesLock.Unlock()
+	f, ok := logFiles[id]
+	if !ok {
+		f = &rotatingFile{filename: filepath.Join(*logDir, id+".log")}
+		logFiles[id] = f
+	}
+	return f
+}
+
+// writeLine appends a timestamped line of a stream to the file, a nil file does nothing
+func (f *rotatingFile) writeLine(runID string, stream string, line string) error {
+	if f == nil {
+		return nil
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if err := f.rotateIfNeeded(); err != nil {
+		return err
+	}
+	n, err := fmt.Fprintf(f.file, "%s %s %s %s\n", time.Now().Format(time.RFC3339Nano), runID, stream, line)
+	f.size += int64(n)
+	return err
+}
+
+func (f *rotatingFile) rotateIfNeeded() error {
+	if f.file != nil {
+		tooLarge := *logMaxSize > 0 && f.size >= *logMaxSize
+		tooOld := *logMaxAge > 0 && time.Since(f.opened) >= *logMaxAge
+		if !tooLarge && !tooOld {
+			return nil
+		}
+		f.file.Close()
+		f.file = nil
+		if err := os.Rename(f.filename, f.filename+"."+time.Now().Format("20060102T150405.000")); err != nil {
+			return err
+		}
+		f.prune()
+	}
+
+	if err := os.MkdirAll(filepath.Dir(f.filename), 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(f.filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file = file
+	f.size = info.Size()
+	f.opened = time.Now()
+	return nil
+}
+
+// prune removes rotated files exceeding the retention
+func (f *rotatingFile) prune() {
+	infos, err := ioutil.ReadDir(filepath.Dir(f.filename))
+	if err != nil {
+		return
+	}
+	prefix := filepath.Base(f.filename) + "."
+	rotated := []os.FileInfo{}
+	for _, info := range infos {
+		if strings.HasPrefix(info.Name(), prefix) {
+			rotated = append(rotated, info)
+		}
+	}
+	// newest first
+	sort.Slice(rotated, func(i, j int) bool {
+		return rotated[i].Name() > rotated[j].Name()
+	})
+	for i, info := range rotated {
+		expired := *logRetention > 0 && time.Since(info.ModTime()) > *logRetention
+		if (*logMaxFiles > 0 && i >= *logMaxFiles) || expired {
+			os.Remove(filepath.Join(filepath.Dir(f.filename), info.Name()))
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package crontinuous
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Struct
+// --------------------------------------------------------------------------------------------
+
+// mailNotifier sends the output of runs and failure reports by mail, like cron does for MAILTO
+type mailNotifier struct {
+	to []string
+}
+
+// --------------------------------------------------------------------------------------------
+// ~ Public methods
+// --------------------------------------------------------------------------------------------
+
+// Notify implements Notifier
+func (m *mailNotifier) Notify(n *Notification) error {
+	// like cron, successful runs are only reported if they wrote any output, and starts not at all
+	if n.Event == EventStart || n.Event == EventSuccess && n.Output == "" {
+		return nil
+	}
+
+	subject := fmt.Sprintf("Cron <%s> %s %s", n.Hostname, n.Job.Command, n.Job.Args)
+	if n.Event != EventSuccess {
+		subject += fmt.Sprintf(" (%s)", n.Event)
+	}
+
+	body := &bytes.Buffer{}
+	fmt.Fprintf(body, "From: %s\r\n", *smtpFrom)
+	fmt.Fprintf(body, "To: %s\r\n", strings.Join(m.to, ", "))
+	fmt.Fprintf(body, "Subject: %s\r\n", strings.TrimSpace(subject))
+	fmt.Fprintf(body, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(body, "Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	fmt.Fprintf(body, "id: %s\r\nschedule: %s\r\nexit code: %d\r\n", n.Job.ID, n.Job.Schedule, n.ExitCode)
+	if n.Err != nil {
+		fmt.Fprintf(body, "error: %s\r\n", n.Err)
+	}
+	if n.Output != "" {
+		fmt.Fprintf(body, "\r\n%s\r\n", strings.Replace(n.Output, "\n", "\r\n", -1))
+	}
+	return sendMail(m.to, body.Bytes())
+}
+
+// --------------------------------------------------------------------------------------------
+// ~ Private methods
+// --------------------------------------------------------------------------------------------
+
+// parseMailto splits a MAILTO value into its recipients
+func parseMailto(value string) []string {
+	to := []string{}
+	for _, address := range strings.Split(value, ",") {
+		if address = strings.TrimSpace(address); address != "" {
+			to = append(to, address)
+		}
+	}
+	return to
+}
+
+// sendMail delivers a message through the configured smtp server,
+// with -smtp-tls the connection is encrypted from the start, otherwise STARTTLS is used if offered
+func sendMail(to []string, message []byte) error {
+	host, _, err := net.SplitHostPort(*smtpAddr)
+	if err != nil {
+		return err
+	}
+
+	var conn net.Conn
+	if *smtpTLS {
+		conn, err = tls.Dial("tcp", *smtpAddr, &tls.Config{ServerName: host})
+	} else {
+		conn, err = net.DialTimeout("tcp", *smtpAddr, 10*time.Second)
+	}
+	if err != nil {
+		return err
+	}
+
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok && !*smtpTLS {
+		if err := client.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if *smtpUser != "" {
+		if err := client.Auth(smtp.PlainAuth("", *smtpUser, *smtpPassword, host)); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(*smtpFrom); err != nil {
+		return err
+	}
+	for _, address := range to {
+		if err := client.Rcpt(address); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(message); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
@@ -0,0 +1,304 @@
+package crontinuous
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/robfig/cron"
+	"gopkg.in/yaml.v2"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Struct
+// --------------------------------------------------------------------------------------------
+
+// MaintenanceStatus describes a maintenance window as exposed by the admin API
+type MaintenanceStatus struct {
+	Name     string    `json:"name"`
+	Tags     []string  `json:"tags,omitempty"`
+	From     time.Time `json:"from"`
+	To       time.Time `json:"to"`
+	Schedule string    `json:"schedule,omitempty"`
+	Duration string    `json:"duration,omitempty"`
+	Source   string    `json:"source"`
+	Active   bool      `json:"active"`
+}
+
+// maintenanceConfig is the file of -maintenance
+type maintenanceConfig struct {
+	Windows []maintenanceDefinition `yaml:"windows"`
+}
+
+// maintenanceDefinition declares a maintenance window, once from a time to another or for a duration,
+// or recurring on a schedule for a duration. Without tags all jobs are in maintenance.
+type maintenanceDefinition struct {
+	Name     string   `yaml:"name" json:"name"`
+	Tags     []string `yaml:"tags" json:"tags"`
+	From     string   `yaml:"from" json:"from"`
+	To       string   `yaml:"to" json:"to"`
+	Schedule string   `yaml:"schedule" json:"schedule"`
+	Duration string   `yaml:"duration" json:"duration"`
+}
+
+// maintenanceWindow suppresses the scheduled runs of the jobs with one of its tags while it is active
+type maintenanceWindow struct {
+	name     string
+	tags     []string
+	from, to time.Time
+	spec     string
+	schedule cron.Schedule
+	duration time.Duration
+	// source is config for windows of the -maintenance file, api for windows added on request
+	source string
+}
+
+// --------------------------------------------------------------------------------------------
+// ~ Variables
+// --------------------------------------------------------------------------------------------
+
+var (
+	configMaintenance []*maintenanceWindow
+	apiMaintenance    []*maintenanceWindow
+	maintenanceLock   sync.Mutex
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Private methods
+// --------------------------------------------------------------------------------------------
+
+// loadMaintenance reads the maintenance windows of the -maintenance file, in place of the ones read before
+func loadMaintenance(filename string) error {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	config := maintenanceConfig{}
+	if err := yaml.UnmarshalStrict(data, &config); err != nil {
+		return fmt.Errorf("%s: %s", filename, err)
+	}
+	windows := []*maintenanceWindow{}
+	names := map[string]bool{}
+	for _, d := range config.Windows {
+		w, err := d.window("config", time.Now())
+		if err != nil {
+			return fmt.Errorf("%s: %s", filename, err)
+		}
+		if names[w.name] {
+			return fmt.Errorf("%s: duplicate maintenance window %q", filename, w.name)
+		}
+		names[w.name] = true
+		windows = append(windows, w)
+	}
+	maintenanceLock.Lock()
+	defer maintenanceLock.Unlock()
+	configMaintenance = windows
+	return nil
+}
+
+// window validates a definition, windows once start now if they have no start
+func (d maintenanceDefinition) window(source string, now time.Time) (*maintenanceWindow, error) {
+	if !jobNameRegexp.MatchString(d.Name) {
+		return nil, fmt.Errorf("invalid maintenance window name %q", d.Name)
+	}
+	w := &maintenanceWindow{name: d.Name, tags: d.Tags, spec: d.Schedule, source: source}
+	var err error
+	if d.Duration != "" {
+		if w.duration, err = time.ParseDuration(d.Duration); err != nil || w.duration <= 0 {
+			return nil, fmt.Errorf("maintenance window %s: invalid duration %q", d.Name, d.Duration)
+		}
+	}
+	if d.Schedule != "" {
+		if d.From != "" || d.To != "" || w.duration == 0 {
+			return nil, fmt.Errorf("maintenance window %s: a schedule takes a duration only", d.Name)
+		}
+		if w.schedule, err = parseSpec(withSecondsField(strings.Fields(d.Schedule))); err != nil {
+			return nil, fmt.Errorf("maintenance window %s: invalid schedule %q: %s", d.Name, d.Schedule, err)
+		}
+		return w, nil
+	}
+	w.from = now
+	if d.From != "" {
+		if w.from, err = parseOnceTime(d.From); err != nil {
+			return nil, fmt.Errorf("maintenance window %s: invalid from: %s", d.Name, err)
+		}
+	}
+	switch {
+	case d.To != "" && w.duration == 0:
+		if w.to, err = parseOnceTime(d.To); err != nil {
+			return nil, fmt.Errorf("maintenance window %s: invalid to: %s", d.Name, err)
+		}
+	case d.To == "" && w.duration > 0:
+		w.to = w.from.Add(w.duration)
+	default:
+		return nil, fmt.Errorf("maintenance window %s: needs either to or a duration", d.Name)
+	}
+	if !w.to.After(w.from) {
+		return nil, fmt.Errorf("maintenance window %s: ends before it starts", d.Name)
+	}
+	return w, nil
+}
+
+// active reports whether the window is active at a time
+func (w *maintenanceWindow) active(now time.Time) bool {
+	if w.schedule != nil {
+		start := w.schedule.Next(now.Add(-w.duration).In(schedulerLocation))
+		return !start.IsZero() && !start.After(now)
+	}
+	return !now.Before(w.from) && now.Before(w.to)
+}
+
+// ended reports whether a window once is over
+func (w *maintenanceWindow) ended(now time.Time) bool {
+	return w.schedule == nil && !now.Before(w.to)
+}
+
+// covers reports whether the window suppresses the runs of a job
+func (w *maintenanceWindow) covers(r *Runnable) bool {
+	if len(w.tags) == 0 {
+		return true
+	}
+	for _, tag := range w.tags {
+		if containsString(r.Tags, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *maintenanceWindow) status(now time.Time) MaintenanceStatus {
+	status := MaintenanceStatus{Name: w.name, Tags: w.tags, From: w.from, To: w.to, Schedule: w.spec, Source: w.source, Active: w.active(now)}
+	// recurring windows are described by their current or next occurrence
+	if w.schedule != nil {
+		status.Duration = w.duration.String()
+		if status.From = w.schedule.Next(now.Add(-w.duration).In(schedulerLocation)); !status.From.IsZero() {
+			status.To = status.From.Add(w.duration)
+		}
+	}
+	return status
+}
+
+// maintenance returns the active maintenance window suppressing the scheduled runs of the job, nil if there is none
+func (r *Runnable) maintenance(now time.Time) *maintenanceWindow {
+	maintenanceLock.Lock()
+	defer maintenanceLock.Unlock()
+	for _, w := range append(append([]*maintenanceWindow{}, configMaintenance...), apiMaintenance...) {
+		if w.covers(r) && w.active(now) {
+			return w
+		}
+	}
+	return nil
+}
+
+// maintenanceStatuses lists the maintenance windows by name, dropping windows added on request that are over
+func maintenanceStatuses(now time.Time) []MaintenanceStatus {
+	maintenanceLock.Lock()
+	defer maintenanceLock.Unlock()
+	pruneMaintenance(now)
+	statuses := []MaintenanceStatus{}
+	for _, w := range append(append([]*maintenanceWindow{}, configMaintenance...), apiMaintenance...) {
+		statuses = append(statuses, w.status(now))
+	}
+	sort.SliceStable(statuses, func(i, j int) bool {
+		return statuses[i].Name < statuses[j].Name
+	})
+	return statuses
+}
+
+// addMaintenance adds a window on request
+func addMaintenance(w *maintenanceWindow) error {
+	maintenanceLock.Lock()
+	defer maintenanceLock.Unlock()
+	pruneMaintenance(time.Now())
+	for _, existing := range append(append([]*maintenanceWindow{}, configMaintenance...), apiMaintenance...) {
+		if existing.name == w.name {
+			return fmt.Errorf("maintenance window %s exists already", w.name)
+		}
+	}
+	apiMaintenance = append(apiMaintenance, w)
+	return nil
+}
+
+// pruneMaintenance drops the windows added on request that are over, with the lock held
+func pruneMaintenance(now time.Time) {
+	current := []*maintenanceWindow{}
+	for _, w := range apiMaintenance {
+		if !w.ended(now) {
+			current = append(current, w)
+		}
+	}
+	apiMaintenance = current
+}
+
+// endMaintenance removes a window added on request, reporting whether there is one, windows of the
+// -maintenance file are changed in the file
+func endMaintenance(name string) (bool, error) {
+	maintenanceLock.Lock()
+	defer maintenanceLock.Unlock()
+	for i, w := range apiMaintenance {
+		if w.name == name {
+			apiMaintenance = append(apiMaintenance[:i:i], apiMaintenance[i+1:]...)
+			return true, nil
+		}
+	}
+	for _, w := range configMaintenance {
+		if w.name == name {
+			return true, errors.New("maintenance window " + name + " is declared in the maintenance file")
+		}
+	}
+	return false, nil
+}
+
+// handleMaintenance lists the maintenance windows on GET and adds one on POST
+func handleMaintenance(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		jsonReply(w, maintenanceStatuses(time.Now()))
+	case "POST":
+		d := maintenanceDefinition{}
+		if err := json.NewDecoder(r.Body).Decode(&d); err != nil {
+			http.Error(w, "invalid maintenance window: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		now := time.Now()
+		window, err := d.window("api", now)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := addMaintenance(window); err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		log.WithFields(log.Fields{"remote": r.RemoteAddr, "maintenance": window.name, "tags": strings.Join(window.tags, ",")}).Info("maintenance window added on request")
+		jsonStatusReply(w, http.StatusCreated, window.status(now))
+	default:
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+// handleMaintenanceWindow ends a maintenance window added on request on DELETE
+func handleMaintenanceWindow(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "DELETE" {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	name := strings.TrimPrefix(r.URL.Path, "/maintenance/")
+	found, err := endMaintenance(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	} else if !found {
+		http.NotFound(w, r)
+		return
+	}
+	log.WithFields(log.Fields{"remote": r.RemoteAddr, "maintenance": name}).Info("maintenance window ended on request")
+	jsonReply(w, map[string]string{"name": name, "status": "ended"})
+}
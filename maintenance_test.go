@@ -0,0 +1,112 @@
+package crontinuous
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMaintenanceWindows(t *testing.T) {
+	dir, err := ioutil.TempDir("", "crontinuous-maintenance")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(location *time.Location) {
+		configMaintenance, apiMaintenance = nil, nil
+		schedulerLocation = location
+	}(schedulerLocation)
+	schedulerLocation = time.UTC
+	filename := filepath.Join(dir, "maintenance.yaml")
+	config := "windows:\n" +
+		"  - name: db-upgrade\n    tags: [db]\n    from: 2016-11-01T02:00:00Z\n    to: 2016-11-01T04:00:00Z\n" +
+		"  - name: vacuum\n    tags: [db, reports]\n    schedule: 0 3 * * 0\n    duration: 2h\n"
+	if err := ioutil.WriteFile(filename, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := loadMaintenance(filename); err != nil {
+		t.Fatal(err)
+	}
+
+	jobs, errs := parseCrontabReader("maintenance", strings.NewReader("# name: backup\n# tags: db\n* * * * * true\n# name: web\n# tags: web\n* * * * * true\n"), nil)
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	backup, web := jobs[0], jobs[1]
+	for _, test := range []struct {
+		at   string
+		want string
+	}{
+		{"2016-11-01T01:59:59Z", ""},
+		{"2016-11-01T02:00:00Z", "db-upgrade"},
+		{"2016-11-01T04:00:00Z", ""},
+		// the 6th of November 2016 was a Sunday
+		{"2016-11-06T04:59:00Z", "vacuum"},
+		{"2016-11-06T05:00:00Z", ""},
+	} {
+		at, _ := time.Parse(time.RFC3339, test.at)
+		got := ""
+		if w := backup.maintenance(at); w != nil {
+			got = w.name
+		}
+		if got != test.want {
+			t.Errorf("at %s: got window %q, want %q", test.at, got, test.want)
+		}
+		if web.maintenance(at) != nil {
+			t.Errorf("at %s: job without tag of a window is in maintenance", test.at)
+		}
+	}
+
+	for _, broken := range []string{
+		"windows:\n  - name: a\n    from: 2016-11-01T02:00:00Z\n",
+		"windows:\n  - name: a\n    schedule: 0 3 * * 0\n",
+		"windows:\n  - name: a\n    duration: 1h\n  - name: a\n    duration: 1h\n",
+		"windows:\n  - name: a\n    duration: 1h\n    until: tomorrow\n",
+	} {
+		ioutil.WriteFile(filename, []byte(broken), 0644)
+		if err := loadMaintenance(filename); err == nil {
+			t.Errorf("expected an error for %q", broken)
+		}
+	}
+
+	// windows added on request suppress scheduled runs until they end
+	request := func(method string, path string, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("X-Requested-With", "XMLHttpRequest")
+		rec := httptest.NewRecorder()
+		apiHandler().ServeHTTP(rec, req)
+		return rec
+	}
+	if rec := request("POST", "/maintenance", `{"name": "deploy", "tags": ["web"], "duration": "1h"}`); rec.Code != http.StatusCreated {
+		t.Fatalf("got status %d: %s", rec.Code, rec.Body)
+	} else if contentType := rec.Result().Header.Get("Content-Type"); contentType != "application/json" {
+		t.Errorf("expected a json reply, got %q", contentType)
+	}
+	if rec := request("POST", "/maintenance", `{"name": "deploy", "duration": "1h"}`); rec.Code != http.StatusConflict {
+		t.Errorf("expected a conflict for a window of the same name, got %d", rec.Code)
+	}
+	web.runUnlessPaused()
+	if web.lastRun() != nil {
+		t.Error("expected the run to be suppressed")
+	}
+	statuses := []MaintenanceStatus{}
+	json.NewDecoder(request("GET", "/maintenance", "").Body).Decode(&statuses)
+	if len(statuses) != 3 || statuses[0].Name != "db-upgrade" || !statuses[1].Active || statuses[1].Source != "api" {
+		t.Errorf("unexpected windows %+v", statuses)
+	}
+	if rec := request("DELETE", "/maintenance/vacuum", ""); rec.Code != http.StatusConflict {
+		t.Errorf("expected windows of the file to be kept, got %d", rec.Code)
+	}
+	if rec := request("DELETE", "/maintenance/deploy", ""); rec.Code != http.StatusOK {
+		t.Errorf("got status %d: %s", rec.Code, rec.Body)
+	}
+	if web.maintenance(time.Now()) != nil {
+		t.Error("expected the window to be over")
+	}
+}
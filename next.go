@@ -0,0 +1,88 @@
+package crontinuous
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Struct
+// --------------------------------------------------------------------------------------------
+
+// upcomingRun is a scheduled execution of a job
+type upcomingRun struct {
+	at  time.Time
+	job *Runnable
+}
+
+// --------------------------------------------------------------------------------------------
+// ~ Private methods
+// --------------------------------------------------------------------------------------------
+
+// nextCommand implements `crontinuous next [job-id]`, printing the upcoming runs of the crontab
+func nextCommand(args []string) int {
+	flags := flag.NewFlagSet("next", flag.ExitOnError)
+	limit := flags.Int("n", 10, "number of upcoming runs to show")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: crontinuous next [options] [job-id]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	paths, _, err := mirrorCrontabs(crontabs.paths)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	sources := newCrontabSources(&crontabPaths{paths: paths, prefixes: crontabs.prefixes})
+	if err := loadSources(sources, parseCrontab); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	jobs, errs, _ := mergeSources(sources)
+	for _, err := range errs {
+		fmt.Fprintln(os.Stderr, err)
+	}
+
+	now := time.Now().In(schedulerLocation)
+	runs := []upcomingRun{}
+	for _, r := range selectByTags(jobs) {
+		if r.schedule == nil || r.Disabled || (flags.Arg(0) != "" && r.ID != flags.Arg(0)) {
+			continue
+		}
+		// a single job may take all of the n upcoming runs
+		next := now
+		for i := 0; i < *limit; i++ {
+			next = r.schedule.Next(next)
+			if next.IsZero() {
+				break
+			}
+			runs = append(runs, upcomingRun{at: next, job: r})
+		}
+	}
+	sort.SliceStable(runs, func(i, j int) bool {
+		return runs[i].at.Before(runs[j].at)
+	})
+	if len(runs) > *limit {
+		runs = runs[:*limit]
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "NEXT\tIN\tSCHEDULE\tID\tCOMMAND")
+	for _, run := range runs {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s %s\n",
+			run.at.Format(time.RFC3339),
+			run.at.Sub(now).Round(time.Second),
+			run.job.Schedule,
+			run.job.ID,
+			run.job.Command,
+			run.job.Args,
+		)
+	}
+	w.Flush()
+	return 0
+}
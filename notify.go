@@ -0,0 +1,303 @@
+package crontinuous
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Constants
+// --------------------------------------------------------------------------------------------
+
+// Event names what happened to a job run
+type Event string
+
+const (
+	// EventStart is sent when a run was started
+	EventStart Event = "start"
+	// EventSuccess is sent when a run exits with zero
+	EventSuccess Event = "success"
+	// EventWarning is sent when a run exits with a code mapped to a warning
+	EventWarning Event = "warning"
+	// EventFailure is sent when a run exits non-zero or could not be started
+	EventFailure Event = "failure"
+	// EventTimeout is sent when a run was killed after exceeding its timeout
+	EventTimeout Event = "timeout"
+	// EventSlow is sent when a run takes longer than its expected duration, it goes on nevertheless
+	EventSlow Event = "slow"
+	// EventMissed is sent when a job did not run within its dead man tolerance of when it was expected
+	EventMissed Event = "missed"
+	// EventSuspended is sent when the circuit breaker of a job suspended it after runs failing in a row
+	EventSuspended Event = "suspended"
+)
+
+const stderrTailLines = 20
+const maxNotificationOutput = 1024 * 64 // in byte => 64Kb
+
+// --------------------------------------------------------------------------------------------
+// ~ Variables
+// --------------------------------------------------------------------------------------------
+
+var (
+	notifyClient = &http.Client{Timeout: 10 * time.Second}
+	// pendingNotifications are being sent, waited for before exiting after single runs
+	pendingNotifications sync.WaitGroup
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Struct
+// --------------------------------------------------------------------------------------------
+
+// Notifier is informed about noteworthy job runs
+type Notifier interface {
+	Notify(n *Notification) error
+}
+
+// Notification describes a job run for notifiers
+type Notification struct {
+	Event     Event
+	Job       *Runnable
+	RunID     string
+	SpanID    string
+	Attempt   int
+	Start     time.Time
+	Duration  time.Duration
+	ExitCode  int
+	Signal    string
+	OOMKilled bool
+	Err       error
+	Stderr    string
+	Output    string
+	// OutputBytes counts all output of the run, Output may be truncated
+	OutputBytes int64
+	Hostname    string
+	// Revision is the version of the crontab the job was loaded from, e.g. a git commit
+	Revision string
+	// UserCPU, SystemCPU and MaxRSS are the resources the process of the run used, zero for runs without local process
+	UserCPU   time.Duration
+	SystemCPU time.Duration
+	MaxRSS    int64
+	// Waited is how long the run was queued for a free slot before its first attempt
+	Waited time.Duration
+}
+
+// slackNotifier posts notifications to a Slack incoming webhook
+type slackNotifier struct {
+	webhook string
+}
+
+// lineTail keeps the last lines written to it
+type lineTail struct {
+	lines []string
+	max   int
+}
+
+// cappedBuffer keeps the lines written to it up to a maximum size
+type cappedBuffer struct {
+	buffer    bytes.Buffer
+	max       int
+	truncated bool
+}
+
+// --------------------------------------------------------------------------------------------
+// ~ Public methods
+// --------------------------------------------------------------------------------------------
+
+// Notify implements Notifier
+func (s *slackNotifier) Notify(n *Notification) error {
+	// starts are for webhooks and pings, a message for every run would drown the channel
+	if n.Event == EventSuccess || n.Event == EventStart {
+		return nil
+	}
+	text := fmt.Sprintf("*crontinuous job %s* on %s\nid: `%s`\nschedule: `%s`\ncommand: `%s %s`\nexit code: %d",
+		n.Event, n.Hostname, n.Job.ID, n.Job.Schedule, n.Job.Command, n.Job.Args, n.ExitCode)
+	if n.Err != nil {
+		text += fmt.Sprintf("\nerror: %s", n.Err)
+	}
+	if n.Stderr != "" {
+		text += "\n```" + n.Stderr + "```"
+	}
+	payload, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		return err
+	}
+	return postJSON(s.webhook, payload)
+}
+
+// --------------------------------------------------------------------------------------------
+// ~ Private methods
+// --------------------------------------------------------------------------------------------
+
+func newLineTail(max int) *lineTail {
+	return &lineTail{max: max}
+}
+
+func (t *lineTail) add(line string) {
+	t.lines = append(t.lines, line)
+	if len(t.lines) > t.max {
+		t.lines = t.lines[len(t.lines)-t.max:]
+	}
+}
+
+func (t *lineTail) String() string {
+	return strings.Join(t.lines, "\n")
+}
+
+func newCappedBuffer(max int) *cappedBuffer {
+	return &cappedBuffer{max: max}
+}
+
+func (b *cappedBuffer) add(line string) {
+	if b.truncated {
+		return
+	}
+	if b.buffer.Len()+len(line)+1 > b.max {
+		b.truncated = true
+		return
+	}
+	b.buffer.WriteString(line)
+	b.buffer.WriteByte('\n')
+}
+
+func (b *cappedBuffer) String() string {
+	if b.truncated {
+		return b.buffer.String() + "[output truncated]"
+	}
+	return strings.TrimRight(b.buffer.String(), "\n")
+}
+
+// notifiers returns the notifiers configured for the runnable
+func (r *Runnable) notifiers() []Notifier {
+	notifiers := append([]Notifier{}, shippers...)
+	if r.SlackWebhook != "" && r.SlackWebhook != "none" {
+		notifiers = append(notifiers, &slackNotifier{webhook: r.SlackWebhook})
+	}
+	if to := parseMailto(r.Mailto); len(to) > 0 && *smtpAddr != "" {
+		notifiers = append(notifiers, &mailNotifier{to: to})
+	}
+	if r.PingURL != "" && r.PingURL != "none" {
+		notifiers = append(notifiers, &pingNotifier{url: r.PingURL})
+	}
+	if r.Webhook != "" && r.Webhook != "none" {
+		notifiers = append(notifiers, &webhookNotifier{url: r.Webhook, events: parseWebhookEvents(*webhookEvents)})
+	}
+	return append(notifiers, r.scheduler.allNotifiers()...)
+}
+
+// notify sends a notification to all of the runnable's notifiers
+func (r *Runnable) notify(n *Notification) {
+	n.Job = r
+	n.Hostname, _ = os.Hostname()
+	if n.Event != EventStart && !n.Start.IsZero() && n.Duration == 0 {
+		n.Duration = time.Since(n.Start)
+	}
+	runEvents.publish(n)
+	for _, notifier := range r.notifiers() {
+		pendingNotifications.Add(1)
+		go func(notifier Notifier) {
+			defer pendingNotifications.Done()
+			if err := notifier.Notify(n); err != nil {
+				r.contextLogger.WithField("runId", n.RunID).Error("failed to send notification", err)
+			}
+		}(notifier)
+	}
+}
+
+// logResult logs the outcome of a run as one structured event
+func (r *Runnable) logResult(n *Notification) {
+	fields := log.Fields{
+		"runId":       n.RunID,
+		"attempt":     n.Attempt,
+		"status":      string(n.Event),
+		"exitCode":    n.ExitCode,
+		"duration":    n.Duration.Seconds(),
+		"outputBytes": n.OutputBytes,
+	}
+	if n.Signal != "" {
+		fields["signal"] = n.Signal
+	}
+	if n.OOMKilled {
+		fields["oomKilled"] = true
+	}
+	if n.Revision != "" {
+		fields["revision"] = n.Revision
+	}
+	if n.Waited > 0 {
+		fields["waited"] = n.Waited.Seconds()
+	}
+	if n.measured() {
+		fields["userCpu"] = n.UserCPU.Seconds()
+		fields["systemCpu"] = n.SystemCPU.Seconds()
+		fields["maxRss"] = n.MaxRSS
+	}
+	if n.Err != nil {
+		fields["error"] = n.Err.Error()
+	}
+	if n.Event == EventSuccess {
+		r.contextLogger.WithFields(fields).Info("run finished")
+	} else {
+		r.contextLogger.WithFields(fields).Warn("run finished")
+	}
+}
+
+// measured reports whether the run tells the resources its process used
+func (n *Notification) measured() bool {
+	return n.UserCPU > 0 || n.SystemCPU > 0 || n.MaxRSS > 0
+}
+
+// newRunID returns a random version 4 uuid to tell runs of a job apart
+func newRunID() string {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		binary.BigEndian.PutUint64(id, uint64(time.Now().UnixNano()))
+	}
+	id[6] = id[6]&0x0f | 0x40
+	id[8] = id[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", id[0:4], id[4:6], id[6:8], id[8:10], id[10:])
+}
+
+func postJSON(url string, payload []byte) error {
+	resp, err := notifyClient.Post(url, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s from %s", resp.Status, url)
+	}
+	return nil
+}
+
+// exitCode returns the exit code of a finished command, -1 if unknown
+func exitCode(cmd *exec.Cmd) int {
+	if cmd.ProcessState == nil {
+		return -1
+	}
+	if status, ok := cmd.ProcessState.Sys().(syscall.WaitStatus); ok {
+		return status.ExitStatus()
+	}
+	return -1
+}
+
+// exitSignal returns the name of the signal that killed the command, empty if it exited
+func exitSignal(cmd *exec.Cmd) string {
+	if cmd.ProcessState == nil {
+		return ""
+	}
+	if status, ok := cmd.ProcessState.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return status.Signal().String()
+	}
+	return ""
+}
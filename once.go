@@ -0,0 +1,168 @@
+package crontinuous
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Constants
+// --------------------------------------------------------------------------------------------
+
+// onceNotificationTimeout bounds waiting for notifications before `crontinuous once` exits
+const onceNotificationTimeout = 30 * time.Second
+
+// --------------------------------------------------------------------------------------------
+// ~ Private methods
+// --------------------------------------------------------------------------------------------
+
+// onceCommand implements `crontinuous once`, running the jobs due in a slot one after another and
+// exiting non-zero if one of them failed, e.g. from a CI pipeline or a systemd timer
+func onceCommand(args []string) int {
+	flags := flag.NewFlagSet("once", flag.ExitOnError)
+	at := flags.String("at", "now", "end of the slot, e.g. now, 2016-11-01T03:00:00Z, 2016-11-01T03:00 or 03:00 (today)")
+	window := flags.Duration("window", time.Minute, "length of the slot, jobs due after -at minus -window up to -at run, e.g. the interval of the timer")
+	dryRun := flags.Bool("dry-run", false, "list the jobs due in the slot without running them")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: crontinuous once [options] [job-id...]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	end, err := parseOnceTime(*at)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "invalid -at:", err)
+		return 2
+	}
+	if *window <= 0 {
+		fmt.Fprintln(os.Stderr, "-window has to be positive")
+		return 2
+	}
+
+	sources := newCrontabSources(crontabs)
+	if err := loadSources(sources, parseCrontab); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	jobs, errs, duplicates := mergeSources(sources)
+	problems := append(append(errs, duplicates...), dependencyErrors(jobs)...)
+	for _, err := range problems {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	jobs = selectByTags(jobs)
+	cronLock.Lock()
+	loadedJobs = jobs
+	cronLock.Unlock()
+	if *redisAddrs != "" {
+		setupJobLocks(*redisAddrs, *redisPassword)
+	}
+
+	due := dueJobs(jobs, end.Add(-*window), end, flags.Args())
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "DUE\tID\tSTATUS\tEXIT\tDURATION\tCOMMAND")
+	failed := 0
+	for _, run := range due {
+		status, exit, duration := "due", "-", "-"
+		if !*dryRun {
+			// runs go through the whole pipeline of scheduled runs, retries and notifications included
+			previous := run.job.lastRun()
+			run.job.runUnlessPaused()
+			status = "skipped"
+			if n := run.job.lastRun(); n != nil && n != previous {
+				status, exit, duration = string(n.Event), fmt.Sprint(n.ExitCode), n.Duration.Round(time.Millisecond).String()
+				if n.Event != EventSuccess && n.Event != EventWarning {
+					failed++
+				}
+			}
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", run.at.Format(time.RFC3339), run.job.ID, status, exit, duration, strings.TrimSpace(run.job.Command+" "+run.job.Args))
+	}
+	w.Flush()
+
+	waitForNotifications(onceNotificationTimeout)
+	if failed > 0 || len(problems) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// dueJobs returns the jobs with a scheduled run after from up to to, in the order of their runs
+// with the jobs they run after first, limited to the given ids if any
+func dueJobs(jobs []*Runnable, from time.Time, to time.Time, ids []string) []upcomingRun {
+	runs := []upcomingRun{}
+	for _, r := range jobs {
+		if r.schedule == nil || r.Disabled || (len(ids) > 0 && !containsString(ids, r.ID)) {
+			continue
+		}
+		if next := r.schedule.Next(from.In(schedulerLocation)); !next.IsZero() && !next.After(to) {
+			runs = append(runs, upcomingRun{at: next, job: r})
+		}
+	}
+	sort.SliceStable(runs, func(i, j int) bool {
+		return runs[i].at.Before(runs[j].at)
+	})
+
+	// a job due in the slot as well as a job it runs after waits for it
+	index := map[string]upcomingRun{}
+	for _, run := range runs {
+		index[run.job.ID] = run
+	}
+	ordered := []upcomingRun{}
+	added := map[string]bool{}
+	var add func(run upcomingRun)
+	add = func(run upcomingRun) {
+		if added[run.job.ID] {
+			return
+		}
+		added[run.job.ID] = true
+		for _, id := range run.job.After {
+			if dependency, ok := index[id]; ok {
+				add(dependency)
+			}
+		}
+		ordered = append(ordered, run)
+	}
+	for _, run := range runs {
+		add(run)
+	}
+	return ordered
+}
+
+// parseOnceTime parses the time of a slot, times without date are today in the time zone of the scheduler
+func parseOnceTime(value string) (time.Time, error) {
+	now := time.Now().In(schedulerLocation)
+	if value == "" || value == "now" {
+		return now, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{"2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02 15:04"} {
+		if t, err := time.ParseInLocation(layout, value, schedulerLocation); err == nil {
+			return t, nil
+		}
+	}
+	for _, layout := range []string{"15:04:05", "15:04"} {
+		if t, err := time.ParseInLocation(layout, value, schedulerLocation); err == nil {
+			return time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), t.Second(), 0, schedulerLocation), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unknown time %q, expected now, %s or %s", value, time.RFC3339, strings.Join([]string{"2006-01-02T15:04", "15:04"}, " or "))
+}
+
+// waitForNotifications waits for the notifications being sent, at most for the timeout
+func waitForNotifications(timeout time.Duration) {
+	done := make(chan struct{})
+	go func() {
+		pendingNotifications.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+	}
+}
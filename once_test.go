@@ -0,0 +1,49 @@
+package crontinuous
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDueJobs(t *testing.T) {
+	crontab := "# name: upload\n# after: backup\n0 3 * * * upload\n" +
+		"# name: backup\n55 2 * * * backup\n" +
+		"# name: report\n0 4 * * * report\n" +
+		"# name: cleanup\n# disabled\n0 3 * * * cleanup\n" +
+		"# name: boot\n@reboot boot\n"
+	jobs, errs := parseCrontabReader("once", strings.NewReader(crontab), nil)
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	at := time.Date(2016, 11, 1, 3, 0, 0, 0, schedulerLocation)
+
+	ids := func(runs []upcomingRun) string {
+		names := []string{}
+		for _, run := range runs {
+			names = append(names, run.job.ID)
+		}
+		return strings.Join(names, ",")
+	}
+	for _, test := range []struct {
+		window time.Duration
+		ids    []string
+		want   string
+	}{
+		{time.Minute, nil, "upload"},
+		{time.Hour, nil, "backup,upload"},
+		{2 * time.Hour, []string{"upload", "report"}, "upload"},
+		{time.Minute, []string{"report"}, ""},
+	} {
+		if got := ids(dueJobs(jobs, at.Add(-test.window), at, test.ids)); got != test.want {
+			t.Errorf("window %s, ids %v: got %q, want %q", test.window, test.ids, got, test.want)
+		}
+	}
+
+	if _, err := parseOnceTime("yesterday"); err == nil {
+		t.Error("expected an error for an unknown time")
+	}
+	if got, err := parseOnceTime("2016-11-01T03:00"); err != nil || !got.Equal(at) {
+		t.Errorf("got %s %v, want %s", got, err, at)
+	}
+}
@@ -0,0 +1,177 @@
+package crontinuous
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"gopkg.in/fsnotify.v1"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Constants
+// --------------------------------------------------------------------------------------------
+
+const (
+	// onFileSchedule is the schedule of jobs run for files appearing, followed by the pattern of the files
+	onFileSchedule = "@onfile"
+	// onFileEnv passes the file to the run
+	onFileEnv = "CRONTINUOUS_FILE"
+	// defaultDebounce is how long a file has to be left alone before its job runs
+	defaultDebounce = 2 * time.Second
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Struct
+// --------------------------------------------------------------------------------------------
+
+// fileTriggers runs @onfile jobs when files matching their pattern are created or written, once
+// a file was left alone for the debounce of the job
+type fileTriggers struct {
+	lock    sync.Mutex
+	watcher *fsnotify.Watcher
+	jobs    []*Runnable
+	dirs    map[string]bool
+	// pending runs by job id and file, reset by every event of the file
+	pending map[string]*time.Timer
+}
+
+// --------------------------------------------------------------------------------------------
+// ~ Variables
+// --------------------------------------------------------------------------------------------
+
+// daemonFileTriggers watches the files of the @onfile jobs of the crontabs
+var daemonFileTriggers = &fileTriggers{}
+
+// --------------------------------------------------------------------------------------------
+// ~ Private methods
+// --------------------------------------------------------------------------------------------
+
+// setOnFile makes the job an @onfile job if its schedule is one, reporting whether it is
+func (r *Runnable) setOnFile(schedule string) (bool, error) {
+	if !strings.HasPrefix(schedule, onFileSchedule+" ") {
+		return false, nil
+	}
+	pattern := strings.TrimSpace(strings.TrimPrefix(schedule, onFileSchedule))
+	if !filepath.IsAbs(pattern) {
+		return false, fmt.Errorf("%s needs an absolute path pattern, got %q", onFileSchedule, pattern)
+	}
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return false, fmt.Errorf("invalid %s pattern %q: %s", onFileSchedule, pattern, err)
+	}
+	if strings.ContainsAny(filepath.Dir(pattern), "*?[") {
+		return false, fmt.Errorf("%s patterns match files of a single directory, got %q", onFileSchedule, pattern)
+	}
+	r.OnFile = filepath.Clean(pattern)
+	return true, nil
+}
+
+// debounce returns how long a file has to be left alone before the job runs for it
+func (r *Runnable) debounce() time.Duration {
+	if r.Debounce > 0 {
+		return r.Debounce
+	}
+	return defaultDebounce
+}
+
+// update watches the directories of the @onfile jobs among the jobs, in place of the ones watched before
+func (t *fileTriggers) update(jobs []*Runnable) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.jobs = nil
+	dirs := map[string]bool{}
+	for _, r := range jobs {
+		if r.OnFile != "" && !r.Disabled {
+			t.jobs = append(t.jobs, r)
+			dirs[filepath.Dir(r.OnFile)] = true
+		}
+	}
+	if t.watcher == nil {
+		if len(dirs) == 0 {
+			return
+		}
+		watcher, err := fsnotify.NewWatcher()
+		if err != nil {
+			log.Error("failed to watch the files of @onfile jobs: ", err)
+			return
+		}
+		t.watcher = watcher
+		t.dirs = map[string]bool{}
+		t.pending = map[string]*time.Timer{}
+		go t.watch(watcher)
+	}
+	for dir := range t.dirs {
+		if !dirs[dir] {
+			t.watcher.Remove(dir)
+			delete(t.dirs, dir)
+		}
+	}
+	for dir := range dirs {
+		if t.dirs[dir] {
+			continue
+		}
+		if err := t.watcher.Add(dir); err != nil {
+			log.WithField("dir", dir).Error("failed to watch the files of @onfile jobs: ", err)
+			continue
+		}
+		t.dirs[dir] = true
+	}
+}
+
+// watch debounces the events of matching files until the watcher is closed
+func (t *fileTriggers) watch(watcher *fsnotify.Watcher) {
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			// removed and renamed files are gone, permissions do not change the content
+			if event.Op&(fsnotify.Create|fsnotify.Write) == 0 {
+				continue
+			}
+			t.schedule(filepath.Clean(event.Name))
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Error("error watching the files of @onfile jobs: ", err)
+		}
+	}
+}
+
+// schedule (re)starts the debounce of the jobs matching the file
+func (t *fileTriggers) schedule(path string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	for _, r := range t.jobs {
+		if matched, _ := filepath.Match(r.OnFile, path); !matched {
+			continue
+		}
+		key := r.ID + "\x00" + path
+		if timer, ok := t.pending[key]; ok {
+			timer.Stop()
+		}
+		job := r
+		t.pending[key] = time.AfterFunc(r.debounce(), func() {
+			t.lock.Lock()
+			delete(t.pending, key)
+			t.lock.Unlock()
+			job.runOnFile(path)
+		})
+	}
+}
+
+// runOnFile runs the job for a file that was left alone for its debounce, unless it is gone already
+func (r *Runnable) runOnFile(path string) {
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() {
+		return
+	}
+	r.contextLogger.WithField("path", path).Info("running job for file")
+	r.runUnlessPaused(onFileEnv + "=" + path)
+}
@@ -0,0 +1,64 @@
+package crontinuous
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOnFileJob(t *testing.T) {
+	dir, err := ioutil.TempDir("", "crontinuous-onfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	incoming := filepath.Join(dir, "incoming")
+	if err := os.Mkdir(incoming, 0755); err != nil {
+		t.Fatal(err)
+	}
+	done := filepath.Join(dir, "done")
+
+	crontab := "# name: import\n# debounce: 100ms\n@onfile " + incoming + "/*.csv echo \"$CRONTINUOUS_FILE\" >> " + done + "\n" +
+		"@onfile incoming/*.csv true\n" +
+		"@onfile " + dir + "/*/*.csv true\n"
+	jobs, errs := parseCrontabReader("onfile", strings.NewReader(crontab), nil)
+	if len(errs) != 2 {
+		t.Errorf("expected errors for a relative pattern and a pattern of many directories, got %v", errs)
+	}
+	if len(jobs) != 1 || jobs[0].OnFile != incoming+"/*.csv" || jobs[0].schedule != nil {
+		t.Fatalf("unexpected jobs %+v", jobs)
+	}
+
+	triggers := &fileTriggers{}
+	triggers.update(jobs)
+	defer triggers.update(nil)
+	for _, name := range []string{"orders.csv", "notes.txt"} {
+		if err := ioutil.WriteFile(filepath.Join(incoming, name), []byte("1,2\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// further writes within the debounce do not run the job again
+	time.Sleep(20 * time.Millisecond)
+	if err := ioutil.WriteFile(filepath.Join(incoming, "orders.csv"), []byte("1,2\n3,4\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if _, err := os.Stat(done); err == nil {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	time.Sleep(300 * time.Millisecond)
+	runs, err := ioutil.ReadFile(done)
+	if err != nil {
+		t.Fatal("job did not run for the file: ", err)
+	}
+	if want := filepath.Join(incoming, "orders.csv") + "\n"; string(runs) != want {
+		t.Errorf("got runs %q, want %q", runs, want)
+	}
+}
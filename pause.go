@@ -0,0 +1,46 @@
+package crontinuous
+
+import (
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Variables
+// --------------------------------------------------------------------------------------------
+
+var (
+	// pausedJobs are kept by id, so jobs stay paused when the crontab is reloaded, and in the state
+	// directory if there is one, so they stay paused when crontinuous is restarted
+	pausedJobs     = map[string]bool{}
+	pausedJobsLock sync.Mutex
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Private methods
+// --------------------------------------------------------------------------------------------
+
+// isPaused reports whether the scheduled runs of a job are paused
+func isPaused(id string) bool {
+	pausedJobsLock.Lock()
+	defer pausedJobsLock.Unlock()
+	return pausedJobs[id]
+}
+
+// setPaused pauses or resumes a job, resuming ends a suspension by the circuit breaker as well
+func setPaused(id string, paused bool) {
+	pausedJobsLock.Lock()
+	if paused {
+		pausedJobs[id] = true
+	} else {
+		delete(pausedJobs, id)
+	}
+	pausedJobsLock.Unlock()
+	if err := jobsState.setPaused(id, paused); err != nil {
+		log.WithField("id", id).Error("failed to save state", err)
+	}
+	if !paused && closeBreaker(id) {
+		log.WithField("id", id).Info("job resumed, circuit breaker closed")
+	}
+}
@@ -0,0 +1,44 @@
+package crontinuous
+
+import (
+	"fmt"
+	"strings"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Struct
+// --------------------------------------------------------------------------------------------
+
+// pingNotifier pings a dead man's switch url following healthchecks.io conventions:
+// <url>/start when a run starts, <url> on success and <url>/fail on failure
+type pingNotifier struct {
+	url string
+}
+
+// --------------------------------------------------------------------------------------------
+// ~ Public methods
+// --------------------------------------------------------------------------------------------
+
+// Notify implements Notifier
+func (p *pingNotifier) Notify(n *Notification) error {
+	url := strings.TrimRight(p.url, "/")
+	switch n.Event {
+	case EventStart:
+		url += "/start"
+	case EventFailure, EventTimeout, EventMissed:
+		url += "/fail"
+	case EventSlow, EventSuspended:
+		// the run still succeeds or fails, or failed already
+		return nil
+	}
+
+	resp, err := notifyClient.Post(url, "text/plain; charset=utf-8", strings.NewReader(n.Output))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s from %s", resp.Status, url)
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+//go:build !windows
+// +build !windows
+
+package crontinuous
+
+import (
+	"os"
+	"os/exec"
+	"runtime"
+	"syscall"
+	"time"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Constants
+// --------------------------------------------------------------------------------------------
+
+const (
+	defaultCrontab = "/etc/crontab"
+	defaultSocket  = "/var/run/crontinuous.sock"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Private methods
+// --------------------------------------------------------------------------------------------
+
+// systemShell is the shell used when neither -exec nor $SHELL is set
+func systemShell() string {
+	return "/bin/sh"
+}
+
+// shellCommand runs a command line with a shell
+func shellCommand(shell string, commandLine string) *exec.Cmd {
+	return exec.Command(shell, "-c", commandLine)
+}
+
+// prepareProcess starts the process of a job in a process group of its own, as the user of the job if set
+func prepareProcess(cmd *exec.Cmd, as *runAs) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+	if as != nil {
+		cmd.SysProcAttr.Credential = &syscall.Credential{Uid: as.uid, Gid: as.gid, Groups: as.groups}
+	}
+}
+
+// terminateProcessGroup asks a job's process and everything it started to exit with SIGTERM
+func terminateProcessGroup(process *os.Process) error {
+	if err := syscall.Kill(-process.Pid, syscall.SIGTERM); err != nil {
+		return process.Signal(syscall.SIGTERM)
+	}
+	return nil
+}
+
+// killProcessGroup kills a job's process together with everything it started. Jobs are
+// started in their own process group, killing only the shell would leave its children running.
+func killProcessGroup(process *os.Process) error {
+	if err := syscall.Kill(-process.Pid, syscall.SIGKILL); err != nil {
+		// the group may be gone already, the process itself still has to be killed
+		return process.Kill()
+	}
+	return nil
+}
+
+// processUsage returns the user and system cpu time and the max resident set size in bytes of a finished
+// process, which include the children it waited for
+func processUsage(state *os.ProcessState) (time.Duration, time.Duration, int64) {
+	if state == nil {
+		return 0, 0, 0
+	}
+	usage, ok := state.SysUsage().(*syscall.Rusage)
+	if !ok {
+		return state.UserTime(), state.SystemTime(), 0
+	}
+	// linux and the bsds count kilobytes, darwin bytes
+	if runtime.GOOS == "darwin" {
+		return state.UserTime(), state.SystemTime(), int64(usage.Maxrss)
+	}
+	return state.UserTime(), state.SystemTime(), int64(usage.Maxrss) * 1024
+}
+
+// openLockedFile opens a file locked exclusively, errLocked if another process holds the lock
+func openLockedFile(filename string) (*os.File, error) {
+	file, err := os.OpenFile(filename, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		file.Close()
+		if err == syscall.EWOULDBLOCK {
+			return nil, errLocked
+		}
+		return nil, err
+	}
+	return file, nil
+}
+
+// unlockFile removes a locked file before closing it releases the lock, so no other process locks the file removed
+func unlockFile(file *os.File) {
+	os.Remove(file.Name())
+	file.Close()
+}
@@ -0,0 +1,109 @@
+//go:build windows
+// +build windows
+
+package crontinuous
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Variables
+// --------------------------------------------------------------------------------------------
+
+var (
+	defaultCrontab = filepath.Join(programData(), "crontinuous", "crontab")
+	defaultSocket  = filepath.Join(programData(), "crontinuous", "crontinuous.sock")
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Private methods
+// --------------------------------------------------------------------------------------------
+
+func programData() string {
+	if dir := os.Getenv("ProgramData"); dir != "" {
+		return dir
+	}
+	return `C:\ProgramData`
+}
+
+// systemShell is cmd.exe when neither -exec nor $SHELL is set
+func systemShell() string {
+	if shell := os.Getenv("ComSpec"); shell != "" {
+		return shell
+	}
+	return "cmd.exe"
+}
+
+// shellCommand runs a command line with cmd.exe, PowerShell or a shell taking -c like bash
+func shellCommand(shell string, commandLine string) *exec.Cmd {
+	switch strings.TrimSuffix(strings.ToLower(filepath.Base(shell)), ".exe") {
+	case "cmd":
+		// cmd.exe does not follow the quoting rules of other programs, the command line is passed as it is
+		cmd := exec.Command(shell)
+		cmd.SysProcAttr = &syscall.SysProcAttr{CmdLine: syscall.EscapeArg(shell) + ` /S /C "` + commandLine + `"`}
+		return cmd
+	case "powershell", "pwsh":
+		return exec.Command(shell, "-NoProfile", "-NonInteractive", "-Command", commandLine)
+	default:
+		return exec.Command(shell, "-c", commandLine)
+	}
+}
+
+// prepareProcess starts the process of a job in a process group of its own, jobs always run as the user of crontinuous
+func prepareProcess(cmd *exec.Cmd, as *runAs) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.CreationFlags |= syscall.CREATE_NEW_PROCESS_GROUP
+}
+
+// terminateProcessGroup asks a job's process and its child processes to close, there are no signals to send
+func terminateProcessGroup(process *os.Process) error {
+	return exec.Command("taskkill", "/T", "/PID", strconv.Itoa(process.Pid)).Run()
+}
+
+// killProcessGroup kills a job's process together with its child processes, there are no process groups to signal
+func killProcessGroup(process *os.Process) error {
+	if err := exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(process.Pid)).Run(); err != nil {
+		return process.Kill()
+	}
+	return nil
+}
+
+// processUsage returns the user and system cpu time of a finished process, windows does not tell its max resident set size
+func processUsage(state *os.ProcessState) (time.Duration, time.Duration, int64) {
+	if state == nil {
+		return 0, 0, 0
+	}
+	return state.UserTime(), state.SystemTime(), 0
+}
+
+// openLockedFile opens a file no other process may open, errLocked if another process has it open
+func openLockedFile(filename string) (*os.File, error) {
+	name, err := syscall.UTF16PtrFromString(filename)
+	if err != nil {
+		return nil, err
+	}
+	handle, err := syscall.CreateFile(name, syscall.GENERIC_READ|syscall.GENERIC_WRITE, 0, nil, syscall.OPEN_ALWAYS, syscall.FILE_ATTRIBUTE_NORMAL, 0)
+	if err != nil {
+		// ERROR_SHARING_VIOLATION
+		if err == syscall.Errno(32) {
+			return nil, errLocked
+		}
+		return nil, err
+	}
+	return os.NewFile(uintptr(handle), filename), nil
+}
+
+// unlockFile closes a locked file and removes it, which is not possible while it is open
+func unlockFile(file *os.File) {
+	file.Close()
+	os.Remove(file.Name())
+}
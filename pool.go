@@ -0,0 +1,104 @@
+package crontinuous
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Constants
+// --------------------------------------------------------------------------------------------
+
+// OverflowPolicy decides what happens to a due job when all worker slots are taken
+type OverflowPolicy string
+
+const (
+	// OverflowQueue waits for a free slot, first in first out
+	OverflowQueue OverflowPolicy = "queue"
+	// OverflowSkip drops the run
+	OverflowSkip OverflowPolicy = "skip"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Struct
+// --------------------------------------------------------------------------------------------
+
+// workerPool limits the number of job processes running at the same time, of all jobs or of the jobs with a tag
+type workerPool struct {
+	slots    chan struct{}
+	overflow OverflowPolicy
+	tag      string
+	// queued counts the runs waiting for a slot, up to -queue-size
+	queued     int
+	queuedLock sync.Mutex
+}
+
+func newWorkerPool(size int, overflow OverflowPolicy) *workerPool {
+	if size <= 0 {
+		return nil
+	}
+	return &workerPool{
+		slots:    make(chan struct{}, size),
+		overflow: overflow,
+	}
+}
+
+// --------------------------------------------------------------------------------------------
+// ~ Private methods
+// --------------------------------------------------------------------------------------------
+
+func parseOverflowPolicy(value string) (OverflowPolicy, error) {
+	switch policy := OverflowPolicy(strings.ToLower(strings.TrimSpace(value))); policy {
+	case OverflowQueue, OverflowSkip:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unknown overflow policy %q, expected queue or skip", value)
+	}
+}
+
+// acquire takes a worker slot and reports whether the run may start,
+// a nil pool does not limit anything
+func (p *workerPool) acquire(e *execution) bool {
+	if p == nil {
+		return true
+	}
+	select {
+	case p.slots <- struct{}{}:
+		return true
+	default:
+	}
+	logger := e.logger
+	if p.tag != "" {
+		logger = logger.WithField("tag", p.tag)
+	}
+	if p.overflow == OverflowSkip {
+		logger.Warn("max concurrent jobs reached, skipping run")
+		return false
+	}
+	p.queuedLock.Lock()
+	if *queueSize > 0 && p.queued >= *queueSize {
+		p.queuedLock.Unlock()
+		logger.WithField("queued", *queueSize).Warn("max concurrent jobs reached and queue full, skipping run")
+		return false
+	}
+	p.queued++
+	queued := p.queued
+	p.queuedLock.Unlock()
+	logger.WithField("queued", queued-1).Info("max concurrent jobs reached, queueing run")
+	// blocked senders of a channel are woken up in order, so queued runs start first in first out
+	p.slots <- struct{}{}
+	p.queuedLock.Lock()
+	p.queued--
+	p.queuedLock.Unlock()
+	e.queued = true
+	return true
+}
+
+// release frees a worker slot
+func (p *workerPool) release() {
+	if p == nil {
+		return
+	}
+	<-p.slots
+}
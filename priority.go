@@ -0,0 +1,90 @@
+package crontinuous
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Constants
+// --------------------------------------------------------------------------------------------
+
+// io scheduling classes as known from ionice
+const (
+	ioClassRealtime   = 1
+	ioClassBestEffort = 2
+	ioClassIdle       = 3
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Struct
+// --------------------------------------------------------------------------------------------
+
+// processPriority is the cpu and io priority runs of a job are started with
+type processPriority struct {
+	// Nice is the niceness from -20 to 19, nil keeps the one of crontinuous
+	Nice *int
+	// IOClass is the io scheduling class, 0 keeps the one of crontinuous
+	IOClass int
+	// IOLevel is the priority from 0 to 7 within the realtime and best-effort classes
+	IOLevel int
+}
+
+// --------------------------------------------------------------------------------------------
+// ~ Private methods
+// --------------------------------------------------------------------------------------------
+
+// parseNice parses a niceness like "10"
+func parseNice(value string) (int, error) {
+	nice, err := strconv.Atoi(value)
+	if err != nil || nice < -20 || nice > 19 {
+		return 0, fmt.Errorf("invalid nice %q, expected a number from -20 to 19", value)
+	}
+	return nice, nil
+}
+
+// parseIONice parses an io priority like "idle", "best-effort" or "best-effort:7"
+func parseIONice(value string) (class int, level int, err error) {
+	parts := strings.SplitN(value, ":", 2)
+	switch parts[0] {
+	case "idle":
+		if len(parts) == 2 {
+			return 0, 0, fmt.Errorf("invalid ionice %q, the idle class has no levels", value)
+		}
+		return ioClassIdle, 0, nil
+	case "best-effort":
+		class = ioClassBestEffort
+	case "realtime":
+		class = ioClassRealtime
+	default:
+		return 0, 0, fmt.Errorf("invalid ionice %q, expected idle, best-effort[:<level>] or realtime[:<level>]", value)
+	}
+	// level 4 is the default of the kernel
+	level = 4
+	if len(parts) == 2 {
+		level, err = strconv.Atoi(parts[1])
+		if err != nil || level < 0 || level > 7 {
+			return 0, 0, fmt.Errorf("invalid ionice level %q, expected a number from 0 to 7", parts[1])
+		}
+	}
+	return class, level, nil
+}
+
+func (r *Runnable) priority() *processPriority {
+	if r.Priority == nil {
+		r.Priority = &processPriority{}
+	}
+	return r.Priority
+}
+
+func (p *processPriority) String() string {
+	parts := []string{}
+	if p.Nice != nil {
+		parts = append(parts, "nice="+strconv.Itoa(*p.Nice))
+	}
+	if p.IOClass != 0 {
+		parts = append(parts, fmt.Sprintf("ionice=%d:%d", p.IOClass, p.IOLevel))
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,60 @@
+//go:build linux
+// +build linux
+
+package crontinuous
+
+import (
+	"os/exec"
+	"runtime"
+	"syscall"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Constants
+// --------------------------------------------------------------------------------------------
+
+const (
+	ioprioWhoProcess = 1
+	ioprioClassShift = 13
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Private methods
+// --------------------------------------------------------------------------------------------
+
+// start starts the command with the job's priority. Niceness and io priority are per thread
+// on linux and inherited by children, so they are set on a thread of its own the command is
+// forked from, without a window in which the command runs with the priority of crontinuous.
+func (r *Runnable) start(cmd *exec.Cmd) error {
+	if r.Priority == nil {
+		return cmd.Start()
+	}
+	started := make(chan error, 1)
+	go func() {
+		// the thread is never unlocked, so it exits with the goroutine instead of
+		// running other goroutines with the changed priority
+		runtime.LockOSThread()
+		if err := r.Priority.apply(); err != nil {
+			started <- err
+			return
+		}
+		started <- cmd.Start()
+	}()
+	return <-started
+}
+
+// apply sets the priority of the calling thread
+func (p *processPriority) apply() error {
+	if p.Nice != nil {
+		if err := syscall.Setpriority(syscall.PRIO_PROCESS, 0, *p.Nice); err != nil {
+			return err
+		}
+	}
+	if p.IOClass != 0 {
+		prio := uintptr(p.IOClass<<ioprioClassShift | p.IOLevel)
+		if _, _, errno := syscall.Syscall(syscall.SYS_IOPRIO_SET, ioprioWhoProcess, 0, prio); errno != 0 {
+			return errno
+		}
+	}
+	return nil
+}
@@ -0,0 +1,20 @@
+//go:build !linux
+// +build !linux
+
+package crontinuous
+
+import (
+	"errors"
+	"os/exec"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Private methods
+// --------------------------------------------------------------------------------------------
+
+func (r *Runnable) start(cmd *exec.Cmd) error {
+	if r.Priority != nil {
+		return errors.New("nice and ionice are only supported on linux")
+	}
+	return cmd.Start()
+}
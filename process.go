@@ -0,0 +1,42 @@
+package crontinuous
+
+import (
+	"os"
+	"sync"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Variables
+// --------------------------------------------------------------------------------------------
+
+var (
+	// runningProcesses are the processes of all running jobs, to kill them on shutdown
+	runningProcesses     = map[*os.Process]struct{}{}
+	runningProcessesLock sync.Mutex
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Private methods
+// --------------------------------------------------------------------------------------------
+
+// trackProcess remembers the process of a running job until untrackProcess is called
+func trackProcess(process *os.Process) {
+	runningProcessesLock.Lock()
+	defer runningProcessesLock.Unlock()
+	runningProcesses[process] = struct{}{}
+}
+
+func untrackProcess(process *os.Process) {
+	runningProcessesLock.Lock()
+	defer runningProcessesLock.Unlock()
+	delete(runningProcesses, process)
+}
+
+// killRunningProcesses kills the process groups of all running jobs
+func killRunningProcesses() {
+	runningProcessesLock.Lock()
+	defer runningProcessesLock.Unlock()
+	for process := range runningProcesses {
+		killProcessGroup(process)
+	}
+}
@@ -0,0 +1,296 @@
+package crontinuous
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Constants
+// --------------------------------------------------------------------------------------------
+
+// publishTimeout bounds publishing a message of a job without timeout
+const publishTimeout = 30 * time.Second
+
+// --------------------------------------------------------------------------------------------
+// ~ Struct
+// --------------------------------------------------------------------------------------------
+
+// publishExecutor runs jobs by publishing their payload to a message broker
+type publishExecutor struct{}
+
+// publishFunc publishes a message to the subject, topic or exchange of the url
+type publishFunc func(ctx context.Context, u *url.URL, payload []byte) error
+
+// --------------------------------------------------------------------------------------------
+// ~ Variables
+// --------------------------------------------------------------------------------------------
+
+// publishers by the scheme of the url of the publish annotation
+var publishers = map[string]publishFunc{
+	"nats":           publishNATS,
+	"kafka+http":     publishKafka,
+	"kafka+https":    publishKafka,
+	"rabbitmq+http":  publishRabbitMQ,
+	"rabbitmq+https": publishRabbitMQ,
+}
+
+// --------------------------------------------------------------------------------------------
+// ~ Public methods
+// --------------------------------------------------------------------------------------------
+
+// Execute implements Executor, the payload is a template of the job
+func (publishExecutor) Execute(a *Attempt) {
+	r := a.Job
+	payload, err := r.expandTemplate(r.Payload, a.Result)
+	if err != nil {
+		a.fail(fmt.Errorf("failed to expand the payload template: %s", err))
+		return
+	}
+	payload = a.expandVariables(payload)
+	u, err := url.Parse(a.expandVariables(a.Args))
+	if err != nil {
+		a.fail(errors.New(a.secrets.mask(err.Error())))
+		return
+	}
+	publish, ok := publishers[u.Scheme]
+	if !ok {
+		a.fail(fmt.Errorf("unknown broker %q", u.Scheme))
+		return
+	}
+
+	timeout := r.Timeout
+	if timeout <= 0 {
+		timeout = publishTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	go func() {
+		select {
+		case <-a.Aborted():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	a.Started()
+	start := time.Now()
+	if err := publish(ctx, u, []byte(payload)); err != nil {
+		a.fail(errors.New(a.secrets.mask(err.Error())))
+		if ctx.Err() == context.DeadlineExceeded {
+			a.Result.Event = EventTimeout
+		}
+		return
+	}
+	a.Logger.WithFields(log.Fields{
+		"broker":  redactURL(a.Args),
+		"bytes":   len(payload),
+		"latency": time.Since(start).Seconds(),
+	}).Info("message published")
+	a.Finish(0, nil)
+}
+
+// --------------------------------------------------------------------------------------------
+// ~ Private methods
+// --------------------------------------------------------------------------------------------
+
+// splitPublishURL returns the broker of the url of a publish annotation, like nats for
+// nats://127.0.0.1:4222/ticks.hourly. The url is parsed when publishing, as it may contain
+// ${NAME} variables.
+func splitPublishURL(value string) (string, error) {
+	i := strings.Index(value, "://")
+	if i < 0 {
+		return "", fmt.Errorf("invalid broker url %q", value)
+	}
+	scheme := value[:i]
+	if _, ok := publishers[scheme]; !ok {
+		return "", fmt.Errorf("unknown broker %q, use nats://, kafka+http(s):// or rabbitmq+http(s)://", scheme)
+	}
+	if strings.Trim(value[i+3:], "/") == "" || !strings.Contains(value[i+3:], "/") {
+		return "", fmt.Errorf("broker url %q names no subject, topic or exchange", redactURL(value))
+	}
+	return strings.SplitN(scheme, "+", 2)[0], nil
+}
+
+// publishNATS publishes to the subject of nats://[user:password@]host:port/<subject>, a url with
+// a user only passes it as token. The server confirms the message with the PONG to a PING.
+func publishNATS(ctx context.Context, u *url.URL, payload []byte) error {
+	subject := strings.Trim(u.Path, "/")
+	if subject == "" || strings.ContainsAny(subject, " \t\r\n") {
+		return fmt.Errorf("invalid nats subject %q", subject)
+	}
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", u.Host)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	go func() {
+		<-ctx.Done()
+		conn.SetDeadline(time.Now())
+	}()
+	reader := bufio.NewReader(conn)
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(line, "INFO ") {
+		return fmt.Errorf("unexpected greeting from nats: %s", strings.TrimSpace(line))
+	}
+
+	options := map[string]interface{}{"verbose": false, "pedantic": false, "name": "crontinuous"}
+	if u.User != nil {
+		if password, ok := u.User.Password(); ok {
+			options["user"] = u.User.Username()
+			options["pass"] = password
+		} else {
+			options["auth_token"] = u.User.Username()
+		}
+	}
+	connect, err := json.Marshal(options)
+	if err != nil {
+		return err
+	}
+	message := &bytes.Buffer{}
+	fmt.Fprintf(message, "CONNECT %s\r\nPUB %s %d\r\n", connect, subject, len(payload))
+	message.Write(payload)
+	message.WriteString("\r\nPING\r\n")
+	if _, err := conn.Write(message.Bytes()); err != nil {
+		return err
+	}
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "PONG":
+			return nil
+		case line == "PING":
+			if _, err := conn.Write([]byte("PONG\r\n")); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			return fmt.Errorf("nats: %s", strings.Trim(strings.TrimSpace(strings.TrimPrefix(line, "-ERR")), "'"))
+		}
+	}
+}
+
+// publishKafka produces to the topic of kafka+http(s)://host:port/<topic> through a kafka rest proxy
+func publishKafka(ctx context.Context, u *url.URL, payload []byte) error {
+	topic := strings.Trim(u.Path, "/")
+	body, err := json.Marshal(map[string]interface{}{
+		"records": []map[string]string{{"value": base64.StdEncoding.EncodeToString(payload)}},
+	})
+	if err != nil {
+		return err
+	}
+	endpoint := brokerEndpoint(u, "/topics/"+url.PathEscape(topic))
+	req, err := http.NewRequest("POST", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/vnd.kafka.binary.v2+json")
+	req.Header.Set("Accept", "application/vnd.kafka.v2+json")
+	resp, err := httpJobClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	result := struct {
+		Offsets []struct {
+			Error *string `json:"error"`
+		} `json:"offsets"`
+		Message string `json:"message"`
+	}{}
+	json.NewDecoder(io.LimitReader(resp.Body, maxHTTPResponse)).Decode(&result)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s from the kafka rest proxy %s", resp.Status, result.Message)
+	}
+	if len(result.Offsets) == 0 {
+		return errors.New("the kafka rest proxy did not confirm the message")
+	}
+	if result.Offsets[0].Error != nil {
+		return fmt.Errorf("kafka: %s", *result.Offsets[0].Error)
+	}
+	return nil
+}
+
+// publishRabbitMQ publishes to rabbitmq+http(s)://user:password@host:port/<exchange>/<routing key>
+// through the management api, the virtual host is set with ?vhost=, / by default. The default
+// exchange is amq.default. A message that is not routed to any queue fails. The management api
+// publishes without publisher confirms, so a routed message may still be lost, e.g. if the broker
+// goes down before a queue stored it; AMQP would need a client library crontinuous does without.
+func publishRabbitMQ(ctx context.Context, u *url.URL, payload []byte) error {
+	parts := strings.SplitN(strings.Trim(u.Path, "/"), "/", 2)
+	exchange, routingKey := parts[0], ""
+	if len(parts) == 2 {
+		routingKey = parts[1]
+	}
+	vhost := u.Query().Get("vhost")
+	if vhost == "" {
+		vhost = "/"
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"properties":       map[string]interface{}{},
+		"routing_key":      routingKey,
+		"payload":          string(payload),
+		"payload_encoding": "string",
+	})
+	if err != nil {
+		return err
+	}
+	endpoint := brokerEndpoint(u, "/api/exchanges/"+url.PathEscape(vhost)+"/"+url.PathEscape(exchange)+"/publish")
+	req, err := http.NewRequest("POST", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if u.User != nil {
+		password, _ := u.User.Password()
+		req.SetBasicAuth(u.User.Username(), password)
+	}
+	resp, err := httpJobClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	result := struct {
+		Routed bool   `json:"routed"`
+		Reason string `json:"reason"`
+	}{}
+	decodeErr := json.NewDecoder(io.LimitReader(resp.Body, maxHTTPResponse)).Decode(&result)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s from rabbitmq %s", resp.Status, result.Reason)
+	}
+	// anything else than the answer of the management api, e.g. of a proxy, does not tell whether the message was routed
+	if decodeErr != nil {
+		return fmt.Errorf("unexpected response from rabbitmq: %s", decodeErr)
+	}
+	if !result.Routed {
+		return fmt.Errorf("message to exchange %s with routing key %q was not routed to a queue", exchange, routingKey)
+	}
+	return nil
+}
+
+// brokerEndpoint returns the http url of a path of a broker api, without the credentials and query of the url
+func brokerEndpoint(u *url.URL, path string) string {
+	scheme := strings.TrimPrefix(u.Scheme, strings.SplitN(u.Scheme, "+", 2)[0]+"+")
+	return scheme + "://" + u.Host + path
+}
@@ -0,0 +1,115 @@
+package crontinuous
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// fakeNATS accepts a single connection and returns the lines a client sent until its PING
+func fakeNATS(t *testing.T) (string, chan []string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	received := make(chan []string, 1)
+	go func() {
+		defer listener.Close()
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		io.WriteString(conn, "INFO {\"server_id\":\"test\"}\r\n")
+		lines := []string{}
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			if scanner.Text() == "PING" {
+				io.WriteString(conn, "PONG\r\n")
+				break
+			}
+			lines = append(lines, scanner.Text())
+		}
+		received <- lines
+	}()
+	return listener.Addr().String(), received
+}
+
+func TestPublishJob(t *testing.T) {
+	addr, received := fakeNATS(t)
+	rabbit := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		user, password, _ := req.BasicAuth()
+		message := map[string]interface{}{}
+		json.NewDecoder(req.Body).Decode(&message)
+		routed := req.URL.EscapedPath() == "/api/exchanges/%2F/ticks/publish" && user == "guest" && password == "s3cret" && message["routing_key"] == "hourly"
+		json.NewEncoder(w).Encode(map[string]bool{"routed": routed})
+	}))
+	defer rabbit.Close()
+
+	crontab := "RABBIT_PASSWORD=s3cret\n" +
+		"# name: tick\n# publish: nats://" + addr + "/ticks.hourly\n# payload: {\"job\":\"{{.JobID}}\"}\n@hourly\n\n" +
+		"# name: rabbit\n# publish: rabbitmq+" + strings.Replace(rabbit.URL, "://", "://guest:${RABBIT_PASSWORD}@", 1) + "/ticks/hourly\n@hourly\n\n" +
+		"# name: unrouted\n# publish: rabbitmq+" + rabbit.URL + "/ticks/daily\n@hourly\n\n" +
+		"# publish: sqs://queue\n@hourly\n"
+	jobs, errs := parseCrontabReader("publish", strings.NewReader(crontab), nil)
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "unknown broker") {
+		t.Errorf("expected an unknown broker error, got %v", errs)
+	}
+	if len(jobs) != 3 {
+		t.Fatalf("expected 3 jobs, got %d", len(jobs))
+	}
+
+	tick := jobs[0]
+	if tick.Command != "nats" || tick.executorName() != "publish" {
+		t.Fatalf("unexpected job %+v", tick)
+	}
+	result := tick.execute(newExecution(tick.contextLogger, ""), 1)
+	if result.Event != EventSuccess {
+		t.Errorf("expected a successful run, got %s %v", result.Event, result.Err)
+	}
+	lines := <-received
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "CONNECT ") || lines[1] != "PUB ticks.hourly 14" || lines[2] != `{"job":"tick"}` {
+		t.Errorf("unexpected nats protocol %q", lines)
+	}
+
+	for _, r := range jobs[1:] {
+		result := r.execute(newExecution(r.contextLogger, ""), 1)
+		if routed := result.Event == EventSuccess; routed != (r.ID == "rabbit") {
+			t.Errorf("%s: unexpected outcome %s %v", r.ID, result.Event, result.Err)
+		}
+	}
+}
+
+func TestPublishRabbitMQResponse(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		wantErr string
+	}{
+		{"routed", http.StatusOK, `{"routed":true}`, ""},
+		{"not routed", http.StatusOK, `{"routed":false}`, "was not routed"},
+		{"no routed field", http.StatusOK, `{}`, "was not routed"},
+		{"not json", http.StatusOK, "<html>ok</html>", "unexpected response"},
+		{"unknown exchange", http.StatusNotFound, `{"error":"not_found","reason":"no exchange 'ticks'"}`, "404"},
+	}
+	for _, test := range tests {
+		rabbit := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.WriteHeader(test.status)
+			w.Write([]byte(test.body))
+		}))
+		u, _ := url.Parse("rabbitmq+" + rabbit.URL + "/ticks/hourly")
+		err := publishRabbitMQ(context.Background(), u, []byte("tick"))
+		rabbit.Close()
+		if test.wantErr == "" && err != nil || test.wantErr != "" && (err == nil || !strings.Contains(err.Error(), test.wantErr)) {
+			t.Errorf("%s: expected error %q, got %v", test.name, test.wantErr, err)
+		}
+	}
+}
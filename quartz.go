@@ -0,0 +1,423 @@
+package crontinuous
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Constants
+// --------------------------------------------------------------------------------------------
+
+const (
+	minYear = 1970
+	maxYear = 2199
+	// yearsAhead is how far schedules without years are searched for their next run, like robfig/cron does
+	yearsAhead = 5
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Struct
+// --------------------------------------------------------------------------------------------
+
+// quartzSchedule is a schedule with Quartz style specials in its day fields and an optional year field,
+// which robfig/cron does not support:
+//
+//	L       last day of the month, L-3 three days before it, LW the last weekday of the month
+//	15W     the weekday nearest to the 15th within its month
+//	5L      the last Friday of the month
+//	5#3     the third Friday of the month
+type quartzSchedule struct {
+	seconds, minutes, hours, months []bool
+	// days of month and days of week, * and ? match all of them
+	days, weekdays       []bool
+	anyDay, anyWeekday   bool
+	lastDays             []int
+	lastWeekdayOfMonth   bool
+	nearestWeekdays      []int
+	lastWeekdays         []time.Weekday
+	nthWeekdays          map[time.Weekday][]int
+	years                []bool
+	firstYear, finalYear int
+}
+
+// --------------------------------------------------------------------------------------------
+// ~ Variables
+// --------------------------------------------------------------------------------------------
+
+var (
+	monthNames   = map[string]int{"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6, "jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12}
+	weekdayNames = map[string]int{"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6}
+	// scheduleNameRegexp matches the names of months and weekdays, which may contain the letters of specials
+	scheduleNameRegexp = regexp.MustCompile(`(?i)jan|feb|mar|apr|may|jun|jul|aug|sep|oct|nov|dec|sun|mon|tue|wed|thu|fri|sat`)
+	// yearFieldRegexp matches a year field following the day of week field, e.g. 2027, 2025-2027 or 2025/2
+	yearFieldRegexp = regexp.MustCompile(`^(19|2[01])[0-9]{2}(-(19|2[01])[0-9]{2})?(/[0-9]+)?(,(19|2[01])[0-9]{2}(-(19|2[01])[0-9]{2})?(/[0-9]+)?)*$`)
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Public methods
+// --------------------------------------------------------------------------------------------
+
+// Next implements cron.Schedule, returning the zero time if there is no run within five years or the years of the schedule
+func (s *quartzSchedule) Next(t time.Time) time.Time {
+	location := t.Location()
+	start := t.Truncate(time.Second).Add(time.Second)
+	// days are walked in utc to stay clear of daylight saving time
+	date := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+	finalYear := start.Year() + yearsAhead
+	if s.years != nil {
+		finalYear = s.finalYear
+	}
+	for date.Year() <= finalYear {
+		if s.years != nil && (date.Year() < s.firstYear || !s.years[date.Year()-minYear]) {
+			date = time.Date(date.Year()+1, 1, 1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if !s.months[date.Month()] {
+			date = time.Date(date.Year(), date.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if s.matchesDay(date) {
+			if next := s.nextInDay(date, start, location); !next.IsZero() {
+				return next
+			}
+		}
+		date = date.AddDate(0, 0, 1)
+	}
+	return time.Time{}
+}
+
+// --------------------------------------------------------------------------------------------
+// ~ Private methods
+// --------------------------------------------------------------------------------------------
+
+// usesQuartzSyntax reports whether a schedule needs a quartzSchedule, schedules with specials anywhere
+// else are parsed by it as well to report them clearly
+func usesQuartzSyntax(fields []string) bool {
+	if len(fields) == 7 {
+		return true
+	}
+	for _, field := range fields {
+		if strings.ContainsAny(scheduleNameRegexp.ReplaceAllString(field, ""), "LlWw#") {
+			return true
+		}
+	}
+	return false
+}
+
+// withSecondsField prepends a seconds field to the time fields of a schedule without, as both
+// robfig/cron and quartzSchedule expect one
+func withSecondsField(fields []string) string {
+	if n := len(fields); n == 5 || n == 6 && yearFieldRegexp.MatchString(fields[5]) {
+		return "0 " + strings.Join(fields, " ")
+	}
+	return strings.Join(fields, " ")
+}
+
+// parseQuartzSchedule parses the fields second, minute, hour, day of month, month, day of week and optionally year
+func parseQuartzSchedule(fields []string) (*quartzSchedule, error) {
+	if len(fields) != 6 && len(fields) != 7 {
+		return nil, fmt.Errorf("expected 6 or 7 fields, found %d", len(fields))
+	}
+	s := &quartzSchedule{nthWeekdays: map[time.Weekday][]int{}}
+	var err error
+	for _, field := range []struct {
+		name     string
+		value    string
+		min, max int
+		names    map[string]int
+		values   *[]bool
+	}{
+		{"second", fields[0], 0, 59, nil, &s.seconds},
+		{"minute", fields[1], 0, 59, nil, &s.minutes},
+		{"hour", fields[2], 0, 23, nil, &s.hours},
+		{"month", fields[4], 1, 12, monthNames, &s.months},
+	} {
+		if *field.values, err = parseScheduleValues(field.value, field.min, field.max, field.names); err != nil {
+			return nil, fieldError(field.name, field.value, err)
+		}
+	}
+	if err := s.parseDays(fields[3]); err != nil {
+		return nil, fieldError("day of month", fields[3], err)
+	}
+	if err := s.parseWeekdays(fields[5]); err != nil {
+		return nil, fieldError("day of week", fields[5], err)
+	}
+	if len(fields) == 7 && fields[6] != "*" && fields[6] != "?" {
+		years, err := parseScheduleValues(fields[6], minYear, maxYear, nil)
+		if err != nil {
+			return nil, fieldError("year", fields[6], err)
+		}
+		s.years = years[minYear:]
+		for i, ok := range s.years {
+			if ok {
+				if s.firstYear == 0 {
+					s.firstYear = minYear + i
+				}
+				s.finalYear = minYear + i
+			}
+		}
+	}
+	return s, nil
+}
+
+// parseDays parses the day of month field, which may contain L, L-n, LW and nW
+func (s *quartzSchedule) parseDays(field string) error {
+	s.days = make([]bool, 32)
+	for _, item := range strings.Split(strings.ToUpper(field), ",") {
+		switch {
+		case item == "*" || item == "?":
+			s.anyDay = true
+		case item == "L":
+			s.lastDays = append(s.lastDays, 0)
+		case item == "LW":
+			s.lastWeekdayOfMonth = true
+		case strings.HasPrefix(item, "L-"):
+			offset, err := strconv.Atoi(item[2:])
+			if err != nil || offset < 1 || offset > 30 {
+				return fmt.Errorf("%s needs an offset of 1 to 30 days", item)
+			}
+			s.lastDays = append(s.lastDays, offset)
+		case strings.HasSuffix(item, "W"):
+			day, err := strconv.Atoi(strings.TrimSuffix(item, "W"))
+			if err != nil || day < 1 || day > 31 {
+				return fmt.Errorf("W takes a single day of 1 to 31, e.g. 15W, got %s", item)
+			}
+			s.nearestWeekdays = append(s.nearestWeekdays, day)
+		case strings.Contains(item, "#"):
+			return errors.New("# is only supported in the day of week field")
+		case strings.Contains(item, "L"):
+			return fmt.Errorf("L stands alone in the day of month field, e.g. L, L-3 or LW, got %s", item)
+		default:
+			values, err := parseScheduleValues(item, 1, 31, nil)
+			if err != nil {
+				return err
+			}
+			for day, ok := range values {
+				s.days[day] = s.days[day] || ok
+			}
+		}
+	}
+	if s.anyDay {
+		for day := range s.days {
+			s.days[day] = true
+		}
+	}
+	return nil
+}
+
+// parseWeekdays parses the day of week field, which may contain nL and n#k
+func (s *quartzSchedule) parseWeekdays(field string) error {
+	s.weekdays = make([]bool, 7)
+	for _, item := range strings.Split(strings.ToLower(field), ",") {
+		switch {
+		case item == "*" || item == "?":
+			s.anyWeekday = true
+		case item == "l":
+			return errors.New("L needs a weekday, e.g. 5L for the last Friday of the month")
+		case strings.Contains(item, "#"):
+			parts := strings.SplitN(item, "#", 2)
+			weekday, err := parseWeekday(parts[0])
+			if err != nil {
+				return err
+			}
+			n, err := strconv.Atoi(parts[1])
+			if err != nil || n < 1 || n > 5 {
+				return fmt.Errorf("# needs a week of 1 to 5, e.g. 5#3 for the third Friday of the month, got %s", item)
+			}
+			s.nthWeekdays[weekday] = append(s.nthWeekdays[weekday], n)
+		case strings.HasSuffix(item, "l"):
+			weekday, err := parseWeekday(strings.TrimSuffix(item, "l"))
+			if err != nil {
+				return err
+			}
+			s.lastWeekdays = append(s.lastWeekdays, weekday)
+		case strings.HasSuffix(item, "w") && !scheduleNameRegexp.MatchString(item):
+			return errors.New("W is only supported in the day of month field")
+		default:
+			values, err := parseScheduleValues(item, 0, 7, weekdayNames)
+			if err != nil {
+				return err
+			}
+			for weekday, ok := range values {
+				// 7 is Sunday as well
+				s.weekdays[weekday%7] = s.weekdays[weekday%7] || ok
+			}
+		}
+	}
+	if s.anyWeekday {
+		for weekday := range s.weekdays {
+			s.weekdays[weekday] = true
+		}
+	}
+	return nil
+}
+
+// matchesDay reports whether the schedule runs on a day. Like in cron a day matches either of
+// the day fields if both are restricted, both of them otherwise.
+func (s *quartzSchedule) matchesDay(date time.Time) bool {
+	day := s.matchesDayOfMonth(date)
+	weekday := s.matchesDayOfWeek(date)
+	if s.anyDay || s.anyWeekday {
+		return day && weekday
+	}
+	return day || weekday
+}
+
+func (s *quartzSchedule) matchesDayOfMonth(date time.Time) bool {
+	day, last := date.Day(), daysInMonth(date)
+	if s.days[day] {
+		return true
+	}
+	for _, offset := range s.lastDays {
+		if day == last-offset {
+			return true
+		}
+	}
+	if s.lastWeekdayOfMonth && day == nearestWeekday(date, last) {
+		return true
+	}
+	for _, nearest := range s.nearestWeekdays {
+		if nearest <= last && day == nearestWeekday(date, nearest) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *quartzSchedule) matchesDayOfWeek(date time.Time) bool {
+	weekday := date.Weekday()
+	if s.weekdays[weekday] {
+		return true
+	}
+	for _, last := range s.lastWeekdays {
+		if weekday == last && date.Day()+7 > daysInMonth(date) {
+			return true
+		}
+	}
+	for _, n := range s.nthWeekdays[weekday] {
+		if (date.Day()-1)/7+1 == n {
+			return true
+		}
+	}
+	return false
+}
+
+// nextInDay returns the first time of the schedule on a day not before start, skipping times
+// left out by daylight saving time
+func (s *quartzSchedule) nextInDay(date time.Time, start time.Time, location *time.Location) time.Time {
+	sameDay := date.Year() == start.Year() && date.YearDay() == start.YearDay()
+	for hour, ok := range s.hours {
+		if !ok || sameDay && hour < start.Hour() {
+			continue
+		}
+		for minute, ok := range s.minutes {
+			if !ok || sameDay && hour == start.Hour() && minute < start.Minute() {
+				continue
+			}
+			for second, ok := range s.seconds {
+				if !ok {
+					continue
+				}
+				next := time.Date(date.Year(), date.Month(), date.Day(), hour, minute, second, 0, location)
+				if next.Hour() != hour || next.Minute() != minute {
+					break
+				}
+				if !next.Before(start) {
+					return next
+				}
+			}
+		}
+	}
+	return time.Time{}
+}
+
+// parseScheduleValues parses a comma separated list of values, ranges and steps like */5 or 1-10/2,
+// returning which of the values up to max are included
+func parseScheduleValues(field string, min int, max int, names map[string]int) ([]bool, error) {
+	values := make([]bool, max+1)
+	for _, item := range strings.Split(strings.ToLower(field), ",") {
+		rangeValue, step := item, 1
+		if i := strings.Index(item, "/"); i >= 0 {
+			var err error
+			if step, err = strconv.Atoi(item[i+1:]); err != nil || step < 1 {
+				return nil, fmt.Errorf("invalid step in %q", item)
+			}
+			rangeValue = item[:i]
+		}
+		from, to := min, max
+		if rangeValue != "*" && rangeValue != "?" {
+			bounds := strings.SplitN(rangeValue, "-", 2)
+			var err error
+			if from, err = parseScheduleValue(bounds[0], min, max, names); err != nil {
+				return nil, err
+			}
+			to = from
+			if len(bounds) == 2 {
+				if to, err = parseScheduleValue(bounds[1], min, max, names); err != nil {
+					return nil, err
+				}
+			} else if step > 1 {
+				to = max
+			}
+			if to < from {
+				return nil, fmt.Errorf("range %q ends before it starts", item)
+			}
+		}
+		for value := from; value <= to; value += step {
+			values[value] = true
+		}
+	}
+	return values, nil
+}
+
+func parseScheduleValue(value string, min int, max int, names map[string]int) (int, error) {
+	if n, ok := names[value]; ok {
+		return n, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		if strings.ContainsAny(strings.ToUpper(value), "LW#") {
+			return 0, errors.New("L, W and # are only supported in the day of month and day of week fields")
+		}
+		return 0, fmt.Errorf("invalid value %q", value)
+	}
+	if n < min || n > max {
+		return 0, fmt.Errorf("%d is out of the range %d-%d", n, min, max)
+	}
+	return n, nil
+}
+
+func parseWeekday(value string) (time.Weekday, error) {
+	n, err := parseScheduleValue(value, 0, 7, weekdayNames)
+	return time.Weekday(n % 7), err
+}
+
+func fieldError(name string, field string, err error) error {
+	return fmt.Errorf("%s field %q: %s", name, field, err)
+}
+
+func daysInMonth(date time.Time) int {
+	return time.Date(date.Year(), date.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+// nearestWeekday returns the weekday nearest to a day within the month of date
+func nearestWeekday(date time.Time, day int) int {
+	switch time.Date(date.Year(), date.Month(), day, 0, 0, 0, 0, time.UTC).Weekday() {
+	case time.Saturday:
+		if day == 1 {
+			return day + 2
+		}
+		return day - 1
+	case time.Sunday:
+		if day == daysInMonth(date) {
+			return day - 2
+		}
+		return day + 1
+	}
+	return day
+}
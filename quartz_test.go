@@ -0,0 +1,82 @@
+package crontinuous
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQuartzSchedule(t *testing.T) {
+	from := time.Date(2016, 11, 1, 0, 0, 0, 0, time.UTC)
+	for _, test := range []struct {
+		spec string
+		want []string
+	}{
+		{"0 0 18 L * *", []string{"2016-11-30 18:00", "2016-12-31 18:00", "2017-01-31 18:00"}},
+		{"0 0 0 L-2 * ?", []string{"2016-11-28 00:00", "2016-12-29 00:00"}},
+		// the last weekday of December 2016 and of April 2017 are before a weekend ending the month
+		{"0 0 9 LW 12,apr *", []string{"2016-12-30 09:00", "2017-04-28 09:00"}},
+		{"0 30 8 1W,15W * *", []string{"2016-11-01 08:30", "2016-11-15 08:30", "2016-12-01 08:30", "2016-12-15 08:30"}},
+		// the 1st of April 2017 is a Saturday, the nearest weekday within the month is Monday the 3rd
+		{"0 0 8 1W apr *", []string{"2017-04-03 08:00"}},
+		{"0 0 22 ? * 5L", []string{"2016-11-25 22:00", "2016-12-30 22:00"}},
+		{"0 0 10 ? * fri#3", []string{"2016-11-18 10:00", "2016-12-16 10:00"}},
+		{"0 0 12 1 1 ? 2018-2019", []string{"2018-01-01 12:00", "2019-01-01 12:00", ""}},
+		{"0 0 12 29 feb * 2020/4", []string{"2020-02-29 12:00", "2024-02-29 12:00"}},
+	} {
+		schedule, err := parseSpec(test.spec)
+		if err != nil {
+			t.Errorf("%s: %s", test.spec, err)
+			continue
+		}
+		next := from
+		for _, want := range test.want {
+			next = schedule.Next(next)
+			got := ""
+			if !next.IsZero() {
+				got = next.Format("2006-01-02 15:04")
+			}
+			if got != want {
+				t.Errorf("%s: got %q, want %q", test.spec, got, want)
+				break
+			}
+		}
+	}
+
+	for spec, want := range map[string]string{
+		"0 L * * * *":      "minute field",
+		"0 0 0 5L * *":     "L stands alone",
+		"0 0 0 1-5W * *":   "W takes a single day",
+		"0 0 0 ? * L":      "L needs a weekday",
+		"0 0 0 ? * 5#6":    "# needs a week",
+		"0 0 0 ? * 5W":     "W is only supported in the day of month field",
+		"0 0 0 1#2 * *":    "# is only supported in the day of week field",
+		"0 0 0 1 1 * 1900": "out of the range",
+	} {
+		if _, err := parseSpec(spec); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: expected an error containing %q, got %v", spec, want, err)
+		}
+	}
+}
+
+func TestYearField(t *testing.T) {
+	crontab := "0 9 L * * 2027 month-end\n" +
+		"30 0 9 L * * 2027-2028 month-end --seconds\n" +
+		// without a command following it, a year is the command
+		"0 9 * * 1-5 2027\n"
+	jobs, errs := parseCrontabReader("years", strings.NewReader(crontab), nil)
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	if len(jobs) != 3 || jobs[2].Command != "2027" {
+		t.Fatalf("unexpected jobs %+v", jobs)
+	}
+	for i, want := range []string{"0 0 9 L * * 2027", "30 0 9 L * * 2027-2028"} {
+		if jobs[i].Schedule != want || jobs[i].Command != "month-end" {
+			t.Errorf("got schedule %q and command %q, want %q", jobs[i].Schedule, jobs[i].Command, want)
+		}
+	}
+	if next := jobs[0].schedule.Next(time.Date(2016, 1, 1, 0, 0, 0, 0, time.Local)); next.Year() != 2027 || next.Month() != time.January || next.Day() != 31 {
+		t.Errorf("unexpected next run %s", next)
+	}
+}
@@ -0,0 +1,55 @@
+package crontinuous
+
+import (
+	"reflect"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Private methods
+// --------------------------------------------------------------------------------------------
+
+// reuseJobs replaces the reloaded jobs that did not change with the previously loaded ones, which
+// keep their active runs, last run and output. Changed jobs take over the last run and output of
+// their previous version, its active runs finish with the previous settings.
+func reuseJobs(previous []*Runnable, jobs []*Runnable) ([]*Runnable, map[*Runnable]bool) {
+	kept := map[*Runnable]bool{}
+	byID := jobsByID(previous)
+	for i, r := range jobs {
+		old, ok := byID[r.ID]
+		if !ok || kept[old] {
+			continue
+		}
+		if reflect.DeepEqual(jobSettings(old), jobSettings(r)) {
+			// the crontab may have a new revision without changes to the job
+			old.setRevision(r.revision)
+			jobs[i] = old
+			kept[old] = true
+			continue
+		}
+		r.inheritState(old)
+	}
+	return jobs, kept
+}
+
+// setRevision sets the version of the crontab the job was loaded from, runs started afterwards record it
+func (r *Runnable) setRevision(revision string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.revision = revision
+}
+
+// inheritState takes over the last run and output of the previous version of a job
+func (r *Runnable) inheritState(old *Runnable) {
+	// the output is copied, runs of the previous version may still add to it
+	runID, lines := old.output()
+	last := old.lastRun()
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.last = last
+	if len(lines) > 0 {
+		r.outputTail = newLineTail(outputTailLines)
+		r.outputTail.lines = lines
+		r.outputRunID = runID
+	}
+}
@@ -0,0 +1,86 @@
+package crontinuous
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestReuseJobs(t *testing.T) {
+	unchanged := createRunnable("/bin/true", "", "0 0 * * * *")
+	unchanged.ID = "unchanged"
+	changed := createRunnable("/bin/backup", "", "0 0 3 * * *")
+	changed.ID = "changed"
+	changed.setLastRun(&Notification{Event: EventFailure})
+	changed.addOutput("run", "output")
+
+	unchangedAgain := createRunnable("/bin/true", "", "0 0 * * * *")
+	unchangedAgain.ID = "unchanged"
+	changedAgain := createRunnable("/bin/backup", "", "0 0 4 * * *")
+	changedAgain.ID = "changed"
+
+	jobs, kept := reuseJobs([]*Runnable{unchanged, changed}, []*Runnable{unchangedAgain, changedAgain})
+	if jobs[0] != unchanged || !kept[unchanged] {
+		t.Error("unchanged job was not kept")
+	}
+	if jobs[1] != changedAgain || kept[changed] {
+		t.Error("changed job was kept")
+	}
+	if last := changedAgain.lastRun(); last == nil || last.Event != EventFailure {
+		t.Errorf("changed job did not take over the last run, got %v", last)
+	}
+	if runID, lines := changedAgain.output(); runID != "run" || len(lines) != 1 {
+		t.Errorf("changed job did not take over the output, got %q %v", runID, lines)
+	}
+}
+
+func TestReloadKeepsJobsOnError(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs symlinks")
+	}
+	dir, err := ioutil.TempDir("", "crontinuous-reload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	crontab := filepath.Join(dir, "crontab")
+	if err := ioutil.WriteFile(crontab, []byte("# name: backup\n0 3 * * * /bin/backup\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	previousSources, previousJobs, previousLoad := loadedSources, loadedJobs, lastLoad
+	previousBooted, previousStarted, previousScheduler := booted, schedulerStarted, cronScheduler
+	defer func() {
+		if cronScheduler != nil {
+			cronScheduler.Stop()
+		}
+		loadedSources, loadedJobs, lastLoad = previousSources, previousJobs, previousLoad
+		booted, schedulerStarted, cronScheduler = previousBooted, previousStarted, previousScheduler
+		daemonFileTriggers.update(nil)
+	}()
+	loadedSources, loadedJobs, cronScheduler = []*crontabSource{{path: crontab}}, nil, nil
+
+	initCron("start")
+	if len(loadedJobs) != 1 {
+		t.Fatalf("expected the job to be loaded, got %d jobs", len(loadedJobs))
+	}
+
+	// a crontab that cannot be read keeps the jobs loaded before
+	if err := os.Remove(crontab); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(crontab, crontab); err != nil {
+		t.Fatal(err)
+	}
+	initCron("SIGHUP")
+	initCron("SIGHUP")
+	if len(loadedJobs) != 1 || loadedJobs[0].ID != "backup" {
+		t.Errorf("expected the job to be kept, got %d jobs", len(loadedJobs))
+	}
+	if len(lastLoad.errors) != 1 || !strings.Contains(lastLoad.errors[0], "failed to load crontab") {
+		t.Errorf("expected the failed reload to be reported once, got %q", lastLoad.errors)
+	}
+}
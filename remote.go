@@ -0,0 +1,276 @@
+package crontinuous
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Constants
+// --------------------------------------------------------------------------------------------
+
+const (
+	// maxRemoteCrontab bounds the size of fetched crontabs
+	maxRemoteCrontab = 10 * 1024 * 1024
+	// watchRetryDelay is waited before watching a crontab again after a failed update
+	watchRetryDelay = 5 * time.Second
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Struct
+// --------------------------------------------------------------------------------------------
+
+// remoteCrontab is a crontab from elsewhere, kept in a local file or directory that is loaded and
+// watched like any other crontab
+type remoteCrontab interface {
+	// update gets the latest version, the local copy is only replaced by versions without problems
+	update() error
+	// run keeps the local copy up to date, checking in the given interval unless changes are watched
+	run(interval time.Duration)
+	// local returns the file or directory of the local copy
+	local() string
+	// origin returns where the crontab comes from, without credentials
+	origin() string
+	// originOf returns where a file of the local copy comes from, empty for other files
+	originOf(filename string) string
+	// revision returns the version of the local copy, empty if unknown
+	revision() string
+}
+
+// crontabFetcher gets a crontab from a remote source
+type crontabFetcher interface {
+	// fetch returns the crontab, nil if it did not change since the last fetch
+	fetch() ([]byte, error)
+	// watches reports whether fetch waits for the next change instead of returning right away
+	watches() bool
+}
+
+// crontabMirror keeps a remote crontab fetched as a whole in a local file
+type crontabMirror struct {
+	// source is where the crontab comes from, without credentials
+	source   string
+	filename string
+	fetcher  crontabFetcher
+}
+
+// httpCrontab fetches a crontab over http(s), asking the server whether it changed since the last fetch
+type httpCrontab struct {
+	url          string
+	etag         string
+	lastModified string
+}
+
+// --------------------------------------------------------------------------------------------
+// ~ Variables
+// --------------------------------------------------------------------------------------------
+
+var (
+	remoteClient = &http.Client{Timeout: 30 * time.Second}
+	// remoteCrontabs are set up on start
+	remoteCrontabs []remoteCrontab
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Private methods
+// --------------------------------------------------------------------------------------------
+
+// newRemoteCrontab returns the remote crontab of a source, false for local paths
+func newRemoteCrontab(source string) (remoteCrontab, bool) {
+	if strings.HasPrefix(source, gitPrefix) {
+		return newGitCrontab(source), true
+	}
+	if fetcher, ok := newKVFetcher(source); ok {
+		return &crontabMirror{
+			source:   redactURL(source),
+			filename: mirrorFilename(source),
+			fetcher:  fetcher,
+		}, true
+	}
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		return &crontabMirror{
+			source:   redactURL(source),
+			filename: mirrorFilename(source),
+			fetcher:  &httpCrontab{url: source},
+		}, true
+	}
+	return nil, false
+}
+
+// mirrorCrontabs replaces the remote crontabs among the paths with local copies, fetched once.
+// A copy kept in the state directory from an earlier start is used if a crontab cannot be fetched.
+func mirrorCrontabs(paths []string) ([]string, []remoteCrontab, error) {
+	local := []string{}
+	remotes := []remoteCrontab{}
+	for _, source := range paths {
+		remote, ok := newRemoteCrontab(source)
+		if !ok {
+			local = append(local, source)
+			continue
+		}
+		if err := remote.update(); err != nil {
+			if _, statErr := os.Stat(remote.local()); statErr != nil {
+				return nil, nil, fmt.Errorf("failed to fetch crontab %s: %s", remote.origin(), err)
+			}
+			log.WithField("crontab", remote.origin()).Warn("failed to fetch crontab, using the copy of an earlier start: ", err)
+		}
+		local = append(local, remote.local())
+		remotes = append(remotes, remote)
+	}
+	remoteCrontabs = append(remoteCrontabs, remotes...)
+	return local, remotes, nil
+}
+
+// setRemoteOrigin attributes the jobs of remote crontabs to where they come from instead of the local copy
+func setRemoteOrigin(jobs []*Runnable) {
+	for _, r := range jobs {
+		for _, remote := range remoteCrontabs {
+			if origin := remote.originOf(r.File); origin != "" {
+				r.setFile(origin)
+				r.revision = remote.revision()
+				break
+			}
+		}
+	}
+}
+
+// mirrorFilename is where the copy of a remote crontab is kept, in the state directory if there is one.
+// It ends like the source, so job configs are told apart from crontabs.
+func mirrorFilename(source string) string {
+	dir := filepath.Join(os.TempDir(), "crontinuous-remote")
+	if *stateDir != "" {
+		dir = filepath.Join(*stateDir, "remote")
+	}
+	name := "crontab"
+	if u, err := url.Parse(source); err == nil && path.Base(u.Path) != "/" && path.Base(u.Path) != "." {
+		name = path.Base(u.Path)
+	}
+	h := sha1.Sum([]byte(source))
+	return filepath.Join(dir, hex.EncodeToString(h[:8])+"-"+name)
+}
+
+// redactURL hides the password of an url for logs
+func redactURL(source string) string {
+	u, err := url.Parse(source)
+	if err != nil {
+		return source
+	}
+	return u.Redacted()
+}
+
+// local implements remoteCrontab
+func (m *crontabMirror) local() string {
+	return m.filename
+}
+
+// origin implements remoteCrontab
+func (m *crontabMirror) origin() string {
+	return m.source
+}
+
+// originOf implements remoteCrontab
+func (m *crontabMirror) originOf(filename string) string {
+	if filename == m.filename {
+		return m.source
+	}
+	return ""
+}
+
+// revision implements remoteCrontab, the versions of fetched crontabs are not known
+func (m *crontabMirror) revision() string {
+	return ""
+}
+
+// run implements remoteCrontab, the watcher reloads the crontab when the copy changes
+func (m *crontabMirror) run(interval time.Duration) {
+	for {
+		if !m.fetcher.watches() {
+			time.Sleep(interval)
+		}
+		if err := m.update(); err != nil {
+			log.WithField("crontab", m.source).Error("failed to update crontab: ", err)
+			if m.fetcher.watches() {
+				time.Sleep(watchRetryDelay)
+			}
+		}
+	}
+}
+
+// update fetches the crontab and replaces the local copy if it changed and has no problems
+func (m *crontabMirror) update() error {
+	data, err := m.fetcher.fetch()
+	if err != nil || data == nil {
+		return err
+	}
+	if current, err := ioutil.ReadFile(m.filename); err == nil && string(current) == string(data) {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(m.filename), 0755); err != nil {
+		return err
+	}
+
+	// the new version is checked next to the copy and moved over it, so the copy is never partial
+	tmp := filepath.Join(filepath.Dir(m.filename), "."+filepath.Base(m.filename))
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	if _, errs := parseCrontab(tmp); len(errs) > 0 {
+		os.Remove(tmp)
+		for _, err := range errs {
+			log.WithField("crontab", m.source).Error(err)
+		}
+		return fmt.Errorf("keeping the previous version, the new one has %d problem(s)", len(errs))
+	}
+	return os.Rename(tmp, m.filename)
+}
+
+// watches implements crontabFetcher, http crontabs are polled
+func (h *httpCrontab) watches() bool {
+	return false
+}
+
+// fetch implements crontabFetcher
+func (h *httpCrontab) fetch() ([]byte, error) {
+	req, err := http.NewRequest("GET", h.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if h.etag != "" {
+		req.Header.Set("If-None-Match", h.etag)
+	}
+	if h.lastModified != "" {
+		req.Header.Set("If-Modified-Since", h.lastModified)
+	}
+	resp, err := remoteClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxRemoteCrontab+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxRemoteCrontab {
+		return nil, fmt.Errorf("crontab is larger than %d bytes", maxRemoteCrontab)
+	}
+	h.etag = resp.Header.Get("ETag")
+	h.lastModified = resp.Header.Get("Last-Modified")
+	return data, nil
+}
@@ -0,0 +1,61 @@
+package crontinuous
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Constants
+// --------------------------------------------------------------------------------------------
+
+// BackoffPolicy decides how the delay between retries grows
+type BackoffPolicy string
+
+const (
+	// BackoffFixed waits the same delay before every retry
+	BackoffFixed BackoffPolicy = "fixed"
+	// BackoffExponential doubles the delay with every retry
+	BackoffExponential BackoffPolicy = "exponential"
+)
+
+// maxRetryDelay caps the exponential backoff, which would overflow after a few dozen attempts
+const maxRetryDelay = time.Hour
+
+// --------------------------------------------------------------------------------------------
+// ~ Private methods
+// --------------------------------------------------------------------------------------------
+
+func parseBackoffPolicy(value string) (BackoffPolicy, error) {
+	switch policy := BackoffPolicy(strings.ToLower(strings.TrimSpace(value))); policy {
+	case BackoffFixed, BackoffExponential:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unknown backoff policy %q, expected fixed or exponential", value)
+	}
+}
+
+// retryDelay returns how long to wait after the given failed attempt, exponential backoff
+// grows up to maxRetryDelay or the retry delay if that is longer
+func (r *Runnable) retryDelay(attempt int) time.Duration {
+	if r.RetryBackoff != BackoffExponential {
+		return r.RetryDelay
+	}
+	delay := r.RetryDelay
+	for i := 1; i < attempt && delay > 0 && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxRetryDelay && r.RetryDelay <= maxRetryDelay {
+		delay = maxRetryDelay
+	}
+	return delay
+}
+
+// nextRun returns when the job is scheduled next, zero if it is not scheduled
+func (r *Runnable) nextRun() time.Time {
+	if r.schedule == nil {
+		return time.Time{}
+	}
+	return r.schedule.Next(time.Now().In(schedulerLocation))
+}
@@ -0,0 +1,104 @@
+package crontinuous
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+func TestRetryDelay(t *testing.T) {
+	tests := []struct {
+		name    string
+		delay   time.Duration
+		backoff BackoffPolicy
+		attempt int
+		want    time.Duration
+	}{
+		{"fixed", 10 * time.Second, BackoffFixed, 5, 10 * time.Second},
+		{"exponential first", 10 * time.Second, BackoffExponential, 1, 10 * time.Second},
+		{"exponential third", 10 * time.Second, BackoffExponential, 3, 40 * time.Second},
+		{"exponential capped", 10 * time.Second, BackoffExponential, 20, maxRetryDelay},
+		{"exponential no overflow", 10 * time.Second, BackoffExponential, 100, maxRetryDelay},
+		{"exponential above cap", 2 * time.Hour, BackoffExponential, 3, 2 * time.Hour},
+		{"exponential zero delay", 0, BackoffExponential, 10, 0},
+	}
+	for _, test := range tests {
+		r := &Runnable{RetryDelay: test.delay, RetryBackoff: test.backoff}
+		if got := r.retryDelay(test.attempt); got != test.want {
+			t.Errorf("%s: retryDelay(%d) = %s, want %s", test.name, test.attempt, got, test.want)
+		}
+	}
+}
+
+func TestParseBackoffPolicy(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    BackoffPolicy
+		wantErr bool
+	}{
+		{"fixed", BackoffFixed, false},
+		{" Exponential ", BackoffExponential, false},
+		{"linear", "", true},
+	}
+	for _, test := range tests {
+		got, err := parseBackoffPolicy(test.value)
+		if (err != nil) != test.wantErr || got != test.want {
+			t.Errorf("parseBackoffPolicy(%q) = %q, %v", test.value, got, err)
+		}
+	}
+}
+
+func TestRetryReleasesSlots(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a shell")
+	}
+	previous := pool
+	defer func() { pool = previous }()
+	pool = newWorkerPool(1, OverflowSkip)
+
+	dir, err := ioutil.TempDir("", "crontinuous-retry")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	attempts := filepath.Join(dir, "attempts")
+
+	job := createRunnable("sh", "-c 'echo attempt >> "+attempts+"; exit 1'", "0 0 * * * *")
+	job.ID = "retry-slots"
+	job.contextLogger = log.WithField("id", job.ID)
+	job.Retries = 1
+	job.RetryDelay = 500 * time.Millisecond
+	job.RetryBackoff = BackoffFixed
+	done := make(chan struct{})
+	go func() {
+		job.run()
+		close(done)
+	}()
+
+	// the first attempt fails at once, the slot is free while waiting for the retry
+	time.Sleep(250 * time.Millisecond)
+	if taken := len(pool.slots); taken != 0 {
+		t.Errorf("expected the slot to be free while waiting for the retry, %d taken", taken)
+	}
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the run to finish")
+	}
+	if taken := len(pool.slots); taken != 0 {
+		t.Errorf("expected the slot to be released after the run, %d taken", taken)
+	}
+	data, err := ioutil.ReadFile(attempts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(data), "attempt"); n != 2 {
+		t.Errorf("expected the run to be retried once, got %d attempts", n)
+	}
+}
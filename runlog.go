@@ -0,0 +1,108 @@
+package crontinuous
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Variables
+// --------------------------------------------------------------------------------------------
+
+// runIDRegexp matches the ids of runs, which name the files of their output
+var runIDRegexp = regexp.MustCompile(`^[0-9a-f-]+$`)
+
+// --------------------------------------------------------------------------------------------
+// ~ Struct
+// --------------------------------------------------------------------------------------------
+
+// runLog is the complete output of a run, kept in the state directory next to the history of the run
+type runLog struct {
+	lock  sync.Mutex
+	jobID string
+	file  *os.File
+}
+
+// --------------------------------------------------------------------------------------------
+// ~ Private methods
+// --------------------------------------------------------------------------------------------
+
+// runLogFilename is where the output of a run is kept, empty if run logs are disabled
+func runLogFilename(jobID string, runID string) string {
+	if !*runLogs || *stateDir == "" {
+		return ""
+	}
+	return filepath.Join(*stateDir, jobID, runID+".log")
+}
+
+// openRunLog opens the file of a run's output, retries of the run append to it. A nil run log does nothing.
+func openRunLog(jobID string, runID string, attempt int) (*runLog, error) {
+	filename := runLogFilename(jobID, runID)
+	if filename == "" {
+		return nil, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	l := &runLog{jobID: jobID, file: file}
+	if attempt > 1 {
+		return l, l.writeLine("attempt", fmt.Sprintf("%d", attempt))
+	}
+	return l, nil
+}
+
+// writeLine appends a timestamped line of a stream
+func (l *runLog) writeLine(stream string, line string) error {
+	if l == nil {
+		return nil
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	_, err := fmt.Fprintf(l.file, "%s %s %s\n", time.Now().Format(time.RFC3339Nano), stream, line)
+	return err
+}
+
+// close closes the file and removes the run logs of the job exceeding the retention
+func (l *runLog) close() {
+	if l == nil {
+		return
+	}
+	l.file.Close()
+	pruneRunLogs(l.jobID)
+}
+
+// pruneRunLogs keeps the newest -run-log-keep runs of a job and removes those older than -run-log-retention
+func pruneRunLogs(jobID string) {
+	dir := filepath.Join(*stateDir, jobID)
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	logs := []os.FileInfo{}
+	for _, info := range infos {
+		if strings.HasSuffix(info.Name(), ".log") {
+			logs = append(logs, info)
+		}
+	}
+	// newest first
+	sort.Slice(logs, func(i, j int) bool {
+		return logs[i].ModTime().After(logs[j].ModTime())
+	})
+	for i, info := range logs {
+		expired := *runLogRetention > 0 && time.Since(info.ModTime()) > *runLogRetention
+		if (*runLogKeep > 0 && i >= *runLogKeep) || expired {
+			os.Remove(filepath.Join(dir, info.Name()))
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package crontinuous
+
+import (
+	"strings"
+	"time"
+
+	"github.com/robfig/cron"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Struct
+// --------------------------------------------------------------------------------------------
+
+// locationSchedule evaluates a schedule in a specific time zone
+type locationSchedule struct {
+	schedule cron.Schedule
+	location *time.Location
+}
+
+// Next implements cron.Schedule
+func (s *locationSchedule) Next(t time.Time) time.Time {
+	return s.schedule.Next(t.In(s.location))
+}
+
+// --------------------------------------------------------------------------------------------
+// ~ Variables
+// --------------------------------------------------------------------------------------------
+
+// descriptorSpecs are the specs of the shortcuts running at fixed times, which daylight saving time may affect
+var descriptorSpecs = map[string]string{
+	"@yearly":   "0 0 0 1 1 *",
+	"@annually": "0 0 0 1 1 *",
+	"@monthly":  "0 0 0 1 * *",
+	"@weekly":   "0 0 0 * * 0",
+	"@daily":    "0 0 0 * * *",
+	"@midnight": "0 0 0 * * *",
+	"@hourly":   "0 0 * * * *",
+}
+
+// --------------------------------------------------------------------------------------------
+// ~ Private methods
+// --------------------------------------------------------------------------------------------
+
+// parseSchedule parses the cron spec of the job, with H fields spread by its id, its daylight saving time
+// policy and the days it skips, evaluating it in its time zone if it has one
+func (r *Runnable) parseSchedule(spec string) (cron.Schedule, error) {
+	spec, err := expandHashes(spec, r.ID)
+	if err != nil {
+		return nil, err
+	}
+	if descriptor, ok := descriptorSpecs[spec]; ok {
+		spec = descriptor
+	}
+	schedule, err := parseSpec(spec)
+	if err != nil {
+		return nil, err
+	}
+	if r.DST != "" && hasFixedHours(strings.Fields(spec)) {
+		schedule = &dstSchedule{schedule: schedule, policy: r.DST, logger: r.contextLogger}
+	}
+	if r.SkipHolidays || r.BusinessDaysOnly {
+		schedule = &calendarSchedule{schedule: schedule, businessDays: r.BusinessDaysOnly, logger: r.contextLogger}
+	}
+	if r.Location == nil {
+		return schedule, nil
+	}
+	return &locationSchedule{schedule: schedule, location: r.Location}, nil
+}
+
+// parseSpec parses a cron spec with a leading seconds field, with Quartz style specials or a year field if needed
+func parseSpec(spec string) (cron.Schedule, error) {
+	if fields := strings.Fields(spec); !strings.HasPrefix(spec, "@") && usesQuartzSyntax(fields) {
+		return parseQuartzSchedule(fields)
+	}
+	return cron.Parse(spec)
+}
@@ -0,0 +1,229 @@
+package crontinuous
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/robfig/cron"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Struct
+// --------------------------------------------------------------------------------------------
+
+// Job is a job of a crontab, scheduled by a Scheduler
+type Job = Runnable
+
+// Source provides the jobs of a Scheduler
+type Source interface {
+	// Name identifies the source in logs
+	Name() string
+	// Load parses the jobs of the source, jobs with problems are left out and returned as errors
+	Load() ([]*Job, []error)
+}
+
+// Scheduler runs the jobs of its sources on their schedules, a job of a later source overrides the
+// job with the same id of an earlier one. It embeds the scheduling of the crontinuous daemon in
+// other programs. Options like -state-dir or -max-concurrent are set by parsing CommandLine before
+// the first scheduler is loaded, they are applied once for all schedulers of the process.
+type Scheduler struct {
+	sources   []Source
+	notifiers []Notifier
+	lock      sync.RWMutex
+	cron      *cron.Cron
+	files     fileTriggers
+	jobs      []*Job
+}
+
+// LoadError lists the problems of the sources found while loading them
+type LoadError struct {
+	Errors []error
+}
+
+// fileSource is a crontab file or a directory of crontabs
+type fileSource struct {
+	path string
+}
+
+// textSource is a crontab held in memory
+type textSource struct {
+	name    string
+	crontab string
+}
+
+// --------------------------------------------------------------------------------------------
+// ~ Public methods
+// --------------------------------------------------------------------------------------------
+
+// NewScheduler creates a scheduler of the jobs of the given sources, in the order of their precedence
+func NewScheduler(sources ...Source) *Scheduler {
+	return &Scheduler{sources: sources}
+}
+
+// FileSource returns a source reading a crontab file or a directory of crontabs like /etc/cron.d
+func FileSource(path string) Source {
+	return &fileSource{path: path}
+}
+
+// TextSource returns a source of a crontab held in memory, the name is the file of its jobs
+func TextSource(name string, crontab string) Source {
+	return &textSource{name: name, crontab: crontab}
+}
+
+// AddNotifier sends the notifications of all runs to the notifier, next to those of the job annotations
+func (s *Scheduler) AddNotifier(notifier Notifier) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.notifiers = append(s.notifiers, notifier)
+}
+
+// Load (re)loads the jobs of the sources. Jobs that did not change are kept with their runs, the
+// jobs without problems are loaded even if an error is returned. A started scheduler schedules
+// the loaded jobs right away.
+func (s *Scheduler) Load() error {
+	if err := setup(); err != nil {
+		return err
+	}
+	sources := []*crontabSource{}
+	for _, source := range s.sources {
+		jobs, errs := source.Load()
+		sources = append(sources, &crontabSource{path: source.Name(), loaded: true, jobs: selectByTags(jobs), errs: errs})
+	}
+	jobs, errs, duplicates := mergeSources(sources)
+	errs = append(append(errs, duplicates...), dependencyErrors(jobs)...)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	var kept map[*Runnable]bool
+	jobs, kept = reuseJobs(s.jobs, jobs)
+	for _, r := range jobs {
+		if !kept[r] {
+			r.scheduler = s
+		}
+	}
+	s.jobs = jobs
+	if s.cron != nil {
+		s.cron.Stop()
+		s.schedule(false)
+	}
+	if len(errs) > 0 {
+		return &LoadError{Errors: errs}
+	}
+	return nil
+}
+
+// Start schedules the jobs, loading them first if they were not loaded yet. @reboot jobs are run
+// right away.
+func (s *Scheduler) Start() error {
+	if err := setup(); err != nil {
+		return err
+	}
+	var err error
+	s.lock.RLock()
+	loaded := s.jobs != nil
+	s.lock.RUnlock()
+	if !loaded {
+		err = s.Load()
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.cron == nil {
+		s.schedule(true)
+	}
+	return err
+}
+
+// Stop stops scheduling the jobs and running them for files, runs that started already are not stopped
+func (s *Scheduler) Stop() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.cron != nil {
+		s.cron.Stop()
+		s.cron = nil
+		s.files.update(nil)
+	}
+}
+
+// Jobs returns the loaded jobs
+func (s *Scheduler) Jobs() []*Job {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return append([]*Job{}, s.jobs...)
+}
+
+// Job returns the loaded job with the given id, nil if there is none
+func (s *Scheduler) Job(id string) *Job {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	for _, r := range s.jobs {
+		if r.ID == id {
+			return r
+		}
+	}
+	return nil
+}
+
+// Error implements error
+func (e *LoadError) Error() string {
+	messages := []string{}
+	for _, err := range e.Errors {
+		messages = append(messages, err.Error())
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Name implements Source
+func (f *fileSource) Name() string {
+	return f.path
+}
+
+// Load implements Source
+func (f *fileSource) Load() ([]*Job, []error) {
+	filenames, err := crontabFiles([]string{f.path})
+	if err != nil {
+		return nil, []error{err}
+	}
+	jobs := []*Job{}
+	errs := []error{}
+	for _, filename := range filenames {
+		parsed, parseErrs := parseCrontab(filename)
+		jobs = append(jobs, parsed...)
+		errs = append(errs, parseErrs...)
+	}
+	return jobs, errs
+}
+
+// Name implements Source
+func (t *textSource) Name() string {
+	return t.name
+}
+
+// Load implements Source
+func (t *textSource) Load() ([]*Job, []error) {
+	return parseCrontabReader(t.name, strings.NewReader(t.crontab), nil)
+}
+
+// --------------------------------------------------------------------------------------------
+// ~ Private methods
+// --------------------------------------------------------------------------------------------
+
+// schedule starts a new cron scheduler with the loaded jobs, the caller holds the lock
+func (s *Scheduler) schedule(reboot bool) {
+	s.cron = cron.NewWithLocation(schedulerLocation)
+	for _, r := range s.jobs {
+		scheduleJob(s.cron, r, reboot)
+	}
+	s.cron.Start()
+	s.files.update(s.jobs)
+}
+
+// allNotifiers returns the notifiers added to the scheduler, none for jobs of the daemon
+func (s *Scheduler) allNotifiers() []Notifier {
+	if s == nil {
+		return nil
+	}
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return append([]Notifier{}, s.notifiers...)
+}
@@ -0,0 +1,79 @@
+package crontinuous
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestSchedulerLoad(t *testing.T) {
+	base := TextSource("base", "# name: backup\n0 3 * * * /bin/backup\n# name: cleanup\n0 4 * * * /bin/cleanup\n")
+	app := TextSource("app", "# name: backup\n0 2 * * * /bin/backup --full\n")
+	s := NewScheduler(base, app)
+	if err := s.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if jobs := s.Jobs(); len(jobs) != 2 {
+		t.Fatalf("expected 2 jobs, got %d", len(jobs))
+	}
+	backup := s.Job("backup")
+	if backup == nil || backup.Args != "--full" || backup.File != "app" {
+		t.Errorf("later source did not override the job, got %+v", backup)
+	}
+
+	// unchanged jobs are kept on reloads
+	cleanup := s.Job("cleanup")
+	if err := s.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if s.Job("cleanup") != cleanup {
+		t.Error("unchanged job was not kept")
+	}
+
+	broken := NewScheduler(TextSource("broken", "0 3 * * *\n# name: report\n0 5 * * * /bin/report\n"))
+	err := broken.Load()
+	if loadErr, ok := err.(*LoadError); !ok || len(loadErr.Errors) != 1 {
+		t.Errorf("expected a load error with one problem, got %v", err)
+	}
+	if broken.Job("report") == nil {
+		t.Error("job without problems was not loaded")
+	}
+}
+
+func TestSchedulerStateDir(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a shell")
+	}
+	dir, err := ioutil.TempDir("", "crontinuous-scheduler")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	previousDir, previousHistory, previousState, previousAudit := *stateDir, runHistory, jobsState, audit
+	defer func() {
+		*stateDir, runHistory, jobsState, audit = previousDir, previousHistory, previousState, previousAudit
+		setupOnce, setupErr = sync.Once{}, nil
+	}()
+	*stateDir = dir
+	setupOnce, setupErr = sync.Once{}, nil
+
+	s := NewScheduler(TextSource("embedded", "# name: embedded\n0 3 * * * echo embedded\n"))
+	if err := s.Load(); err != nil {
+		t.Fatal(err)
+	}
+	defer runHistory.file.Close()
+	defer audit.file.Close()
+	s.Job("embedded").run()
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "history.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"jobId":"embedded"`) {
+		t.Errorf("expected the run of the embedded scheduler in the history, got %q", data)
+	}
+}
@@ -0,0 +1,196 @@
+package crontinuous
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Constants
+// --------------------------------------------------------------------------------------------
+
+// secretMask replaces the values of secrets in the output of jobs
+const secretMask = "***"
+
+// --------------------------------------------------------------------------------------------
+// ~ Variables
+// --------------------------------------------------------------------------------------------
+
+// secretSources resolve secret references by scheme, only the configured ones are set
+var secretSources = map[string]secretSource{}
+
+// knownSecretSchemes are the schemes of secret references, vault is the default
+var knownSecretSchemes = []string{"vault", "aws-sm", "ssm"}
+
+// daemonCredentials are the variables crontinuous authenticates its secret sources and uploads with,
+// names ending in _ are prefixes. They are not inherited by jobs and hooks.
+var daemonCredentials = []string{
+	"VAULT_",
+	"AWS_ACCESS_KEY_ID",
+	"AWS_SECRET_ACCESS_KEY",
+	"AWS_SESSION_TOKEN",
+	"AWS_SECURITY_TOKEN",
+	"AWS_WEB_IDENTITY_TOKEN_FILE",
+	"AWS_ROLE_ARN",
+	"AWS_ROLE_SESSION_NAME",
+	"AWS_CONTAINER_",
+	"GOOGLE_APPLICATION_CREDENTIALS",
+	"CRONTINUOUS_API_TOKEN",
+}
+
+// --------------------------------------------------------------------------------------------
+// ~ Struct
+// --------------------------------------------------------------------------------------------
+
+// secretSource looks up the value of a secret
+type secretSource interface {
+	resolve(ref string) (string, error)
+}
+
+// cachedSecretSource keeps the values of a source for a while, to not call it on every run
+type cachedSecretSource struct {
+	source secretSource
+	ttl    time.Duration
+	values map[string]cachedSecret
+	lock   sync.Mutex
+}
+
+type cachedSecret struct {
+	value   string
+	expires time.Time
+}
+
+// secretRef is a variable of a job whose value is looked up in a secret source on every run
+type secretRef struct {
+	name   string
+	scheme string
+	ref    string
+}
+
+// resolvedSecrets are the secrets of a run
+type resolvedSecrets struct {
+	env    []string
+	names  []string
+	masker *strings.Replacer
+}
+
+// --------------------------------------------------------------------------------------------
+// ~ Private methods
+// --------------------------------------------------------------------------------------------
+
+// parseSecrets parses space separated NAME=reference pairs, references may start with the scheme of their source
+func parseSecrets(value string) ([]secretRef, error) {
+	pairs, err := parseEnv(value)
+	if err != nil {
+		return nil, err
+	}
+	secrets := []secretRef{}
+	for _, pair := range pairs {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("invalid secret %q, expected NAME=reference", pair)
+		}
+		secret := secretRef{name: parts[0], scheme: "vault", ref: parts[1]}
+		if prefix := strings.SplitN(parts[1], ":", 2); len(prefix) == 2 && containsString(knownSecretSchemes, prefix[0]) {
+			secret.scheme = prefix[0]
+			secret.ref = prefix[1]
+		}
+		secrets = append(secrets, secret)
+	}
+	return secrets, nil
+}
+
+// resolveSecrets looks up the secrets of the job, their values are never logged
+func (r *Runnable) resolveSecrets() (*resolvedSecrets, error) {
+	resolved := &resolvedSecrets{}
+	replacements := []string{}
+	for _, secret := range r.Secrets {
+		source, ok := secretSources[secret.scheme]
+		if !ok {
+			return nil, fmt.Errorf("secret %s: %s is not configured", secret.name, secret.scheme)
+		}
+		value, err := source.resolve(secret.ref)
+		if err != nil {
+			return nil, fmt.Errorf("secret %s: %s", secret.name, err)
+		}
+		resolved.env = append(resolved.env, secret.name+"="+value)
+		resolved.names = append(resolved.names, secret.name)
+		if value != "" {
+			replacements = append(replacements, value, secretMask)
+		}
+	}
+	if len(replacements) > 0 {
+		resolved.masker = strings.NewReplacer(replacements...)
+	}
+	return resolved, nil
+}
+
+// jobEnviron returns the environment of crontinuous without its own credentials, for jobs and hooks
+func jobEnviron() []string {
+	env := []string{}
+	for _, variable := range os.Environ() {
+		if !isDaemonCredential(strings.SplitN(variable, "=", 2)[0]) {
+			env = append(env, variable)
+		}
+	}
+	return env
+}
+
+func isDaemonCredential(name string) bool {
+	name = strings.ToUpper(name)
+	for _, credential := range daemonCredentials {
+		if name == credential || strings.HasSuffix(credential, "_") && strings.HasPrefix(name, credential) {
+			return true
+		}
+	}
+	return false
+}
+
+// invalidateSecrets drops the cached values of the job's secrets
+func (r *Runnable) invalidateSecrets() {
+	for _, secret := range r.Secrets {
+		if cached, ok := secretSources[secret.scheme].(*cachedSecretSource); ok {
+			cached.invalidate(secret.ref)
+		}
+	}
+}
+
+func newCachedSecretSource(source secretSource, ttl time.Duration) *cachedSecretSource {
+	return &cachedSecretSource{source: source, ttl: ttl, values: map[string]cachedSecret{}}
+}
+
+func (c *cachedSecretSource) resolve(ref string) (string, error) {
+	c.lock.Lock()
+	cached, ok := c.values[ref]
+	c.lock.Unlock()
+	if ok && time.Now().Before(cached.expires) {
+		return cached.value, nil
+	}
+	value, err := c.source.resolve(ref)
+	if err != nil {
+		return "", err
+	}
+	if c.ttl > 0 {
+		c.lock.Lock()
+		c.values[ref] = cachedSecret{value: value, expires: time.Now().Add(c.ttl)}
+		c.lock.Unlock()
+	}
+	return value, nil
+}
+
+func (c *cachedSecretSource) invalidate(ref string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	delete(c.values, ref)
+}
+
+// mask hides the values of the secrets in a line of output
+func (s *resolvedSecrets) mask(line string) string {
+	if s == nil || s.masker == nil {
+		return line
+	}
+	return s.masker.Replace(line)
+}
@@ -0,0 +1,24 @@
+package crontinuous
+
+import "testing"
+
+func TestIsDaemonCredential(t *testing.T) {
+	tests := map[string]bool{
+		"VAULT_TOKEN":                            true,
+		"VAULT_ADDR":                             true,
+		"AWS_ACCESS_KEY_ID":                      true,
+		"AWS_SECRET_ACCESS_KEY":                  true,
+		"AWS_CONTAINER_CREDENTIALS_RELATIVE_URI": true,
+		"aws_session_token":                      true,
+		"GOOGLE_APPLICATION_CREDENTIALS":         true,
+		"AWS_REGION":                             false,
+		"AWS_ACCESS_KEY_ID_OLD":                  false,
+		"VAULT":                                  false,
+		"PATH":                                   false,
+	}
+	for name, want := range tests {
+		if got := isDaemonCredential(name); got != want {
+			t.Errorf("isDaemonCredential(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,15 @@
+//go:build !windows
+// +build !windows
+
+package crontinuous
+
+import "os"
+
+// initService does nothing, there are only windows services
+func initService() {}
+
+// startService does nothing, init systems run crontinuous in the foreground
+func startService(stop chan<- os.Signal) {}
+
+// stopService does nothing
+func stopService() {}
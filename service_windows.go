@@ -0,0 +1,116 @@
+//go:build windows
+// +build windows
+
+package crontinuous
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"golang.org/x/sys/windows/svc"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Constants
+// --------------------------------------------------------------------------------------------
+
+const (
+	serviceName = "crontinuous"
+	// serviceStopTimeout is how long a stopping service waits for the service control manager to be told
+	serviceStopTimeout = 5 * time.Second
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Variables
+// --------------------------------------------------------------------------------------------
+
+// windowsServiceStopped is closed when the scheduler stopped, after the service control manager asked it to
+var windowsServiceStopped = make(chan struct{})
+
+// windowsServiceExited is closed when the service control manager was told the service stopped
+var windowsServiceExited chan struct{}
+
+// --------------------------------------------------------------------------------------------
+// ~ Struct
+// --------------------------------------------------------------------------------------------
+
+// windowsService runs crontinuous as a service of the service control manager
+type windowsService struct {
+	stop chan<- os.Signal
+}
+
+// --------------------------------------------------------------------------------------------
+// ~ Public methods
+// --------------------------------------------------------------------------------------------
+
+// Execute implements svc.Handler, stop and shutdown requests stop the scheduler like SIGTERM does
+func (s *windowsService) Execute(args []string, requests <-chan svc.ChangeRequest, status chan<- svc.Status) (bool, uint32) {
+	status <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+	for request := range requests {
+		switch request.Cmd {
+		case svc.Interrogate:
+			status <- request.CurrentStatus
+		case svc.Stop, svc.Shutdown:
+			status <- svc.Status{State: svc.StopPending}
+			s.stop <- syscall.SIGTERM
+			<-windowsServiceStopped
+			return false, 0
+		}
+	}
+	return false, 0
+}
+
+// --------------------------------------------------------------------------------------------
+// ~ Private methods
+// --------------------------------------------------------------------------------------------
+
+func isWindowsService() bool {
+	service, err := svc.IsWindowsService()
+	return err == nil && service
+}
+
+// initService logs to a file next to the default crontab when running as a service, services have no console
+func initService() {
+	if !isWindowsService() {
+		return
+	}
+	dir := filepath.Dir(defaultCrontab)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return
+	}
+	file, err := os.OpenFile(filepath.Join(dir, "crontinuous.log"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return
+	}
+	log.SetOutput(file)
+	log.SetFormatter(&log.TextFormatter{DisableColors: true})
+}
+
+// startService hands control to the service control manager when running as a service
+func startService(stop chan<- os.Signal) {
+	if !isWindowsService() {
+		return
+	}
+	windowsServiceExited = make(chan struct{})
+	go func() {
+		defer close(windowsServiceExited)
+		if err := svc.Run(serviceName, &windowsService{stop: stop}); err != nil {
+			log.Error("failed to run as windows service: ", err)
+		}
+	}()
+}
+
+// stopService tells the service control manager that the service stopped before crontinuous exits
+func stopService() {
+	if windowsServiceExited == nil {
+		return
+	}
+	close(windowsServiceStopped)
+	select {
+	case <-windowsServiceExited:
+	case <-time.After(serviceStopTimeout):
+	}
+}
@@ -0,0 +1,146 @@
+package crontinuous
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Variables
+// --------------------------------------------------------------------------------------------
+
+var (
+	// setupOnce applies the options of CommandLine once for embedded schedulers
+	setupOnce sync.Once
+	setupErr  error
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Private methods
+// --------------------------------------------------------------------------------------------
+
+// setup applies the options of CommandLine and opens -state-dir, once for all schedulers of the process
+func setup() error {
+	setupOnce.Do(func() {
+		if setupErr = applyOptions(); setupErr == nil {
+			setupErr = openStateDir()
+		}
+	})
+	return setupErr
+}
+
+// applyOptions applies the options of CommandLine shared by the daemon, its subcommands and embedded schedulers
+func applyOptions() error {
+	var err error
+	if *timezone != "" {
+		location, err := time.LoadLocation(*timezone)
+		if err != nil {
+			return err
+		}
+		schedulerLocation = location
+	}
+
+	if onlyTagsFilter, err = parseTags(*onlyTags); err != nil {
+		return fmt.Errorf("invalid -only-tags: %s", err)
+	}
+	if skipTagsFilter, err = parseTags(*skipTags); err != nil {
+		return fmt.Errorf("invalid -skip-tags: %s", err)
+	}
+	if defaultDSTPolicy, err = parseDSTPolicy(*dst); err != nil {
+		return err
+	}
+	if *holidayFiles != "" {
+		if err := loadHolidays(strings.Split(*holidayFiles, ",")); err != nil {
+			return fmt.Errorf("failed to read holidays: %s", err)
+		}
+	}
+
+	if *logBuffer < minLogBuffer {
+		return fmt.Errorf("-log-buffer has to be at least %d bytes", minLogBuffer)
+	}
+
+	policy, err := parseConcurrencyPolicy(*concurrency)
+	if err != nil {
+		return err
+	}
+	defaultConcurrency = policy
+
+	overflowPolicy, err := parseOverflowPolicy(*overflow)
+	if err != nil {
+		return err
+	}
+	if *queueSize < 0 {
+		return errors.New("-queue-size must not be negative")
+	}
+	pool = newWorkerPool(*maxConcurrent, overflowPolicy)
+	if tagPools, err = parseTagConcurrency(*tagConcurrency, overflowPolicy); err != nil {
+		return err
+	}
+
+	if *vaultAddr != "" {
+		secretSources["vault"] = newVaultClient(*vaultAddr, *vaultNamespace, *vaultTokenFile)
+	}
+	aws := newAWSClient(*awsRegion)
+	secretSources["aws-sm"] = newCachedSecretSource(&awsSecretsManager{aws}, *awsSecretsTTL)
+	secretSources["ssm"] = newCachedSecretSource(&awsParameterStore{aws}, *awsSecretsTTL)
+
+	backoff, err := parseBackoffPolicy(*retryBackoff)
+	if err != nil {
+		return err
+	}
+	defaultRetryBackoff = backoff
+	if *retries < 0 {
+		return errors.New("-retries must not be negative")
+	}
+	if *circuitBreaker < 0 {
+		return errors.New("-circuit-breaker must not be negative")
+	}
+
+	pullPolicy, err := parsePullPolicy(*dockerPull)
+	if err != nil {
+		return err
+	}
+	defaultPullPolicy = pullPolicy
+	return nil
+}
+
+// openStateDir opens the run history, the state and the audit log in -state-dir
+func openStateDir() error {
+	if *stateDir == "" {
+		if *catchUp > 0 {
+			log.Warn("catching up missed runs requires -state-dir")
+		}
+		return nil
+	}
+	h, err := openHistory(*stateDir)
+	if err != nil {
+		return err
+	}
+	h.maxAge, h.maxRecords = *historyMaxAge, *historyMax
+	if dropped, err := h.compact(); err != nil {
+		log.Error("failed to compact the history", err)
+	} else if dropped > 0 {
+		log.WithField("dropped", dropped).Info("dropped runs beyond the retention from the history")
+	}
+	runHistory = h
+	// statistics start off with the recorded runs
+	if records, err := h.query(historyFilter{}); err == nil {
+		seedStats(records)
+	}
+
+	state, err := loadState(*stateDir)
+	if err != nil {
+		return err
+	}
+	jobsState = state
+	// paused jobs stay paused, failures keep counting and missed runs are not notified about again
+	state.restore()
+
+	audit, err = openAuditLog(*stateDir)
+	return err
+}
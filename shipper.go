@@ -0,0 +1,227 @@
+package crontinuous
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Struct
+// --------------------------------------------------------------------------------------------
+
+// lokiShipper pushes a record per finished run to Grafana Loki
+type lokiShipper struct {
+	url    string
+	labels map[string]string
+}
+
+// fluentdShipper forwards a record per finished run to a Fluentd forward input
+type fluentdShipper struct {
+	addr   string
+	tag    string
+	labels map[string]string
+	conn   net.Conn
+	lock   sync.Mutex
+}
+
+// --------------------------------------------------------------------------------------------
+// ~ Public methods
+// --------------------------------------------------------------------------------------------
+
+// Notify implements Notifier
+func (l *lokiShipper) Notify(n *Notification) error {
+	if n.Event == EventStart {
+		return nil
+	}
+	line, err := json.Marshal(runRecord(n, nil))
+	if err != nil {
+		return err
+	}
+	stream := map[string]string{
+		"job":    n.Job.ID,
+		"status": string(n.Event),
+		"host":   n.Hostname,
+	}
+	for key, value := range l.labels {
+		stream[key] = value
+	}
+	payload, err := json.Marshal(map[string]interface{}{
+		"streams": []interface{}{
+			map[string]interface{}{
+				"stream": stream,
+				"values": [][]string{{strconv.FormatInt(n.Start.UnixNano(), 10), string(line)}},
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	return postJSON(strings.TrimRight(l.url, "/")+"/loki/api/v1/push", payload)
+}
+
+// Notify implements Notifier
+func (f *fluentdShipper) Notify(n *Notification) error {
+	if n.Event == EventStart {
+		return nil
+	}
+	// forward protocol message mode: [tag, time, record]
+	message := &bytes.Buffer{}
+	msgpackArrayHeader(message, 3)
+	msgpackString(message, f.tag)
+	msgpackValue(message, n.Start.Unix())
+	msgpackValue(message, runRecord(n, f.labels))
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.conn == nil {
+		conn, err := net.DialTimeout("tcp", f.addr, 10*time.Second)
+		if err != nil {
+			return err
+		}
+		f.conn = conn
+	}
+	f.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	if _, err := f.conn.Write(message.Bytes()); err != nil {
+		// reconnect with the next record
+		f.conn.Close()
+		f.conn = nil
+		return err
+	}
+	return nil
+}
+
+// --------------------------------------------------------------------------------------------
+// ~ Private methods
+// --------------------------------------------------------------------------------------------
+
+// parseLabels parses comma separated key=value pairs
+func parseLabels(value string) (map[string]string, error) {
+	labels := map[string]string{}
+	for _, pair := range strings.Split(value, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid label %q, expected key=value", pair)
+		}
+		labels[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+	return labels, nil
+}
+
+// runRecord returns the structured record shipped for a finished run
+func runRecord(n *Notification, labels map[string]string) map[string]interface{} {
+	record := map[string]interface{}{
+		"job":      n.Job.ID,
+		"runId":    n.RunID,
+		"attempt":  n.Attempt,
+		"hostname": n.Hostname,
+		"command":  n.Job.Command,
+		"args":     n.Job.Args,
+		"schedule": n.Job.Schedule,
+		"status":   string(n.Event),
+		"exitCode": n.ExitCode,
+		"start":    n.Start.Format(time.RFC3339Nano),
+		"duration": n.Duration.Seconds(),
+		"output":   n.Output,
+	}
+	if n.Err != nil {
+		record["error"] = n.Err.Error()
+	}
+	if n.Signal != "" {
+		record["signal"] = n.Signal
+	}
+	if n.OOMKilled {
+		record["oomKilled"] = "true"
+	}
+	if n.Waited > 0 {
+		record["waited"] = n.Waited.Seconds()
+	}
+	if n.measured() {
+		record["userCpu"] = n.UserCPU.Seconds()
+		record["systemCpu"] = n.SystemCPU.Seconds()
+		record["maxRss"] = n.MaxRSS
+	}
+	for key, value := range labels {
+		record[key] = value
+	}
+	return record
+}
+
+// msgpackValue encodes the few types used in run records
+func msgpackValue(buf *bytes.Buffer, v interface{}) {
+	switch value := v.(type) {
+	case string:
+		msgpackString(buf, value)
+	case int:
+		msgpackInt(buf, int64(value))
+	case int64:
+		msgpackInt(buf, value)
+	case float64:
+		buf.WriteByte(0xcb)
+		binary.Write(buf, binary.BigEndian, math.Float64bits(value))
+	case map[string]interface{}:
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		msgpackMapHeader(buf, len(keys))
+		for _, key := range keys {
+			msgpackString(buf, key)
+			msgpackValue(buf, value[key])
+		}
+	default:
+		msgpackString(buf, fmt.Sprint(value))
+	}
+}
+
+func msgpackInt(buf *bytes.Buffer, value int64) {
+	buf.WriteByte(0xd3)
+	binary.Write(buf, binary.BigEndian, value)
+}
+
+func msgpackString(buf *bytes.Buffer, value string) {
+	switch length := len(value); {
+	case length < 32:
+		buf.WriteByte(0xa0 | byte(length))
+	case length < 1<<8:
+		buf.WriteByte(0xd9)
+		buf.WriteByte(byte(length))
+	case length < 1<<16:
+		buf.WriteByte(0xda)
+		binary.Write(buf, binary.BigEndian, uint16(length))
+	default:
+		buf.WriteByte(0xdb)
+		binary.Write(buf, binary.BigEndian, uint32(length))
+	}
+	buf.WriteString(value)
+}
+
+func msgpackArrayHeader(buf *bytes.Buffer, length int) {
+	if length < 16 {
+		buf.WriteByte(0x90 | byte(length))
+		return
+	}
+	buf.WriteByte(0xdd)
+	binary.Write(buf, binary.BigEndian, uint32(length))
+}
+
+func msgpackMapHeader(buf *bytes.Buffer, length int) {
+	if length < 16 {
+		buf.WriteByte(0x80 | byte(length))
+		return
+	}
+	buf.WriteByte(0xdf)
+	binary.Write(buf, binary.BigEndian, uint32(length))
+}
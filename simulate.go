@@ -0,0 +1,267 @@
+package crontinuous
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Constants
+// --------------------------------------------------------------------------------------------
+
+const (
+	// maxSimulatedRuns stops simulations of windows too long for the schedules, e.g. @every 1s for a year
+	maxSimulatedRuns = 100000
+	// simulatedHistoryRuns is how many recent runs of a job its expected duration is averaged over
+	simulatedHistoryRuns = 20
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Struct
+// --------------------------------------------------------------------------------------------
+
+// simulatedRun is a run of a job in a simulation, which runs for its expected duration
+type simulatedRun struct {
+	upcomingRun
+	end time.Time
+	// note tells how the run meets runs of the job still running, if any
+	note string
+}
+
+// minuteLoad is the load of a minute in a simulation
+type minuteLoad struct {
+	minute  time.Time
+	starts  []string
+	running int
+}
+
+// --------------------------------------------------------------------------------------------
+// ~ Private methods
+// --------------------------------------------------------------------------------------------
+
+// simulateCommand implements `crontinuous simulate`, printing the runs of a window with the runs
+// meeting previous runs of their job still running and the busiest minutes
+func simulateCommand(args []string) int {
+	flags := flag.NewFlagSet("simulate", flag.ExitOnError)
+	from := flags.String("from", "now", "start of the window, e.g. now, 2016-11-01T00:00:00Z, 2016-11-01T00:00 or 00:00 (today)")
+	to := flags.String("to", "24h", "end of the window, same formats as -from or a duration after -from")
+	duration := flags.Duration("duration", time.Minute, "expected duration of jobs without history, the timeout of a job if shorter")
+	top := flags.Int("top", 10, "number of busiest minutes to show")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: crontinuous [-state-dir <dir>] simulate [options] [job-id...]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	start, err := parseOnceTime(*from)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "invalid -from:", err)
+		return 2
+	}
+	end, err := parseOnceTime(*to)
+	if d, durationErr := time.ParseDuration(*to); durationErr == nil {
+		end, err = start.Add(d), nil
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "invalid -to:", err)
+		return 2
+	}
+	if !end.After(start) {
+		fmt.Fprintln(os.Stderr, "-to has to be after -from")
+		return 2
+	}
+	paths, _, err := mirrorCrontabs(crontabs.paths)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	sources := newCrontabSources(&crontabPaths{paths: paths, prefixes: crontabs.prefixes})
+	if err := loadSources(sources, parseCrontab); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	jobs, errs, _ := mergeSources(sources)
+	for _, err := range errs {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	selected := []*Runnable{}
+	for _, r := range selectByTags(jobs) {
+		if flags.NArg() == 0 || containsString(flags.Args(), r.ID) {
+			selected = append(selected, r)
+		}
+	}
+
+	durations := map[string]time.Duration{}
+	if *stateDir != "" {
+		if durations, err = historyDurations(*stateDir); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+	expected := func(r *Runnable) time.Duration {
+		d, ok := durations[r.ID]
+		if !ok {
+			d = *duration
+		}
+		if r.Timeout > 0 && r.Timeout < d {
+			d = r.Timeout
+		}
+		return d
+	}
+
+	runs, err := simulateRuns(selected, start, end, expected)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "AT\tID\tSCHEDULE\tEXPECTED\tNOTE")
+	for _, run := range runs {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", run.at.Format(time.RFC3339), run.job.ID, run.job.Schedule, run.end.Sub(run.at), run.note)
+	}
+	w.Flush()
+
+	loads := minuteLoads(runs, start, end)
+	busiest := append([]*minuteLoad{}, loads...)
+	sort.SliceStable(busiest, func(i, j int) bool {
+		if busiest[i].running != busiest[j].running {
+			return busiest[i].running > busiest[j].running
+		}
+		return len(busiest[i].starts) > len(busiest[j].starts)
+	})
+	if len(busiest) > *top {
+		busiest = busiest[:*top]
+	}
+	fmt.Println()
+	fmt.Fprintln(w, "MINUTE\tRUNNING\tSTARTS\tJOBS STARTED")
+	for _, load := range busiest {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%s\n", load.minute.Format("2006-01-02 15:04"), load.running, len(load.starts), strings.Join(load.starts, ","))
+	}
+	w.Flush()
+
+	overlaps, started := 0, 0
+	for _, run := range runs {
+		if run.note != "" {
+			overlaps++
+		}
+	}
+	for _, load := range loads {
+		started += len(load.starts)
+	}
+	minutes := end.Sub(start).Minutes()
+	fmt.Printf("\n%d runs in %s, %d meeting a previous run, %.2f starts per minute on average\n", len(runs), end.Sub(start).Round(time.Minute), overlaps, float64(started)/minutes)
+	return 0
+}
+
+// simulateRuns returns the runs of the jobs from start up to end, noting how runs meet previous runs of
+// their job still running after the expected duration according to the concurrency policy of the job
+func simulateRuns(jobs []*Runnable, start time.Time, end time.Time, expected func(r *Runnable) time.Duration) ([]simulatedRun, error) {
+	runs := []simulatedRun{}
+	for _, r := range jobs {
+		if r.schedule == nil || r.Disabled {
+			continue
+		}
+		d := expected(r)
+		running := []int{}
+		for next := r.schedule.Next(start.Add(-time.Nanosecond).In(schedulerLocation)); !next.IsZero() && next.Before(end); next = r.schedule.Next(next) {
+			if len(runs) >= maxSimulatedRuns {
+				return nil, fmt.Errorf("more than %d runs, simulate a shorter window or fewer jobs", maxSimulatedRuns)
+			}
+			run := simulatedRun{upcomingRun: upcomingRun{at: next, job: r}, end: next.Add(d)}
+			active := running[:0]
+			for _, i := range running {
+				if runs[i].end.After(next) {
+					active = append(active, i)
+				}
+			}
+			running = active
+			if len(running) > 0 {
+				switch r.Concurrency {
+				case ConcurrencyForbid:
+					run.note = "skipped, previous run still running"
+					run.end = next
+				case ConcurrencyReplace:
+					run.note = "replaces previous run"
+					for _, i := range running {
+						runs[i].end = next
+					}
+					running = running[:0]
+				default:
+					run.note = fmt.Sprintf("overlaps %d previous run(s)", len(running))
+				}
+			}
+			if run.end.After(run.at) {
+				running = append(running, len(runs))
+			}
+			runs = append(runs, run)
+		}
+	}
+	sort.SliceStable(runs, func(i, j int) bool {
+		return runs[i].at.Before(runs[j].at)
+	})
+	return runs, nil
+}
+
+// minuteLoads returns the runs started and running in the minutes from start up to end having any
+func minuteLoads(runs []simulatedRun, start time.Time, end time.Time) []*minuteLoad {
+	loads := map[time.Time]*minuteLoad{}
+	load := func(minute time.Time) *minuteLoad {
+		l, ok := loads[minute]
+		if !ok {
+			l = &minuteLoad{minute: minute}
+			loads[minute] = l
+		}
+		return l
+	}
+	for _, run := range runs {
+		if !run.end.After(run.at) {
+			// skipped runs do not start
+			continue
+		}
+		first := run.at.Truncate(time.Minute)
+		load(first).starts = append(load(first).starts, run.job.ID)
+		for minute := first; minute.Before(run.end) && minute.Before(end); minute = minute.Add(time.Minute) {
+			load(minute).running++
+		}
+	}
+	list := []*minuteLoad{}
+	for _, l := range loads {
+		if !l.minute.Before(start.Truncate(time.Minute)) {
+			list = append(list, l)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].minute.Before(list[j].minute)
+	})
+	return list
+}
+
+// historyDurations returns the average duration of the recent runs of the jobs in the history
+func historyDurations(stateDir string) (map[string]time.Duration, error) {
+	h, err := openHistory(stateDir)
+	if err != nil {
+		return nil, err
+	}
+	records, err := h.query(historyFilter{})
+	if err != nil {
+		return nil, err
+	}
+	totals := map[string]time.Duration{}
+	counts := map[string]int{}
+	for _, record := range records {
+		if counts[record.JobID] < simulatedHistoryRuns {
+			totals[record.JobID] += record.FinishedAt.Sub(record.StartedAt)
+			counts[record.JobID]++
+		}
+	}
+	durations := map[string]time.Duration{}
+	for id, total := range totals {
+		durations[id] = total / time.Duration(counts[id])
+	}
+	return durations, nil
+}
@@ -0,0 +1,59 @@
+package crontinuous
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSimulateRuns(t *testing.T) {
+	crontab := "# name: sync\n*/10 * * * * sync\n" +
+		"# name: report\n# concurrency: forbid\n*/10 * * * * report\n" +
+		"# name: import\n# concurrency: replace\n*/10 * * * * import\n" +
+		"# name: backup\n0 * * * * backup\n"
+	jobs, errs := parseCrontabReader("simulate", strings.NewReader(crontab), nil)
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	start := time.Date(2016, 11, 1, 3, 0, 0, 0, schedulerLocation)
+	end := start.Add(30 * time.Minute)
+	runs, err := simulateRuns(jobs, start, end, func(r *Runnable) time.Duration {
+		if r.ID == "backup" {
+			return 5 * time.Minute
+		}
+		return 15 * time.Minute
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	notes := map[string][]string{}
+	for _, run := range runs {
+		notes[run.job.ID] = append(notes[run.job.ID], run.note)
+	}
+	for id, want := range map[string][]string{
+		"sync":   {"", "overlaps 1 previous run(s)", "overlaps 1 previous run(s)"},
+		"report": {"", "skipped, previous run still running", ""},
+		"import": {"", "replaces previous run", "replaces previous run"},
+		"backup": {""},
+	} {
+		if strings.Join(notes[id], "|") != strings.Join(want, "|") {
+			t.Errorf("%s: got notes %q, want %q", id, notes[id], want)
+		}
+	}
+
+	loads := minuteLoads(runs, start, end)
+	if first := loads[0]; !first.minute.Equal(start) || first.running != 4 || len(first.starts) != 4 {
+		t.Errorf("unexpected load of the first minute %+v", first)
+	}
+	// at 03:10 sync runs twice, report once as its second run is skipped, import once as it was replaced
+	for _, load := range loads {
+		if load.minute.Equal(start.Add(10*time.Minute)) && (load.running != 4 || len(load.starts) != 2) {
+			t.Errorf("unexpected load at 03:10 %+v", load)
+		}
+	}
+
+	if _, err := simulateRuns(jobs, start, start.AddDate(10, 0, 0), func(r *Runnable) time.Duration { return 0 }); err == nil {
+		t.Error("expected an error for too many runs")
+	}
+}
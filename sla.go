@@ -0,0 +1,35 @@
+package crontinuous
+
+import (
+	"fmt"
+	"time"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Private methods
+// --------------------------------------------------------------------------------------------
+
+// watchSLA notifies once if an attempt takes longer than the expected duration of the job,
+// unlike the timeout the run goes on, the returned func stops watching
+func (r *Runnable) watchSLA(e *execution, attempt int) func() {
+	if r.SLA <= 0 {
+		return func() {}
+	}
+	start := time.Now()
+	timer := time.AfterFunc(r.SLA, func() {
+		err := fmt.Errorf("run takes longer than %s", r.SLA)
+		e.logger.Warn(err)
+		r.notify(&Notification{
+			Event:    EventSlow,
+			RunID:    e.id,
+			Attempt:  attempt,
+			Start:    start,
+			ExitCode: -1,
+			Err:      err,
+			Revision: e.revision,
+		})
+	})
+	return func() {
+		timer.Stop()
+	}
+}
@@ -0,0 +1,233 @@
+package crontinuous
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Struct
+// --------------------------------------------------------------------------------------------
+
+// socketListener only accepts connections of processes allowed to control crontinuous
+type socketListener struct {
+	net.Listener
+	// gid is the group allowed next to root and our user, -1 if none
+	gid int
+}
+
+// --------------------------------------------------------------------------------------------
+// ~ Public methods
+// --------------------------------------------------------------------------------------------
+
+// Accept implements net.Listener, rejected connections are closed right away
+func (l *socketListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		if err := authorizePeer(conn, l.gid); err != nil {
+			log.Warn("rejected control socket connection: ", err)
+			conn.Close()
+			continue
+		}
+		return conn, nil
+	}
+}
+
+// --------------------------------------------------------------------------------------------
+// ~ Private methods
+// --------------------------------------------------------------------------------------------
+
+// startSocketAPI serves the admin api on a unix socket, which the cli subcommands and crontinuousctl talk to
+func startSocketAPI(path string) {
+	// remove a stale socket of a previous instance
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		log.WithField("socket", path).Warn("failed to remove stale control socket", err)
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		log.WithField("socket", path).Warn("control socket disabled", err)
+		return
+	}
+	gid, mode := -1, os.FileMode(0600)
+	if *socketGroup != "" {
+		group, err := lookupGroup(*socketGroup)
+		if err == nil {
+			gid, err = strconv.Atoi(group.Gid)
+		}
+		if err == nil {
+			err = os.Chown(path, -1, gid)
+		}
+		if err != nil {
+			log.WithField("socket", path).Fatal("failed to hand the control socket to its group ", err)
+		}
+		mode = 0660
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		log.WithField("socket", path).Warn("failed to restrict control socket permissions", err)
+	}
+	log.WithField("socket", path).Info("starting control socket")
+	go func() {
+		if err := http.Serve(&socketListener{Listener: listener, gid: gid}, apiHandler()); err != nil {
+			log.WithField("socket", path).Error(err)
+		}
+	}()
+}
+
+// socketClient returns a http client talking to the daemon's control socket
+func socketClient() *http.Client {
+	return &http.Client{
+		Timeout: 30 * time.Second,
+		Transport: &http.Transport{
+			Dial: func(network, addr string) (net.Conn, error) {
+				return net.Dial("unix", *socket)
+			},
+		},
+	}
+}
+
+// socketRequest sends a request to the daemon and decodes the JSON response into v if not nil
+func socketRequest(method string, path string, v interface{}) error {
+	req, err := http.NewRequest(method, "http://crontinuous"+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Requested-With", "crontinuous")
+	resp, err := socketClient().Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach crontinuous on %s: %s", *socket, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	if v == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// runCommand implements `crontinuous run <job-id>` and `crontinuous run -tag <tag>`
+func runCommand(args []string) int {
+	return jobCommand("run", "started", args)
+}
+
+// pauseCommand implements `crontinuous pause <job-id>` and `crontinuous resume <job-id>`, or -tag <tag> for all jobs with a tag
+func pauseCommand(args []string, paused bool) int {
+	if paused {
+		return jobCommand("pause", "paused", args)
+	}
+	return jobCommand("resume", "resumed", args)
+}
+
+// jobCommand posts an action to a job or to all jobs with a tag
+func jobCommand(action string, done string, args []string) int {
+	flags := flag.NewFlagSet(action, flag.ExitOnError)
+	tag := flags.String("tag", "", action+" all jobs with this tag")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: crontinuous [-socket <path>] %s <job-id> | -tag <tag>\n", action)
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if (*tag == "") == (flags.NArg() == 0) || flags.NArg() > 1 {
+		flags.Usage()
+		return 2
+	}
+
+	if *tag != "" {
+		reply := struct {
+			Jobs []string `json:"jobs"`
+		}{}
+		if err := socketRequest("POST", "/tags/"+url.PathEscape(*tag)+"/"+action, &reply); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		for _, id := range reply.Jobs {
+			fmt.Printf("%s job %s\n", done, id)
+		}
+		return 0
+	}
+	if err := socketRequest("POST", "/jobs/"+url.PathEscape(flags.Arg(0))+"/"+action, nil); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Printf("%s job %s\n", done, flags.Arg(0))
+	return 0
+}
+
+// listCommand implements `crontinuous list`, printing the jobs of the running daemon
+func listCommand(args []string) int {
+	flags := flag.NewFlagSet("list", flag.ExitOnError)
+	tag := flags.String("tag", "", "list the jobs with this tag only")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: crontinuous [-socket <path>] list [-tag <tag>]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 0 {
+		flags.Usage()
+		return 2
+	}
+	statuses := []JobStatus{}
+	if err := socketRequest("GET", "/jobs?tag="+url.QueryEscape(*tag), &statuses); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSCHEDULE\tSTATUS\tEXIT\tPREV\tNEXT\tTAGS\tCOMMAND")
+	for _, status := range statuses {
+		lastStatus, lastExit := "-", "-"
+		if status.IsRunning {
+			lastStatus = "running"
+		} else if status.Disabled {
+			lastStatus = "disabled"
+		} else if status.Paused {
+			lastStatus = "paused"
+		} else if status.LastStatus != "" {
+			lastStatus = string(status.LastStatus)
+		}
+		if status.LastStatus != "" {
+			lastExit = fmt.Sprint(status.LastExit)
+		}
+		tags := "-"
+		if len(status.Tags) > 0 {
+			tags = strings.Join(status.Tags, ",")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s %s\n",
+			status.ID,
+			status.Schedule,
+			lastStatus,
+			lastExit,
+			formatListTime(status.Prev),
+			formatListTime(status.Next),
+			tags,
+			status.Command,
+			status.Args,
+		)
+	}
+	w.Flush()
+	return 0
+}
+
+func formatListTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Format(time.RFC3339)
+}
@@ -0,0 +1,56 @@
+//go:build linux
+// +build linux
+
+package crontinuous
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"strconv"
+	"syscall"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Private methods
+// --------------------------------------------------------------------------------------------
+
+// authorizePeer allows root, the user crontinuous runs as and members of the socket group
+// to use the control socket, going by the credentials of the connecting process
+func authorizePeer(conn net.Conn, gid int) error {
+	unixConn, ok := conn.(*net.UnixConn)
+	if !ok {
+		return fmt.Errorf("not a unix socket connection")
+	}
+	raw, err := unixConn.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var cred *syscall.Ucred
+	var credErr error
+	if err := raw.Control(func(fd uintptr) {
+		cred, credErr = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	}); err != nil {
+		return err
+	}
+	if credErr != nil {
+		return credErr
+	}
+
+	if cred.Uid == 0 || int(cred.Uid) == os.Getuid() {
+		return nil
+	}
+	if gid >= 0 {
+		if int(cred.Gid) == gid {
+			return nil
+		}
+		if u, err := user.LookupId(strconv.Itoa(int(cred.Uid))); err == nil {
+			groupIDs, _ := u.GroupIds()
+			if containsString(groupIDs, strconv.Itoa(gid)) {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("uid %d pid %d is not allowed to use the control socket", cred.Uid, cred.Pid)
+}
@@ -0,0 +1,151 @@
+package crontinuous
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Struct
+// --------------------------------------------------------------------------------------------
+
+// crontabSource is one of the crontabs given with -crontab, it is only parsed again when it changes
+type crontabSource struct {
+	path string
+	// prefix is put in front of the ids of its jobs
+	prefix      string
+	fingerprint string
+	loaded      bool
+	jobs        []*Runnable
+	errs        []error
+}
+
+// --------------------------------------------------------------------------------------------
+// ~ Variables
+// --------------------------------------------------------------------------------------------
+
+var (
+	// sourcePrefixRegexp matches crontabs given as <prefix>=<crontab>
+	sourcePrefixRegexp = regexp.MustCompile(`^([A-Za-z0-9][A-Za-z0-9._-]*)=(.+)$`)
+	// loadedSources keep the jobs of the crontabs between loads
+	loadedSources []*crontabSource
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Private methods
+// --------------------------------------------------------------------------------------------
+
+// newCrontabSources returns the sources of the given crontabs in the order of their precedence
+func newCrontabSources(paths *crontabPaths) []*crontabSource {
+	sources := []*crontabSource{}
+	for i, path := range paths.paths {
+		sources = append(sources, &crontabSource{path: path, prefix: paths.prefixes[i]})
+	}
+	return sources
+}
+
+// load parses the crontab if it changed since the last load and reports whether it did. A crontab
+// that is missing keeps its jobs until it is back, as it may be replaced at the moment.
+func (s *crontabSource) load(parse func(filename string) ([]*Runnable, []error)) (bool, error) {
+	current := fingerprint([]string{s.path})
+	if s.loaded && current == s.fingerprint {
+		return false, nil
+	}
+	filenames, err := crontabFiles([]string{s.path})
+	if err != nil {
+		if s.loaded && os.IsNotExist(err) {
+			log.WithField("crontab", s.origin()).Warn("crontab is missing, keeping its jobs until it is back")
+			return false, nil
+		}
+		return false, err
+	}
+	jobs := []*Runnable{}
+	errs := []error{}
+	for _, filename := range filenames {
+		parsed, parseErrs := parse(filename)
+		jobs = append(jobs, parsed...)
+		errs = append(errs, parseErrs...)
+	}
+	applyPrefix(jobs, s.prefix)
+
+	s.fingerprint = current
+	s.loaded = true
+	s.jobs = jobs
+	s.errs = errs
+	return true, nil
+}
+
+// origin returns where the crontab comes from for logs
+func (s *crontabSource) origin() string {
+	for _, remote := range remoteCrontabs {
+		if remote.local() == s.path {
+			return remote.origin()
+		}
+	}
+	return s.path
+}
+
+// applyPrefix prefixes the ids of the jobs of a crontab, dependencies on jobs of the same crontab included
+func applyPrefix(jobs []*Runnable, prefix string) {
+	if prefix == "" {
+		return
+	}
+	ids := map[string]bool{}
+	for _, r := range jobs {
+		ids[r.ID] = true
+	}
+	for _, r := range jobs {
+		r.ID = prefix + r.ID
+		r.contextLogger = r.contextLogger.WithField("id", r.ID)
+		for i, id := range r.After {
+			if ids[id] {
+				r.After[i] = prefix + id
+			}
+		}
+	}
+}
+
+// mergeSources combines the jobs of the crontabs and returns them with the problems of the crontabs and
+// the jobs of a single crontab sharing an id. A job of a later crontab overrides the job of an earlier
+// one with the same id, e.g. defaults of a base image extended by an application.
+func mergeSources(sources []*crontabSource) ([]*Runnable, []error, []error) {
+	overrides := map[string]*crontabSource{}
+	for _, source := range sources {
+		for _, r := range source.jobs {
+			overrides[r.ID] = source
+		}
+	}
+	jobs := []*Runnable{}
+	errs := []error{}
+	duplicates := []error{}
+	for _, source := range sources {
+		for _, r := range source.jobs {
+			if by := overrides[r.ID]; by != source {
+				r.contextLogger.WithField("crontab", by.origin()).Info("job is overridden by a later crontab")
+				continue
+			}
+			jobs = append(jobs, r)
+		}
+		errs = append(errs, source.errs...)
+		duplicates = append(duplicates, duplicateIDs(source.jobs)...)
+	}
+	return jobs, errs, duplicates
+}
+
+// loadSources loads the crontabs that changed, logging which ones did on reloads
+func loadSources(sources []*crontabSource, parse func(filename string) ([]*Runnable, []error)) error {
+	for _, source := range sources {
+		reload := source.loaded
+		changed, err := source.load(parse)
+		if err != nil {
+			return fmt.Errorf("failed to load crontab %s: %s", source.origin(), err)
+		}
+		if changed && reload {
+			log.WithField("crontab", source.origin()).Info("crontab updated")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package crontinuous
+
+import (
+	"testing"
+)
+
+func TestMergeSources(t *testing.T) {
+	backup := createRunnable("/bin/backup", "", "0 0 3 * * *")
+	backup.ID = "backup"
+	cleanup := createRunnable("/bin/cleanup", "", "0 0 4 * * *")
+	cleanup.ID = "cleanup"
+	cleanup.After = []string{"backup", "other"}
+	base := &crontabSource{path: "base", prefix: "base.", jobs: []*Runnable{backup, cleanup}}
+	applyPrefix(base.jobs, base.prefix)
+	if backup.ID != "base.backup" || cleanup.ID != "base.cleanup" {
+		t.Errorf("ids were not prefixed, got %s and %s", backup.ID, cleanup.ID)
+	}
+	if cleanup.After[0] != "base.backup" || cleanup.After[1] != "other" {
+		t.Errorf("only dependencies on jobs of the same crontab must be prefixed, got %v", cleanup.After)
+	}
+
+	override := createRunnable("/bin/backup", "--full", "0 0 2 * * *")
+	override.ID = "base.backup"
+	duplicate := createRunnable("/bin/report", "", "0 0 5 * * *")
+	duplicate.ID = "report"
+	duplicateAgain := createRunnable("/bin/report", "--weekly", "0 0 5 * * 1")
+	duplicateAgain.ID = "report"
+	app := &crontabSource{path: "app", jobs: []*Runnable{override, duplicate, duplicateAgain}}
+
+	jobs, _, duplicates := mergeSources([]*crontabSource{base, app})
+	if len(jobs) != 4 || jobs[0] != cleanup || jobs[1] != override {
+		t.Errorf("later crontab did not override the job, got %v", jobs)
+	}
+	if len(duplicates) != 1 {
+		t.Errorf("expected the duplicate in a single crontab, got %v", duplicates)
+	}
+}
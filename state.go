@@ -0,0 +1,222 @@
+package crontinuous
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Constants
+// --------------------------------------------------------------------------------------------
+
+const stateFilename = "state.json"
+
+// --------------------------------------------------------------------------------------------
+// ~ Struct
+// --------------------------------------------------------------------------------------------
+
+// schedulerState is the state that survives restarts, kept as JSON in the state directory
+type schedulerState struct {
+	Jobs     map[string]*jobState `json:"jobs"`
+	filename string
+	lock     sync.Mutex
+}
+
+// jobState is the persisted state of a single job
+type jobState struct {
+	LastRun      time.Time `json:"lastRun"`
+	LastStatus   Event     `json:"lastStatus,omitempty"`
+	LastFinished time.Time `json:"lastFinished,omitempty"`
+	// Failures counts the consecutive failed runs, Paused the pause flag and MissedSlot the
+	// run the dead man's switch notified about last
+	Failures   int       `json:"failures,omitempty"`
+	Paused     bool      `json:"paused,omitempty"`
+	MissedSlot time.Time `json:"missedSlot,omitempty"`
+	// Suspended is when the circuit breaker suspended the job, zero if it does not
+	Suspended time.Time `json:"suspended,omitempty"`
+}
+
+// --------------------------------------------------------------------------------------------
+// ~ Private methods
+// --------------------------------------------------------------------------------------------
+
+func loadState(stateDir string) (*schedulerState, error) {
+	s := &schedulerState{
+		Jobs:     map[string]*jobState{},
+		filename: filepath.Join(stateDir, stateFilename),
+	}
+	data, err := ioutil.ReadFile(s.filename)
+	if os.IsNotExist(err) {
+		return s, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, err
+	}
+	if s.Jobs == nil {
+		s.Jobs = map[string]*jobState{}
+	}
+	return s, nil
+}
+
+// lastRun returns when a job was run last, zero if unknown
+func (s *schedulerState) lastRun(id string) time.Time {
+	if s == nil {
+		return time.Time{}
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if job, ok := s.Jobs[id]; ok {
+		return job.LastRun
+	}
+	return time.Time{}
+}
+
+// setLastRun records a job's run and persists the state
+func (s *schedulerState) setLastRun(id string, t time.Time) error {
+	if s == nil {
+		return nil
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	job, ok := s.Jobs[id]
+	if !ok {
+		job = &jobState{}
+		s.Jobs[id] = job
+	}
+	job.LastRun = t
+	return s.save()
+}
+
+// lastResult returns the outcome of a job's most recent run, false if unknown
+func (s *schedulerState) lastResult(id string) (runResult, bool) {
+	if s == nil {
+		return runResult{}, false
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if job, ok := s.Jobs[id]; ok && job.LastStatus != "" {
+		return runResult{Event: job.LastStatus, Finished: job.LastFinished}, true
+	}
+	return runResult{}, false
+}
+
+// setLastResult records the outcome of a job's run and persists the state
+func (s *schedulerState) setLastResult(id string, result runResult) error {
+	if s == nil {
+		return nil
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	job, ok := s.Jobs[id]
+	if !ok {
+		job = &jobState{}
+		s.Jobs[id] = job
+	}
+	job.LastStatus = result.Event
+	job.LastFinished = result.Finished
+	return s.save()
+}
+
+// setFailures records the consecutive failed runs of a job and persists the state
+func (s *schedulerState) setFailures(id string, failures int) error {
+	if s == nil {
+		return nil
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.job(id).Failures = failures
+	return s.save()
+}
+
+// setPaused records whether a job is paused and persists the state
+func (s *schedulerState) setPaused(id string, paused bool) error {
+	if s == nil {
+		return nil
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.job(id).Paused = paused
+	return s.save()
+}
+
+// setMissedSlot records the run the dead man's switch notified about and persists the state
+func (s *schedulerState) setMissedSlot(id string, slot time.Time) error {
+	if s == nil {
+		return nil
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.job(id).MissedSlot = slot
+	return s.save()
+}
+
+// setSuspended records when the circuit breaker suspended a job, zero if it does not, and persists the state
+func (s *schedulerState) setSuspended(id string, since time.Time) error {
+	if s == nil {
+		return nil
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.job(id).Suspended = since
+	return s.save()
+}
+
+// restore takes over the pause flags, failure counters, suspensions and missed runs of the jobs after a restart
+func (s *schedulerState) restore() {
+	if s == nil {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for id, job := range s.Jobs {
+		if job.Paused {
+			pausedJobsLock.Lock()
+			pausedJobs[id] = true
+			pausedJobsLock.Unlock()
+		}
+		if job.Failures > 0 {
+			failureCountsLock.Lock()
+			failureCounts[id] = job.Failures
+			failureCountsLock.Unlock()
+		}
+		if !job.Suspended.IsZero() {
+			suspendedJobsLock.Lock()
+			suspendedJobs[id] = job.Suspended
+			suspendedJobsLock.Unlock()
+		}
+		if !job.MissedSlot.IsZero() {
+			deadmanLock.Lock()
+			missedSlots[id] = job.MissedSlot
+			deadmanLock.Unlock()
+		}
+	}
+}
+
+// job returns the state of a job, adding it if there is none yet, with the lock held
+func (s *schedulerState) job(id string) *jobState {
+	job, ok := s.Jobs[id]
+	if !ok {
+		job = &jobState{}
+		s.Jobs[id] = job
+	}
+	return job
+}
+
+// save writes the state to a temporary file first, so a crash never leaves a broken state behind
+func (s *schedulerState) save() error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.filename + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.filename)
+}
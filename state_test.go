@@ -0,0 +1,76 @@
+package crontinuous
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+func TestStateRestore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "crontinuous-state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	previous := jobsState
+	defer func() { jobsState = previous }()
+	defer func() {
+		setPaused("restored", false)
+		failureCountsLock.Lock()
+		delete(failureCounts, "restored")
+		failureCountsLock.Unlock()
+		deadmanLock.Lock()
+		delete(missedSlots, "restored")
+		deadmanLock.Unlock()
+	}()
+
+	if jobsState, err = loadState(dir); err != nil {
+		t.Fatal(err)
+	}
+	job := &Runnable{ID: "restored", contextLogger: log.WithField("id", "restored")}
+	setPaused(job.ID, true)
+	for i := 0; i < 3; i++ {
+		countFailures(&Notification{Job: job, Event: EventFailure})
+	}
+	slot := time.Date(2016, 11, 1, 3, 0, 0, 0, time.UTC)
+	if err := jobsState.setMissedSlot(job.ID, slot); err != nil {
+		t.Fatal(err)
+	}
+
+	// a restart starts over in memory
+	pausedJobsLock.Lock()
+	delete(pausedJobs, job.ID)
+	pausedJobsLock.Unlock()
+	failureCountsLock.Lock()
+	delete(failureCounts, job.ID)
+	failureCountsLock.Unlock()
+	if jobsState, err = loadState(dir); err != nil {
+		t.Fatal(err)
+	}
+	jobsState.restore()
+	if !isPaused(job.ID) {
+		t.Error("expected the job to stay paused")
+	}
+	if failures := consecutiveFailures(job.ID); failures != 3 {
+		t.Errorf("expected 3 failures, got %d", failures)
+	}
+	deadmanLock.Lock()
+	missed := missedSlots[job.ID]
+	deadmanLock.Unlock()
+	if !missed.Equal(slot) {
+		t.Errorf("expected the missed run %s, got %s", slot, missed)
+	}
+
+	// a success starts over, resuming is persisted as well
+	countFailures(&Notification{Job: job, Event: EventSuccess})
+	setPaused(job.ID, false)
+	if jobsState, err = loadState(dir); err != nil {
+		t.Fatal(err)
+	}
+	if state := jobsState.Jobs[job.ID]; state.Failures != 0 || state.Paused {
+		t.Errorf("expected no failures and not paused, got %+v", state)
+	}
+}
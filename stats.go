@@ -0,0 +1,161 @@
+package crontinuous
+
+import (
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Constants
+// --------------------------------------------------------------------------------------------
+
+// statsRuns is how many recent runs of a job its statistics cover
+const statsRuns = 100
+
+// --------------------------------------------------------------------------------------------
+// ~ Struct
+// --------------------------------------------------------------------------------------------
+
+// JobStats are the statistics of the recent runs of a job as exposed by the admin API, durations
+// and cpu times in seconds, memory in bytes. Cpu and memory are left out for jobs without local process.
+type JobStats struct {
+	ID       string      `json:"id"`
+	Runs     int         `json:"runs"`
+	Duration *ValueStats `json:"duration,omitempty"`
+	CPU      *ValueStats `json:"cpu,omitempty"`
+	MaxRSS   *ValueStats `json:"maxRss,omitempty"`
+}
+
+// ValueStats summarizes a value of the recent runs of a job, Recent is the mean of the latest tenth
+// of them, which is above Mean for jobs getting slower
+type ValueStats struct {
+	Min    float64 `json:"min"`
+	Max    float64 `json:"max"`
+	Mean   float64 `json:"mean"`
+	P95    float64 `json:"p95"`
+	Last   float64 `json:"last"`
+	Recent float64 `json:"recent"`
+}
+
+// runSample is what the statistics keep of a run
+type runSample struct {
+	duration time.Duration
+	cpu      time.Duration
+	maxRSS   int64
+	// measured is false for runs without local process, which do not tell their resource usage
+	measured bool
+}
+
+// --------------------------------------------------------------------------------------------
+// ~ Variables
+// --------------------------------------------------------------------------------------------
+
+var (
+	// runSamples are kept by id like lastResults, so statistics survive reloads of the crontab
+	runSamples     = map[string][]runSample{}
+	runSamplesLock sync.Mutex
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Private methods
+// --------------------------------------------------------------------------------------------
+
+// recordStats adds a finished run to the statistics of its job
+func recordStats(n *Notification) {
+	addSample(n.Job.ID, runSample{duration: n.Duration, cpu: n.UserCPU + n.SystemCPU, maxRSS: n.MaxRSS, measured: n.measured()})
+}
+
+// seedStats starts the statistics off with the runs of the history, latest first as queried
+func seedStats(records []RunRecord) {
+	for i := len(records) - 1; i >= 0; i-- {
+		record := records[i]
+		cpu := time.Duration((record.UserCPU + record.SystemCPU) * float64(time.Second))
+		addSample(record.JobID, runSample{
+			duration: record.FinishedAt.Sub(record.StartedAt),
+			cpu:      cpu,
+			maxRSS:   record.MaxRSS,
+			measured: cpu > 0 || record.MaxRSS > 0,
+		})
+	}
+}
+
+func addSample(id string, sample runSample) {
+	runSamplesLock.Lock()
+	defer runSamplesLock.Unlock()
+	samples := append(runSamples[id], sample)
+	if len(samples) > statsRuns {
+		samples = append([]runSample{}, samples[len(samples)-statsRuns:]...)
+	}
+	runSamples[id] = samples
+}
+
+// jobStats returns the statistics of the recent runs of a job
+func jobStats(id string) JobStats {
+	runSamplesLock.Lock()
+	samples := append([]runSample{}, runSamples[id]...)
+	runSamplesLock.Unlock()
+
+	stats := JobStats{ID: id, Runs: len(samples)}
+	durations, cpu, maxRSS := []float64{}, []float64{}, []float64{}
+	for _, sample := range samples {
+		durations = append(durations, sample.duration.Seconds())
+		if sample.measured {
+			cpu = append(cpu, sample.cpu.Seconds())
+			maxRSS = append(maxRSS, float64(sample.maxRSS))
+		}
+	}
+	stats.Duration = valueStats(durations)
+	stats.CPU = valueStats(cpu)
+	stats.MaxRSS = valueStats(maxRSS)
+	return stats
+}
+
+// valueStats summarizes values in the order of the runs, nil if there are none
+func valueStats(values []float64) *ValueStats {
+	if len(values) == 0 {
+		return nil
+	}
+	stats := &ValueStats{Last: values[len(values)-1]}
+	recent := int(math.Ceil(float64(len(values)) / 10))
+	sum, recentSum := 0.0, 0.0
+	for i, value := range values {
+		sum += value
+		if i >= len(values)-recent {
+			recentSum += value
+		}
+	}
+	stats.Mean = sum / float64(len(values))
+	stats.Recent = recentSum / float64(recent)
+
+	sorted := append([]float64{}, values...)
+	sort.Float64s(sorted)
+	stats.Min, stats.Max = sorted[0], sorted[len(sorted)-1]
+	// nearest rank
+	stats.P95 = sorted[int(math.Ceil(0.95*float64(len(sorted))))-1]
+	return stats
+}
+
+// handleStats lists the statistics of all loaded jobs
+func handleStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	stats := []JobStats{}
+	for _, status := range jobStatuses() {
+		stats = append(stats, jobStats(status.ID))
+	}
+	jsonReply(w, stats)
+}
+
+// handleJobStats returns the statistics of a job
+func handleJobStats(w http.ResponseWriter, r *http.Request, id string) {
+	if findJob(id) == nil {
+		http.NotFound(w, r)
+		return
+	}
+	jsonReply(w, jobStats(id))
+}
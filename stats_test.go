@@ -0,0 +1,50 @@
+package crontinuous
+
+import (
+	"testing"
+	"time"
+)
+
+func TestJobStats(t *testing.T) {
+	job := &Runnable{ID: "stats"}
+	defer func() {
+		runSamplesLock.Lock()
+		delete(runSamples, job.ID)
+		runSamplesLock.Unlock()
+	}()
+	if stats := jobStats(job.ID); stats.Runs != 0 || stats.Duration != nil {
+		t.Errorf("expected no statistics without runs, got %+v", stats)
+	}
+
+	seedStats([]RunRecord{
+		{JobID: job.ID, StartedAt: time.Unix(100, 0), FinishedAt: time.Unix(102, 0)},
+		{JobID: job.ID, StartedAt: time.Unix(0, 0), FinishedAt: time.Unix(1, 0)},
+	})
+	for i := 3; i <= statsRuns+2; i++ {
+		recordStats(&Notification{Job: job, Duration: time.Duration(i) * time.Second, UserCPU: time.Second, MaxRSS: int64(i) << 20})
+	}
+	// the seeded runs are dropped for the latest ones
+	stats := jobStats(job.ID)
+	if stats.Runs != statsRuns {
+		t.Fatalf("expected %d runs, got %d", statsRuns, stats.Runs)
+	}
+	d := stats.Duration
+	if d.Min != 3 || d.Max != 102 || d.Last != 102 || d.P95 != 97 || d.Mean != 52.5 || d.Recent != 97.5 {
+		t.Errorf("unexpected duration statistics %+v", d)
+	}
+	if stats.CPU == nil || stats.CPU.Mean != 1 || stats.MaxRSS == nil || stats.MaxRSS.Max != 102<<20 {
+		t.Errorf("unexpected resource statistics %+v %+v", stats.CPU, stats.MaxRSS)
+	}
+
+	// runs without local process do not tell their resource usage
+	other := &Runnable{ID: "stats-http"}
+	defer func() {
+		runSamplesLock.Lock()
+		delete(runSamples, other.ID)
+		runSamplesLock.Unlock()
+	}()
+	recordStats(&Notification{Job: other, Duration: time.Second})
+	if stats := jobStats(other.ID); stats.Duration == nil || stats.CPU != nil || stats.MaxRSS != nil {
+		t.Errorf("expected duration statistics only, got %+v", stats)
+	}
+}
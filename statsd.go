@@ -0,0 +1,81 @@
+package crontinuous
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Struct
+// --------------------------------------------------------------------------------------------
+
+// statsdSink sends metrics about job runs to StatsD over udp. With tags the DogStatsD
+// format is used, otherwise the job id is part of the metric names.
+type statsdSink struct {
+	conn   net.Conn
+	prefix string
+	tags   bool
+}
+
+// --------------------------------------------------------------------------------------------
+// ~ Variables
+// --------------------------------------------------------------------------------------------
+
+var (
+	metrics          *statsdSink
+	statsdNameRegexp = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Private methods
+// --------------------------------------------------------------------------------------------
+
+func newStatsdSink(addr string, prefix string, tags bool) (*statsdSink, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &statsdSink{conn: conn, prefix: prefix, tags: tags}, nil
+}
+
+// record sends the count, outcome, duration and resource usage of a finished execution
+func (s *statsdSink) record(n *Notification) {
+	if s == nil {
+		return
+	}
+	lines := []string{
+		s.metric("runs", n, "1|c"),
+		s.metric("duration", n, fmt.Sprintf("%d|ms", n.Duration.Nanoseconds()/1e6)),
+	}
+	switch n.Event {
+	case EventFailure, EventTimeout:
+		lines = append(lines, s.metric("failures", n, "1|c"))
+	}
+	if n.Waited > 0 {
+		lines = append(lines, s.metric("waited", n, fmt.Sprintf("%d|ms", n.Waited.Nanoseconds()/1e6)))
+	}
+	if n.measured() {
+		lines = append(lines,
+			s.metric("cpu", n, fmt.Sprintf("%d|ms", (n.UserCPU+n.SystemCPU).Nanoseconds()/1e6)),
+			s.metric("max_rss", n, fmt.Sprintf("%d|g", n.MaxRSS)),
+		)
+	}
+	// udp does not tell whether anyone is listening, losing metrics is fine
+	s.conn.Write([]byte(strings.Join(lines, "\n")))
+}
+
+// metric formats a metric line like "crontinuous.runs:1|c|#job:backup,status:success,tag:db"
+func (s *statsdSink) metric(name string, n *Notification, value string) string {
+	if !s.tags {
+		// dots separate the levels of metric names
+		job := statsdNameRegexp.ReplaceAllString(n.Job.ID, "_")
+		return s.prefix + "job." + job + "." + name + ":" + value
+	}
+	line := s.prefix + name + ":" + value + "|#job:" + n.Job.ID + ",status:" + string(n.Event)
+	for _, tag := range n.Job.Tags {
+		line += ",tag:" + tag
+	}
+	return line
+}
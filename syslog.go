@@ -0,0 +1,108 @@
+//go:build !windows
+// +build !windows
+
+package crontinuous
+
+import (
+	"fmt"
+	"log/syslog"
+	"net/url"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Struct
+// --------------------------------------------------------------------------------------------
+
+// syslogHook sends log entries to syslog, tagging job entries with the job's id
+type syslogHook struct {
+	network string
+	raddr   string
+	tag     string
+	writers map[string]*syslog.Writer
+	lock    sync.Mutex
+}
+
+// --------------------------------------------------------------------------------------------
+// ~ Public methods
+// --------------------------------------------------------------------------------------------
+
+// Levels implements log.Hook
+func (h *syslogHook) Levels() []log.Level {
+	return log.AllLevels
+}
+
+// Fire implements log.Hook
+func (h *syslogHook) Fire(entry *log.Entry) error {
+	tag := h.tag
+	if id, ok := entry.Data["id"]; ok {
+		tag = fmt.Sprintf("%s-%v", h.tag, id)
+	}
+	w, err := h.writer(tag)
+	if err != nil {
+		return err
+	}
+	line, err := entry.String()
+	if err != nil {
+		return err
+	}
+
+	switch entry.Level {
+	case log.PanicLevel:
+		return w.Emerg(line)
+	case log.FatalLevel:
+		return w.Crit(line)
+	case log.ErrorLevel:
+		return w.Err(line)
+	case log.WarnLevel:
+		return w.Warning(line)
+	case log.InfoLevel:
+		return w.Info(line)
+	default:
+		return w.Debug(line)
+	}
+}
+
+// --------------------------------------------------------------------------------------------
+// ~ Private methods
+// --------------------------------------------------------------------------------------------
+
+// newSyslogHook creates a hook for a target like "local", "udp://host:514" or "tcp://host:514"
+func newSyslogHook(target string, tag string) (*syslogHook, error) {
+	h := &syslogHook{
+		tag:     tag,
+		writers: map[string]*syslog.Writer{},
+	}
+	if target != "local" {
+		u, err := url.Parse(target)
+		if err != nil {
+			return nil, err
+		}
+		if u.Scheme != "udp" && u.Scheme != "tcp" {
+			return nil, fmt.Errorf("unsupported syslog target %q, expected local, udp://host:port or tcp://host:port", target)
+		}
+		h.network = u.Scheme
+		h.raddr = u.Host
+	}
+	// fail early if syslog is not reachable
+	if _, err := h.writer(tag); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+func (h *syslogHook) writer(tag string) (*syslog.Writer, error) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if w, ok := h.writers[tag]; ok {
+		return w, nil
+	}
+	w, err := syslog.Dial(h.network, h.raddr, syslog.LOG_INFO|syslog.LOG_CRON, tag)
+	if err != nil {
+		return nil, err
+	}
+	h.writers[tag] = w
+	return w, nil
+}
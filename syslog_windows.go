@@ -0,0 +1,15 @@
+//go:build windows
+// +build windows
+
+package crontinuous
+
+import (
+	"errors"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// newSyslogHook fails, there is no syslog on windows
+func newSyslogHook(target string, tag string) (log.Hook, error) {
+	return nil, errors.New("syslog is not supported on windows, use -log-dir or a log shipper instead")
+}
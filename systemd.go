@@ -0,0 +1,74 @@
+package crontinuous
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Private methods
+// --------------------------------------------------------------------------------------------
+
+// sdNotify sends a state like READY=1 to systemd, nothing is sent if not run by systemd with Type=notify
+func sdNotify(states ...string) {
+	socketPath := os.Getenv("NOTIFY_SOCKET")
+	if socketPath == "" {
+		return
+	}
+	// abstract sockets are prefixed with @
+	if strings.HasPrefix(socketPath, "@") {
+		socketPath = "\x00" + socketPath[1:]
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socketPath, Net: "unixgram"})
+	if err != nil {
+		log.Warn("failed to notify systemd", err)
+		return
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(strings.Join(states, "\n"))); err != nil {
+		log.Warn("failed to notify systemd", err)
+	}
+}
+
+// sdWatchdog sends keep-alives to the systemd watchdog as long as the scheduler is responsive,
+// so systemd restarts crontinuous if it wedges
+func sdWatchdog() {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return
+	}
+	interval := time.Duration(usec) * time.Microsecond / 2
+	for range time.Tick(interval) {
+		if schedulerAlive(interval) {
+			sdNotify("WATCHDOG=1")
+		} else {
+			log.Error("scheduler is not responding, skipping watchdog keep-alive")
+		}
+	}
+}
+
+// schedulerAlive reports whether the scheduler answers within the timeout, a running
+// scheduler returns its entries from its own goroutine
+func schedulerAlive(timeout time.Duration) bool {
+	alive := make(chan struct{})
+	go func() {
+		if scheduler := currentScheduler(); scheduler != nil {
+			scheduler.Entries()
+		}
+		close(alive)
+	}()
+	select {
+	case <-alive:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
@@ -0,0 +1,127 @@
+package crontinuous
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Variables
+// --------------------------------------------------------------------------------------------
+
+var (
+	// tagPools limit how many jobs with a tag run at the same time
+	tagPools = map[string]*workerPool{}
+	// onlyTagsFilter and skipTagsFilter select the jobs loaded on this host
+	onlyTagsFilter []string
+	skipTagsFilter []string
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Private methods
+// --------------------------------------------------------------------------------------------
+
+// parseTags parses a comma separated list of tags
+func parseTags(value string) ([]string, error) {
+	tags := []string{}
+	for _, tag := range strings.Split(value, ",") {
+		if tag = strings.TrimSpace(tag); tag == "" || containsString(tags, tag) {
+			continue
+		}
+		if !jobNameRegexp.MatchString(tag) {
+			return nil, fmt.Errorf("invalid tag %q, use letters, digits, dots, dashes and underscores", tag)
+		}
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	return tags, nil
+}
+
+// parseTagConcurrency parses comma separated tag=limit pairs into worker pools
+func parseTagConcurrency(value string, overflow OverflowPolicy) (map[string]*workerPool, error) {
+	limits, err := parseLabels(value)
+	if err != nil {
+		return nil, err
+	}
+	pools := map[string]*workerPool{}
+	for tag, limit := range limits {
+		size, err := strconv.Atoi(limit)
+		if err != nil || size <= 0 {
+			return nil, fmt.Errorf("invalid limit %q of tag %s, expected a positive number", limit, tag)
+		}
+		pool := newWorkerPool(size, overflow)
+		pool.tag = tag
+		pools[tag] = pool
+	}
+	return pools, nil
+}
+
+// hasTag reports whether the job is tagged with tag
+func (r *Runnable) hasTag(tag string) bool {
+	return containsString(r.Tags, tag)
+}
+
+// selectedByTags reports whether the job is loaded on this host: it needs one of -only-tags if set and none of -skip-tags
+func (r *Runnable) selectedByTags() bool {
+	for _, tag := range skipTagsFilter {
+		if r.hasTag(tag) {
+			return false
+		}
+	}
+	if len(onlyTagsFilter) == 0 {
+		return true
+	}
+	for _, tag := range onlyTagsFilter {
+		if r.hasTag(tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// selectByTags returns the jobs selected by -only-tags and -skip-tags
+func selectByTags(jobs []*Runnable) []*Runnable {
+	selected := []*Runnable{}
+	for _, r := range jobs {
+		if r.selectedByTags() {
+			selected = append(selected, r)
+		}
+	}
+	return selected
+}
+
+// acquireTags takes a slot of the pool of every tag of the job and reports whether the run may start,
+// the returned func releases them. Tags are sorted, so jobs sharing tags take their slots in the same order.
+func (r *Runnable) acquireTags(e *execution) (func(), bool) {
+	acquired := []*workerPool{}
+	release := func() {
+		for _, pool := range acquired {
+			pool.release()
+		}
+	}
+	for _, tag := range r.Tags {
+		pool, ok := tagPools[tag]
+		if !ok {
+			continue
+		}
+		if !pool.acquire(e) {
+			release()
+			return nil, false
+		}
+		acquired = append(acquired, pool)
+	}
+	return release, true
+}
+
+// taggedJobs returns the loaded jobs with a tag
+func taggedJobs(tag string) []*Runnable {
+	jobs := []*Runnable{}
+	for _, r := range loadedJobsCopy() {
+		if r.hasTag(tag) {
+			jobs = append(jobs, r)
+		}
+	}
+	return jobs
+}
@@ -0,0 +1,116 @@
+package crontinuous
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Struct
+// --------------------------------------------------------------------------------------------
+
+// commandData is what the templates of commands and arguments are executed with
+type commandData struct {
+	// Now is when the run started, in the time zone of the job
+	Now time.Time
+	// Date is the day of Now like 2006-01-02
+	Date     string
+	RunID    string
+	JobID    string
+	Attempt  int
+	Hostname string
+}
+
+// --------------------------------------------------------------------------------------------
+// ~ Private methods
+// --------------------------------------------------------------------------------------------
+
+// parseCommandTemplate parses a command or argument, env looks up variables of the job before the ones of crontinuous
+// jobs inherit, the credentials of crontinuous are not handed to templates either
+func (r *Runnable) parseCommandTemplate(text string) (*template.Template, error) {
+	return template.New(r.ID).Option("missingkey=error").Funcs(template.FuncMap{
+		"env": func(name string) (string, error) {
+			for i := len(r.Env) - 1; i >= 0; i-- {
+				if strings.HasPrefix(r.Env[i], name+"=") {
+					return strings.TrimPrefix(r.Env[i], name+"="), nil
+				}
+			}
+			if isDaemonCredential(name) {
+				return "", fmt.Errorf("%s is a credential of crontinuous, not of the job", name)
+			}
+			return os.Getenv(name), nil
+		},
+	}).Parse(text)
+}
+
+// checkTemplate parses the command and arguments of a templated job, so mistakes show when the crontab is loaded
+func (r *Runnable) checkTemplate() error {
+	if !r.Template {
+		return nil
+	}
+	for _, text := range append([]string{r.Command, r.Args}, r.argv...) {
+		if _, err := r.parseCommandTemplate(text); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// expandCommand returns the command, arguments and argument list of a run, with their templates executed if enabled
+func (r *Runnable) expandCommand(n *Notification) (string, string, []string, error) {
+	if !r.Template {
+		return r.Command, r.Args, r.argv, nil
+	}
+	expand := func(text string) (string, error) {
+		return r.expandTemplate(text, n)
+	}
+
+	command, err := expand(r.Command)
+	if err != nil {
+		return "", "", nil, err
+	}
+	args, err := expand(r.Args)
+	if err != nil {
+		return "", "", nil, err
+	}
+	var argv []string
+	for _, arg := range r.argv {
+		expanded, err := expand(arg)
+		if err != nil {
+			return "", "", nil, err
+		}
+		argv = append(argv, expanded)
+	}
+	return command, args, argv, nil
+}
+
+// expandTemplate executes a template of the job for a run
+func (r *Runnable) expandTemplate(text string, n *Notification) (string, error) {
+	location := r.Location
+	if location == nil {
+		location = schedulerLocation
+	}
+	now := n.Start.In(location)
+	hostname, _ := os.Hostname()
+	data := commandData{
+		Now:      now,
+		Date:     now.Format("2006-01-02"),
+		RunID:    n.RunID,
+		JobID:    r.ID,
+		Attempt:  n.Attempt,
+		Hostname: hostname,
+	}
+	t, err := r.parseCommandTemplate(text)
+	if err != nil {
+		return "", err
+	}
+	buf := &bytes.Buffer{}
+	if err := t.Execute(buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,41 @@
+package crontinuous
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTemplateEnv(t *testing.T) {
+	for name, value := range map[string]string{"CRONTINUOUS_TEMPLATE_TEST": "shared", "VAULT_TOKEN": "s.daemon", "CRONTINUOUS_API_TOKEN": "api"} {
+		previous, ok := os.LookupEnv(name)
+		defer func(name string) {
+			if ok {
+				os.Setenv(name, previous)
+			} else {
+				os.Unsetenv(name)
+			}
+		}(name)
+		os.Setenv(name, value)
+	}
+	job := &Runnable{ID: "template", Template: true, Env: []string{"TARGET=prod"}}
+	n := &Notification{Start: time.Now(), RunID: "run"}
+
+	expanded, err := job.expandTemplate(`{{env "TARGET"}} {{env "CRONTINUOUS_TEMPLATE_TEST"}}`, n)
+	if err != nil || expanded != "prod shared" {
+		t.Errorf("expected the variables of the job and crontinuous, got %q, %v", expanded, err)
+	}
+	for _, name := range []string{"VAULT_TOKEN", "CRONTINUOUS_API_TOKEN", "AWS_SECRET_ACCESS_KEY"} {
+		expanded, err := job.expandTemplate(`{{env "`+name+`"}}`, n)
+		if err == nil || !strings.Contains(err.Error(), "credential of crontinuous") {
+			t.Errorf("expected %s to be rejected, got %q, %v", name, expanded, err)
+		}
+	}
+
+	// a job setting a credential variable itself may use it
+	job.Env = append(job.Env, "VAULT_TOKEN=s.job")
+	if expanded, err := job.expandTemplate(`{{env "VAULT_TOKEN"}}`, n); err != nil || expanded != "s.job" {
+		t.Errorf("expected the variable of the job, got %q, %v", expanded, err)
+	}
+}
@@ -0,0 +1,178 @@
+package crontinuous
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Constants
+// --------------------------------------------------------------------------------------------
+
+// otlp span kind and status codes
+const (
+	otlpSpanKindInternal = 1
+	otlpStatusUnset      = 0
+	otlpStatusOK         = 1
+	otlpStatusError      = 2
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Struct
+// --------------------------------------------------------------------------------------------
+
+// otlpTracer exports a span per execution of a job to an OTLP/HTTP endpoint, the run id
+// is the trace id so all attempts of a run end up in the same trace
+type otlpTracer struct {
+	url      string
+	resource []otlpAttribute
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	IntValue    *string `json:"intValue,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes"`
+	Status            otlpStatus      `json:"status"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+// --------------------------------------------------------------------------------------------
+// ~ Variables
+// --------------------------------------------------------------------------------------------
+
+var tracer *otlpTracer
+
+// --------------------------------------------------------------------------------------------
+// ~ Private methods
+// --------------------------------------------------------------------------------------------
+
+// newOTLPTracer exports to the traces path of an OTLP/HTTP endpoint like http://collector:4318
+func newOTLPTracer(endpoint string, serviceName string, hostname string) *otlpTracer {
+	return &otlpTracer{
+		url: strings.TrimRight(endpoint, "/") + "/v1/traces",
+		resource: []otlpAttribute{
+			stringAttribute("service.name", serviceName),
+			stringAttribute("host.name", hostname),
+		},
+	}
+}
+
+func stringAttribute(key string, value string) otlpAttribute {
+	return otlpAttribute{Key: key, Value: otlpValue{StringValue: &value}}
+}
+
+// intAttribute encodes the int64 as string like the OTLP JSON encoding expects
+func intAttribute(key string, value int) otlpAttribute {
+	s := strconv.Itoa(value)
+	return otlpAttribute{Key: key, Value: otlpValue{IntValue: &s}}
+}
+
+// traceID returns the trace id of a run, its uuid without dashes
+func traceID(runID string) string {
+	return strings.Replace(runID, "-", "", -1)
+}
+
+// newSpanID returns a random span id
+func newSpanID() string {
+	id := make([]byte, 8)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// traceparent returns the W3C trace context of an execution for its environment, empty if not traced
+func (t *otlpTracer) traceparent(n *Notification) string {
+	if t == nil || len(traceID(n.RunID)) != 32 {
+		return ""
+	}
+	return "TRACEPARENT=00-" + traceID(n.RunID) + "-" + n.SpanID + "-01"
+}
+
+// export sends the span of a finished execution in the background
+func (t *otlpTracer) export(n *Notification) {
+	if t == nil || len(traceID(n.RunID)) != 32 {
+		return
+	}
+	span := otlpSpan{
+		TraceID:           traceID(n.RunID),
+		SpanID:            n.SpanID,
+		Name:              "job " + n.Job.ID,
+		Kind:              otlpSpanKindInternal,
+		StartTimeUnixNano: strconv.FormatInt(n.Start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(n.Start.Add(n.Duration).UnixNano(), 10),
+		Attributes: []otlpAttribute{
+			stringAttribute("job.id", n.Job.ID),
+			stringAttribute("job.command", strings.TrimSpace(n.Job.Command+" "+n.Job.Args)),
+			stringAttribute("job.schedule", n.Job.Schedule),
+			stringAttribute("job.status", string(n.Event)),
+			intAttribute("job.attempt", n.Attempt),
+			intAttribute("process.exit.code", n.ExitCode),
+		},
+	}
+	if n.Signal != "" {
+		span.Attributes = append(span.Attributes, stringAttribute("process.signal", n.Signal))
+	}
+	switch n.Event {
+	case EventSuccess:
+		span.Status.Code = otlpStatusOK
+	case EventWarning:
+		span.Status.Code = otlpStatusUnset
+	default:
+		span.Status.Code = otlpStatusError
+		if n.Err != nil {
+			span.Status.Message = n.Err.Error()
+		}
+	}
+
+	payload, err := json.Marshal(map[string]interface{}{
+		"resourceSpans": []interface{}{
+			map[string]interface{}{
+				"resource": map[string]interface{}{"attributes": t.resource},
+				"scopeSpans": []interface{}{
+					map[string]interface{}{
+						"scope": map[string]string{"name": "crontinuous", "version": version},
+						"spans": []otlpSpan{span},
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		n.Job.contextLogger.Error("failed to encode span", err)
+		return
+	}
+	go func() {
+		if err := postJSON(t.url, payload); err != nil {
+			n.Job.contextLogger.WithField("runId", n.RunID).Warn("failed to export span", err)
+		}
+	}()
+}
+
+// runEnv returns the environment added for an execution of the job
+func (r *Runnable) runEnv(e *execution, n *Notification) []string {
+	env := append(append([]string{}, r.Env...), e.env...)
+	if traceparent := tracer.traceparent(n); traceparent != "" {
+		env = append(env, traceparent)
+	}
+	return env
+}
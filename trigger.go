@@ -0,0 +1,97 @@
+package crontinuous
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"regexp"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Constants
+// --------------------------------------------------------------------------------------------
+
+// triggerSchedule is the schedule of jobs that only run when triggered
+const triggerSchedule = "@trigger"
+
+// --------------------------------------------------------------------------------------------
+// ~ Variables
+// --------------------------------------------------------------------------------------------
+
+// triggerVariableRegexp matches a trigger token given as ${NAME} of a variable or secret of the job
+var triggerVariableRegexp = regexp.MustCompile(`^\$\{([A-Za-z_][A-Za-z0-9_]*)\}$`)
+
+// --------------------------------------------------------------------------------------------
+// ~ Private methods
+// --------------------------------------------------------------------------------------------
+
+// isTriggerPath reports whether a request triggers a job, which is authenticated by the token of the job
+func isTriggerPath(path string) bool {
+	return strings.HasPrefix(path, "/jobs/") && strings.HasSuffix(path, "/trigger")
+}
+
+// handleJobTrigger runs a job annotated with trigger on a POST with its token as bearer token,
+// e.g. from a CI pipeline. Jobs without trigger annotation are not found.
+func handleJobTrigger(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != "POST" {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	runnable := findJob(id)
+	if runnable == nil || runnable.Trigger == "" {
+		http.NotFound(w, r)
+		return
+	}
+	logger := runnable.contextLogger.WithField("remote", r.RemoteAddr)
+	token, err := runnable.triggerToken()
+	if err != nil {
+		logger.Error("failed to look up the trigger token: ", err)
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+token)) != 1 {
+		logger.Warn("rejected trigger with a wrong token")
+		w.Header().Set("WWW-Authenticate", `Bearer realm="crontinuous"`)
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	if runnable.Disabled || isPaused(runnable.ID) {
+		http.Error(w, "job is disabled or paused", http.StatusConflict)
+		return
+	}
+	if isDraining() {
+		http.Error(w, "draining, launching no new runs", http.StatusServiceUnavailable)
+		return
+	}
+	logger.WithFields(log.Fields{"userAgent": r.UserAgent()}).Info("running job on trigger")
+	go runnable.run()
+	jsonStatusReply(w, http.StatusAccepted, map[string]string{"id": runnable.ID, "status": "triggered"})
+}
+
+// triggerToken returns the token triggers of the job need, looking up ${NAME} in the variables
+// and secrets of the job on every trigger
+func (r *Runnable) triggerToken() (string, error) {
+	matches := triggerVariableRegexp.FindStringSubmatch(r.Trigger)
+	if matches == nil {
+		return r.Trigger, nil
+	}
+	env := r.Env
+	if len(r.Secrets) > 0 {
+		secrets, err := r.resolveSecrets()
+		if err != nil {
+			return "", err
+		}
+		env = append(append([]string{}, env...), secrets.env...)
+	}
+	for i := len(env) - 1; i >= 0; i-- {
+		if strings.HasPrefix(env[i], matches[1]+"=") {
+			if token := strings.TrimPrefix(env[i], matches[1]+"="); token != "" {
+				return token, nil
+			}
+		}
+	}
+	return "", errors.New("no variable or secret " + matches[1])
+}
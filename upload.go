@@ -0,0 +1,173 @@
+package crontinuous
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Constants
+// --------------------------------------------------------------------------------------------
+
+const defaultUploadKey = `{{.JobID}}/{{.Start.Format "2006/01/02"}}/{{.RunID}}`
+
+// --------------------------------------------------------------------------------------------
+// ~ Struct
+// --------------------------------------------------------------------------------------------
+
+// objectStore stores objects in a bucket
+type objectStore interface {
+	put(key string, contentType string, body []byte) error
+}
+
+// uploadKeyData is what the key template of uploads is executed with
+type uploadKeyData struct {
+	JobID    string
+	RunID    string
+	Attempt  int
+	Status   string
+	Start    time.Time
+	Hostname string
+}
+
+// uploader uploads the output and a metadata document of every finished run to a bucket
+type uploader struct {
+	store  objectStore
+	prefix string
+	key    *template.Template
+	labels map[string]string
+}
+
+// s3Store puts objects into an S3 bucket
+type s3Store struct {
+	aws    *awsClient
+	bucket string
+}
+
+// --------------------------------------------------------------------------------------------
+// ~ Public methods
+// --------------------------------------------------------------------------------------------
+
+// Notify implements Notifier
+func (u *uploader) Notify(n *Notification) error {
+	if n.Event == EventStart || n.Event == EventSlow || n.Event == EventMissed || n.Event == EventSuspended {
+		return nil
+	}
+	key := &bytes.Buffer{}
+	if err := u.key.Execute(key, uploadKeyData{
+		JobID:    n.Job.ID,
+		RunID:    n.RunID,
+		Attempt:  n.Attempt,
+		Status:   string(n.Event),
+		Start:    n.Start.UTC(),
+		Hostname: n.Hostname,
+	}); err != nil {
+		return err
+	}
+	base := strings.TrimLeft(u.prefix+"/"+key.String(), "/")
+
+	// the run log is complete, the output of the notification may be truncated
+	output := []byte(n.Output)
+	if filename := runLogFilename(n.Job.ID, n.RunID); filename != "" {
+		if data, err := ioutil.ReadFile(filename); err == nil {
+			output = data
+		}
+	}
+	if err := u.store.put(base+".log", "text/plain; charset=utf-8", output); err != nil {
+		return fmt.Errorf("failed to upload output: %s", err)
+	}
+
+	record := runRecord(n, u.labels)
+	delete(record, "output")
+	record["outputBytes"] = n.OutputBytes
+	record["outputKey"] = base + ".log"
+	metadata, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := u.store.put(base+".json", "application/json", metadata); err != nil {
+		return fmt.Errorf("failed to upload metadata: %s", err)
+	}
+	return nil
+}
+
+// --------------------------------------------------------------------------------------------
+// ~ Private methods
+// --------------------------------------------------------------------------------------------
+
+// newUploader creates an uploader for a bucket like s3://bucket/prefix or gs://bucket/prefix
+func newUploader(target string, keyTemplate string, labels map[string]string) (*uploader, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, err
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid upload target %q, expected s3://bucket/prefix or gs://bucket/prefix", target)
+	}
+	key, err := template.New("key").Option("missingkey=error").Parse(keyTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid upload key: %s", err)
+	}
+	up := &uploader{prefix: strings.Trim(u.Path, "/"), key: key, labels: labels}
+	switch u.Scheme {
+	case "s3":
+		up.store = &s3Store{aws: newAWSClient(*awsRegion), bucket: u.Host}
+	case "gs":
+		up.store = &gcsStore{bucket: u.Host, client: &http.Client{Timeout: time.Minute}}
+	default:
+		return nil, fmt.Errorf("unsupported upload target %q, expected s3://bucket/prefix or gs://bucket/prefix", target)
+	}
+	return up, nil
+}
+
+func (s *s3Store) put(key string, contentType string, body []byte) error {
+	region, err := s.aws.defaultRegion()
+	if err != nil {
+		return err
+	}
+	// virtual hosted buckets, path style on custom endpoints like minio
+	endpoint := "https://" + s.bucket + ".s3." + region + ".amazonaws.com"
+	path := "/" + key
+	if custom := awsEndpoint("s3", region); !strings.HasSuffix(custom, ".amazonaws.com") {
+		endpoint = custom
+		path = "/" + s.bucket + path
+	}
+	req, err := http.NewRequest("PUT", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.URL.Path = path
+	req.URL.RawPath = awsEscapePath(path)
+	req.Header.Set("Content-Type", contentType)
+	resp, err := s.aws.do(req, body, "s3", region)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		message, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("unexpected status %s from s3: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
+
+// awsEscapePath escapes a path like signature version 4 expects it, everything but unreserved characters and slashes
+func awsEscapePath(path string) string {
+	escaped := &strings.Builder{}
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		if c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || strings.IndexByte("-._~/", c) >= 0 {
+			escaped.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(escaped, "%%%02X", c)
+	}
+	return escaped.String()
+}
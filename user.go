@@ -0,0 +1,104 @@
+package crontinuous
+
+import (
+	"errors"
+	"os/user"
+	"runtime"
+	"strconv"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Struct
+// --------------------------------------------------------------------------------------------
+
+// runAs describes the user a job is executed as
+type runAs struct {
+	user   *user.User
+	uid    uint32
+	gid    uint32
+	groups []uint32
+}
+
+// --------------------------------------------------------------------------------------------
+// ~ Private methods
+// --------------------------------------------------------------------------------------------
+
+// lookupRunAs resolves a user and group name or id to the credential used to start a job's process,
+// an empty user name refers to the current user and an empty group to the user's primary group
+func lookupRunAs(username string, groupname string) (*runAs, error) {
+	if runtime.GOOS == "windows" {
+		return nil, errors.New("running jobs as another user is not supported on windows")
+	}
+	u, err := lookupUser(username)
+	if err != nil {
+		return nil, err
+	}
+
+	uid, err := strconv.ParseUint(u.Uid, 10, 32)
+	if err != nil {
+		return nil, err
+	}
+	gid, err := strconv.ParseUint(u.Gid, 10, 32)
+	if err != nil {
+		return nil, err
+	}
+
+	// keep the supplementary groups of the user unless a group is given explicitly
+	groups := []uint32{}
+	if groupname != "" {
+		g, err := lookupGroup(groupname)
+		if err != nil {
+			return nil, err
+		}
+		if gid, err = strconv.ParseUint(g.Gid, 10, 32); err != nil {
+			return nil, err
+		}
+	} else if groupIDs, err := u.GroupIds(); err == nil {
+		for _, groupID := range groupIDs {
+			if id, err := strconv.ParseUint(groupID, 10, 32); err == nil {
+				groups = append(groups, uint32(id))
+			}
+		}
+	}
+
+	return &runAs{
+		user:   u,
+		uid:    uint32(uid),
+		gid:    uint32(gid),
+		groups: groups,
+	}, nil
+}
+
+func lookupUser(username string) (*user.User, error) {
+	if username == "" {
+		return user.Current()
+	}
+	u, err := user.Lookup(username)
+	if err != nil {
+		if _, convErr := strconv.Atoi(username); convErr != nil {
+			return nil, err
+		}
+		return user.LookupId(username)
+	}
+	return u, nil
+}
+
+func lookupGroup(groupname string) (*user.Group, error) {
+	g, err := user.LookupGroup(groupname)
+	if err != nil {
+		if _, convErr := strconv.Atoi(groupname); convErr != nil {
+			return nil, err
+		}
+		return user.LookupGroupId(groupname)
+	}
+	return g, nil
+}
+
+// environ returns the environment for a process running as the user
+func (a *runAs) environ() []string {
+	return append(jobEnviron(),
+		"HOME="+a.user.HomeDir,
+		"USER="+a.user.Username,
+		"LOGNAME="+a.user.Username,
+	)
+}
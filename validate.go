@@ -0,0 +1,60 @@
+package crontinuous
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Private methods
+// --------------------------------------------------------------------------------------------
+
+// validateCommand implements `crontinuous validate [crontab...]`, parsing the crontab
+// without scheduling anything and exiting non-zero if there are problems
+func validateCommand(args []string) int {
+	paths := crontabs
+	if len(args) > 0 {
+		paths = &crontabPaths{}
+		for _, arg := range args {
+			paths.Set(arg)
+		}
+	}
+	mirrored, _, err := mirrorCrontabs(paths.paths)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	sources := newCrontabSources(&crontabPaths{paths: mirrored, prefixes: paths.prefixes})
+	if err := loadSources(sources, parseCrontab); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	jobs, errs, duplicates := mergeSources(sources)
+
+	now := time.Now().In(schedulerLocation)
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "FILE\tSCHEDULE\tNEXT\tID\tCOMMAND")
+	for _, r := range jobs {
+		next := "on start"
+		if r.Disabled {
+			next = "disabled"
+		} else if r.schedule != nil {
+			next = r.schedule.Next(now).Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s %s\n", r.File, r.Schedule, next, r.ID, r.Command, r.Args)
+	}
+	w.Flush()
+	problems := 0
+	for _, err := range append(append(errs, duplicates...), dependencyErrors(jobs)...) {
+		fmt.Fprintln(os.Stderr, err)
+		problems++
+	}
+
+	if problems > 0 {
+		fmt.Fprintf(os.Stderr, "%d problem(s) found\n", problems)
+		return 1
+	}
+	return 0
+}
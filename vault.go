@@ -0,0 +1,105 @@
+package crontinuous
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Struct
+// --------------------------------------------------------------------------------------------
+
+// vaultClient reads secrets from the key value engines of HashiCorp Vault
+type vaultClient struct {
+	addr      string
+	namespace string
+	tokenFile string
+	client    *http.Client
+}
+
+// --------------------------------------------------------------------------------------------
+// ~ Private methods
+// --------------------------------------------------------------------------------------------
+
+func newVaultClient(addr string, namespace string, tokenFile string) *vaultClient {
+	return &vaultClient{
+		addr:      strings.TrimRight(addr, "/"),
+		namespace: namespace,
+		tokenFile: tokenFile,
+		client:    &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// token is read for every request, so tokens renewed by a vault agent are picked up
+func (v *vaultClient) token() (string, error) {
+	if v.tokenFile == "" {
+		if token := os.Getenv("VAULT_TOKEN"); token != "" {
+			return token, nil
+		}
+		return "", fmt.Errorf("no vault token, set VAULT_TOKEN or -vault-token-file")
+	}
+	data, err := ioutil.ReadFile(v.tokenFile)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// resolve reads the field of a secret referenced like secret/data/app#password,
+// from version 1 and version 2 key value engines
+func (v *vaultClient) resolve(ref string) (string, error) {
+	parts := strings.SplitN(ref, "#", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", fmt.Errorf("invalid vault reference %q, expected path#field", ref)
+	}
+	path, field := strings.Trim(parts[0], "/"), parts[1]
+
+	token, err := v.token()
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequest("GET", v.addr+"/v1/"+path, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", token)
+	if v.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", v.namespace)
+	}
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status %s reading %s from vault", resp.Status, path)
+	}
+
+	secret := struct {
+		Data map[string]interface{} `json:"data"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&secret); err != nil {
+		return "", err
+	}
+	data := secret.Data
+	// version 2 engines wrap the data next to its metadata
+	if nested, ok := data["data"].(map[string]interface{}); ok {
+		if _, ok := data["metadata"]; ok {
+			data = nested
+		}
+	}
+	value, ok := data[field]
+	if !ok {
+		return "", fmt.Errorf("vault secret %s has no field %s", path, field)
+	}
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+	encoded, err := json.Marshal(value)
+	return string(encoded), err
+}
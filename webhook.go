@@ -0,0 +1,101 @@
+package crontinuous
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// --------------------------------------------------------------------------------------------
+// ~ Struct
+// --------------------------------------------------------------------------------------------
+
+// webhookNotifier posts run lifecycle events as JSON to an url
+type webhookNotifier struct {
+	url    string
+	events map[Event]bool
+}
+
+// webhookPayload is the JSON document posted by webhookNotifier
+type webhookPayload struct {
+	Event    Event      `json:"event"`
+	RunID    string     `json:"runId"`
+	Attempt  int        `json:"attempt"`
+	Job      webhookJob `json:"job"`
+	Hostname string     `json:"hostname"`
+	Start    time.Time  `json:"start"`
+	Duration float64    `json:"duration"`
+	ExitCode int        `json:"exitCode"`
+	Signal   string     `json:"signal,omitempty"`
+	OOMKill  bool       `json:"oomKilled,omitempty"`
+	Error    string     `json:"error,omitempty"`
+	Output   string     `json:"output,omitempty"`
+	Revision string     `json:"revision,omitempty"`
+	Waited   float64    `json:"waited,omitempty"`
+}
+
+type webhookJob struct {
+	ID       string `json:"id"`
+	Command  string `json:"command"`
+	Args     string `json:"args"`
+	Schedule string `json:"schedule"`
+}
+
+// --------------------------------------------------------------------------------------------
+// ~ Public methods
+// --------------------------------------------------------------------------------------------
+
+// Notify implements Notifier
+func (w *webhookNotifier) Notify(n *Notification) error {
+	if !w.events[n.Event] {
+		return nil
+	}
+	payload := webhookPayload{
+		Event:   n.Event,
+		RunID:   n.RunID,
+		Attempt: n.Attempt,
+		Job: webhookJob{
+			ID:       n.Job.ID,
+			Command:  n.Job.Command,
+			Args:     n.Job.Args,
+			Schedule: n.Job.Schedule,
+		},
+		Hostname: n.Hostname,
+		Start:    n.Start,
+		Duration: n.Duration.Seconds(),
+		ExitCode: n.ExitCode,
+		Signal:   n.Signal,
+		Output:   n.Output,
+		Revision: n.Revision,
+		Waited:   n.Waited.Seconds(),
+	}
+	payload.OOMKill = n.OOMKilled
+	if n.Err != nil {
+		payload.Error = n.Err.Error()
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return postJSON(w.url, body)
+}
+
+// --------------------------------------------------------------------------------------------
+// ~ Private methods
+// --------------------------------------------------------------------------------------------
+
+// parseWebhookEvents parses a comma separated list of events, all events if empty
+func parseWebhookEvents(value string) map[Event]bool {
+	events := map[Event]bool{}
+	for _, event := range strings.Split(value, ",") {
+		if event = strings.TrimSpace(event); event != "" {
+			events[Event(event)] = true
+		}
+	}
+	if len(events) == 0 {
+		for _, event := range []Event{EventStart, EventSuccess, EventWarning, EventFailure, EventTimeout, EventSlow, EventMissed, EventSuspended} {
+			events[event] = true
+		}
+	}
+	return events
+}